@@ -0,0 +1,46 @@
+// Command riidme-rotate-keys re-encrypts every stored destination URL with the current
+// DESTINATION_ENCRYPTION_KEY, after the key was rotated or encryption was turned on or
+// off. It reads the same configuration (.env and environment) as the server and can
+// run while the server is up, which keeps decrypting with
+// DESTINATION_ENCRYPTION_PREVIOUS_KEY until this has finished.
+//
+// Usage:
+//
+//	DESTINATION_ENCRYPTION_KEY=<new> DESTINATION_ENCRYPTION_PREVIOUS_KEY=<old> riidme-rotate-keys
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"riid.me/pkg/config"
+	"riid.me/pkg/storage"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "riidme-rotate-keys:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	config.LoadEnv()
+	cfg := config.GlobalAppConfig
+	if err := storage.InitDestinationEncryption(cfg); err != nil {
+		return err
+	}
+	if err := storage.InitRedis(cfg); err != nil {
+		return fmt.Errorf("connect to Redis: %w", err)
+	}
+	if err := storage.InitSQLite(cfg); err != nil {
+		return fmt.Errorf("open %s: %w", cfg.SQLiteDBPath, err)
+	}
+	defer storage.StatsDB.Close()
+
+	result, err := storage.RotateDestinationEncryption(context.Background())
+	fmt.Printf("Rewrote %d destinations in Redis, %d links, %d rollouts, %d rule sets, %d clicks and %d outbox events\n",
+		result.Destinations, result.Links, result.Rollouts, result.Rules, result.Clicks, result.Events)
+	return err
+}
@@ -0,0 +1,106 @@
+// Command riidme-sync sends a declarative links file to a riid.me instance and prints
+// the resulting plan, applying it with -apply. It exits non-zero when the plan has
+// conflicts or the request fails, so it can gate CI pipelines.
+//
+// Usage:
+//
+//	RIIDME_ADMIN_CODE=... riidme-sync -url https://riid.me -file links.yaml [-apply]
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:3000", "base URL of the riid.me instance")
+	file := flag.String("file", "links.yaml", "declarative links file (.yaml, .yml or .json)")
+	apply := flag.Bool("apply", false, "apply the plan instead of only showing it")
+	flag.Parse()
+
+	if err := run(*baseURL, *file, *apply, os.Getenv("RIIDME_ADMIN_CODE")); err != nil {
+		fmt.Fprintln(os.Stderr, "riidme-sync:", err)
+		os.Exit(1)
+	}
+}
+
+func run(baseURL, file string, apply bool, adminCode string) error {
+	if adminCode == "" {
+		return fmt.Errorf("RIIDME_ADMIN_CODE is not set")
+	}
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimSuffix(baseURL, "/") + "/api/admin/sync"
+	if apply {
+		endpoint += "?apply=true"
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+adminCode)
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		req.Header.Set("Content-Type", "application/yaml")
+	default:
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := (&http.Client{Timeout: time.Minute}).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("server responded %s: %s", resp.Status, apiErr.Error)
+	}
+
+	var plan models.SyncPlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		return fmt.Errorf("decode plan: %w", err)
+	}
+	printPlan(plan)
+	if len(plan.Conflicts) > 0 {
+		return fmt.Errorf("%d conflicts, nothing applied", len(plan.Conflicts))
+	}
+	return nil
+}
+
+func printPlan(plan models.SyncPlan) {
+	for _, c := range plan.Changes {
+		switch c.Action {
+		case models.SyncActionCreate:
+			fmt.Printf("+ %s -> %s\n", c.Code, c.After.LongURL)
+		case models.SyncActionUpdate:
+			fmt.Printf("~ %s -> %s\n", c.Code, c.After.LongURL)
+		case models.SyncActionDelete:
+			fmt.Printf("- %s\n", c.Code)
+		}
+	}
+	for _, c := range plan.Conflicts {
+		fmt.Printf("! %s: %s\n", c.Code, c.Reason)
+	}
+
+	verb := "to apply"
+	if plan.Applied {
+		verb = "applied"
+	}
+	fmt.Printf("%d changes %s, %d unchanged, %d conflicts\n", len(plan.Changes), verb, plan.Unchanged, len(plan.Conflicts))
+}
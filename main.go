@@ -1,47 +1,90 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 
+	"riid.me/pkg/buildinfo"
+	"riid.me/pkg/cdnpurge"
 	customlogger "riid.me/pkg/logger"
 	"riid.me/pkg/config"
+	"riid.me/pkg/drops"
+	"riid.me/pkg/events"
 	"riid.me/pkg/handlers"
+	"riid.me/pkg/hostrep"
+	"riid.me/pkg/instances"
+	"riid.me/pkg/iprep"
+	"riid.me/pkg/listener"
+	"riid.me/pkg/models"
+	"riid.me/pkg/pagemeta"
+	"riid.me/pkg/policyscan"
+	"riid.me/pkg/safebrowsing"
+	"riid.me/pkg/snippets"
 	"riid.me/pkg/storage"
+	"riid.me/pkg/unwrap"
+	"riid.me/pkg/validation"
 )
 
-// healthCheck checks the status of the application and its dependencies (e.g., Redis).
+// dependencyStatus runs a single dependency check and reports its status and latency.
+func dependencyStatus(check func() error) (map[string]interface{}, bool) {
+	start := time.Now()
+	err := check()
+	result := map[string]interface{}{
+		"status":     "ok",
+		"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result["status"] = "error"
+		result["error"] = err.Error()
+		return result, false
+	}
+	return result, true
+}
+
+// healthCheck checks the status of the application and its dependencies (Redis and the SQLite stats DB),
+// reporting per-dependency status and latency.
 func healthCheck(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	status := map[string]interface{}{
 		"status": "ok",
 		"time":   time.Now().Format(time.RFC3339),
+		"build":  buildinfo.Get(),
 	}
+	healthy := true
 
 	// Check Redis connection using the global Rdb client from the storage package
-	if storage.Rdb == nil {
-		status["redis"] = map[string]string{
-			"status": "error",
-			"error":  "Redis client not initialized",
-		}
-		w.WriteHeader(http.StatusServiceUnavailable)
-	} else if err := storage.Rdb.Ping(ctx).Err(); err != nil {
-		status["redis"] = map[string]string{
-			"status": "error",
-			"error":  err.Error(),
-		}
-		w.WriteHeader(http.StatusServiceUnavailable)
-	} else {
-		status["redis"] = map[string]string{
-			"status": "ok",
+	redisStatus, ok := dependencyStatus(func() error {
+		if storage.Rdb == nil {
+			return errors.New("Redis client not initialized")
 		}
-	}
+		return storage.Rdb.Ping(ctx).Err()
+	})
+	status["redis"] = redisStatus
+	healthy = healthy && ok
+
+	// Check the stats database with a ping and a trivial write/read
+	sqliteStatus, ok := dependencyStatus(func() error {
+		return storage.CheckSQLite(ctx)
+	})
+	status["sqlite"] = sqliteStatus
+	healthy = healthy && ok
 
 	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		status["status"] = "degraded"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
 	json.NewEncoder(w).Encode(status)
 }
 
@@ -49,6 +92,14 @@ func main() {
 	// 1. Initialize Logger
 	customlogger.Init()
 
+	build := buildinfo.Get()
+	customlogger.Info().
+		Str("version", build.Version).
+		Str("commit", build.Commit).
+		Str("build_date", build.BuildDate).
+		Str("go_version", build.GoVersion).
+		Msg("Starting riid.me")
+
 	// 2. Load Configuration
 	config.LoadEnv() // This populates config.GlobalAppConfig
 
@@ -56,6 +107,9 @@ func main() {
 	if err := storage.InitRedis(config.GlobalAppConfig); err != nil {
 		customlogger.Fatal().Err(err).Msg("Failed to initialize Redis during startup")
 	}
+	if err := storage.InitDestinationEncryption(config.GlobalAppConfig); err != nil {
+		customlogger.Fatal().Err(err).Msg("Failed to initialize destination encryption during startup")
+	}
 	if err := storage.InitSQLite(config.GlobalAppConfig); err != nil {
 		customlogger.Fatal().Err(err).Msg("Failed to initialize SQLite during startup")
 	}
@@ -65,13 +119,111 @@ func main() {
 		customlogger.Fatal().Err(err).Msg("Failed to initialize ShortID service during startup")
 	}
 
+	// Sweep expired links into the event outbox and publish it, if configured
+	var publisher events.Publisher
+	if config.GlobalAppConfig.EventsWebhookURL != "" {
+		publisher = events.NewWebhookPublisher(config.GlobalAppConfig.EventsWebhookURL)
+	}
+	go events.Run(context.Background(), publisher, time.Duration(config.GlobalAppConfig.EventsInterval)*time.Second)
+
+	// Push every click on notify_each_click links to the click webhook, if configured
+	if config.GlobalAppConfig.ClickWebhookURL != "" {
+		handlers.ClickNotifier = events.NewClickNotifier(config.GlobalAppConfig.ClickWebhookURL, config.GlobalAppConfig.ClickWebhookSecret)
+		go handlers.ClickNotifier.Run(context.Background())
+	}
+
+	// Write redirect traffic in Combined Log Format for log analyzers, if configured
+	if path := config.GlobalAppConfig.AccessLog; path != "" {
+		writer, err := handlers.OpenAccessLog(path)
+		if err != nil {
+			customlogger.Fatal().Err(err).Msg("Failed to open access log during startup")
+		}
+		handlers.AccessLogWriter = writer
+	}
+
+	// Purge cached redirects at the CDN when links change, if configured
+	if purger := cdnpurge.New(config.GlobalAppConfig); purger != nil {
+		handlers.CachePurger = purger
+		go cdnpurge.Run(context.Background(), purger)
+	}
+
+	// Keep dropped files on disk and remove them once their link expires, if enabled
+	if config.GlobalAppConfig.DropsDir != "" {
+		store, err := drops.NewDiskStore(config.GlobalAppConfig.DropsDir)
+		if err != nil {
+			customlogger.Fatal().Err(err).Msg("Failed to initialize drops store during startup")
+		}
+		handlers.DropStore = store
+		go drops.Run(context.Background(), store)
+	}
+
+	// Remove snippets once their link expires
+	go snippets.Run(context.Background())
+
+	// Download the IP blocklists checked on redirects and keep them fresh, if configured
+	if len(config.GlobalAppConfig.IPBlocklistSources) > 0 {
+		handlers.IPBlocklist = iprep.NewBlocklist(config.GlobalAppConfig.IPBlocklistSources)
+		go handlers.IPBlocklist.Run(context.Background(), time.Duration(config.GlobalAppConfig.IPBlocklistRefreshMinutes)*time.Minute)
+	}
+
+	// Resolve the redirect chains of submitted URLs, if enabled. Our own domain counts
+	// as a shortener, so links to other riid.me links are caught too.
+	if config.GlobalAppConfig.UnwrapMaxHops > 0 {
+		shorteners := append(append([]string{}, unwrap.DefaultShorteners...), config.GlobalAppConfig.UnwrapShorteners...)
+		shorteners = append(shorteners, config.GlobalAppConfig.Domain)
+		handlers.URLUnwrapper = unwrap.NewResolver(config.GlobalAppConfig.UnwrapMaxHops, shorteners)
+	}
+
+	// Describe new links with the title, description and favicon of their destination, if enabled
+	if config.GlobalAppConfig.FetchPageMeta {
+		handlers.PageMetaFetcher = pagemeta.NewFetcher()
+	}
+
+	// Notify owners of links disabled for abuse
+	handlers.TakedownNotifier = events.NewTakedownNotifier(config.GlobalAppConfig)
+
+	// Report campaigns crossing their click-count alerts
+	handlers.CampaignAlertNotifier = events.NewCampaignAlertNotifier(config.GlobalAppConfig)
+	go handlers.CampaignAlertNotifier.Run(context.Background())
+
+	// Register per-deployment handle and destination policies
+	if err := validation.Init(config.GlobalAppConfig); err != nil {
+		customlogger.Fatal().Err(err).Msg("Failed to initialize validation policies during startup")
+	}
+
+	// Refuse links to hosts on the malicious host blocklists, if configured, and
+	// recheck existing links whenever the lists change
+	if len(config.GlobalAppConfig.HostBlocklistSources) > 0 {
+		hostBlocklist := hostrep.NewBlocklist(config.GlobalAppConfig.HostBlocklistSources)
+		hostBlocklist.OnUpdate = func(ctx context.Context) { policyscan.Rescan(ctx, handlers.NotifyScanTakedown) }
+		validation.RegisterDestinationValidator(hostBlocklist)
+		go hostBlocklist.Run(context.Background(), time.Duration(config.GlobalAppConfig.HostBlocklistRefreshMinutes)*time.Minute)
+	}
+
+	// Refuse links to URLs Google Safe Browsing flags as malware or phishing
+	if config.GlobalAppConfig.SafeBrowsing {
+		validation.RegisterDestinationValidator(safebrowsing.NewClient(config.GlobalAppConfig.SafeBrowsingAPIKey))
+	}
+
+	// Recheck existing links against the destination policy, domain lists and malicious
+	// site checks, if configured, so links whose destinations turn bad are disabled
+	hasDestinationChecks := config.GlobalAppConfig.DestinationPolicy != "" ||
+		len(config.GlobalAppConfig.DomainAllowlist) > 0 || len(config.GlobalAppConfig.DomainDenylist) > 0 ||
+		len(config.GlobalAppConfig.HostBlocklistSources) > 0 || config.GlobalAppConfig.SafeBrowsing
+	if hasDestinationChecks && config.GlobalAppConfig.DestinationRescanHours > 0 {
+		go policyscan.Run(context.Background(), time.Duration(config.GlobalAppConfig.DestinationRescanHours)*time.Hour, handlers.NotifyScanTakedown)
+	}
+
 	// 5. Setup Router with request logging and subrouters
 	router := mux.NewRouter().StrictSlash(true)
 
-	// Request logging middleware
+	// Request-scoped logger (request ID, shortcode) and request logging middleware
+	router.Use(handlers.RequestContext)
+	// Compress JSON and pages for clients that accept it; redirects go out unchanged
+	router.Use(handlers.Compress)
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			customlogger.Info().
+			customlogger.FromContext(r.Context()).Info().
 				Str("method", r.Method).
 				Str("path", r.URL.Path).
 				Str("host", r.Host).
@@ -84,10 +236,91 @@ func main() {
 
 	// API subrouter for all /api/* routes
 	apiRouter := router.PathPrefix("/api").Subrouter()
+	apiRouter.HandleFunc("/version", handlers.VersionHandler).Methods("GET")
 	apiRouter.HandleFunc("/validate-auth", handlers.ValidateAuthCodeHandler).Methods("POST")
-	apiRouter.HandleFunc("/shorten", handlers.CreateShortURL).Methods("POST")
-	apiRouter.HandleFunc("/stats/{shortcode}", handlers.GetLinkStatsHandler).Methods("GET")
-	apiRouter.HandleFunc("/qr/{shortcode}", handlers.GenerateQRCodeHandler).Methods("GET")
+	apiRouter.HandleFunc("/auth/register", handlers.RegisterUserHandler).Methods("POST")
+	apiRouter.HandleFunc("/auth/login", handlers.LoginUserHandler).Methods("POST")
+	apiRouter.HandleFunc("/auth/me", handlers.GetCurrentUserHandler).Methods("GET")
+	apiRouter.Handle("/shorten", handlers.APIKeyQuota(handlers.QuotaShorten, http.HandlerFunc(handlers.CreateShortURL))).Methods("POST")
+	apiRouter.Handle("/stats/bulk", handlers.LoadShed(handlers.APIKeyQuota(handlers.QuotaStats, http.HandlerFunc(handlers.GetBulkStatsHandler)))).Methods("POST")
+	apiRouter.Handle("/stats/by-tag/{tag}", handlers.LoadShed(handlers.APIKeyQuota(handlers.QuotaStats, http.HandlerFunc(handlers.GetTagStatsHandler)))).Methods("GET")
+	apiRouter.Handle("/stats/{shortcode}", handlers.LoadShed(handlers.APIKeyQuota(handlers.QuotaStats, http.HandlerFunc(handlers.GetLinkStatsHandler)))).Methods("GET")
+	apiRouter.Handle("/grafana/timeseries", handlers.LoadShed(handlers.APIKeyQuota(handlers.QuotaStats, http.HandlerFunc(handlers.GrafanaTimeSeriesHandler)))).Methods("GET")
+	apiRouter.Handle("/grafana/table", handlers.LoadShed(handlers.APIKeyQuota(handlers.QuotaStats, http.HandlerFunc(handlers.GrafanaTableHandler)))).Methods("GET")
+	apiRouter.HandleFunc("/conversions", handlers.PostConversionHandler).Methods("POST")
+	apiRouter.Handle("/qr/sheet", handlers.LoadShed(http.HandlerFunc(handlers.CreateQRSheetHandler))).Methods("POST")
+	apiRouter.Handle("/qr/{shortcode}", handlers.LoadShed(http.HandlerFunc(handlers.GenerateQRCodeHandler))).Methods("GET")
+	apiRouter.Handle("/qr/{shortcode}/variants", handlers.LoadShed(http.HandlerFunc(handlers.CreateQRVariantsHandler))).Methods("POST")
+	apiRouter.HandleFunc("/handles/{handle}", handlers.CheckHandleHandler).Methods("GET")
+	apiRouter.HandleFunc("/lookup", handlers.LookupLinksHandler).Methods("GET")
+	apiRouter.HandleFunc("/lookup", handlers.LookupOwnLinksHandler).Methods("POST")
+	apiRouter.HandleFunc("/links", handlers.ListLinksHandler).Methods("GET")
+	apiRouter.HandleFunc("/links/batch", handlers.CreateLinkBatchHandler).Methods("POST")
+	apiRouter.HandleFunc("/links/{shortcode}", handlers.GetLinkHandler).Methods("GET")
+	apiRouter.HandleFunc("/links/{shortcode}", handlers.DeleteLinkHandler).Methods("DELETE")
+	apiRouter.HandleFunc("/links/{shortcode}/preview", handlers.CreateLinkPreviewHandler).Methods("POST")
+	apiRouter.HandleFunc("/links/{shortcode}/publish", handlers.PublishLinkHandler).Methods("POST")
+	apiRouter.HandleFunc("/links/{shortcode}/claim", handlers.ClaimLinkHandler).Methods("POST")
+	apiRouter.HandleFunc("/links/{shortcode}/freeze", handlers.FreezeLinkHandler).Methods("PUT")
+	apiRouter.HandleFunc("/links/{shortcode}/freeze", handlers.UnfreezeLinkHandler).Methods("DELETE")
+	apiRouter.HandleFunc("/links/{shortcode}/headers", handlers.PutLinkHeadersHandler).Methods("PUT")
+	apiRouter.HandleFunc("/links/{shortcode}/comments", handlers.ListLinkCommentsHandler).Methods("GET")
+	apiRouter.HandleFunc("/links/{shortcode}/comments", handlers.AddLinkCommentHandler).Methods("POST")
+	apiRouter.HandleFunc("/links/{shortcode}/comments/{id:[0-9]+}", handlers.DeleteLinkCommentHandler).Methods("DELETE")
+	apiRouter.HandleFunc("/links/{shortcode}/rules", handlers.GetLinkRulesHandler).Methods("GET")
+	apiRouter.HandleFunc("/links/{shortcode}/rules", handlers.PutLinkRulesHandler).Methods("PUT")
+	apiRouter.HandleFunc("/links/{shortcode}/rules", handlers.DeleteLinkRulesHandler).Methods("DELETE")
+	apiRouter.HandleFunc("/links/{shortcode}/rules/test", handlers.TestLinkRulesHandler).Methods("POST")
+	apiRouter.HandleFunc("/links/{shortcode}/rollout", handlers.GetLinkRolloutHandler).Methods("GET")
+	apiRouter.HandleFunc("/links/{shortcode}/rollout", handlers.PutLinkRolloutHandler).Methods("PUT")
+	apiRouter.HandleFunc("/links/{shortcode}/rollout", handlers.DeleteLinkRolloutHandler).Methods("DELETE")
+	apiRouter.HandleFunc("/debug/redirect/{shortcode}", handlers.DebugRedirectHandler).Methods("GET")
+	apiRouter.HandleFunc("/drops", handlers.CreateDropHandler).Methods("POST")
+	apiRouter.HandleFunc("/drops/{shortcode}", handlers.GetDropHandler).Methods("GET")
+	apiRouter.HandleFunc("/snippets", handlers.CreateSnippetHandler).Methods("POST")
+	apiRouter.HandleFunc("/snippets/{shortcode}", handlers.GetSnippetHandler).Methods("GET")
+	apiRouter.HandleFunc("/integrations/github", handlers.GitHubWebhookHandler).Methods("POST")
+	apiRouter.HandleFunc("/transparency/takedowns", handlers.ListTakedownLogHandler).Methods("GET")
+
+	// Files shared through drop links
+	router.HandleFunc("/d/{shortcode}/{filename}", handlers.ServeDropHandler).Methods("GET", "HEAD")
+
+	// Snippets shared through snippet links
+	router.HandleFunc("/s/{shortcode}", handlers.ServeSnippetHandler).Methods("GET")
+
+	// Embeddable link status badge
+	router.Handle("/badge/{shortcode}.svg", handlers.LoadShed(http.HandlerFunc(handlers.GetLinkBadgeHandler))).Methods("GET")
+
+	// Atom feed of recently created public links
+	router.HandleFunc("/feed.xml", handlers.GetFeedHandler).Methods("GET")
+
+	// Admin API, restricted to ADMIN_AUTH_CODES
+	adminRouter := apiRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(handlers.RequireAdmin)
+	adminRouter.HandleFunc("/config", handlers.GetConfigHandler).Methods("GET")
+	adminRouter.HandleFunc("/patterns", handlers.ListRedirectPatternsHandler).Methods("GET")
+	adminRouter.HandleFunc("/patterns", handlers.SaveRedirectPatternHandler).Methods("POST")
+	adminRouter.HandleFunc("/patterns/{id:[0-9]+}", handlers.DeleteRedirectPatternHandler).Methods("DELETE")
+	adminRouter.HandleFunc("/domains", handlers.ListDomainRoutesHandler).Methods("GET")
+	adminRouter.HandleFunc("/domains/{host}", handlers.PutDomainRouteHandler).Methods("PUT")
+	adminRouter.HandleFunc("/domains/{host}", handlers.DeleteDomainRouteHandler).Methods("DELETE")
+	adminRouter.HandleFunc("/events", handlers.ListLinkEventsHandler).Methods("GET")
+	adminRouter.HandleFunc("/sync", handlers.SyncLinksHandler).Methods("POST")
+	adminRouter.HandleFunc("/maintenance", handlers.RunMaintenanceHandler).Methods("POST")
+	adminRouter.HandleFunc("/policy-scan", handlers.RunPolicyScanHandler).Methods("POST")
+	adminRouter.HandleFunc("/takedowns", handlers.ListLinkTakedownsHandler).Methods("GET")
+	adminRouter.HandleFunc("/takedowns/{shortcode}", handlers.PutLinkTakedownHandler).Methods("PUT")
+	adminRouter.HandleFunc("/takedowns/{shortcode}", handlers.DeleteLinkTakedownHandler).Methods("DELETE")
+	adminRouter.HandleFunc("/campaign-alerts", handlers.ListCampaignAlertsHandler).Methods("GET")
+	adminRouter.HandleFunc("/campaign-alerts", handlers.CreateCampaignAlertHandler).Methods("POST")
+	adminRouter.HandleFunc("/campaign-alerts/{id:[0-9]+}", handlers.DeleteCampaignAlertHandler).Methods("DELETE")
+	adminRouter.HandleFunc("/api-keys", handlers.ListAPIKeysHandler).Methods("GET")
+	adminRouter.HandleFunc("/api-keys", handlers.CreateAPIKeyHandler).Methods("POST")
+	adminRouter.HandleFunc("/api-keys/{id}", handlers.RevokeAPIKeyHandler).Methods("DELETE")
+	adminRouter.HandleFunc("/api-keys/{id}/quotas", handlers.PutAPIKeyQuotasHandler).Methods("PUT")
+	adminRouter.HandleFunc("/ip-reputation", handlers.GetIPReputationHandler).Methods("GET")
+	adminRouter.Handle("/report", handlers.LoadShed(http.HandlerFunc(handlers.ClickReportHandler))).Methods("GET")
+	adminRouter.HandleFunc("/instances", handlers.ListInstancesHandler).Methods("GET")
 
 	// Health check at root level
 	router.HandleFunc("/health", healthCheck).Methods("GET")
@@ -106,13 +339,31 @@ func main() {
 	// StripPrefix also needs to match that slash.
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(staticFileDirectory)))
 
-	// Serve index.html at the root path "/"
-	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, "./static/index.html")
-	}).Methods("GET")
+	// Serve index.html at the root path "/", unless the domain has its own root destination
+	router.HandleFunc("/", handlers.RootHandler).Methods("GET")
+
+	// A shortcode followed by "+" shows where the link leads instead of redirecting
+	router.HandleFunc("/{shortcode}+", handlers.LinkInfoPageHandler).Methods("GET")
 
 	// IMPORTANT: Redirection for shortcodes must be the last route to act as a catch-all for root paths.
-	router.HandleFunc("/{shortcode}", handlers.RedirectToLongURL).Methods("GET")
+	// Redirects are refused or challenged for clients in blocklisted networks, written
+	// to the access log if one is configured, and timed for load shedding.
+	router.Handle("/{shortcode}", handlers.MeasureRedirects(handlers.AccessLog(handlers.IPReputation(http.HandlerFunc(handlers.RedirectToLongURL))))).Methods("GET")
+
+	// Anything no route matched (e.g. multi-segment vanity paths) is tried against the redirect patterns.
+	router.NotFoundHandler = handlers.MeasureRedirects(handlers.AccessLog(handlers.IPReputation(http.HandlerFunc(handlers.PatternRedirectHandler))))
+
+	// Custom handles may not shadow a route or take a reserved word
+	var routePrefixes []string
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if template, err := route.GetPathTemplate(); err == nil {
+			if prefix := validation.RoutePrefix(template); prefix != "" {
+				routePrefixes = append(routePrefixes, prefix)
+			}
+		}
+		return nil
+	})
+	validation.RegisterHandleValidator(validation.NewReservedHandles(validation.DefaultReservedHandles, routePrefixes, config.GlobalAppConfig.ReservedHandles))
 
 	// 6. Start Server
 	portToUse := config.GlobalAppConfig.Port
@@ -121,8 +372,130 @@ func main() {
 		portToUse = envPort
 	}
 
-	customlogger.Info().Str("port", portToUse).Msgf("Server starting on :%s", portToUse)
-	if err := http.ListenAndServe(":"+portToUse, router); err != nil {
+	// Register this instance with heartbeats, so operators can list the live instances
+	if config.GlobalAppConfig.InstanceRegistry {
+		hostname, _ := os.Hostname()
+		instanceID := config.GlobalAppConfig.InstanceID
+		if instanceID == "" {
+			instanceID = hostname + ":" + portToUse
+		}
+		instance := models.Instance{
+			ID:        instanceID,
+			Address:   config.GlobalAppConfig.InstanceAddress,
+			Hostname:  hostname,
+			Version:   build.Version,
+			Commit:    build.Commit,
+			StartedAt: time.Now().UTC().Truncate(time.Second),
+		}
+		interval := time.Duration(config.GlobalAppConfig.InstanceHeartbeatSeconds) * time.Second
+		go instances.Run(context.Background(), instance, interval, storage.CheckSQLite)
+	}
+
+	// Scanner probes are answered before routing, so they skip the request log too;
+	// all other requests count towards the load low-priority routes are shed on
+	var handler http.Handler = handlers.ScannerGuard(handlers.TrackLoad(router))
+	idleTimeout := time.Duration(config.GlobalAppConfig.ServerIdleTimeout) * time.Second
+	newServer := func(addr string, handler http.Handler) *http.Server {
+		if config.GlobalAppConfig.ServerH2C {
+			handler = h2c.NewHandler(handler, &http2.Server{IdleTimeout: idleTimeout})
+		}
+		return &http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			MaxHeaderBytes:    config.GlobalAppConfig.ServerMaxHeaderBytes,
+			ReadHeaderTimeout: time.Duration(config.GlobalAppConfig.ServerReadHeaderTimeout) * time.Second,
+			IdleTimeout:       idleTimeout,
+		}
+	}
+
+	// Sockets passed by systemd socket activation, or by the process this one replaces
+	// in a reload, take the place of PORT and API_LISTEN, in that order
+	inherited, err := listener.Inherited()
+	if err != nil {
+		customlogger.Fatal().Err(err).Msg("Failed to use the inherited sockets")
+	}
+
+	// With API_LISTEN set, the API gets a listener of its own and the public one serves
+	// redirects only
+	var apiServer *http.Server
+	var apiListener net.Listener
+	if apiListen := config.GlobalAppConfig.APIListen; apiListen != "" {
+		apiServer = newServer(apiListen, handlers.APIListener(handler))
+		if len(inherited) > 1 {
+			apiListener = inherited[1]
+		} else if apiListener, err = net.Listen("tcp", apiListen); err != nil {
+			customlogger.Fatal().Err(err).Msg("API server failed to start")
+		}
+		go func() {
+			customlogger.Info().Str("addr", apiListener.Addr().String()).Msg("API server starting")
+			if err := apiServer.Serve(apiListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				customlogger.Fatal().Err(err).Msg("API server failed")
+			}
+		}()
+		handler = handlers.PublicListener(handler)
+	}
+	server := newServer(":"+portToUse, handler)
+
+	// Listen on the inherited socket, the Unix socket or PORT, in that order of preference
+	var serverListener net.Listener
+	switch {
+	case len(inherited) > 0:
+		serverListener = inherited[0]
+	case config.GlobalAppConfig.UnixSocket != "":
+		serverListener, err = listener.Unix(config.GlobalAppConfig.UnixSocket, os.FileMode(config.GlobalAppConfig.UnixSocketMode))
+	default:
+		serverListener, err = net.Listen("tcp", server.Addr)
+	}
+	if err != nil {
 		customlogger.Fatal().Err(err).Msg("Server failed to start")
 	}
-}
\ No newline at end of file
+
+	// On SIGTERM or SIGINT, stop accepting connections and finish the requests in flight,
+	// so restarts (with systemd keeping the socket open meanwhile) drop no requests. On
+	// the reload signal, SIGUSR2, first hand the listeners to a new process of the
+	// (possibly replaced) binary, so deploys do not even make connections wait
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+		if listener.ReloadSignal != nil {
+			signal.Notify(signals, listener.ReloadSignal)
+		}
+		for sig := range signals {
+			if sig != listener.ReloadSignal {
+				break
+			}
+			handedOver := []net.Listener{serverListener}
+			if apiListener != nil {
+				handedOver = append(handedOver, apiListener)
+			}
+			if err := listener.Reload(handedOver); err != nil {
+				customlogger.Error().Err(err).Msg("Reload failed, still serving")
+				continue
+			}
+			customlogger.Info().Msg("New process took over the listeners")
+			break
+		}
+		signal.Stop(signals)
+
+		customlogger.Info().Msg("Shutting down, finishing requests in flight")
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if apiServer != nil {
+			apiServer.Shutdown(ctx)
+		}
+		if err := server.Shutdown(ctx); err != nil {
+			customlogger.Error().Err(err).Msg("Requests were still in flight at shutdown")
+		}
+	}()
+
+	if err := listener.Ready(); err != nil {
+		customlogger.Warn().Err(err).Msg("Failed to report readiness")
+	}
+	customlogger.Info().Str("addr", serverListener.Addr().String()).Bool("h2c", config.GlobalAppConfig.ServerH2C).Msg("Server starting")
+	if err := server.Serve(serverListener); !errors.Is(err, http.ErrServerClosed) {
+		customlogger.Fatal().Err(err).Msg("Server failed")
+	}
+	<-shutdownDone
+}
@@ -0,0 +1,65 @@
+// Package buildinfo exposes the version, commit and build date of the running binary.
+//
+// The values are injected at build time via ldflags, e.g.
+//
+//	go build -ldflags "-X riid.me/pkg/buildinfo.Version=v1.2.0 \
+//	  -X riid.me/pkg/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X riid.me/pkg/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When they are not set, the commit and date fall back to the VCS information
+// the Go toolchain embeds in the binary, if any.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// Version is the release version of the build.
+	Version = "dev"
+	// Commit is the VCS revision the binary was built from.
+	Commit = ""
+	// BuildDate is the UTC time the binary was built, in RFC3339.
+	BuildDate = ""
+)
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = "unknown"
+	}
+	return info
+}
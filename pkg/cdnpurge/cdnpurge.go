@@ -0,0 +1,207 @@
+// Package cdnpurge invalidates the redirects a CDN in front of the service cached for
+// short links, so visitors stop being sent to old destinations once a link changes.
+// Changes are picked up from the outbox of link lifecycle events.
+package cdnpurge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// Timeout bounds a single purge request to the CDN.
+	Timeout = 10 * time.Second
+	// PollInterval is how often the outbox is checked for changed links.
+	PollInterval = 5 * time.Second
+	// consumer is the outbox cursor name of the purge loop.
+	consumer = "cdn-purge"
+	// batchSize is the most outbox events handled at once.
+	batchSize = 100
+	// cloudflareMaxFiles is the most URLs Cloudflare accepts in one purge request.
+	cloudflareMaxFiles = 30
+)
+
+// Purger drops cached responses for URLs at a CDN.
+type Purger interface {
+	Purge(ctx context.Context, urls []string) error
+}
+
+// New returns the Purger configured by CDN_PURGE_PROVIDER, or nil if purging is disabled.
+func New(cfg config.AppConfig) Purger {
+	switch cfg.CDNPurgeProvider {
+	case config.CDNPurgeCloudflare:
+		return &Cloudflare{
+			Endpoint: "https://api.cloudflare.com/client/v4",
+			ZoneID:   cfg.CDNPurgeZoneID,
+			APIToken: cfg.CDNPurgeAPIToken,
+			Client:   &http.Client{Timeout: Timeout},
+		}
+	case config.CDNPurgeFastly:
+		return &Fastly{
+			Endpoint: "https://api.fastly.com",
+			APIToken: cfg.CDNPurgeAPIToken,
+			Client:   &http.Client{Timeout: Timeout},
+		}
+	}
+	return nil
+}
+
+// Cloudflare purges URLs from a Cloudflare zone's cache.
+type Cloudflare struct {
+	Endpoint string
+	ZoneID   string
+	APIToken string
+	Client   *http.Client
+}
+
+// Purge implements Purger.
+func (c *Cloudflare) Purge(ctx context.Context, urls []string) error {
+	for start := 0; start < len(urls); start += cloudflareMaxFiles {
+		end := min(start+cloudflareMaxFiles, len(urls))
+		body, err := json.Marshal(map[string][]string{"files": urls[start:end]})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+			c.Endpoint+"/zones/"+url.PathEscape(c.ZoneID)+"/purge_cache", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+c.APIToken)
+		if err := do(c.Client, req); err != nil {
+			return fmt.Errorf("cloudflare purge: %w", err)
+		}
+	}
+	return nil
+}
+
+// Fastly purges URLs from the Fastly cache, one request per URL.
+type Fastly struct {
+	Endpoint string
+	APIToken string
+	Client   *http.Client
+}
+
+// Purge implements Purger.
+func (f *Fastly) Purge(ctx context.Context, urls []string) error {
+	for _, u := range urls {
+		// Fastly takes the cached URL without its scheme as the path.
+		_, hostPath, _ := strings.Cut(u, "://")
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.Endpoint+"/purge/"+hostPath, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Fastly-Key", f.APIToken)
+		if err := do(f.Client, req); err != nil {
+			return fmt.Errorf("fastly purge of %s: %w", u, err)
+		}
+	}
+	return nil
+}
+
+// do sends req, treating any non-2xx response as a failure.
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ShortURL returns the URL the CDN caches the redirect of shortCode under.
+func ShortURL(shortCode string) string {
+	return fmt.Sprintf("%s://%s/%s", config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain, shortCode)
+}
+
+// purgedEvents are the link events after which a cached redirect is stale.
+var purgedEvents = map[string]bool{
+	models.LinkEventUpdated: true,
+	models.LinkEventExpired: true,
+	models.LinkEventDeleted: true,
+}
+
+// PurgePending purges the short URLs of links updated, expired or deleted since the
+// purge loop's outbox cursor, advancing the cursor after each purged batch.
+func PurgePending(ctx context.Context, purger Purger) error {
+	after, err := storage.OutboxCursor(ctx, consumer)
+	if err != nil {
+		return err
+	}
+	for {
+		batch, err := storage.ListLinkEvents(ctx, after, batchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		seen := map[string]bool{}
+		var urls []string
+		for _, event := range batch {
+			if purgedEvents[event.Type] && !seen[event.ShortCode] {
+				seen[event.ShortCode] = true
+				urls = append(urls, ShortURL(event.ShortCode))
+			}
+		}
+		if len(urls) > 0 {
+			if err := purger.Purge(ctx, urls); err != nil {
+				return err
+			}
+			customlogger.Info().Int("urls", len(urls)).Msg("Purged cached redirects of changed links")
+		}
+		after = batch[len(batch)-1].ID
+		if err := storage.SetOutboxCursor(ctx, consumer, after); err != nil {
+			return err
+		}
+	}
+}
+
+// Run purges the cached redirects of changed links every PollInterval until ctx is
+// cancelled. On its first run against a database, events from before purging was
+// enabled are skipped rather than purged all at once.
+func Run(ctx context.Context, purger Purger) {
+	if err := skipHistory(ctx); err != nil {
+		customlogger.Error().Err(err).Msg("Failed to initialize CDN purge cursor")
+	}
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		if err := PurgePending(ctx, purger); err != nil {
+			customlogger.Error().Err(err).Msg("Failed to purge cached redirects, retrying")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// skipHistory moves a cursor that has never been set to the newest event.
+func skipHistory(ctx context.Context) error {
+	after, err := storage.OutboxCursor(ctx, consumer)
+	if err != nil || after != 0 {
+		return err
+	}
+	latest, err := storage.LatestLinkEventID(ctx)
+	if err != nil || latest == 0 {
+		return err
+	}
+	return storage.SetOutboxCursor(ctx, consumer, latest)
+}
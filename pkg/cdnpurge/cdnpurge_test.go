@@ -0,0 +1,96 @@
+package cdnpurge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestCloudflarePurgeBatches(t *testing.T) {
+	var batches [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/zones/zone1/purge_cache", r.URL.Path)
+		assert.Equal(t, "Bearer tok", r.Header.Get("Authorization"))
+		var body struct{ Files []string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		batches = append(batches, body.Files)
+	}))
+	defer server.Close()
+
+	urls := make([]string, cloudflareMaxFiles+1)
+	for i := range urls {
+		urls[i] = "https://riid.me/" + strconv.Itoa(i)
+	}
+	purger := &Cloudflare{Endpoint: server.URL, ZoneID: "zone1", APIToken: "tok", Client: server.Client()}
+	require.NoError(t, purger.Purge(context.Background(), urls))
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], cloudflareMaxFiles)
+	assert.Equal(t, []string{urls[cloudflareMaxFiles]}, batches[1])
+}
+
+func TestFastlyPurge(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "tok", r.Header.Get("Fastly-Key"))
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/purge/riid.me/bad" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	purger := &Fastly{Endpoint: server.URL, APIToken: "tok", Client: server.Client()}
+	require.NoError(t, purger.Purge(context.Background(), []string{"https://riid.me/abc"}))
+	assert.Equal(t, []string{"/purge/riid.me/abc"}, paths)
+	assert.Error(t, purger.Purge(context.Background(), []string{"https://riid.me/bad"}))
+}
+
+// recordingPurger remembers the URLs it was asked to purge.
+type recordingPurger struct{ urls []string }
+
+func (p *recordingPurger) Purge(_ context.Context, urls []string) error {
+	p.urls = append(p.urls, urls...)
+	return nil
+}
+
+func TestPurgePending(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Scheme = "https"
+	config.GlobalAppConfig.Domain = "riid.me"
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	old := models.Link{ShortCode: "old", LongURL: "https://example.com", CreatedAt: now}
+	require.NoError(t, storage.CreateLink(ctx, old))
+	require.NoError(t, storage.UpdateLink(ctx, old))
+	require.NoError(t, skipHistory(ctx), "events before purging was enabled are skipped")
+
+	link := models.Link{ShortCode: "abc", LongURL: "https://example.com", CreatedAt: now}
+	require.NoError(t, storage.CreateLink(ctx, link))
+	link.LongURL = "https://example.com/new"
+	require.NoError(t, storage.UpdateLink(ctx, link))
+	require.NoError(t, storage.UpdateLink(ctx, link))
+	require.NoError(t, storage.DeleteLink(ctx, link))
+
+	purger := &recordingPurger{}
+	require.NoError(t, PurgePending(ctx, purger))
+	assert.Equal(t, []string{"https://riid.me/abc"}, purger.urls)
+
+	purger.urls = nil
+	require.NoError(t, PurgePending(ctx, purger))
+	assert.Empty(t, purger.urls, "the cursor advanced")
+}
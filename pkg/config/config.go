@@ -1,6 +1,7 @@
 package config
 
 import (
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -11,15 +12,133 @@ import (
 
 // AppConfig holds all configuration for the application.
 // These values are typically loaded from environment variables.
+// Fields tagged redact:"true" hold secrets and are masked by Redacted.
 type AppConfig struct {
-	Port           string // Port the server will listen on (e.g., "3000")
-	Domain         string // Domain name for constructing short URLs (e.g., "localhost:3000")
-	Scheme         string // URL scheme (e.g., "http" or "https")
-	RedisURL       string // Address of the Redis server (e.g., "localhost:6379")
-	RedisPW        string // Password for the Redis server (empty if none)
-	RedisDB        int    // Redis database number (typically 0)
-	SQLiteDBPath   string // Filesystem path to the SQLite database file
-	ValidAuthCodes []string // Slice of valid authorization codes for protected features
+	Port                                 string   // Port the server will listen on (e.g., "3000")
+	ServerMaxHeaderBytes                 int      // Largest request header, in bytes, the server reads
+	ServerReadHeaderTimeout              int      // Seconds a client has to send its request headers
+	ServerIdleTimeout                    int      // Seconds an idle keep-alive connection is kept open; 0 keeps it until the client closes it
+	ServerH2C                            bool     // Also serve HTTP/2 without TLS (h2c), for internal clients such as a gRPC gateway
+	APIListen                            string   // Address ("host:port" or port) the API is served on instead of PORT, which then serves redirects only; empty serves both on PORT
+	UnixSocket                           string   // Path of a Unix domain socket to listen on instead of PORT, e.g. for nginx on the same host; empty to use PORT
+	UnixSocketMode                       int      // Permissions of the Unix socket, e.g. 0o660
+	Domain                               string   // Domain name for constructing short URLs (e.g., "localhost:3000")
+	Scheme                               string   // URL scheme (e.g., "http" or "https")
+	RedisURL                             string   // Address of the Redis server (e.g., "localhost:6379")
+	RedisPW                              string   `redact:"true"` // Password for the Redis server (empty if none)
+	RedisDB                              int      // Redis database number (typically 0)
+	SQLiteDBPath                         string   // Filesystem path to the SQLite database file
+	ValidAuthCodes                       []string `redact:"true"` // Slice of valid authorization codes for protected features
+	HandlePattern                        string   // Regular expression custom handles must fully match, empty for none
+	HandleMinLength                      int      // Shortest custom handle allowed
+	HandleMaxLength                      int      // Longest custom handle allowed
+	HandlePolicy                         string   // Custom handle policy rules (see pkg/validation), empty for none
+	ReservedHandles                      []string // Custom handles no one may register, besides the built-in list and the paths of routes
+	ShortCodeStrategy                    string   // How short codes are generated: ShortCodeShortID, ShortCodeNanoID or ShortCodeSequential; empty picks shortid, or nanoid when ShortCodeLength or ShortCodeAlphabet is set
+	ShortCodeLength                      int      // Length of generated short codes; 0 with no ShortCodeAlphabet keeps shortid's time-based codes
+	ShortCodeAlphabet                    string   // Characters generated short codes are drawn from, e.g. lowercase letters and digits only; empty for letters and digits
+	ShortCodeAttempts                    int      // How often a short code is generated for a new link before giving up, when codes turn out taken or offensive
+	CaseInsensitiveCodes                 bool     // Lowercase custom handles and generated short codes on creation and resolve short URLs regardless of letter case
+	ProfanityFilter                      bool     // Regenerate generated short codes containing an offensive word
+	ProfanityWords                       []string // Words generated short codes must not contain, besides the built-in list
+	DestinationPolicy                    string   // Destination URL policy rules (see pkg/validation), empty for none
+	DestinationRescanHours               int      // Hours between rescans of existing links against DESTINATION_POLICY, which disable violating links; 0 disables rescans
+	DomainAllowlist                      []string // Domains (with their subdomains) links may point at, empty to allow all
+	DomainDenylist                       []string // Domains (with their subdomains) links may not point at
+	CountryHeader                        string   // Request header carrying the visitor's country code, set by a trusted proxy/CDN
+	RedirectCacheMaxAge                  int      // Default seconds browsers may cache redirects; 0 sends no-store
+	HideReferrer                         bool     // Send visitors of every link through a page that strips the Referer, not only links created with hide_referrer
+	NoIndex                              bool     // Ask search engines not to index or follow any link, not only links created with noindex
+	DedupeLinks                          bool     // Return an owner's existing link when they shorten the same URL again instead of creating another
+	AdminAuthCodes                       []string `redact:"true"` // Authorization codes granting access to the admin API
+	NotFoundMode                         string   // What visitors of unknown short codes get: NotFoundMode404, NotFoundModeRedirect or NotFoundModeSearch
+	NotFoundRedirectURL                  string   // Fallback URL unknown short codes redirect to in NotFoundModeRedirect
+	EventsWebhookURL                     string   `redact:"true"` // URL link lifecycle events are POSTed to, empty to only keep them in the outbox
+	EventsInterval                       int      // Seconds between outbox runs (expired-link sweep and publishing)
+	GitHubWebhookSecret                  string   `redact:"true"` // Secret GitHub release webhooks are signed with, empty to disable the integration
+	GitHubWebhookPreviousSecret          string   `redact:"true"` // Former GitHub webhook secret still accepted while the secret is rotated
+	GitHubReleaseAsset                   string   // Glob selecting the release asset latest-{repo} links point to, empty for the first asset
+	ClickWebhookURL                      string   `redact:"true"` // URL a click event is POSTed to for every redirect of notify_each_click links, empty to disable
+	ClickWebhookSecret                   string   `redact:"true"` // Secret click events are signed with (X-Riidme-Signature), empty to send them unsigned
+	CDNPurgeProvider                     string   // CDN whose cached redirects are purged when links change: CDNPurgeCloudflare, CDNPurgeFastly, or empty to disable
+	CDNPurgeAPIToken                     string   `redact:"true"` // API token of the CDN purge provider
+	CDNPurgeZoneID                       string   // Cloudflare zone of the short link domain
+	PreviewTokenHours                    int      // Hours a draft link's preview token stays valid
+	QRMaxSize                            int      // Largest QR code size, in pixels, the QR endpoint renders
+	QRRateLimit                          int      // QR codes a client may request per minute; 0 disables the limit
+	AnonShortenLimitPerHour              int      // Links a client without a valid auth code may shorten per hour, as a token bucket; 0 disables the limit
+	RequireAuthToShorten                 bool     // Refuse to create links for callers without a valid auth code, API key or session; redirects stay public
+	JWTSecret                            string   `redact:"true"` // Secret user session tokens (JWTs) are signed with, empty to disable user accounts
+	JWTTTLHours                          int      // Hours a user session token stays valid
+	JWTPreviousSecret                    string   `redact:"true"` // Former JWT_SECRET whose session tokens stay valid while the secret is rotated
+	DestinationEncryptionKey             string   `redact:"true"` // Base64-encoded 32-byte key destination URLs are encrypted at rest with, empty to store them in plain
+	DestinationEncryptionKeyFile         string   // File holding DestinationEncryptionKey, e.g. mounted by a KMS or secret manager; takes precedence
+	DestinationEncryptionPreviousKey     string   `redact:"true"` // Former destination encryption key, only used to decrypt while keys are rotated
+	DestinationEncryptionPreviousKeyFile string   // File holding DestinationEncryptionPreviousKey; takes precedence
+	APIKeyShortenQuota                   int      // Links an API key may shorten per day unless the key sets its own quota; 0 is unlimited
+	APIKeyStatsQuota                     int      // Stats requests an API key may make per day unless the key sets its own quota; 0 is unlimited
+	DropsDir                             string   // Directory uploaded files shared through short links are kept in, empty to disable drops
+	DropMaxBytes                         int64    // Largest file, in bytes, that can be dropped
+	AccessLog                            string   // File redirect hits are appended to in Apache Combined Log Format, "stdout" for standard output, empty to disable
+	ConversionTracking                   bool     // Give every recorded click an ID, sent as a cookie and CLICK_ID_PARAM, that conversions can be posted for
+	ClickIDParam                         string   // Query parameter the click ID is appended to destinations as, empty to only set the cookie
+	ConversionWindowHours                int      // Hours after a click a conversion is still attributed to it
+	VisitorCookie                        bool     // Recognize visitors across links by a first-party cookie instead of IP address and user agent; visitors sending GPC or DNT never get one
+	VisitorCookieDays                    int      // Days the visitor cookie lasts after a visitor's latest redirect
+	HandleCooldownDays                   int      // Days an expired custom handle stays reserved for its former owner before others may register it; 0 disables
+	TransparencyLog                      bool     // Publish the log of links disabled for abuse and reinstated, without destinations, at /api/transparency/takedowns
+	FetchPageMeta                        bool     // Fetch the title, description and favicon of new links' destination pages in the background
+	CrawlerPreviews                      bool     // Answer link preview bots with the fetched Open Graph tags of a link's destination instead of a redirect
+	InstanceRegistry                     bool     // Register this instance in the Redis instance registry with heartbeats
+	InstanceID                           string   // Name of this instance in the registry; defaults to hostname:port
+	InstanceAddress                      string   // Where this instance can be reached, as listed in the registry (optional)
+	InstanceHeartbeatSeconds             int      // Seconds between heartbeats; instances missing three are no longer listed
+	ScannerGuard                         bool     // Answer requests for well-known vulnerability scanner paths with an instant 404
+	ScannerPaths                         []string // Extra path fragments (matched case-insensitively) treated as scanner probes
+	ScannerTarpitSeconds                 int      // Seconds scanner probes are held before their 404; 0 answers at once
+	ScannerBanThreshold                  int      // Scanner probes after which a client is banned; 0 disables bans
+	ScannerBanMinutes                    int      // Minutes a banned client is refused, and the window probes are counted in
+	LoadShedding                         bool     // Answer low-priority requests (statistics, QR codes, reports) with 503 while the instance is saturated, protecting redirects
+	LoadShedMaxInFlight                  int      // Requests in flight above which the instance counts as saturated; 0 disables the check
+	LoadShedRedirectLatencyMs            int      // Average redirect latency, in milliseconds, above which the instance counts as saturated; 0 disables the check
+	IPBlocklistSources                   []string // URLs or file paths of IP blocklists (e.g. Spamhaus DROP) checked on redirects, empty to disable
+	IPBlocklistRefreshMinutes            int      // Minutes between downloads of the IP blocklists
+	IPBlocklistAction                    string   // What listed clients get on redirects: IPBlocklistActionDeny or IPBlocklistActionChallenge
+	TrustedProxies                       Networks // Reverse proxies whose X-Forwarded-For is believed for rate limits, scanner bans and the IP blocklist; empty to use the connection's address
+	HostBlocklistSources                 []string // URLs or file paths of malicious host blocklists (e.g. URLhaus) links may not point at, empty to disable
+	HostBlocklistRefreshMinutes          int      // Minutes between downloads of the host blocklists; existing links are rescanned when they change
+	SafeBrowsing                         bool     // Check destinations of new links with the Google Safe Browsing Lookup API and refuse flagged ones
+	SafeBrowsingAPIKey                   string   `redact:"true"` // Google API key with the Safe Browsing API enabled
+	TakedownAppealURL                    string   // URL owners of disabled links can appeal at, "{code}" is replaced by the short code; empty for none
+	TakedownWebhookURL                   string   `redact:"true"` // URL takedown notices are POSTed to, empty to disable
+	TakedownWebhookSecret                string   `redact:"true"` // Secret takedown notices are signed with (X-Riidme-Signature), empty to send them unsigned
+	SMTPAddr                             string   // host:port of the SMTP server takedown notices and campaign alerts are emailed through, empty to disable emails
+	SMTPUsername                         string   // SMTP username, empty to send without authentication
+	SMTPPassword                         string   `redact:"true"` // SMTP password
+	SMTPFrom                             string   // Sender address of notice emails
+	CampaignAlertWebhookSecret           string   `redact:"true"` // Secret campaign alert webhooks are signed with (X-Riidme-Signature), empty to send them unsigned
+	UnwrapMaxHops                        int      // Redirects followed to resolve a submitted URL's final destination before storing it; 0 disables unwrapping
+	UnwrapShorteners                     []string // Hosts treated as link shorteners in addition to the built-in list and our own domain
+	UnwrapShortenerAction                string   // What happens to URLs redirecting through a shortener: UnwrapShortenerFlag or UnwrapShortenerReject
+	InternalLinkAction                   string   // What happens to new links pointing at another of our short links: InternalLinkResolve or InternalLinkReject
+	InternalLinkMaxHops                  int      // Most of our own short links a redirect may pass through before it is treated as a loop
+	ViewerAuthCodes                      []string `redact:"true"` // Authorization codes of the viewer role, which may read stats but not manage links
+	ViewerStatsAggregatesOnly            bool     // Show viewers only aggregate click counts instead of raw clicks; everyone else only sees the statistics of their own links
+}
+
+// Networks is a list of IP networks, as configured by comma-separated CIDR ranges or
+// single addresses.
+type Networks []netip.Prefix
+
+// Contains reports whether addr lies in one of the networks.
+func (n Networks) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range n {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
 
 // GlobalAppConfig is a package-level variable that stores the loaded application configuration.
@@ -31,6 +150,65 @@ const (
 	DefaultExpirationDays = 365 // 1 year
 	// MaxExpirationDays is the maximum allowed custom expiration period in days.
 	MaxExpirationDays = 365 * 10 // 10 years
+	// DefaultShortCodeAttempts is how often a short code is generated for a new link
+	// unless SHORTCODE_ATTEMPTS says otherwise.
+	DefaultShortCodeAttempts = 10
+	// DefaultHandleMinLength is the shortest custom handle allowed unless HANDLE_MIN_LEN says otherwise.
+	DefaultHandleMinLength = 3
+	// DefaultHandleMaxLength is the longest custom handle allowed unless HANDLE_MAX_LEN says otherwise.
+	DefaultHandleMaxLength = 30
+	// MaxRedirectCacheMaxAge is the longest redirect cache lifetime, in seconds, a link may request.
+	MaxRedirectCacheMaxAge = 365 * 24 * 60 * 60 // 1 year
+	// ShortCodeShortID generates short codes with shortid, from the time and a counter.
+	ShortCodeShortID = "shortid"
+	// ShortCodeNanoID draws short codes at random from SHORTCODE_ALPHABET.
+	ShortCodeNanoID = "nanoid"
+	// ShortCodeSequential numbers short codes from a Redis counter, written in SHORTCODE_ALPHABET.
+	ShortCodeSequential = "sequential"
+	// NotFoundMode404 answers unknown short codes with a plain 404 response.
+	NotFoundMode404 = "404"
+	// NotFoundModeRedirect redirects unknown short codes to NotFoundRedirectURL.
+	NotFoundModeRedirect = "redirect"
+	// NotFoundModeSearch answers unknown short codes with a 404 page suggesting similar codes.
+	NotFoundModeSearch = "search"
+	// MinQRSize is the smallest QR code size, in pixels, that can be requested (one pixel per module).
+	MinQRSize = 35
+	// MaxQRSize is the hard upper bound for QR_MAX_SIZE, in pixels.
+	MaxQRSize = 4096
+	// IPBlocklistActionDeny refuses redirects to clients in a blocklisted network with a 403.
+	IPBlocklistActionDeny = "deny"
+	// IPBlocklistActionChallenge shows clients in a blocklisted network a page they must
+	// confirm before being redirected.
+	IPBlocklistActionChallenge = "challenge"
+	// UnwrapShortenerFlag stores URLs redirecting through another shortener, tagged "via-shortener".
+	UnwrapShortenerFlag = "flag"
+	// UnwrapShortenerReject refuses to shorten URLs redirecting through another shortener.
+	UnwrapShortenerReject = "reject"
+	// MaxUnwrapHops is the hard upper bound for UNWRAP_MAX_HOPS.
+	MaxUnwrapHops = 20
+	// InternalLinkResolve stores links pointing at another of our short links with that
+	// link's destination instead.
+	InternalLinkResolve = "resolve"
+	// InternalLinkReject refuses links pointing at another of our short links.
+	InternalLinkReject = "reject"
+	// MaxInternalLinkHops is the hard upper bound for INTERNAL_LINK_MAX_HOPS.
+	MaxInternalLinkHops = 10
+	// CDNPurgeCloudflare purges cached redirects through the Cloudflare API.
+	CDNPurgeCloudflare = "cloudflare"
+	// CDNPurgeFastly purges cached redirects through the Fastly API.
+	CDNPurgeFastly = "fastly"
+	// MinHostBlocklistRefreshMinutes is the shortest interval between host blocklist downloads.
+	MinHostBlocklistRefreshMinutes = 15
+	// MinIPBlocklistRefreshMinutes is the shortest interval between blocklist downloads;
+	// list providers such as Spamhaus ask not to be fetched more than once an hour.
+	MinIPBlocklistRefreshMinutes = 60
+	// MinServerMaxHeaderBytes is the smallest accepted SERVER_MAX_HEADER_BYTES; smaller
+	// limits would refuse ordinary browser requests.
+	MinServerMaxHeaderBytes = 4096
+	// MinJWTSecretLength is the shortest JWT_SECRET accepted without a warning.
+	MinJWTSecretLength = 32
+	// MaxScannerTarpitSeconds is the hard upper bound for SCANNER_TARPIT_SECONDS.
+	MaxScannerTarpitSeconds = 30
 	// NoExpirationValue is used in requests to indicate that a URL should never expire.
 	// For Redis, a TTL of 0 means no expiry.
 	NoExpirationValue = 0
@@ -52,6 +230,50 @@ func LoadEnv() {
 	}
 
 	GlobalAppConfig.Port = getEnv("PORT", "3000")
+
+	maxHeaderBytesStr := getEnv("SERVER_MAX_HEADER_BYTES", "16384")
+	maxHeaderBytes, err := strconv.Atoi(maxHeaderBytesStr)
+	if err != nil || maxHeaderBytes < MinServerMaxHeaderBytes {
+		customlogger.Warn().Str("server_max_header_bytes", maxHeaderBytesStr).Msg("Invalid SERVER_MAX_HEADER_BYTES value, defaulting to 16384")
+		maxHeaderBytes = 16384
+	}
+	GlobalAppConfig.ServerMaxHeaderBytes = maxHeaderBytes
+
+	readHeaderTimeoutStr := getEnv("SERVER_READ_HEADER_TIMEOUT_SECONDS", "10")
+	readHeaderTimeout, err := strconv.Atoi(readHeaderTimeoutStr)
+	if err != nil || readHeaderTimeout <= 0 {
+		customlogger.Warn().Str("server_read_header_timeout_seconds", readHeaderTimeoutStr).Msg("Invalid SERVER_READ_HEADER_TIMEOUT_SECONDS value, defaulting to 10")
+		readHeaderTimeout = 10
+	}
+	GlobalAppConfig.ServerReadHeaderTimeout = readHeaderTimeout
+
+	idleTimeoutStr := getEnv("SERVER_IDLE_TIMEOUT_SECONDS", "60")
+	idleTimeout, err := strconv.Atoi(idleTimeoutStr)
+	if err != nil || idleTimeout < 0 {
+		customlogger.Warn().Str("server_idle_timeout_seconds", idleTimeoutStr).Msg("Invalid SERVER_IDLE_TIMEOUT_SECONDS value, defaulting to 60")
+		idleTimeout = 60
+	}
+	GlobalAppConfig.ServerIdleTimeout = idleTimeout
+
+	h2cStr := getEnv("SERVER_H2C", "false")
+	h2c, err := strconv.ParseBool(h2cStr)
+	if err != nil {
+		customlogger.Warn().Str("server_h2c", h2cStr).Msg("Invalid SERVER_H2C value, defaulting to false")
+		h2c = false
+	}
+	GlobalAppConfig.ServerH2C = h2c
+	GlobalAppConfig.APIListen = getEnv("API_LISTEN", "")
+	if GlobalAppConfig.APIListen != "" && !strings.Contains(GlobalAppConfig.APIListen, ":") {
+		GlobalAppConfig.APIListen = ":" + GlobalAppConfig.APIListen
+	}
+	GlobalAppConfig.UnixSocket = getEnv("UNIX_SOCKET", "")
+	unixSocketModeStr := getEnv("UNIX_SOCKET_MODE", "0660")
+	unixSocketMode, err := strconv.ParseUint(unixSocketModeStr, 8, 32)
+	if err != nil || unixSocketMode > 0o777 {
+		customlogger.Warn().Str("unix_socket_mode", unixSocketModeStr).Msg("Invalid UNIX_SOCKET_MODE value, defaulting to 0660")
+		unixSocketMode = 0o660
+	}
+	GlobalAppConfig.UnixSocketMode = int(unixSocketMode)
 	GlobalAppConfig.Domain = getEnv("APP_DOMAIN", "localhost:3000")
 	GlobalAppConfig.Scheme = getEnv("APP_SCHEME", "http")
 	GlobalAppConfig.RedisURL = getEnv("REDIS_ADDR", "localhost:6379")
@@ -75,5 +297,528 @@ func LoadEnv() {
 		customlogger.Info().Msg("No VALID_AUTH_CODES configured. Custom handles via auth code will not be available.")
 	}
 
+	adminCodesEnv := getEnv("ADMIN_AUTH_CODES", "")
+	if adminCodesEnv != "" {
+		GlobalAppConfig.AdminAuthCodes = strings.Split(adminCodesEnv, ",")
+	} else {
+		GlobalAppConfig.AdminAuthCodes = []string{}
+		customlogger.Info().Msg("No ADMIN_AUTH_CODES configured. The admin API will not be available.")
+	}
+
+	GlobalAppConfig.ViewerAuthCodes = []string{}
+	if viewerCodesEnv := getEnv("VIEWER_AUTH_CODES", ""); viewerCodesEnv != "" {
+		GlobalAppConfig.ViewerAuthCodes = strings.Split(viewerCodesEnv, ",")
+	}
+	viewerAggregatesStr := getEnv("VIEWER_STATS_AGGREGATES_ONLY", "false")
+	viewerAggregates, err := strconv.ParseBool(viewerAggregatesStr)
+	if err != nil {
+		customlogger.Warn().Str("viewer_stats_aggregates_only", viewerAggregatesStr).Msg("Invalid VIEWER_STATS_AGGREGATES_ONLY value, defaulting to false")
+		viewerAggregates = false
+	}
+	GlobalAppConfig.ViewerStatsAggregatesOnly = viewerAggregates
+
+	GlobalAppConfig.HandlePattern = getEnv("HANDLE_PATTERN", `[a-zA-Z0-9_-]+`)
+	handleMinLenStr := getEnv("HANDLE_MIN_LEN", strconv.Itoa(DefaultHandleMinLength))
+	handleMinLen, err := strconv.Atoi(handleMinLenStr)
+	if err != nil || handleMinLen < 1 {
+		customlogger.Warn().Str("handle_min_len", handleMinLenStr).Msgf("Invalid HANDLE_MIN_LEN value, defaulting to %d", DefaultHandleMinLength)
+		handleMinLen = DefaultHandleMinLength
+	}
+	handleMaxLenStr := getEnv("HANDLE_MAX_LEN", strconv.Itoa(DefaultHandleMaxLength))
+	handleMaxLen, err := strconv.Atoi(handleMaxLenStr)
+	if err != nil || handleMaxLen < handleMinLen {
+		customlogger.Warn().Str("handle_max_len", handleMaxLenStr).Int("handle_min_len", handleMinLen).Msgf("Invalid HANDLE_MAX_LEN value, defaulting to %d", max(DefaultHandleMaxLength, handleMinLen))
+		handleMaxLen = max(DefaultHandleMaxLength, handleMinLen)
+	}
+	GlobalAppConfig.HandleMinLength = handleMinLen
+	GlobalAppConfig.HandleMaxLength = handleMaxLen
+	GlobalAppConfig.HandlePolicy = getEnv("HANDLE_POLICY", "")
+	GlobalAppConfig.ReservedHandles = nil
+	if reservedEnv := getEnv("RESERVED_HANDLES", ""); reservedEnv != "" {
+		for _, handle := range strings.Split(reservedEnv, ",") {
+			if handle = strings.ToLower(strings.TrimSpace(handle)); handle != "" {
+				GlobalAppConfig.ReservedHandles = append(GlobalAppConfig.ReservedHandles, handle)
+			}
+		}
+	}
+	shortCodeStrategy := strings.ToLower(getEnv("SHORTCODE_STRATEGY", ""))
+	switch shortCodeStrategy {
+	case "", ShortCodeShortID, ShortCodeNanoID, ShortCodeSequential:
+	default:
+		customlogger.Warn().Str("shortcode_strategy", shortCodeStrategy).Msg("Invalid SHORTCODE_STRATEGY value, defaulting to picking one from SHORTCODE_LENGTH and SHORTCODE_ALPHABET")
+		shortCodeStrategy = ""
+	}
+	GlobalAppConfig.ShortCodeStrategy = shortCodeStrategy
+	shortCodeLengthStr := getEnv("SHORTCODE_LENGTH", "0")
+	shortCodeLength, err := strconv.Atoi(shortCodeLengthStr)
+	if err != nil || shortCodeLength < 0 {
+		customlogger.Warn().Str("shortcode_length", shortCodeLengthStr).Msg("Invalid SHORTCODE_LENGTH value, defaulting to 0")
+		shortCodeLength = 0
+	}
+	GlobalAppConfig.ShortCodeLength = shortCodeLength
+	GlobalAppConfig.ShortCodeAlphabet = getEnv("SHORTCODE_ALPHABET", "")
+	shortCodeAttemptsStr := getEnv("SHORTCODE_ATTEMPTS", strconv.Itoa(DefaultShortCodeAttempts))
+	shortCodeAttempts, err := strconv.Atoi(shortCodeAttemptsStr)
+	if err != nil || shortCodeAttempts < 1 {
+		customlogger.Warn().Str("shortcode_attempts", shortCodeAttemptsStr).Msgf("Invalid SHORTCODE_ATTEMPTS value, defaulting to %d", DefaultShortCodeAttempts)
+		shortCodeAttempts = DefaultShortCodeAttempts
+	}
+	GlobalAppConfig.ShortCodeAttempts = shortCodeAttempts
+	caseInsensitiveStr := getEnv("CASE_INSENSITIVE_CODES", "false")
+	caseInsensitive, err := strconv.ParseBool(caseInsensitiveStr)
+	if err != nil {
+		customlogger.Warn().Str("case_insensitive_codes", caseInsensitiveStr).Msg("Invalid CASE_INSENSITIVE_CODES value, defaulting to false")
+		caseInsensitive = false
+	}
+	GlobalAppConfig.CaseInsensitiveCodes = caseInsensitive
+	profanityFilterStr := getEnv("PROFANITY_FILTER", "true")
+	profanityFilter, err := strconv.ParseBool(profanityFilterStr)
+	if err != nil {
+		customlogger.Warn().Str("profanity_filter", profanityFilterStr).Msg("Invalid PROFANITY_FILTER value, defaulting to true")
+		profanityFilter = true
+	}
+	GlobalAppConfig.ProfanityFilter = profanityFilter
+	GlobalAppConfig.ProfanityWords = nil
+	if wordsEnv := getEnv("PROFANITY_WORDS", ""); wordsEnv != "" {
+		for _, word := range strings.Split(wordsEnv, ",") {
+			if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+				GlobalAppConfig.ProfanityWords = append(GlobalAppConfig.ProfanityWords, word)
+			}
+		}
+	}
+	GlobalAppConfig.DestinationPolicy = getEnv("DESTINATION_POLICY", "")
+	rescanHoursStr := getEnv("DESTINATION_RESCAN_HOURS", "24")
+	rescanHours, err := strconv.Atoi(rescanHoursStr)
+	if err != nil || rescanHours < 0 {
+		customlogger.Warn().Str("destination_rescan_hours", rescanHoursStr).Msg("Invalid DESTINATION_RESCAN_HOURS value, defaulting to 24")
+		rescanHours = 24
+	}
+	GlobalAppConfig.DestinationRescanHours = rescanHours
+	GlobalAppConfig.DomainAllowlist = nil
+	if allowlistEnv := getEnv("DOMAIN_ALLOWLIST", ""); allowlistEnv != "" {
+		for _, domain := range strings.Split(allowlistEnv, ",") {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+				GlobalAppConfig.DomainAllowlist = append(GlobalAppConfig.DomainAllowlist, domain)
+			}
+		}
+	}
+	GlobalAppConfig.DomainDenylist = nil
+	if denylistEnv := getEnv("DOMAIN_DENYLIST", ""); denylistEnv != "" {
+		for _, domain := range strings.Split(denylistEnv, ",") {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+				GlobalAppConfig.DomainDenylist = append(GlobalAppConfig.DomainDenylist, domain)
+			}
+		}
+	}
+	GlobalAppConfig.CountryHeader = getEnv("COUNTRY_HEADER", "CF-IPCountry")
+
+	cacheMaxAgeStr := getEnv("REDIRECT_CACHE_MAX_AGE", "0")
+	cacheMaxAge, err := strconv.Atoi(cacheMaxAgeStr)
+	if err != nil || cacheMaxAge < 0 || cacheMaxAge > MaxRedirectCacheMaxAge {
+		customlogger.Warn().Str("redirect_cache_max_age", cacheMaxAgeStr).Msg("Invalid REDIRECT_CACHE_MAX_AGE value, defaulting to 0")
+		cacheMaxAge = 0
+	}
+	GlobalAppConfig.RedirectCacheMaxAge = cacheMaxAge
+
+	hideReferrerStr := getEnv("HIDE_REFERRER", "false")
+	hideReferrer, err := strconv.ParseBool(hideReferrerStr)
+	if err != nil {
+		customlogger.Warn().Str("hide_referrer", hideReferrerStr).Msg("Invalid HIDE_REFERRER value, defaulting to false")
+		hideReferrer = false
+	}
+	GlobalAppConfig.HideReferrer = hideReferrer
+
+	noIndexStr := getEnv("NOINDEX", "false")
+	noIndex, err := strconv.ParseBool(noIndexStr)
+	if err != nil {
+		customlogger.Warn().Str("noindex", noIndexStr).Msg("Invalid NOINDEX value, defaulting to false")
+		noIndex = false
+	}
+	GlobalAppConfig.NoIndex = noIndex
+
+	dedupeLinksStr := getEnv("DEDUPE_LINKS", "false")
+	dedupeLinks, err := strconv.ParseBool(dedupeLinksStr)
+	if err != nil {
+		customlogger.Warn().Str("dedupe_links", dedupeLinksStr).Msg("Invalid DEDUPE_LINKS value, defaulting to false")
+		dedupeLinks = false
+	}
+	GlobalAppConfig.DedupeLinks = dedupeLinks
+
+	GlobalAppConfig.NotFoundRedirectURL = getEnv("NOT_FOUND_REDIRECT_URL", "")
+	notFoundMode := strings.ToLower(getEnv("NOT_FOUND_MODE", NotFoundMode404))
+	switch notFoundMode {
+	case NotFoundMode404, NotFoundModeSearch:
+	case NotFoundModeRedirect:
+		if GlobalAppConfig.NotFoundRedirectURL == "" {
+			customlogger.Warn().Msg("NOT_FOUND_MODE is redirect but NOT_FOUND_REDIRECT_URL is empty, defaulting to 404")
+			notFoundMode = NotFoundMode404
+		}
+	default:
+		customlogger.Warn().Str("not_found_mode", notFoundMode).Msg("Invalid NOT_FOUND_MODE value, defaulting to 404")
+		notFoundMode = NotFoundMode404
+	}
+	GlobalAppConfig.NotFoundMode = notFoundMode
+
+	GlobalAppConfig.EventsWebhookURL = getEnv("EVENTS_WEBHOOK_URL", "")
+	eventsIntervalStr := getEnv("EVENTS_INTERVAL", "30")
+	eventsInterval, err := strconv.Atoi(eventsIntervalStr)
+	if err != nil || eventsInterval <= 0 {
+		customlogger.Warn().Str("events_interval", eventsIntervalStr).Msg("Invalid EVENTS_INTERVAL value, defaulting to 30")
+		eventsInterval = 30
+	}
+	GlobalAppConfig.EventsInterval = eventsInterval
+
+	GlobalAppConfig.GitHubWebhookSecret = getEnv("GITHUB_WEBHOOK_SECRET", "")
+	GlobalAppConfig.GitHubWebhookPreviousSecret = getEnv("GITHUB_WEBHOOK_PREVIOUS_SECRET", "")
+	GlobalAppConfig.GitHubReleaseAsset = getEnv("GITHUB_RELEASE_ASSET", "")
+
+	GlobalAppConfig.ClickWebhookURL = getEnv("CLICK_WEBHOOK_URL", "")
+	GlobalAppConfig.ClickWebhookSecret = getEnv("CLICK_WEBHOOK_SECRET", "")
+
+	cdnProvider := strings.ToLower(getEnv("CDN_PURGE_PROVIDER", ""))
+	if cdnProvider != "" && cdnProvider != CDNPurgeCloudflare && cdnProvider != CDNPurgeFastly {
+		customlogger.Warn().Str("cdn_purge_provider", cdnProvider).Msg("Invalid CDN_PURGE_PROVIDER value, disabling CDN purges")
+		cdnProvider = ""
+	}
+	GlobalAppConfig.CDNPurgeProvider = cdnProvider
+	GlobalAppConfig.CDNPurgeAPIToken = getEnv("CDN_PURGE_API_TOKEN", "")
+	GlobalAppConfig.CDNPurgeZoneID = getEnv("CDN_PURGE_ZONE_ID", "")
+
+	previewHoursStr := getEnv("PREVIEW_TOKEN_HOURS", "72")
+	previewHours, err := strconv.Atoi(previewHoursStr)
+	if err != nil || previewHours <= 0 {
+		customlogger.Warn().Str("preview_token_hours", previewHoursStr).Msg("Invalid PREVIEW_TOKEN_HOURS value, defaulting to 72")
+		previewHours = 72
+	}
+	GlobalAppConfig.PreviewTokenHours = previewHours
+
+	qrMaxSizeStr := getEnv("QR_MAX_SIZE", "1024")
+	qrMaxSize, err := strconv.Atoi(qrMaxSizeStr)
+	if err != nil || qrMaxSize < MinQRSize || qrMaxSize > MaxQRSize {
+		customlogger.Warn().Str("qr_max_size", qrMaxSizeStr).Msg("Invalid QR_MAX_SIZE value, defaulting to 1024")
+		qrMaxSize = 1024
+	}
+	GlobalAppConfig.QRMaxSize = qrMaxSize
+
+	qrRateLimitStr := getEnv("QR_RATE_LIMIT", "60")
+	qrRateLimit, err := strconv.Atoi(qrRateLimitStr)
+	if err != nil || qrRateLimit < 0 {
+		customlogger.Warn().Str("qr_rate_limit", qrRateLimitStr).Msg("Invalid QR_RATE_LIMIT value, defaulting to 60")
+		qrRateLimit = 60
+	}
+	GlobalAppConfig.QRRateLimit = qrRateLimit
+
+	anonShortenLimitStr := getEnv("ANON_SHORTEN_LIMIT_PER_HOUR", "30")
+	anonShortenLimit, err := strconv.Atoi(anonShortenLimitStr)
+	if err != nil || anonShortenLimit < 0 {
+		customlogger.Warn().Str("anon_shorten_limit_per_hour", anonShortenLimitStr).Msg("Invalid ANON_SHORTEN_LIMIT_PER_HOUR value, defaulting to 30")
+		anonShortenLimit = 30
+	}
+	GlobalAppConfig.AnonShortenLimitPerHour = anonShortenLimit
+	requireAuthStr := getEnv("REQUIRE_AUTH_TO_SHORTEN", "false")
+	requireAuth, err := strconv.ParseBool(requireAuthStr)
+	if err != nil {
+		customlogger.Warn().Str("require_auth_to_shorten", requireAuthStr).Msg("Invalid REQUIRE_AUTH_TO_SHORTEN value, defaulting to false")
+		requireAuth = false
+	}
+	GlobalAppConfig.RequireAuthToShorten = requireAuth
+
+	GlobalAppConfig.JWTSecret = getEnv("JWT_SECRET", "")
+	if secret := GlobalAppConfig.JWTSecret; secret != "" && len(secret) < MinJWTSecretLength {
+		customlogger.Warn().Int("length", len(secret)).Msgf("JWT_SECRET is shorter than %d bytes; session tokens may be forged", MinJWTSecretLength)
+	}
+	jwtTTLStr := getEnv("JWT_TTL_HOURS", "24")
+	jwtTTL, err := strconv.Atoi(jwtTTLStr)
+	if err != nil || jwtTTL <= 0 {
+		customlogger.Warn().Str("jwt_ttl_hours", jwtTTLStr).Msg("Invalid JWT_TTL_HOURS value, defaulting to 24")
+		jwtTTL = 24
+	}
+	GlobalAppConfig.JWTTTLHours = jwtTTL
+	GlobalAppConfig.JWTPreviousSecret = getEnv("JWT_PREVIOUS_SECRET", "")
+
+	GlobalAppConfig.AccessLog = getEnv("ACCESS_LOG", "")
+
+	conversionTrackingStr := getEnv("CONVERSION_TRACKING", "false")
+	conversionTracking, err := strconv.ParseBool(conversionTrackingStr)
+	if err != nil {
+		customlogger.Warn().Str("conversion_tracking", conversionTrackingStr).Msg("Invalid CONVERSION_TRACKING value, defaulting to false")
+		conversionTracking = false
+	}
+	GlobalAppConfig.ConversionTracking = conversionTracking
+	GlobalAppConfig.ClickIDParam = getEnv("CLICK_ID_PARAM", "")
+	conversionWindowStr := getEnv("CONVERSION_WINDOW_HOURS", "720")
+	conversionWindow, err := strconv.Atoi(conversionWindowStr)
+	if err != nil || conversionWindow <= 0 {
+		customlogger.Warn().Str("conversion_window_hours", conversionWindowStr).Msg("Invalid CONVERSION_WINDOW_HOURS value, defaulting to 720")
+		conversionWindow = 720
+	}
+	GlobalAppConfig.ConversionWindowHours = conversionWindow
+
+	visitorCookieStr := getEnv("VISITOR_COOKIE", "false")
+	visitorCookie, err := strconv.ParseBool(visitorCookieStr)
+	if err != nil {
+		customlogger.Warn().Str("visitor_cookie", visitorCookieStr).Msg("Invalid VISITOR_COOKIE value, defaulting to false")
+		visitorCookie = false
+	}
+	GlobalAppConfig.VisitorCookie = visitorCookie
+	visitorCookieDaysStr := getEnv("VISITOR_COOKIE_DAYS", "365")
+	visitorCookieDays, err := strconv.Atoi(visitorCookieDaysStr)
+	if err != nil || visitorCookieDays <= 0 {
+		customlogger.Warn().Str("visitor_cookie_days", visitorCookieDaysStr).Msg("Invalid VISITOR_COOKIE_DAYS value, defaulting to 365")
+		visitorCookieDays = 365
+	}
+	GlobalAppConfig.VisitorCookieDays = visitorCookieDays
+
+	handleCooldownStr := getEnv("HANDLE_COOLDOWN_DAYS", "30")
+	handleCooldown, err := strconv.Atoi(handleCooldownStr)
+	if err != nil || handleCooldown < 0 {
+		customlogger.Warn().Str("handle_cooldown_days", handleCooldownStr).Msg("Invalid HANDLE_COOLDOWN_DAYS value, defaulting to 30")
+		handleCooldown = 30
+	}
+	GlobalAppConfig.HandleCooldownDays = handleCooldown
+
+	transparencyLogStr := getEnv("TRANSPARENCY_LOG", "false")
+	transparencyLog, err := strconv.ParseBool(transparencyLogStr)
+	if err != nil {
+		customlogger.Warn().Str("transparency_log", transparencyLogStr).Msg("Invalid TRANSPARENCY_LOG value, defaulting to false")
+		transparencyLog = false
+	}
+	GlobalAppConfig.TransparencyLog = transparencyLog
+
+	fetchPageMetaStr := getEnv("FETCH_PAGE_META", "false")
+	fetchPageMeta, err := strconv.ParseBool(fetchPageMetaStr)
+	if err != nil {
+		customlogger.Warn().Str("fetch_page_meta", fetchPageMetaStr).Msg("Invalid FETCH_PAGE_META value, defaulting to false")
+		fetchPageMeta = false
+	}
+	GlobalAppConfig.FetchPageMeta = fetchPageMeta
+
+	crawlerPreviewsStr := getEnv("CRAWLER_PREVIEWS", "true")
+	crawlerPreviews, err := strconv.ParseBool(crawlerPreviewsStr)
+	if err != nil {
+		customlogger.Warn().Str("crawler_previews", crawlerPreviewsStr).Msg("Invalid CRAWLER_PREVIEWS value, defaulting to true")
+		crawlerPreviews = true
+	}
+	GlobalAppConfig.CrawlerPreviews = crawlerPreviews
+
+	instanceRegistryStr := getEnv("INSTANCE_REGISTRY", "false")
+	instanceRegistry, err := strconv.ParseBool(instanceRegistryStr)
+	if err != nil {
+		customlogger.Warn().Str("instance_registry", instanceRegistryStr).Msg("Invalid INSTANCE_REGISTRY value, defaulting to false")
+		instanceRegistry = false
+	}
+	GlobalAppConfig.InstanceRegistry = instanceRegistry
+	GlobalAppConfig.InstanceID = getEnv("INSTANCE_ID", "")
+	GlobalAppConfig.InstanceAddress = getEnv("INSTANCE_ADDRESS", "")
+	heartbeatStr := getEnv("INSTANCE_HEARTBEAT_SECONDS", "15")
+	heartbeat, err := strconv.Atoi(heartbeatStr)
+	if err != nil || heartbeat <= 0 {
+		customlogger.Warn().Str("instance_heartbeat_seconds", heartbeatStr).Msg("Invalid INSTANCE_HEARTBEAT_SECONDS value, defaulting to 15")
+		heartbeat = 15
+	}
+	GlobalAppConfig.InstanceHeartbeatSeconds = heartbeat
+
+	GlobalAppConfig.DestinationEncryptionKey = getEnv("DESTINATION_ENCRYPTION_KEY", "")
+	GlobalAppConfig.DestinationEncryptionKeyFile = getEnv("DESTINATION_ENCRYPTION_KEY_FILE", "")
+	GlobalAppConfig.DestinationEncryptionPreviousKey = getEnv("DESTINATION_ENCRYPTION_PREVIOUS_KEY", "")
+	GlobalAppConfig.DestinationEncryptionPreviousKeyFile = getEnv("DESTINATION_ENCRYPTION_PREVIOUS_KEY_FILE", "")
+
+	shortenQuotaStr := getEnv("API_KEY_SHORTEN_QUOTA", "1000")
+	shortenQuota, err := strconv.Atoi(shortenQuotaStr)
+	if err != nil || shortenQuota < 0 {
+		customlogger.Warn().Str("api_key_shorten_quota", shortenQuotaStr).Msg("Invalid API_KEY_SHORTEN_QUOTA value, defaulting to 1000")
+		shortenQuota = 1000
+	}
+	GlobalAppConfig.APIKeyShortenQuota = shortenQuota
+	statsQuotaStr := getEnv("API_KEY_STATS_QUOTA", "10000")
+	statsQuota, err := strconv.Atoi(statsQuotaStr)
+	if err != nil || statsQuota < 0 {
+		customlogger.Warn().Str("api_key_stats_quota", statsQuotaStr).Msg("Invalid API_KEY_STATS_QUOTA value, defaulting to 10000")
+		statsQuota = 10000
+	}
+	GlobalAppConfig.APIKeyStatsQuota = statsQuota
+
+	GlobalAppConfig.DropsDir = getEnv("DROPS_DIR", "")
+	dropMaxBytesStr := getEnv("DROP_MAX_BYTES", "10485760")
+	dropMaxBytes, err := strconv.ParseInt(dropMaxBytesStr, 10, 64)
+	if err != nil || dropMaxBytes <= 0 {
+		customlogger.Warn().Str("drop_max_bytes", dropMaxBytesStr).Msg("Invalid DROP_MAX_BYTES value, defaulting to 10 MiB")
+		dropMaxBytes = 10 << 20
+	}
+	GlobalAppConfig.DropMaxBytes = dropMaxBytes
+
+	scannerGuardStr := getEnv("SCANNER_GUARD", "true")
+	scannerGuard, err := strconv.ParseBool(scannerGuardStr)
+	if err != nil {
+		customlogger.Warn().Str("scanner_guard", scannerGuardStr).Msg("Invalid SCANNER_GUARD value, defaulting to true")
+		scannerGuard = true
+	}
+	GlobalAppConfig.ScannerGuard = scannerGuard
+	GlobalAppConfig.ScannerPaths = nil
+	if scannerPathsEnv := getEnv("SCANNER_PATHS", ""); scannerPathsEnv != "" {
+		for _, path := range strings.Split(scannerPathsEnv, ",") {
+			if path = strings.ToLower(strings.TrimSpace(path)); path != "" {
+				GlobalAppConfig.ScannerPaths = append(GlobalAppConfig.ScannerPaths, path)
+			}
+		}
+	}
+	scannerTarpitStr := getEnv("SCANNER_TARPIT_SECONDS", "0")
+	scannerTarpit, err := strconv.Atoi(scannerTarpitStr)
+	if err != nil || scannerTarpit < 0 || scannerTarpit > MaxScannerTarpitSeconds {
+		customlogger.Warn().Str("scanner_tarpit_seconds", scannerTarpitStr).Msg("Invalid SCANNER_TARPIT_SECONDS value, defaulting to 0")
+		scannerTarpit = 0
+	}
+	GlobalAppConfig.ScannerTarpitSeconds = scannerTarpit
+	scannerBanThresholdStr := getEnv("SCANNER_BAN_THRESHOLD", "0")
+	scannerBanThreshold, err := strconv.Atoi(scannerBanThresholdStr)
+	if err != nil || scannerBanThreshold < 0 {
+		customlogger.Warn().Str("scanner_ban_threshold", scannerBanThresholdStr).Msg("Invalid SCANNER_BAN_THRESHOLD value, defaulting to 0")
+		scannerBanThreshold = 0
+	}
+	GlobalAppConfig.ScannerBanThreshold = scannerBanThreshold
+	scannerBanMinutesStr := getEnv("SCANNER_BAN_MINUTES", "60")
+	scannerBanMinutes, err := strconv.Atoi(scannerBanMinutesStr)
+	if err != nil || scannerBanMinutes <= 0 {
+		customlogger.Warn().Str("scanner_ban_minutes", scannerBanMinutesStr).Msg("Invalid SCANNER_BAN_MINUTES value, defaulting to 60")
+		scannerBanMinutes = 60
+	}
+	GlobalAppConfig.ScannerBanMinutes = scannerBanMinutes
+
+	loadSheddingStr := getEnv("LOAD_SHEDDING", "false")
+	loadShedding, err := strconv.ParseBool(loadSheddingStr)
+	if err != nil {
+		customlogger.Warn().Str("load_shedding", loadSheddingStr).Msg("Invalid LOAD_SHEDDING value, defaulting to false")
+		loadShedding = false
+	}
+	GlobalAppConfig.LoadShedding = loadShedding
+	loadShedInFlightStr := getEnv("LOAD_SHED_MAX_IN_FLIGHT", "256")
+	loadShedInFlight, err := strconv.Atoi(loadShedInFlightStr)
+	if err != nil || loadShedInFlight < 0 {
+		customlogger.Warn().Str("load_shed_max_in_flight", loadShedInFlightStr).Msg("Invalid LOAD_SHED_MAX_IN_FLIGHT value, defaulting to 256")
+		loadShedInFlight = 256
+	}
+	GlobalAppConfig.LoadShedMaxInFlight = loadShedInFlight
+	loadShedLatencyStr := getEnv("LOAD_SHED_REDIRECT_LATENCY_MS", "250")
+	loadShedLatency, err := strconv.Atoi(loadShedLatencyStr)
+	if err != nil || loadShedLatency < 0 {
+		customlogger.Warn().Str("load_shed_redirect_latency_ms", loadShedLatencyStr).Msg("Invalid LOAD_SHED_REDIRECT_LATENCY_MS value, defaulting to 250")
+		loadShedLatency = 250
+	}
+	GlobalAppConfig.LoadShedRedirectLatencyMs = loadShedLatency
+
+	GlobalAppConfig.IPBlocklistSources = nil
+	if sourcesEnv := getEnv("IP_BLOCKLIST_SOURCES", ""); sourcesEnv != "" {
+		for _, source := range strings.Split(sourcesEnv, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				GlobalAppConfig.IPBlocklistSources = append(GlobalAppConfig.IPBlocklistSources, source)
+			}
+		}
+	}
+	blocklistRefreshStr := getEnv("IP_BLOCKLIST_REFRESH_MINUTES", "720")
+	blocklistRefresh, err := strconv.Atoi(blocklistRefreshStr)
+	if err != nil || blocklistRefresh < MinIPBlocklistRefreshMinutes {
+		customlogger.Warn().Str("ip_blocklist_refresh_minutes", blocklistRefreshStr).Msg("Invalid IP_BLOCKLIST_REFRESH_MINUTES value, defaulting to 720")
+		blocklistRefresh = 720
+	}
+	GlobalAppConfig.IPBlocklistRefreshMinutes = blocklistRefresh
+	blocklistAction := strings.ToLower(getEnv("IP_BLOCKLIST_ACTION", IPBlocklistActionDeny))
+	if blocklistAction != IPBlocklistActionDeny && blocklistAction != IPBlocklistActionChallenge {
+		customlogger.Warn().Str("ip_blocklist_action", blocklistAction).Msg("Invalid IP_BLOCKLIST_ACTION value, defaulting to deny")
+		blocklistAction = IPBlocklistActionDeny
+	}
+	GlobalAppConfig.IPBlocklistAction = blocklistAction
+	GlobalAppConfig.TrustedProxies = nil
+	if proxiesEnv := getEnv("TRUSTED_PROXIES", ""); proxiesEnv != "" {
+		for _, proxy := range strings.Split(proxiesEnv, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy == "" {
+				continue
+			}
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				addr, addrErr := netip.ParseAddr(proxy)
+				if addrErr != nil {
+					customlogger.Warn().Str("trusted_proxy", proxy).Msg("Invalid TRUSTED_PROXIES entry, ignoring it")
+					continue
+				}
+				prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+			}
+			GlobalAppConfig.TrustedProxies = append(GlobalAppConfig.TrustedProxies, prefix.Masked())
+		}
+	}
+
+	GlobalAppConfig.HostBlocklistSources = nil
+	if sourcesEnv := getEnv("HOST_BLOCKLIST_SOURCES", ""); sourcesEnv != "" {
+		for _, source := range strings.Split(sourcesEnv, ",") {
+			if source = strings.TrimSpace(source); source != "" {
+				GlobalAppConfig.HostBlocklistSources = append(GlobalAppConfig.HostBlocklistSources, source)
+			}
+		}
+	}
+	hostRefreshStr := getEnv("HOST_BLOCKLIST_REFRESH_MINUTES", "360")
+	hostRefresh, err := strconv.Atoi(hostRefreshStr)
+	if err != nil || hostRefresh < MinHostBlocklistRefreshMinutes {
+		customlogger.Warn().Str("host_blocklist_refresh_minutes", hostRefreshStr).Msg("Invalid HOST_BLOCKLIST_REFRESH_MINUTES value, defaulting to 360")
+		hostRefresh = 360
+	}
+	GlobalAppConfig.HostBlocklistRefreshMinutes = hostRefresh
+
+	safeBrowsingStr := getEnv("SAFE_BROWSING", "false")
+	safeBrowsing, err := strconv.ParseBool(safeBrowsingStr)
+	if err != nil {
+		customlogger.Warn().Str("safe_browsing", safeBrowsingStr).Msg("Invalid SAFE_BROWSING value, defaulting to false")
+		safeBrowsing = false
+	}
+	GlobalAppConfig.SafeBrowsingAPIKey = getEnv("SAFE_BROWSING_API_KEY", "")
+	if safeBrowsing && GlobalAppConfig.SafeBrowsingAPIKey == "" {
+		customlogger.Warn().Msg("SAFE_BROWSING is enabled but SAFE_BROWSING_API_KEY is not set, disabling Safe Browsing checks")
+		safeBrowsing = false
+	}
+	GlobalAppConfig.SafeBrowsing = safeBrowsing
+
+	GlobalAppConfig.TakedownAppealURL = getEnv("TAKEDOWN_APPEAL_URL", "")
+	GlobalAppConfig.TakedownWebhookURL = getEnv("TAKEDOWN_WEBHOOK_URL", "")
+	GlobalAppConfig.TakedownWebhookSecret = getEnv("TAKEDOWN_WEBHOOK_SECRET", "")
+	GlobalAppConfig.SMTPAddr = getEnv("SMTP_ADDR", "")
+	GlobalAppConfig.SMTPUsername = getEnv("SMTP_USERNAME", "")
+	GlobalAppConfig.SMTPPassword = getEnv("SMTP_PASSWORD", "")
+	GlobalAppConfig.SMTPFrom = getEnv("SMTP_FROM", "")
+	GlobalAppConfig.CampaignAlertWebhookSecret = getEnv("CAMPAIGN_ALERT_WEBHOOK_SECRET", "")
+
+	unwrapMaxHopsStr := getEnv("UNWRAP_MAX_HOPS", "0")
+	unwrapMaxHops, err := strconv.Atoi(unwrapMaxHopsStr)
+	if err != nil || unwrapMaxHops < 0 || unwrapMaxHops > MaxUnwrapHops {
+		customlogger.Warn().Str("unwrap_max_hops", unwrapMaxHopsStr).Msg("Invalid UNWRAP_MAX_HOPS value, defaulting to 0")
+		unwrapMaxHops = 0
+	}
+	GlobalAppConfig.UnwrapMaxHops = unwrapMaxHops
+	GlobalAppConfig.UnwrapShorteners = nil
+	if shortenersEnv := getEnv("UNWRAP_SHORTENERS", ""); shortenersEnv != "" {
+		for _, host := range strings.Split(shortenersEnv, ",") {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				GlobalAppConfig.UnwrapShorteners = append(GlobalAppConfig.UnwrapShorteners, host)
+			}
+		}
+	}
+	shortenerAction := strings.ToLower(getEnv("UNWRAP_SHORTENER_ACTION", UnwrapShortenerFlag))
+	if shortenerAction != UnwrapShortenerFlag && shortenerAction != UnwrapShortenerReject {
+		customlogger.Warn().Str("unwrap_shortener_action", shortenerAction).Msg("Invalid UNWRAP_SHORTENER_ACTION value, defaulting to flag")
+		shortenerAction = UnwrapShortenerFlag
+	}
+	GlobalAppConfig.UnwrapShortenerAction = shortenerAction
+
+	internalAction := strings.ToLower(getEnv("INTERNAL_LINK_ACTION", InternalLinkResolve))
+	if internalAction != InternalLinkResolve && internalAction != InternalLinkReject {
+		customlogger.Warn().Str("internal_link_action", internalAction).Msg("Invalid INTERNAL_LINK_ACTION value, defaulting to resolve")
+		internalAction = InternalLinkResolve
+	}
+	GlobalAppConfig.InternalLinkAction = internalAction
+	internalMaxHopsStr := getEnv("INTERNAL_LINK_MAX_HOPS", "3")
+	internalMaxHops, err := strconv.Atoi(internalMaxHopsStr)
+	if err != nil || internalMaxHops < 1 || internalMaxHops > MaxInternalLinkHops {
+		customlogger.Warn().Str("internal_link_max_hops", internalMaxHopsStr).Msg("Invalid INTERNAL_LINK_MAX_HOPS value, defaulting to 3")
+		internalMaxHops = 3
+	}
+	GlobalAppConfig.InternalLinkMaxHops = internalMaxHops
+
 	customlogger.Info().Msg("Application configuration loaded")
 }
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadEnvServerTuning(t *testing.T) {
+	original := GlobalAppConfig
+	defer func() { GlobalAppConfig = original }()
+
+	t.Setenv("SERVER_MAX_HEADER_BYTES", "65536")
+	t.Setenv("SERVER_READ_HEADER_TIMEOUT_SECONDS", "5")
+	t.Setenv("SERVER_IDLE_TIMEOUT_SECONDS", "0")
+	t.Setenv("SERVER_H2C", "true")
+	LoadEnv()
+	assert.Equal(t, 65536, GlobalAppConfig.ServerMaxHeaderBytes)
+	assert.Equal(t, 5, GlobalAppConfig.ServerReadHeaderTimeout)
+	assert.Equal(t, 0, GlobalAppConfig.ServerIdleTimeout, "0 keeps idle connections until the client closes them")
+	assert.True(t, GlobalAppConfig.ServerH2C)
+
+	// Invalid values fall back to the defaults rather than weakening the server.
+	t.Setenv("SERVER_MAX_HEADER_BYTES", "1024")
+	t.Setenv("SERVER_READ_HEADER_TIMEOUT_SECONDS", "0")
+	t.Setenv("SERVER_IDLE_TIMEOUT_SECONDS", "-1")
+	t.Setenv("SERVER_H2C", "maybe")
+	LoadEnv()
+	assert.Equal(t, 16384, GlobalAppConfig.ServerMaxHeaderBytes)
+	assert.Equal(t, 10, GlobalAppConfig.ServerReadHeaderTimeout)
+	assert.Equal(t, 60, GlobalAppConfig.ServerIdleTimeout)
+	assert.False(t, GlobalAppConfig.ServerH2C)
+}
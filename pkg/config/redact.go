@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// redactedValue replaces secret values in the output of Redacted.
+const redactedValue = "[REDACTED]"
+
+// Redacted returns the configuration as a field-name-keyed map suitable for display,
+// with every field tagged redact:"true" masked. Empty secrets are reported as empty so
+// operators can still tell whether a secret was loaded at all; secret lists keep their length.
+func (c AppConfig) Redacted() map[string]interface{} {
+	out := make(map[string]interface{})
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+		if field.Tag.Get("redact") != "true" {
+			out[field.Name] = value.Interface()
+			continue
+		}
+
+		switch value.Kind() {
+		case reflect.Slice, reflect.Map:
+			out[field.Name] = fmt.Sprintf("%s (%d entries)", redactedValue, value.Len())
+		default:
+			if value.IsZero() {
+				out[field.Name] = ""
+			} else {
+				out[field.Name] = redactedValue
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,24 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactedMasksSecrets(t *testing.T) {
+	cfg := AppConfig{
+		Port:               "3000",
+		RedisPW:            "hunter2",
+		ValidAuthCodes:     []string{"a", "b"},
+		SafeBrowsingAPIKey: "AIzaSecret",
+	}
+
+	out := cfg.Redacted()
+	assert.Equal(t, "3000", out["Port"])
+	assert.Equal(t, redactedValue, out["RedisPW"])
+	assert.Equal(t, "[REDACTED] (2 entries)", out["ValidAuthCodes"])
+	assert.Equal(t, "[REDACTED] (0 entries)", out["AdminAuthCodes"])
+	assert.Equal(t, redactedValue, out["SafeBrowsingAPIKey"])
+	assert.NotContains(t, out, "hunter2")
+}
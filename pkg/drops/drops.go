@@ -0,0 +1,138 @@
+// Package drops stores files uploaded to be shared through a short link ("drops")
+// and removes them once their link has expired.
+package drops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/storage"
+)
+
+// cleanupInterval is how often files of expired drops are removed.
+const cleanupInterval = time.Hour
+
+// ErrInvalidKey is returned for file keys that are not lowercase hex strings.
+var ErrInvalidKey = errors.New("invalid drop file key")
+
+// keyPattern matches valid file keys. Keys are generated, never user input, but are
+// still checked so a corrupt record can never address a path outside the store.
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{16,64}$`)
+
+// Store keeps the contents of dropped files. DiskStore is the built-in backend; an
+// object store such as S3 can be plugged in by implementing the same interface.
+type Store interface {
+	// Save stores the contents of r under key.
+	Save(ctx context.Context, key string, r io.Reader) error
+	// Open returns the contents stored under key.
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes the contents stored under key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// DiskStore keeps dropped files in a directory on the local filesystem.
+type DiskStore struct {
+	Dir string
+}
+
+// NewDiskStore returns a DiskStore for dir, creating the directory if needed.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("create drops directory: %w", err)
+	}
+	return &DiskStore{Dir: dir}, nil
+}
+
+func (s *DiskStore) path(key string) (string, error) {
+	if !keyPattern.MatchString(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Dir, key), nil
+}
+
+// Save implements Store. The file is written under a temporary name and renamed into
+// place, so a failed upload never leaves a truncated file behind.
+func (s *DiskStore) Save(ctx context.Context, key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Open implements Store.
+func (s *DiskStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete implements Store.
+func (s *DiskStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Cleanup removes the files and records of drops whose link has expired and returns
+// how many were removed.
+func Cleanup(ctx context.Context, store Store) (int, error) {
+	expired, err := storage.ExpiredDrops(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for i, drop := range expired {
+		if err := store.Delete(ctx, drop.FileKey); err != nil {
+			return i, err
+		}
+		if err := storage.DeleteDrop(ctx, drop.ShortCode); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// Run removes expired drops every cleanupInterval until ctx is cancelled.
+func Run(ctx context.Context, store Store) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := Cleanup(ctx, store); err != nil {
+			customlogger.Error().Err(err).Msg("Failed to clean up expired drops")
+		} else if n > 0 {
+			customlogger.Info().Int("count", n).Msg("Removed expired drops")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package drops
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestDiskStoreRoundTrip(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+	key := "0123456789abcdef0123456789abcdef"
+
+	require.NoError(t, store.Save(ctx, key, strings.NewReader("hello drop")))
+	f, err := store.Open(ctx, key)
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	assert.Equal(t, "hello drop", string(content))
+
+	require.NoError(t, store.Delete(ctx, key))
+	_, err = store.Open(ctx, key)
+	assert.Error(t, err)
+	assert.NoError(t, store.Delete(ctx, key), "deleting a missing file is not an error")
+
+	for _, bad := range []string{"", "../etc/passwd", "ABCDEF0123456789", "short"} {
+		assert.Error(t, store.Save(ctx, bad, strings.NewReader("x")), bad)
+	}
+}
+
+func TestCleanupRemovesExpiredDrops(t *testing.T) {
+	cfg := config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}
+	require.NoError(t, storage.InitSQLite(cfg))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	store, err := NewDiskStore(t.TempDir())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	drops := []struct {
+		code, key string
+		expiresAt *time.Time
+	}{
+		{"gone", "00000000000000a1", &past},
+		{"live", "00000000000000b2", &future},
+		{"forever", "00000000000000c3", nil},
+	}
+	for _, d := range drops {
+		code, key, expiresAt := d.code, d.key, d.expiresAt
+		require.NoError(t, store.Save(ctx, key, strings.NewReader(code)))
+		require.NoError(t, storage.CreateLink(ctx, models.Link{ShortCode: code, LongURL: "https://example.com/d/" + code, CreatedAt: now, ExpiresAt: expiresAt}))
+		require.NoError(t, storage.SaveDrop(ctx, models.Drop{ShortCode: code, FileKey: key, Filename: code + ".txt", ContentType: "text/plain", Size: int64(len(code)), CreatedAt: now}))
+	}
+
+	n, err := Cleanup(ctx, store)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = storage.GetDrop(ctx, "gone")
+	assert.ErrorIs(t, err, storage.ErrDropNotFound)
+	for _, code := range []string{"live", "forever"} {
+		drop, err := storage.GetDrop(ctx, code)
+		require.NoError(t, err)
+		f, err := store.Open(ctx, drop.FileKey)
+		require.NoError(t, err)
+		f.Close()
+	}
+}
@@ -0,0 +1,159 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+)
+
+const (
+	// campaignAlertQueueSize is how many triggered alerts may wait for delivery.
+	campaignAlertQueueSize = 100
+	// campaignAlertAttempts is how often each channel of an alert is tried before it is given up.
+	campaignAlertAttempts = 4
+)
+
+// CampaignAlertNotifier reports campaigns crossing a click-count threshold to the
+// webhook and email address of the alert. Alerts are delivered by a background worker
+// and retried with backoff, so the redirect whose click triggered one never waits.
+type CampaignAlertNotifier struct {
+	Mailer
+	Secret     string
+	Client     *http.Client
+	RetryDelay time.Duration
+	queue      chan models.CampaignAlert
+}
+
+// NewCampaignAlertNotifier returns a CampaignAlertNotifier using the SMTP settings of
+// cfg, signing webhooks with CAMPAIGN_ALERT_WEBHOOK_SECRET when set.
+func NewCampaignAlertNotifier(cfg config.AppConfig) *CampaignAlertNotifier {
+	return &CampaignAlertNotifier{
+		Mailer:     NewMailer(cfg),
+		Secret:     cfg.CampaignAlertWebhookSecret,
+		Client:     &http.Client{Timeout: webhookTimeout},
+		RetryDelay: clickRetryDelay,
+		queue:      make(chan models.CampaignAlert, campaignAlertQueueSize),
+	}
+}
+
+// Notify queues a triggered alert for delivery without blocking. It reports false when
+// the queue is full and the alert was dropped.
+func (n *CampaignAlertNotifier) Notify(alert models.CampaignAlert) bool {
+	select {
+	case n.queue <- alert:
+		return true
+	default:
+		customlogger.Warn().Int64("alert_id", alert.ID).Msg("Campaign alert queue full, dropping alert")
+		return false
+	}
+}
+
+// Run delivers queued alerts until ctx is cancelled.
+func (n *CampaignAlertNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case alert := <-n.queue:
+			n.deliver(ctx, alert)
+		}
+	}
+}
+
+// deliver sends alert to its webhook and email address, whichever are set, logging
+// the channels that failed.
+func (n *CampaignAlertNotifier) deliver(ctx context.Context, alert models.CampaignAlert) {
+	event := models.CampaignAlertEvent{
+		AlertID:   alert.ID,
+		Campaign:  alert.Campaign,
+		Threshold: alert.Threshold,
+		Clicks:    alert.Clicks,
+	}
+	if alert.TriggeredAt != nil {
+		event.TriggeredAt = *alert.TriggeredAt
+	}
+	if alert.WebhookURL != "" {
+		if err := n.retry(ctx, func() error { return n.postWebhook(ctx, alert.WebhookURL, event) }); err != nil {
+			customlogger.Error().Err(err).Int64("alert_id", alert.ID).Msg("Failed to deliver campaign alert webhook")
+		}
+	}
+	if alert.Email != "" {
+		send := func() error {
+			return n.Mailer.Send(alert.Email, func(from, to *mail.Address) []byte {
+				return campaignAlertEmail(from, to, event)
+			})
+		}
+		if err := n.retry(ctx, send); err != nil {
+			customlogger.Error().Err(err).Int64("alert_id", alert.ID).Msg("Failed to email campaign alert")
+		}
+	}
+}
+
+// retry calls attempt up to campaignAlertAttempts times, doubling the delay between
+// attempts, and returns its last error.
+func (n *CampaignAlertNotifier) retry(ctx context.Context, attempt func() error) error {
+	delay := n.RetryDelay
+	var err error
+	for i := 1; i <= campaignAlertAttempts; i++ {
+		if err = attempt(); err == nil || i == campaignAlertAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return err
+}
+
+// postWebhook POSTs event as JSON to url, signed like click events. Any non-2xx
+// response is a failure.
+func (n *CampaignAlertNotifier) postWebhook(ctx context.Context, url string, event models.CampaignAlertEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		req.Header.Set(ClickSignatureHeader, SignBody(n.Secret, body))
+	}
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("campaign alert webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// campaignAlertEmail formats event as a plain text email.
+func campaignAlertEmail(from, to *mail.Address, event models.CampaignAlertEvent) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: Campaign %s reached %d clicks\r\n", event.Campaign, event.Threshold)
+	fmt.Fprintf(&msg, "Date: %s\r\n", event.TriggeredAt.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&msg, "The links of campaign %s have been clicked %d times, crossing the alert threshold of %d clicks, at %s.\r\n",
+		event.Campaign, event.Clicks, event.Threshold, event.TriggeredAt.UTC().Format("2006-01-02 15:04 UTC"))
+	return []byte(msg.String())
+}
@@ -0,0 +1,70 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+func TestCampaignAlertNotifier(t *testing.T) {
+	var attempts int32
+	received := make(chan models.CampaignAlertEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, SignBody("s3cret", body), r.Header.Get(ClickSignatureHeader))
+		if atomic.AddInt32(&attempts, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event models.CampaignAlertEvent
+		json.Unmarshal(body, &event)
+		received <- event
+	}))
+	defer server.Close()
+
+	n := NewCampaignAlertNotifier(config.AppConfig{
+		CampaignAlertWebhookSecret: "s3cret",
+		SMTPAddr:                   "mail.example.com:25",
+		SMTPFrom:                   "riid.me <alerts@example.com>",
+	})
+	n.RetryDelay = time.Millisecond
+	emailed := make(chan string, 1)
+	n.SendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Nil(t, a, "no SMTP username, no auth")
+		assert.Equal(t, []string{"ads@example.com"}, to)
+		emailed <- string(msg)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go n.Run(ctx)
+
+	triggeredAt := time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)
+	require.True(t, n.Notify(models.CampaignAlert{
+		ID: 7, Campaign: "paid", Threshold: 10000, Clicks: 10000,
+		WebhookURL: server.URL, Email: "ads@example.com", TriggeredAt: &triggeredAt,
+	}))
+	select {
+	case event := <-received:
+		assert.Equal(t, models.CampaignAlertEvent{AlertID: 7, Campaign: "paid", Threshold: 10000, Clicks: 10000, TriggeredAt: triggeredAt}, event)
+	case <-time.After(5 * time.Second):
+		t.Fatal("campaign alert webhook was not delivered")
+	}
+	select {
+	case msg := <-emailed:
+		assert.Contains(t, msg, "Subject: Campaign paid reached 10000 clicks\r\n")
+	case <-time.After(5 * time.Second):
+		t.Fatal("campaign alert was not emailed")
+	}
+}
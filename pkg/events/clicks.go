@@ -0,0 +1,133 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+)
+
+const (
+	// ClickSignatureHeader carries the HMAC-SHA256 signature of a click event body,
+	// formatted as "sha256=<hex>".
+	ClickSignatureHeader = "X-Riidme-Signature"
+	// clickQueueSize is how many click events may wait for delivery before new ones are dropped.
+	clickQueueSize = 1000
+	// clickDeliveryAttempts is how often a click event is tried before it is given up.
+	clickDeliveryAttempts = 4
+	// clickRetryDelay is the wait before the first retry; it doubles with every attempt.
+	clickRetryDelay = 2 * time.Second
+)
+
+// ClickNotifier POSTs a signed ClickEvent to a webhook for every click on links
+// flagged notify_each_click. Events are delivered by a background worker and retried
+// with backoff, so redirects never wait for the webhook; when the queue is full new
+// events are dropped.
+type ClickNotifier struct {
+	URL        string
+	Secret     string
+	Client     *http.Client
+	RetryDelay time.Duration
+	queue      chan models.ClickEvent
+}
+
+// NewClickNotifier returns a ClickNotifier for url, signing events with secret when set.
+func NewClickNotifier(url, secret string) *ClickNotifier {
+	return &ClickNotifier{
+		URL:        url,
+		Secret:     secret,
+		Client:     &http.Client{Timeout: webhookTimeout},
+		RetryDelay: clickRetryDelay,
+		queue:      make(chan models.ClickEvent, clickQueueSize),
+	}
+}
+
+// Notify queues event for delivery without blocking. It reports false when the queue
+// is full and the event was dropped.
+func (n *ClickNotifier) Notify(event models.ClickEvent) bool {
+	select {
+	case n.queue <- event:
+		return true
+	default:
+		customlogger.Warn().Str("short_code", event.ShortCode).Msg("Click webhook queue full, dropping click event")
+		return false
+	}
+}
+
+// Run delivers queued click events until ctx is cancelled.
+func (n *ClickNotifier) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-n.queue:
+			if err := n.deliverWithRetry(ctx, event); err != nil {
+				customlogger.Error().Err(err).Str("short_code", event.ShortCode).Msg("Failed to deliver click event")
+			}
+		}
+	}
+}
+
+// deliverWithRetry tries to deliver event up to clickDeliveryAttempts times, doubling
+// the delay between attempts.
+func (n *ClickNotifier) deliverWithRetry(ctx context.Context, event models.ClickEvent) error {
+	delay := n.RetryDelay
+	var err error
+	for attempt := 1; attempt <= clickDeliveryAttempts; attempt++ {
+		if err = n.deliver(ctx, event); err == nil {
+			return nil
+		}
+		if attempt == clickDeliveryAttempts {
+			break
+		}
+		customlogger.Warn().Err(err).Str("short_code", event.ShortCode).Int("attempt", attempt).Msg("Click event delivery failed, retrying")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	return err
+}
+
+// deliver makes a single delivery attempt. Any non-2xx response is a failure.
+func (n *ClickNotifier) deliver(ctx context.Context, event models.ClickEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Secret != "" {
+		req.Header.Set(ClickSignatureHeader, SignBody(n.Secret, body))
+	}
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("click webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignBody returns the "sha256=<hex>" HMAC-SHA256 signature of body under secret,
+// which receivers recompute over the raw request body to authenticate an event.
+func SignBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestClickNotifierSignsAndRetries(t *testing.T) {
+	var attempts int32
+	received := make(chan models.ClickEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, SignBody("s3cret", body), r.Header.Get(ClickSignatureHeader))
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var event models.ClickEvent
+		json.Unmarshal(body, &event)
+		received <- event
+	}))
+	defer server.Close()
+
+	notifier := NewClickNotifier(server.URL, "s3cret")
+	notifier.RetryDelay = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go notifier.Run(ctx)
+
+	require.True(t, notifier.Notify(models.ClickEvent{ShortCode: "demo", Country: "DE"}))
+	select {
+	case event := <-received:
+		assert.Equal(t, "demo", event.ShortCode)
+		assert.Equal(t, "DE", event.Country)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	case <-time.After(5 * time.Second):
+		t.Fatal("click event was not delivered")
+	}
+}
+
+func TestClickNotifierDropsWhenQueueFull(t *testing.T) {
+	notifier := NewClickNotifier("http://127.0.0.1:0", "")
+	for i := 0; i < clickQueueSize; i++ {
+		require.True(t, notifier.Notify(models.ClickEvent{ShortCode: "a"}))
+	}
+	assert.False(t, notifier.Notify(models.ClickEvent{ShortCode: "a"}))
+}
@@ -0,0 +1,125 @@
+// Package events delivers the outbox of link lifecycle events kept by the storage
+// layer. A background loop records "expired" events as links expire and, when a
+// publisher is configured, pushes new events to it in order, at least once.
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// publisherConsumer is the outbox cursor name used by the configured publisher.
+	publisherConsumer = "publisher"
+	// publishBatchSize is the most events sent to the publisher at once.
+	publishBatchSize = 100
+	// webhookTimeout bounds a single webhook delivery.
+	webhookTimeout = 10 * time.Second
+)
+
+// Publisher sends a batch of outbox events to a message bus or other downstream
+// system. Returning nil acknowledges the whole batch.
+type Publisher interface {
+	Publish(ctx context.Context, events []models.LinkEvent) error
+}
+
+// WebhookPublisher publishes events by POSTing them as {"events": [...]} to a URL,
+// e.g. an HTTP ingestion endpoint of a message bus. Any non-2xx response is a failure
+// and the batch is retried on the next run.
+type WebhookPublisher struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookPublisher returns a WebhookPublisher for url.
+func NewWebhookPublisher(url string) *WebhookPublisher {
+	return &WebhookPublisher{URL: url, Client: &http.Client{Timeout: webhookTimeout}}
+}
+
+// Publish implements Publisher.
+func (p *WebhookPublisher) Publish(ctx context.Context, events []models.LinkEvent) error {
+	body, err := json.Marshal(map[string]interface{}{"events": events})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Run records expired-link events and publishes pending events every interval until
+// ctx is cancelled. pub may be nil, in which case events are only kept in the outbox
+// for the export endpoint.
+func Run(ctx context.Context, pub Publisher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		runOnce(ctx, pub)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce performs a single sweep and publish pass, logging failures.
+func runOnce(ctx context.Context, pub Publisher) {
+	if n, err := storage.RecordExpiredLinkEvents(ctx); err != nil {
+		customlogger.Error().Err(err).Msg("Failed to record expired link events")
+	} else if n > 0 {
+		customlogger.Info().Int("count", n).Msg("Recorded expired link events")
+	}
+
+	if pub == nil {
+		return
+	}
+	if err := PublishPending(ctx, pub); err != nil {
+		customlogger.Error().Err(err).Msg("Failed to publish link events")
+	}
+}
+
+// PublishPending sends every event after the publisher's cursor to pub, in batches,
+// advancing the cursor after each acknowledged batch.
+func PublishPending(ctx context.Context, pub Publisher) error {
+	after, err := storage.OutboxCursor(ctx, publisherConsumer)
+	if err != nil {
+		return err
+	}
+	for {
+		batch, err := storage.ListLinkEvents(ctx, after, publishBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := pub.Publish(ctx, batch); err != nil {
+			return err
+		}
+		after = batch[len(batch)-1].ID
+		if err := storage.SetOutboxCursor(ctx, publisherConsumer, after); err != nil {
+			return err
+		}
+		customlogger.Info().Int("count", len(batch)).Int64("last_event_id", after).Msg("Published link events")
+	}
+}
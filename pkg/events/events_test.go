@@ -0,0 +1,76 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// recordingPublisher collects published batches and can be made to fail.
+type recordingPublisher struct {
+	batches [][]models.LinkEvent
+	err     error
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, events []models.LinkEvent) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.batches = append(p.batches, events)
+	return nil
+}
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	cfg := config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}
+	require.NoError(t, storage.InitSQLite(cfg))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+}
+
+func TestPublishPendingAdvancesCursor(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, storage.CreateLink(ctx, models.Link{ShortCode: "a", LongURL: "https://example.com", CreatedAt: time.Now().UTC()}))
+
+	failing := &recordingPublisher{err: errors.New("bus unavailable")}
+	assert.Error(t, PublishPending(ctx, failing))
+
+	pub := &recordingPublisher{}
+	require.NoError(t, PublishPending(ctx, pub))
+	require.Len(t, pub.batches, 1)
+	assert.Equal(t, "a", pub.batches[0][0].ShortCode)
+
+	require.NoError(t, storage.CreateLink(ctx, models.Link{ShortCode: "b", LongURL: "https://example.com", CreatedAt: time.Now().UTC()}))
+	require.NoError(t, PublishPending(ctx, pub))
+	require.Len(t, pub.batches, 2)
+	assert.Len(t, pub.batches[1], 1, "already published events are not sent again")
+	assert.Equal(t, "b", pub.batches[1][0].ShortCode)
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	var received map[string][]models.LinkEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+		if len(received["events"]) > 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	pub := NewWebhookPublisher(server.URL)
+	assert.NoError(t, pub.Publish(context.Background(), []models.LinkEvent{{ID: 1, Type: models.LinkEventCreated, ShortCode: "a"}}))
+	assert.Equal(t, "a", received["events"][0].ShortCode)
+
+	assert.Error(t, pub.Publish(context.Background(), []models.LinkEvent{{ID: 2}, {ID: 3}}))
+}
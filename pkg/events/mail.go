@@ -0,0 +1,56 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+
+	"riid.me/pkg/config"
+)
+
+// Mailer sends the emails of the notifiers through the configured SMTP server.
+type Mailer struct {
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	// SendMail sends an email; it is smtp.SendMail, replaceable in tests.
+	SendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewMailer returns a Mailer using the SMTP settings of cfg.
+func NewMailer(cfg config.AppConfig) Mailer {
+	return Mailer{
+		SMTPAddr:     cfg.SMTPAddr,
+		SMTPUsername: cfg.SMTPUsername,
+		SMTPPassword: cfg.SMTPPassword,
+		From:         cfg.SMTPFrom,
+		SendMail:     smtp.SendMail,
+	}
+}
+
+// Send emails the message compose formats for the sender and recipient to email.
+func (m Mailer) Send(email string, compose func(from, to *mail.Address) []byte) error {
+	if m.SMTPAddr == "" {
+		return errors.New("SMTP is not configured")
+	}
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender address: %w", err)
+	}
+	to, err := mail.ParseAddress(email)
+	if err != nil {
+		return fmt.Errorf("invalid recipient address: %w", err)
+	}
+	var auth smtp.Auth
+	if m.SMTPUsername != "" {
+		host, _, err := net.SplitHostPort(m.SMTPAddr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", m.SMTPUsername, m.SMTPPassword, host)
+	}
+	return m.SendMail(m.SMTPAddr, auth, from.Address, []string{to.Address}, compose(from, to))
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+// TakedownNotifier tells the owners of links disabled for abuse about the takedown:
+// by email, to the address the admin gave, and through a webhook for systems that
+// know the owners.
+type TakedownNotifier struct {
+	Mailer
+	WebhookURL    string
+	WebhookSecret string
+	Client        *http.Client
+}
+
+// NewTakedownNotifier returns a TakedownNotifier using the webhook and SMTP settings of cfg.
+func NewTakedownNotifier(cfg config.AppConfig) *TakedownNotifier {
+	return &TakedownNotifier{
+		Mailer:        NewMailer(cfg),
+		WebhookURL:    cfg.TakedownWebhookURL,
+		WebhookSecret: cfg.TakedownWebhookSecret,
+		Client:        &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Notify sends notice to the webhook, if one is configured, and emails it to email,
+// if given and SMTP is configured. It returns the channels ("webhook", "email") the
+// notice was delivered on, and the errors of those that failed.
+func (n *TakedownNotifier) Notify(ctx context.Context, notice models.TakedownNotice, email string) ([]string, error) {
+	notified := []string{}
+	var errs []error
+	if n.WebhookURL != "" {
+		if err := n.postWebhook(ctx, notice); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		} else {
+			notified = append(notified, "webhook")
+		}
+	}
+	if email != "" {
+		if err := n.sendEmail(notice, email); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		} else {
+			notified = append(notified, "email")
+		}
+	}
+	return notified, errors.Join(errs...)
+}
+
+// postWebhook POSTs notice as JSON, signed like click events. Any non-2xx response
+// is a failure.
+func (n *TakedownNotifier) postWebhook(ctx context.Context, notice models.TakedownNotice) error {
+	body, err := json.Marshal(notice)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.WebhookSecret != "" {
+		req.Header.Set(ClickSignatureHeader, SignBody(n.WebhookSecret, body))
+	}
+	resp, err := n.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("takedown webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sendEmail emails notice to the given address through the configured SMTP server.
+func (n *TakedownNotifier) sendEmail(notice models.TakedownNotice, email string) error {
+	return n.Mailer.Send(email, func(from, to *mail.Address) []byte {
+		return takedownEmail(from, to, notice)
+	})
+}
+
+// takedownEmail formats notice as a plain text email.
+func takedownEmail(from, to *mail.Address, notice models.TakedownNotice) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: Your short link %s has been disabled\r\n", notice.ShortURL)
+	fmt.Fprintf(&msg, "Date: %s\r\n", notice.DisabledAt.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&msg, "Your short link %s was disabled for violating our acceptable use policy", notice.ShortURL)
+	if notice.Category != "" {
+		fmt.Fprintf(&msg, " (%s)", notice.Category)
+	}
+	msg.WriteString(".\r\n\r\nReason:\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(notice.Reason, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n\r\nVisitors of the link now see a notice instead of being redirected.\r\n")
+	if notice.AppealURL != "" {
+		fmt.Fprintf(&msg, "If you believe this is a mistake, you can appeal at %s\r\n", notice.AppealURL)
+	}
+	return []byte(msg.String())
+}
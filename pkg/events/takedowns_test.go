@@ -0,0 +1,71 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+func TestTakedownNotifier(t *testing.T) {
+	var received models.TakedownNotice
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(ClickSignatureHeader)
+		assert.Equal(t, SignBody("s3cret", body), signature)
+		json.Unmarshal(body, &received)
+	}))
+	defer server.Close()
+
+	n := NewTakedownNotifier(config.AppConfig{
+		TakedownWebhookURL:    server.URL,
+		TakedownWebhookSecret: "s3cret",
+		SMTPAddr:              "mail.example.com:587",
+		SMTPUsername:          "riid",
+		SMTPFrom:              "Abuse Desk <abuse@example.com>",
+	})
+	var mailFrom string
+	var mailTo []string
+	var message string
+	n.SendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "mail.example.com:587", addr)
+		assert.NotNil(t, a)
+		mailFrom, mailTo, message = from, to, string(msg)
+		return nil
+	}
+
+	notice := models.TakedownNotice{
+		ShortCode:  "abc",
+		ShortURL:   "https://riid.me/abc",
+		Reason:     "Phishing page\nimpersonating a bank",
+		Category:   "phishing",
+		DisabledAt: time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC),
+		AppealURL:  "https://example.com/appeal/abc",
+	}
+	notified, err := n.Notify(context.Background(), notice, "owner@example.org")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"webhook", "email"}, notified)
+	assert.Equal(t, notice, received)
+	assert.NotEmpty(t, signature)
+
+	assert.Equal(t, "abuse@example.com", mailFrom)
+	assert.Equal(t, []string{"owner@example.org"}, mailTo)
+	assert.Contains(t, message, "Subject: Your short link https://riid.me/abc has been disabled\r\n")
+	assert.Contains(t, message, "Phishing page\r\nimpersonating a bank")
+	assert.Contains(t, message, "appeal at https://example.com/appeal/abc")
+
+	n.SMTPAddr = ""
+	notified, err = n.Notify(context.Background(), notice, "owner@example.org")
+	assert.Error(t, err, "emails need SMTP")
+	assert.Equal(t, []string{"webhook"}, notified, "the webhook is still notified")
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	AccessLogWriter = &buf
+	defer func() { AccessLogWriter = nil }()
+
+	handler := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://example.com", http.StatusMovedPermanently)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/abc?src=mail", nil)
+	req.RemoteAddr = "203.0.113.7:5000"
+	req.Header.Set("Referer", "https://news.example.com/")
+	req.Header.Set("User-Agent", `Evil "agent"`+"\n")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Regexp(t, `^203\.0\.113\.7 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /abc\?src=mail HTTP/1\.1" 301 \d+ "https://news\.example\.com/" "Evil \\"agent\\"\\x0a"\n$`, buf.String())
+}
+
+func TestCombinedLogLine(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/gone", nil)
+	req.RemoteAddr = "198.51.100.1:1234"
+	at := time.Date(2026, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*3600))
+	assert.Equal(t, `198.51.100.1 - - [10/Oct/2026:13:55:36 -0700] "GET /gone HTTP/1.1" 410 - "-" "-"`+"\n", combinedLogLine(req, http.StatusGone, 0, at))
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/policyscan"
+	"riid.me/pkg/storage"
+)
+
+// isAdminAuthCode reports whether code is one of the configured admin authorization codes.
+func isAdminAuthCode(code string) bool {
+	if code == "" {
+		return false
+	}
+	for _, adminCode := range config.GlobalAppConfig.AdminAuthCodes {
+		if code == adminCode {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireAdmin is middleware that only lets requests through when they carry a valid
+// admin code as "Authorization: Bearer <code>".
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAdminAuthCode(bearerToken(r)) {
+			customlogger.Warn().Str("path", r.URL.Path).Str("remote", r.RemoteAddr).Msg("Unauthorized admin API request")
+			writeJSONError(w, http.StatusUnauthorized, "Valid admin authorization code required.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetConfigHandler returns the effective configuration of the running instance with
+// secrets redacted, so operators can verify what was actually loaded.
+func GetConfigHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, config.GlobalAppConfig.Redacted())
+}
+
+// RunMaintenanceHandler runs ANALYZE on the stats database so the query planner keeps
+// choosing the clicks indexes as data grows, and with ?reindex=true rebuilds all
+// indexes first.
+func RunMaintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	reindex := r.URL.Query().Get("reindex") == "true"
+	result, err := storage.RunMaintenance(r.Context(), reindex)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Bool("reindex", reindex).Msg("Stats database maintenance failed")
+		writeJSONError(w, http.StatusInternalServerError, "Maintenance failed")
+		return
+	}
+	customlogger.FromContext(r.Context()).Info().Bool("reindex", reindex).Float64("analyze_seconds", result.AnalyzeSeconds).Msg("Stats database maintenance completed")
+	writeJSON(w, http.StatusOK, result)
+}
+
+// RunPolicyScanHandler rescans all links against the destination policy right away,
+// disabling the violating ones, and reports what it found.
+func RunPolicyScanHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := policyscan.Scan(r.Context(), time.Now().UTC(), NotifyScanTakedown)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Destination policy scan failed")
+		writeJSONError(w, http.StatusInternalServerError, "Policy scan failed")
+		return
+	}
+	customlogger.FromContext(r.Context()).Info().Int("scanned", result.Scanned).Int("disabled", len(result.Violations)).Msg("Destination policy scan completed")
+	writeJSON(w, http.StatusOK, result)
+}
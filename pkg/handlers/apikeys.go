@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// apiKeyPrefix starts every issued API key, so keys are recognizable, e.g. by
+	// secret scanners, and other codes are never looked up in the database.
+	apiKeyPrefix = "rk_"
+	// maxAPIKeyNameLength caps the length of an API key's name.
+	maxAPIKeyNameLength = 100
+	// maxAPIKeyBodyBytes caps the size of an API key request.
+	maxAPIKeyBodyBytes = 4 << 10
+)
+
+// hashAPIKey returns the hex SHA-256 of an API key, under which it is stored. Its first
+// 32 characters are the key's ID, which equals linkOwner(key), so links created with a
+// key are attributed to it.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupAPIKey returns the issued API key code is, if it is an unrevoked one. Auth
+// checks do not carry a request context, so neither does the lookup.
+func lookupAPIKey(code string) (models.APIKey, bool) {
+	if !strings.HasPrefix(code, apiKeyPrefix) {
+		return models.APIKey{}, false
+	}
+	key, err := storage.APIKeyByHash(context.Background(), hashAPIKey(code))
+	if err != nil {
+		if !errors.Is(err, storage.ErrNotFound) {
+			customlogger.Error().Err(err).Msg("Failed to look up API key")
+		}
+		return models.APIKey{}, false
+	}
+	return key, true
+}
+
+// apiKeyRole returns the role of an issued API key, or roleAnonymous if code is not an
+// unrevoked key.
+func apiKeyRole(code string) string {
+	key, ok := lookupAPIKey(code)
+	if !ok {
+		return roleAnonymous
+	}
+	return key.Role
+}
+
+// CreateAPIKeyHandler issues a new API key with a member or viewer role. The key is
+// only ever returned in this response.
+func CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req models.APIKeyRequest
+	if err := decodeJSONOrYAML(r, maxAPIKeyBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for CreateAPIKey")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAPIKeyNameLength || strings.ContainsAny(req.Name, "\r\n") {
+		writeJSONError(w, http.StatusBadRequest, "name must be a single line of 1 to 100 characters")
+		return
+	}
+	if req.Role == "" {
+		req.Role = roleMember
+	}
+	if req.Role != roleMember && req.Role != roleViewer {
+		writeJSONError(w, http.StatusBadRequest, "role must be member or viewer")
+		return
+	}
+	if err := validateAPIKeyQuotas(req.APIKeyQuotas); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to generate API key")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	keyHash := hashAPIKey(key)
+	apiKey := models.APIKey{
+		ID:           keyHash[:32],
+		Name:         req.Name,
+		Role:         req.Role,
+		CreatedAt:    time.Now().UTC().Truncate(time.Second),
+		APIKeyQuotas: req.APIKeyQuotas,
+	}
+	if err := storage.CreateAPIKey(ctx, apiKey, keyHash); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store API key")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create API key")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Str("key_id", apiKey.ID).Str("role", apiKey.Role).Msg("API key created")
+	writeJSON(w, http.StatusCreated, models.APIKeyCreatedResponse{APIKey: apiKey, Key: key})
+}
+
+// ListAPIKeysHandler returns all API keys, without their secrets.
+func ListAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := storage.ListAPIKeys(r.Context())
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to list API keys")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve API keys")
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// validateAPIKeyQuotas rejects negative quotas.
+func validateAPIKeyQuotas(quotas models.APIKeyQuotas) error {
+	if (quotas.ShortenQuota != nil && *quotas.ShortenQuota < 0) || (quotas.StatsQuota != nil && *quotas.StatsQuota < 0) {
+		return errors.New("quotas must not be negative; use 0 for unlimited")
+	}
+	return nil
+}
+
+// PutAPIKeyQuotasHandler replaces the daily quotas of an API key. Quotas left out
+// fall back to the configured defaults.
+func PutAPIKeyQuotasHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	var quotas models.APIKeyQuotas
+	if err := decodeJSONOrYAML(r, maxAPIKeyBodyBytes, &quotas); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for PutAPIKeyQuotas")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateAPIKeyQuotas(quotas); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := storage.SetAPIKeyQuotas(ctx, id, quotas); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to update API key quotas")
+		}
+		writeStorageError(w, err, "Failed to update API key quotas")
+		return
+	}
+	customlogger.FromContext(ctx).Info().Str("key_id", id).Msg("API key quotas updated")
+	writeJSON(w, http.StatusOK, quotas)
+}
+
+// RevokeAPIKeyHandler revokes an API key; requests sending it are rejected from then on.
+func RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	if err := storage.RevokeAPIKey(ctx, id, time.Now()); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to revoke API key")
+		}
+		writeStorageError(w, err, "Failed to revoke API key")
+		return
+	}
+	customlogger.FromContext(ctx).Info().Str("key_id", id).Msg("API key revoked")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+
+	create := func(body string) (int, models.APIKeyCreatedResponse) {
+		rr := httptest.NewRecorder()
+		CreateAPIKeyHandler(rr, httptest.NewRequest(http.MethodPost, "/api/admin/api-keys", strings.NewReader(body)))
+		var resp models.APIKeyCreatedResponse
+		if rr.Code == http.StatusCreated {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		}
+		return rr.Code, resp
+	}
+
+	for _, body := range []string{`{}`, `{"name":"x","role":"admin"}`, `{"name":"` + strings.Repeat("x", 101) + `"}`} {
+		code, _ := create(body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+
+	code, member := create(`{"name":"CI pipeline"}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.True(t, strings.HasPrefix(member.Key, apiKeyPrefix))
+	assert.Equal(t, roleMember, member.Role)
+	_, viewer := create(`{"name":"Dashboard","role":"viewer"}`)
+
+	assert.True(t, isValidAuthCode(member.Key))
+	assert.Equal(t, member.ID, linkOwner(member.Key), "links are attributed to the key")
+	assert.False(t, isValidAuthCode(viewer.Key))
+	assert.True(t, isViewerAuthCode(viewer.Key))
+	assert.False(t, isValidAuthCode(apiKeyPrefix+"forged"))
+
+	rr := httptest.NewRecorder()
+	RevokeAPIKeyHandler(rr, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/admin/api-keys/"+member.ID, nil), map[string]string{"id": member.ID}))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	assert.False(t, isValidAuthCode(member.Key))
+
+	rr = httptest.NewRecorder()
+	RevokeAPIKeyHandler(rr, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/admin/api-keys/"+member.ID, nil), map[string]string{"id": member.ID}))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestAPIKeyQuota(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.APIKeyShortenQuota = 1000
+	config.GlobalAppConfig.APIKeyStatsQuota = 0
+
+	custom, unlimited := 5, 0
+	assert.Equal(t, 1000, apiKeyQuota(models.APIKey{}, QuotaShorten))
+	assert.Equal(t, 0, apiKeyQuota(models.APIKey{}, QuotaStats))
+	assert.Equal(t, 5, apiKeyQuota(models.APIKey{APIKeyQuotas: models.APIKeyQuotas{ShortenQuota: &custom}}, QuotaShorten))
+	assert.Equal(t, 0, apiKeyQuota(models.APIKey{APIKeyQuotas: models.APIKeyQuotas{ShortenQuota: &unlimited}}, QuotaShorten))
+
+	// Requests without an API key are not counted.
+	called := false
+	rr := httptest.NewRecorder()
+	APIKeyQuota(QuotaShorten, http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true })).
+		ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/shorten", nil))
+	assert.True(t, called)
+	assert.Empty(t, rr.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestCreateShortURLRequiresAPIKeysAsBearer(t *testing.T) {
+	rr := httptest.NewRecorder()
+	CreateShortURL(rr, httptest.NewRequest(http.MethodPost, "/api/shorten",
+		strings.NewReader(`{"long_url":"https://example.com","auth_code":"`+apiKeyPrefix+`secret"}`)))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Authorization")
+}
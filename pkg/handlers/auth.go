@@ -1,14 +1,119 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"strconv"
+	"strings"
 
 	customlogger "riid.me/pkg/logger"
 	"riid.me/pkg/models"
 	"riid.me/pkg/config"
 )
 
+// isValidAuthCode reports whether code may create links: a member code or a user's
+// session token.
+func isValidAuthCode(code string) bool {
+	if isMemberAuthCode(code) {
+		return true
+	}
+	_, ok := sessionUserID(code)
+	return ok
+}
+
+// isMemberAuthCode reports whether code is one of the configured authorization codes
+// or an API key with the member role. Unlike session users, who anyone may become by
+// registering, members are trusted with the links of others, e.g. to list them.
+func isMemberAuthCode(code string) bool {
+	if code == "" {
+		return false
+	}
+	for _, validCode := range config.GlobalAppConfig.ValidAuthCodes {
+		if code == validCode {
+			return true
+		}
+	}
+	return apiKeyRole(code) == roleMember
+}
+
+// Roles of API callers, from most to least privileged, derived from the auth code
+// they send as a bearer token.
+const (
+	roleAdmin     = "admin"
+	roleMember    = "member"
+	roleUser      = "user" // signed in with an account session; sees and acts on only their own links
+	roleViewer    = "viewer"
+	roleAnonymous = ""
+)
+
+// isViewerAuthCode reports whether code is one of the configured viewer codes or an
+// API key with the viewer role.
+func isViewerAuthCode(code string) bool {
+	if code == "" {
+		return false
+	}
+	for _, viewerCode := range config.GlobalAppConfig.ViewerAuthCodes {
+		if code == viewerCode {
+			return true
+		}
+	}
+	return apiKeyRole(code) == roleViewer
+}
+
+// callerRole returns the role of the caller of r. Unknown codes are anonymous.
+func callerRole(r *http.Request) string {
+	code := bearerToken(r)
+	switch {
+	case isAdminAuthCode(code):
+		return roleAdmin
+	case isMemberAuthCode(code):
+		return roleMember
+	case isValidAuthCode(code):
+		return roleUser
+	case isViewerAuthCode(code):
+		return roleViewer
+	}
+	return roleAnonymous
+}
+
+// canSeeClickDetails reports whether the caller of r may see the raw click rows of
+// link, with their user agents and referrers, rather than only aggregate counts: its
+// owner, admins, and viewers unless VIEWER_STATS_AGGREGATES_ONLY is set.
+func canSeeClickDetails(r *http.Request, link models.Link) bool {
+	token := bearerToken(r)
+	if ownsLink(token, link) {
+		return true
+	}
+	return !config.GlobalAppConfig.ViewerStatsAggregatesOnly && callerRole(r) == roleViewer
+}
+
+// linkOwner identifies the holder of a valid auth code on the links they create
+// without storing the code itself. For API keys it is the key's ID, for session tokens
+// "user:" and the user's ID. It is empty for anonymous or invalid codes.
+func linkOwner(code string) string {
+	if userID, ok := sessionUserID(code); ok {
+		return userOwnerPrefix + strconv.FormatInt(userID, 10)
+	}
+	if !isValidAuthCode(code) {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:16])
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" request header.
+// It returns an empty string if the header is missing or uses another scheme.
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
 // ValidateAuthCodeHandler handles requests to validate an authorization code.
 // It checks the provided AuthCode against the list of valid codes loaded from configuration.
 func ValidateAuthCodeHandler(w http.ResponseWriter, r *http.Request) {
@@ -29,15 +134,7 @@ func ValidateAuthCodeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	isValidCode := false
-	for _, validCode := range config.GlobalAppConfig.ValidAuthCodes {
-		if req.AuthCode == validCode {
-			isValidCode = true
-			break
-		}
-	}
-
-	if isValidCode {
+	if isValidAuthCode(req.AuthCode) {
 		customlogger.Info().Msg("Auth code validated successfully")
 		json.NewEncoder(w).Encode(models.AuthValidationResponse{Valid: true})
 	} else {
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/unwrap"
+)
+
+const (
+	// badgeHealthTTL is how long the result of a destination health probe is cached in Redis.
+	badgeHealthTTL = 10 * time.Minute
+	// badgeProbeTimeout bounds the time spent probing a destination for the badge.
+	badgeProbeTimeout = 3 * time.Second
+
+	badgeColorActive   = "#4c1"
+	badgeColorExpired  = "#9f9f9f"
+	badgeColorBroken   = "#e05d44"
+	badgeColorNotFound = "#9f9f9f"
+	badgeColorDisabled = "#e05d44"
+)
+
+// badgeProbeMaxRedirects caps the redirects followed when probing a destination.
+const badgeProbeMaxRedirects = 5
+
+// badgeProbeClient is the HTTP client used to check whether a link destination is
+// reachable. Badges are served to anyone, so it refuses to connect to private,
+// loopback and other non-public addresses, on every redirect hop.
+var badgeProbeClient = newBadgeProbeClient()
+
+// newBadgeProbeClient returns the client probing destinations for badges.
+func newBadgeProbeClient() *http.Client {
+	dialer := &net.Dialer{Timeout: badgeProbeTimeout, Control: unwrap.PublicAddressesOnly}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   badgeProbeTimeout,
+		ResponseHeaderTimeout: badgeProbeTimeout,
+	}
+	return &http.Client{Transport: transport, Timeout: badgeProbeTimeout, CheckRedirect: checkBadgeProbeRedirect}
+}
+
+// checkBadgeProbeRedirect limits the redirects followed when probing a destination and
+// checks each hop like the destination itself.
+func checkBadgeProbeRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > badgeProbeMaxRedirects {
+		return fmt.Errorf("more than %d redirects", badgeProbeMaxRedirects)
+	}
+	return probeableURL(req.URL)
+}
+
+// probeableURL rejects URLs a badge probe must not request: other schemes than http
+// and https, and hosts that are non-public IP addresses or localhost. Host names are
+// checked again once resolved, when the probe client dials them.
+func probeableURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("refusing to probe localhost")
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if ip = ip.Unmap(); !ip.IsGlobalUnicast() || ip.IsPrivate() {
+			return fmt.Errorf("refusing to probe non-public address %s", ip)
+		}
+	}
+	return nil
+}
+
+// badgeTemplate renders a flat, shields.io-style badge with a label and a message section.
+var badgeTemplate = template.Must(template.New("badge").Parse(`<svg xmlns="http://www.w3.org/2000/svg" width="{{.Width}}" height="20" role="img" aria-label="{{.Label}}: {{.Message}}">
+<title>{{.Label}}: {{.Message}}</title>
+<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="{{.Width}}" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)">
+<rect width="{{.LabelWidth}}" height="20" fill="#555"/>
+<rect x="{{.LabelWidth}}" width="{{.MessageWidth}}" height="20" fill="{{.Color}}"/>
+<rect width="{{.Width}}" height="20" fill="url(#s)"/>
+</g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">
+<text x="{{.LabelX}}" y="15" fill="#010101" fill-opacity=".3">{{.Label}}</text>
+<text x="{{.LabelX}}" y="14">{{.Label}}</text>
+<text x="{{.MessageX}}" y="15" fill="#010101" fill-opacity=".3">{{.Message}}</text>
+<text x="{{.MessageX}}" y="14">{{.Message}}</text>
+</g>
+</svg>`))
+
+// badgeData holds the computed geometry and escaped text for a single badge.
+type badgeData struct {
+	Label        string
+	Message      string
+	Color        string
+	Width        int
+	LabelWidth   int
+	MessageWidth int
+	LabelX       float64
+	MessageX     float64
+}
+
+// badgeTextWidth approximates the rendered width in pixels of text in 11px Verdana,
+// plus horizontal padding on both sides.
+func badgeTextWidth(text string) int {
+	return len([]rune(text))*7 + 10
+}
+
+// renderBadge produces the SVG markup for a badge with the given label, message and color.
+// Label and message are XML-escaped before rendering.
+func renderBadge(label, message, color string) ([]byte, error) {
+	labelWidth := badgeTextWidth(label)
+	messageWidth := badgeTextWidth(message)
+	data := badgeData{
+		Label:        html.EscapeString(label),
+		Message:      html.EscapeString(message),
+		Color:        color,
+		Width:        labelWidth + messageWidth,
+		LabelWidth:   labelWidth,
+		MessageWidth: messageWidth,
+		LabelX:       float64(labelWidth) / 2,
+		MessageX:     float64(labelWidth) + float64(messageWidth)/2,
+	}
+
+	var buf bytes.Buffer
+	if err := badgeTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// probeDestination reports whether the destination URL responds with a non-error status.
+// Servers that reject HEAD requests are retried with GET. Destinations that may not be
+// probed, such as private addresses, are reported unhealthy without being contacted.
+func probeDestination(ctx context.Context, longURL string) bool {
+	u, err := url.Parse(longURL)
+	if err != nil || probeableURL(u) != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, badgeProbeTimeout)
+	defer cancel()
+
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, longURL, nil)
+		if err != nil {
+			return false
+		}
+		resp, err := badgeProbeClient.Do(req)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+		return resp.StatusCode < http.StatusBadRequest
+	}
+	return false
+}
+
+// destinationHealthy returns the cached health of a link destination, probing it
+// and caching the result in Redis when no recent result is available.
+func destinationHealthy(ctx context.Context, shortCode, longURL string) bool {
+	cacheKey := "badge:health:" + shortCode
+	cached, err := storage.GetCached(ctx, cacheKey)
+	if err == nil {
+		return cached == "1"
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		customlogger.FromContext(ctx).Warn().Err(err).Msg("Failed to read cached destination health")
+	}
+
+	healthy := probeDestination(ctx, longURL)
+	value := "0"
+	if healthy {
+		value = "1"
+	}
+	if err := storage.SetCached(ctx, cacheKey, value, badgeHealthTTL); err != nil {
+		customlogger.FromContext(ctx).Warn().Err(err).Msg("Failed to cache destination health")
+	}
+	return healthy
+}
+
+// unavailableBadge returns the badge message and color of a link visitors cannot reach
+// although it has a destination: "disabled" when it was taken down for abuse and
+// "draft" when only preview token holders are redirected. The message is empty for
+// links that redirect as usual. Lookup failures are logged and treated as no
+// restriction, as they are for redirects.
+func unavailableBadge(ctx context.Context, shortCode string) (message, color string) {
+	takedown, err := storage.GetLinkTakedown(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up link takedown for badge")
+	} else if takedown != nil {
+		return "disabled", badgeColorDisabled
+	}
+
+	link, err := storage.GetLink(ctx, shortCode)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load link metadata for badge")
+	} else if err == nil && link.Draft {
+		return "draft", badgeColorNotFound
+	}
+	return "", ""
+}
+
+// GetLinkBadgeHandler serves an SVG status badge for a shortcode, suitable for embedding
+// in READMEs and wikis. The badge shows whether the link is active, expired or broken,
+// along with its total click count. Drafts and links taken down are never probed and
+// show as such instead.
+func GetLinkBadgeHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+
+	totalClicks, err := storage.CountClicks(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count clicks for badge")
+	}
+
+	status := http.StatusOK
+	var message, color string
+	longURL, err := storage.GetDestination(ctx, shortCode)
+	var unavailable, unavailableColor string
+	if err == nil {
+		unavailable, unavailableColor = unavailableBadge(ctx, shortCode)
+	}
+	switch {
+	case errors.Is(err, storage.ErrExpired),
+		// Links created before metadata was recorded have no expiry on file, but a
+		// click history shows they existed and have expired.
+		errors.Is(err, storage.ErrNotFound) && totalClicks > 0:
+		message, color = fmt.Sprintf("expired | %d clicks", totalClicks), badgeColorExpired
+	case errors.Is(err, storage.ErrNotFound):
+		status = http.StatusNotFound
+		message, color = "not found", badgeColorNotFound
+	case err != nil:
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis for badge")
+		http.Error(w, "Error retrieving URL", http.StatusInternalServerError)
+		return
+	case unavailable != "":
+		message, color = unavailable, unavailableColor
+	case destinationHealthy(ctx, shortCode, longURL):
+		message, color = fmt.Sprintf("active | %d clicks", totalClicks), badgeColorActive
+	default:
+		message, color = fmt.Sprintf("broken | %d clicks", totalClicks), badgeColorBroken
+	}
+
+	svg, err := renderBadge(shortCode, message, color)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to render badge")
+		http.Error(w, "Failed to render badge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "max-age=300")
+	w.WriteHeader(status)
+	w.Write(svg)
+}
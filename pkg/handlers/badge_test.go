@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestRenderBadge(t *testing.T) {
+	svg, err := renderBadge("docs<x>", "active | 3 clicks", badgeColorActive)
+	assert.NoError(t, err)
+
+	out := string(svg)
+	assert.Contains(t, out, "docs&lt;x&gt;")
+	assert.NotContains(t, out, "docs<x>")
+	assert.Contains(t, out, "active | 3 clicks")
+	assert.Contains(t, out, badgeColorActive)
+}
+
+func TestBadgeTextWidthGrowsWithText(t *testing.T) {
+	assert.Less(t, badgeTextWidth("ab"), badgeTextWidth("abcdef"))
+	assert.Equal(t, badgeTextWidth("äö"), badgeTextWidth("ab"))
+}
+
+func TestProbeDestinationRefusesPrivateAddresses(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	assert.False(t, probeDestination(ctx, server.URL), "loopback destinations are reported unhealthy")
+	assert.False(t, probeDestination(ctx, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)))
+	assert.False(t, probeDestination(ctx, "file:///etc/passwd"))
+
+	// Addresses only known once a host name is resolved are refused when dialing.
+	_, err := badgeProbeClient.Get(server.URL)
+	assert.Error(t, err)
+	assert.Zero(t, hits.Load(), "the destination is never contacted")
+
+	for _, target := range []string{"http://10.0.0.1/", "http://169.254.169.254/latest/meta-data/", "http://[::1]/", "gopher://example.com/"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		assert.Error(t, checkBadgeProbeRedirect(req, []*http.Request{req}), target)
+	}
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/", nil)
+	assert.NoError(t, checkBadgeProbeRedirect(req, []*http.Request{req}))
+}
+
+func TestUnavailableBadge(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "live", LongURL: "https://example.com/", CreatedAt: now}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "wip", LongURL: "https://example.com/", CreatedAt: now, Draft: true}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "scam", LongURL: "https://example.com/", CreatedAt: now}))
+	require.NoError(t, storage.SaveLinkTakedown(ctx, models.LinkTakedown{ShortCode: "scam", Reason: "phishing", CreatedAt: now}))
+
+	message, _ := unavailableBadge(ctx, "live")
+	assert.Empty(t, message)
+	message, _ = unavailableBadge(ctx, "wip")
+	assert.Equal(t, "draft", message)
+	message, color := unavailableBadge(ctx, "scam")
+	assert.Equal(t, "disabled", message)
+	assert.Equal(t, badgeColorDisabled, color)
+	message, _ = unavailableBadge(ctx, "unknown")
+	assert.Empty(t, message, "links without metadata redirect as usual")
+}
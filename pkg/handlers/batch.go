@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+const (
+	// maxBatchBodyBytes caps the size of a personalized link batch request.
+	maxBatchBodyBytes = 2 << 20
+	// maxBatchRows is the most links a single batch may mint.
+	maxBatchRows = 1000
+)
+
+// mergeVariablePattern matches a {{column}} placeholder in a batch destination template.
+var mergeVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// parseMergeCSV reads a CSV document whose first row names the merge variables and
+// returns the header and the data rows.
+func parseMergeCSV(doc string) ([]string, [][]string, error) {
+	records, err := csv.NewReader(strings.NewReader(doc)).ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	if len(records) < 2 {
+		return nil, nil, errors.New("CSV needs a header row and at least one data row")
+	}
+	header := records[0]
+	for i, name := range header {
+		header[i] = strings.TrimSpace(name)
+	}
+	return header, records[1:], nil
+}
+
+// expandTemplate substitutes the {{column}} placeholders of template with the row's
+// values, escaped so they are safe anywhere in a URL. Placeholders naming a column
+// the CSV does not have are an error.
+func expandTemplate(template string, header, row []string) (string, error) {
+	var missing string
+	expanded := mergeVariablePattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		name := mergeVariablePattern.FindStringSubmatch(placeholder)[1]
+		for i, column := range header {
+			if column == name && i < len(row) {
+				return strings.ReplaceAll(url.QueryEscape(row[i]), "+", "%20")
+			}
+		}
+		missing = name
+		return placeholder
+	})
+	if missing != "" {
+		return "", fmt.Errorf("template variable %q is not a CSV column", missing)
+	}
+	return expanded, nil
+}
+
+// expirationTTL returns the Redis TTL for links created by authenticated users given
+// the requested expiration days, following the same rules as custom handles.
+func expirationTTL(days *int) (time.Duration, error) {
+	if days == nil {
+		return time.Duration(config.DefaultExpirationDays) * 24 * time.Hour, nil
+	}
+	if *days == config.NoExpirationValue {
+		return 0, nil
+	}
+	if *days < 0 || *days > config.MaxExpirationDays {
+		return 0, fmt.Errorf("expiration must be 0 (for no expiry) or between 1 and %d days.", config.MaxExpirationDays)
+	}
+	return time.Duration(*days) * 24 * time.Hour, nil
+}
+
+// CreateLinkBatchHandler mints one personalized short link per row of a CSV, filling
+// the row's values into a destination template, and returns the CSV with short_url,
+// destination and qr_url columns appended as a mapping file, plus a qr_thumbnail data
+// URI column if asked for, so printed material can be made from that one file. Every destination is validated before any link
+// is created.
+func CreateLinkBatchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !isValidAuthCode(bearerToken(r)) {
+		customlogger.FromContext(ctx).Warn().Msg("Unauthorized attempt to create a link batch")
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return
+	}
+
+	var req models.LinkBatchRequest
+	if err := decodeJSONOrYAML(r, maxBatchBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for CreateLinkBatch")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !mergeVariablePattern.MatchString(req.Template) {
+		writeJSONError(w, http.StatusBadRequest, "template must contain at least one {{column}} placeholder")
+		return
+	}
+	ttl, err := expirationTTL(req.ExpirationDays)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errorMessage(err))
+		return
+	}
+	header, rows, err := parseMergeCSV(req.CSV)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(rows) > maxBatchRows {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("A batch may contain at most %d rows.", maxBatchRows))
+		return
+	}
+
+	destinations := make([]string, len(rows))
+	for i, row := range rows {
+		destination, err := expandTemplate(req.Template, header, row)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		destination = NormalizeURL(destination)
+		if err := validation.ValidateDestination(destination); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Row %d: %s", i+1, err.Error()))
+			return
+		}
+		destination, err = internalDestination(ctx, "", destination)
+		if isInternalLinkError(err) {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Row %d: %s", i+1, err.Error()))
+			return
+		} else if err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Int("row", i+1).Msg("Failed to resolve internal destination")
+			writeJSONError(w, http.StatusInternalServerError, "Error resolving destination")
+			return
+		}
+		destinations[i] = destination
+	}
+
+	codes := make([]string, len(rows))
+	shortURLs := make([]string, len(rows))
+	for i, destination := range destinations {
+		code, err := mintBatchLink(r, destination, ttl, req.Tags)
+		if err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Int("created", i).Msg("Failed to create link batch")
+			writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("Error storing URL for row %d; %d links were created.", i+1, i))
+			return
+		}
+		codes[i], shortURLs[i] = code, shortURLFor(code)
+	}
+
+	var out bytes.Buffer
+	writer := csv.NewWriter(&out)
+	columns := append(append([]string{}, header...), "short_url", "destination", "qr_url")
+	if req.QRThumbnails {
+		columns = append(columns, "qr_thumbnail")
+	}
+	writer.Write(columns)
+	for i, row := range rows {
+		record := append(append([]string{}, row...), shortURLs[i], destinations[i], qrURLFor(codes[i]))
+		if req.QRThumbnails {
+			thumbnail, err := qrThumbnailDataURI(shortURLs[i])
+			if err != nil {
+				// The links exist either way; a missing thumbnail should not lose the mapping.
+				customlogger.FromContext(ctx).Error().Err(err).Int("row", i+1).Msg("Failed to render QR thumbnail")
+			}
+			record = append(record, thumbnail)
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+
+	customlogger.FromContext(ctx).Info().Int("links", len(rows)).Msg("Personalized link batch created")
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="links.csv"`)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(out.Bytes())
+}
+
+// mintBatchLink stores a link with a generated code, retrying on the rare collision,
+// and returns the code.
+func mintBatchLink(r *http.Request, destination string, ttl time.Duration, tags []string) (string, error) {
+	ctx := r.Context()
+	code, _, err := storeGeneratedCode(ctx, ttl, func(string) (string, error) { return destination, nil })
+	if err != nil {
+		return "", err
+	}
+
+	expiresAt, err := storage.LinkExpiry(ctx, code)
+	if err != nil {
+		customlogger.FromContext(ctx).Warn().Err(err).Str("code", code).Msg("Failed to read TTL of stored URL")
+	}
+	link := models.Link{
+		ShortCode: code,
+		LongURL:   destination,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+		Tags:      tags,
+		Owner:     linkOwner(bearerToken(r)),
+	}
+	if err := storage.CreateLink(ctx, link); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Str("code", code).Msg("Failed to record link metadata")
+	}
+	return code, nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMergeCSV(t *testing.T) {
+	header, rows, err := parseMergeCSV("id, name\n42,Ada Lovelace\n43,\"Grace, Hopper\"\n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"id", "name"}, header)
+	assert.Equal(t, [][]string{{"42", "Ada Lovelace"}, {"43", "Grace, Hopper"}}, rows)
+
+	_, _, err = parseMergeCSV("id,name\n")
+	assert.Error(t, err)
+	_, _, err = parseMergeCSV("id,name\n1\n")
+	assert.Error(t, err, "rows must have as many fields as the header")
+}
+
+func TestExpandTemplate(t *testing.T) {
+	header := []string{"id", "name"}
+	got, err := expandTemplate("https://example.com/offer/{{ id }}?name={{name}}", header, []string{"42", "Ada & Co/Ltd"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/offer/42?name=Ada%20%26%20Co%2FLtd", got)
+
+	_, err = expandTemplate("https://example.com/?u={{email}}", header, []string{"42", "Ada"})
+	assert.EqualError(t, err, `template variable "email" is not a CSV column`)
+}
+
+func TestExpirationTTL(t *testing.T) {
+	never := 0
+	ttl, err := expirationTTL(&never)
+	require.NoError(t, err)
+	assert.Zero(t, ttl)
+
+	tooLong := 365*10 + 1
+	_, err = expirationTTL(&tooLong)
+	assert.Error(t, err)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"riid.me/pkg/events"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// maxCampaignAlertBodyBytes caps the size of a campaign alert request.
+	maxCampaignAlertBodyBytes = 4 << 10
+	// maxCampaignLength caps the length of a campaign, the tag its links share.
+	maxCampaignLength = 100
+)
+
+// CampaignAlertNotifier delivers the campaign alerts clicks trigger; nil delivers none,
+// though alerts are still marked triggered.
+var CampaignAlertNotifier *events.CampaignAlertNotifier
+
+// validateCampaignAlert normalizes a campaign alert request and checks it has a
+// campaign, a positive threshold and somewhere to report to.
+func validateCampaignAlert(req *models.CampaignAlertRequest) error {
+	req.Campaign = strings.TrimSpace(req.Campaign)
+	if req.Campaign == "" || len(req.Campaign) > maxCampaignLength || strings.ContainsAny(req.Campaign, "\r\n") {
+		return errors.New("campaign must be a single line of 1 to 100 characters")
+	}
+	if req.Threshold <= 0 {
+		return errors.New("threshold must be a positive number of clicks")
+	}
+	req.WebhookURL = strings.TrimSpace(req.WebhookURL)
+	req.Email = strings.TrimSpace(req.Email)
+	if req.WebhookURL == "" && req.Email == "" {
+		return errors.New("webhook_url or email is required")
+	}
+	if req.WebhookURL != "" {
+		u, err := url.Parse(req.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("webhook_url must be an http or https URL")
+		}
+	}
+	if req.Email != "" {
+		if address, err := mail.ParseAddress(req.Email); err != nil || address.Address != req.Email {
+			return errors.New("email must be a valid email address")
+		}
+	}
+	return nil
+}
+
+// countCampaignClick counts a redirect of a link towards the alerts of the campaigns
+// it is tagged with and hands the alerts it triggers to CampaignAlertNotifier. Failures
+// are logged and never block the redirect.
+func countCampaignClick(ctx context.Context, tags []string) {
+	if len(tags) == 0 {
+		return
+	}
+	triggered, err := storage.CountCampaignClick(ctx, tags, time.Now())
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count click towards campaign alerts")
+		return
+	}
+	for _, alert := range triggered {
+		customlogger.FromContext(ctx).Info().Int64("alert_id", alert.ID).Str("campaign", alert.Campaign).Int("threshold", alert.Threshold).Msg("Campaign alert triggered")
+		if CampaignAlertNotifier != nil {
+			CampaignAlertNotifier.Notify(alert)
+		}
+	}
+}
+
+// CreateCampaignAlertHandler sets a click-count threshold on a campaign, the links
+// sharing a tag. Counting starts from the campaign's current clicks, so a threshold
+// it has already reached is rejected.
+func CreateCampaignAlertHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	var req models.CampaignAlertRequest
+	if err := decodeJSONOrYAML(r, maxCampaignAlertBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for CreateCampaignAlert")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateCampaignAlert(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	clicks, err := storage.CampaignClicks(ctx, req.Campaign)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count campaign clicks")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create campaign alert")
+		return
+	}
+	if clicks >= req.Threshold {
+		writeJSONError(w, http.StatusUnprocessableEntity, "The campaign already has "+strconv.Itoa(clicks)+" clicks.")
+		return
+	}
+
+	alert := models.CampaignAlert{
+		Campaign:   req.Campaign,
+		Threshold:  req.Threshold,
+		Clicks:     clicks,
+		WebhookURL: req.WebhookURL,
+		Email:      req.Email,
+		CreatedAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	if alert.ID, err = storage.CreateCampaignAlert(ctx, alert); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store campaign alert")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create campaign alert")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Int64("alert_id", alert.ID).Str("campaign", alert.Campaign).Int("threshold", alert.Threshold).Msg("Campaign alert created")
+	writeJSON(w, http.StatusCreated, alert)
+}
+
+// ListCampaignAlertsHandler returns all campaign alerts with their click counts.
+func ListCampaignAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	alerts, err := storage.ListCampaignAlerts(r.Context())
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to list campaign alerts")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve campaign alerts")
+		return
+	}
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+// DeleteCampaignAlertHandler removes a campaign alert.
+func DeleteCampaignAlertHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid campaign alert ID")
+		return
+	}
+
+	if err := storage.DeleteCampaignAlert(r.Context(), id); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(r.Context()).Error().Err(err).Int64("alert_id", id).Msg("Failed to delete campaign alert")
+		}
+		writeStorageError(w, err, "Failed to delete campaign alert")
+		return
+	}
+
+	customlogger.FromContext(r.Context()).Info().Int64("alert_id", id).Msg("Campaign alert deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
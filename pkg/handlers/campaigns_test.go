@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestValidateCampaignAlert(t *testing.T) {
+	req := models.CampaignAlertRequest{Campaign: " paid ", Threshold: 10000, Email: " ads@example.com "}
+	require.NoError(t, validateCampaignAlert(&req))
+	assert.Equal(t, "paid", req.Campaign)
+	assert.Equal(t, "ads@example.com", req.Email)
+
+	for _, req := range []models.CampaignAlertRequest{
+		{Threshold: 10, Email: "ads@example.com"},
+		{Campaign: "paid\r\nBcc: x@example.com", Threshold: 10, Email: "ads@example.com"},
+		{Campaign: "paid", Email: "ads@example.com"},
+		{Campaign: "paid", Threshold: 10},
+		{Campaign: "paid", Threshold: 10, WebhookURL: "ftp://example.com/hook"},
+		{Campaign: "paid", Threshold: 10, Email: "Ads <ads@example.com>"},
+	} {
+		assert.Error(t, validateCampaignAlert(&req), "%+v", req)
+	}
+}
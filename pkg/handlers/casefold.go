@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"riid.me/pkg/config"
+	"riid.me/pkg/storage"
+)
+
+// foldShortCode returns the form a new custom handle or generated short code is stored
+// under: lowercase with CASE_INSENSITIVE_CODES on, as it is otherwise.
+func foldShortCode(code string) string {
+	if config.GlobalAppConfig.CaseInsensitiveCodes {
+		return strings.ToLower(code)
+	}
+	return code
+}
+
+// shortCodeTaken reports whether code currently has a destination. With
+// CASE_INSENSITIVE_CODES on, so does a link stored under code in another letter case,
+// like one created before the flag was turned on.
+func shortCodeTaken(ctx context.Context, code string) (bool, error) {
+	taken, err := storage.LinkExists(ctx, code)
+	if err != nil || taken || !config.GlobalAppConfig.CaseInsensitiveCodes {
+		return taken, err
+	}
+	stored, err := storage.ShortCodesIgnoringCase(ctx, code)
+	if err != nil {
+		return false, err
+	}
+	for _, other := range stored {
+		if other == code {
+			continue
+		}
+		if taken, err := storage.LinkExists(ctx, other); err != nil || taken {
+			return taken, err
+		}
+	}
+	return false, nil
+}
+
+// resolveShortCode looks up the destination of the short code a short URL was
+// requested with. The code is tried as typed first, so links differing only in case
+// from before CASE_INSENSITIVE_CODES was turned on keep resolving; with the flag on, a
+// code not found is then tried lowercase, as links created with the flag on are
+// stored, and finally in any letter case. It returns the code the link is stored under
+// along with its destination.
+func resolveShortCode(ctx context.Context, code string) (string, string, error) {
+	longURL, err := storage.GetDestination(ctx, code)
+	if !errors.Is(err, storage.ErrNotFound) || !config.GlobalAppConfig.CaseInsensitiveCodes {
+		return code, longURL, err
+	}
+	folded := strings.ToLower(code)
+	if folded != code {
+		if longURL, foldedErr := storage.GetDestination(ctx, folded); !errors.Is(foldedErr, storage.ErrNotFound) {
+			return folded, longURL, foldedErr
+		}
+	}
+	stored, lookupErr := storage.ShortCodesIgnoringCase(ctx, code)
+	if lookupErr != nil {
+		return code, "", lookupErr
+	}
+	for _, other := range stored {
+		if other == code || other == folded {
+			continue
+		}
+		if longURL, otherErr := storage.GetDestination(ctx, other); !errors.Is(otherErr, storage.ErrNotFound) {
+			return other, longURL, otherErr
+		}
+	}
+	return code, longURL, err
+}
+
+// requestedShortCode returns the short code a per-link endpoint, such as statistics,
+// badges or link management, was requested for, as the link is stored. With
+// CASE_INSENSITIVE_CODES on it is resolved like a short URL, so /api/links/AbC
+// manages the link stored as abc; codes of no link are returned as requested, for
+// the handler to report.
+func requestedShortCode(r *http.Request) string {
+	code := mux.Vars(r)["shortcode"]
+	if code == "" || !config.GlobalAppConfig.CaseInsensitiveCodes {
+		return code
+	}
+	stored, _, _ := resolveShortCode(r.Context(), code)
+	return stored
+}
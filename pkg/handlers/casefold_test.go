@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// serveFakeRedis points storage.Rdb at a minimal Redis server on loopback answering
+// GET, EXISTS and PTTL of a single key from values, enough for destination lookups.
+// Keys expire after their duration in ttls, if any; other commands fail.
+func serveFakeRedis(t *testing.T, values map[string]string, ttls map[string]time.Duration) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go answerFakeRedis(conn, values, ttls)
+		}
+	}()
+
+	original := storage.Rdb
+	storage.Rdb = redis.NewClient(&redis.Options{Addr: listener.Addr().String(), MaxRetries: -1})
+	t.Cleanup(func() {
+		storage.Rdb.Close()
+		storage.Rdb = original
+		listener.Close()
+	})
+}
+
+// answerFakeRedis reads RESP commands from conn until it is closed.
+func answerFakeRedis(conn net.Conn, values map[string]string, ttls map[string]time.Duration) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+		args := make([]string, n)
+		for i := range args {
+			if _, err := reader.ReadString('\n'); err != nil { // bulk string length
+				return
+			}
+			arg, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			args[i] = strings.TrimSuffix(arg, "\r\n")
+		}
+		if len(args) != 2 {
+			fmt.Fprintf(conn, "-ERR unsupported command\r\n")
+			continue
+		}
+
+		value, ok := values[args[1]]
+		switch command := strings.ToLower(args[0]); {
+		case command == "get" && ok:
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+		case command == "get":
+			fmt.Fprintf(conn, "$-1\r\n")
+		case command == "exists" && ok:
+			fmt.Fprintf(conn, ":1\r\n")
+		case command == "exists":
+			fmt.Fprintf(conn, ":0\r\n")
+		case command == "pttl" && !ok:
+			fmt.Fprintf(conn, ":-2\r\n")
+		case command == "pttl" && ttls[args[1]] > 0:
+			fmt.Fprintf(conn, ":%d\r\n", ttls[args[1]].Milliseconds())
+		case command == "pttl":
+			fmt.Fprintf(conn, ":-1\r\n")
+		default:
+			fmt.Fprintf(conn, "-ERR unsupported command\r\n")
+		}
+	}
+}
+
+func TestFoldShortCode(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+
+	config.GlobalAppConfig.CaseInsensitiveCodes = false
+	assert.Equal(t, "AbC", foldShortCode("AbC"))
+
+	config.GlobalAppConfig.CaseInsensitiveCodes = true
+	assert.Equal(t, "abc", foldShortCode("AbC"))
+}
+
+func TestRequestedShortCode(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+	now := time.Now().UTC()
+	expired := now.Add(-time.Hour)
+
+	serveFakeRedis(t, map[string]string{"promo": "https://example.com/", "Legacy": "https://example.com/legacy"}, nil)
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "promo", LongURL: "https://example.com/", CreatedAt: now}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "Legacy", LongURL: "https://example.com/legacy", CreatedAt: now}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "gone", LongURL: "https://example.com/gone", CreatedAt: expired, ExpiresAt: &expired}))
+
+	requested := func(code string) string {
+		r := mux.SetURLVars(httptest.NewRequest("GET", "/api/links/"+code, nil), map[string]string{"shortcode": code})
+		return requestedShortCode(r)
+	}
+
+	config.GlobalAppConfig.CaseInsensitiveCodes = false
+	assert.Equal(t, "PROMO", requested("PROMO"), "codes are case-sensitive by default")
+
+	config.GlobalAppConfig.CaseInsensitiveCodes = true
+	assert.Equal(t, "promo", requested("PROMO"))
+	assert.Equal(t, "Legacy", requested("legacy"), "links stored in another case before the flag resolve too")
+	assert.Equal(t, "gone", requested("GONE"), "expired links keep their statistics reachable")
+	assert.Equal(t, "Nope", requested("Nope"))
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// maxClaimBodyBytes caps the size of a link claim request.
+const maxClaimBodyBytes = 1 << 10
+
+// hashClaimToken returns the hex SHA-256 of a claim token, under which it is stored.
+func hashClaimToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newClaimToken issues the one-time claim token of an anonymous link. Only its hash is
+// kept, so the token is returned once, with the short URL.
+func newClaimToken(ctx context.Context, shortCode string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	if err := storage.SaveClaimToken(ctx, shortCode, hashClaimToken(token)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ClaimLinkHandler hands an anonymous link to the caller, who proves they created it
+// with the claim token returned by /api/shorten. From then on the link is theirs to
+// edit and see the stats of, like links they created with their auth code. The token
+// works once.
+func ClaimLinkHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	shortCode := requestedShortCode(r)
+	owner := linkOwner(bearerToken(r))
+	if owner == "" {
+		writeJSONError(w, http.StatusUnauthorized, "An auth code, API key or session that can own links is required.")
+		return
+	}
+
+	var req models.LinkClaimRequest
+	if err := decodeJSONOrYAML(r, maxClaimBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for ClaimLink")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.ClaimToken = strings.TrimSpace(req.ClaimToken)
+	if req.ClaimToken == "" {
+		writeJSONError(w, http.StatusBadRequest, "claim_token is required")
+		return
+	}
+
+	if err := storage.ClaimLink(ctx, shortCode, hashClaimToken(req.ClaimToken), owner); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to claim link")
+		}
+		writeStorageError(w, err, "Failed to claim link")
+		return
+	}
+	link, err := storage.GetLink(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load claimed link")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Msg("Anonymous link claimed")
+	writeJSON(w, http.StatusOK, link)
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestClaimLink(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member-code"}
+	ctx := context.Background()
+
+	require.NoError(t, storage.CreateLink(ctx, models.Link{ShortCode: "anon", LongURL: "https://example.com", CreatedAt: time.Now().UTC()}))
+	token, err := newClaimToken(ctx, "anon")
+	require.NoError(t, err)
+
+	claim := func(authCode, body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/links/anon/claim", strings.NewReader(body))
+		if authCode != "" {
+			req.Header.Set("Authorization", "Bearer "+authCode)
+		}
+		rr := httptest.NewRecorder()
+		ClaimLinkHandler(rr, mux.SetURLVars(req, map[string]string{"shortcode": "anon"}))
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, claim("", `{"claim_token":"`+token+`"}`))
+	assert.Equal(t, http.StatusBadRequest, claim("member-code", `{}`))
+	assert.Equal(t, http.StatusNotFound, claim("member-code", `{"claim_token":"wrong"}`))
+	assert.Equal(t, http.StatusOK, claim("member-code", `{"claim_token":"`+token+`"}`))
+
+	link, err := storage.GetLink(ctx, "anon")
+	require.NoError(t, err)
+	assert.Equal(t, linkOwner("member-code"), link.Owner)
+	assert.True(t, ownsLink("member-code", link))
+
+	// The token works once.
+	assert.Equal(t, http.StatusNotFound, claim("member-code", `{"claim_token":"`+token+`"}`))
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"riid.me/pkg/config"
+)
+
+// trustedClientAddr returns the address of the client a request came from, for
+// security controls such as rate limits, scanner bans and the IP blocklist. Unlike
+// clientIP, X-Forwarded-For is only believed when the connection comes from one of
+// TRUSTED_PROXIES, and it is read from the right, past further trusted proxies, as
+// clients can put anything in front of what the proxies append. ok is false when the
+// address cannot be parsed.
+func trustedClientAddr(r *http.Request) (addr netip.Addr, ok bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err = netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr = addr.Unmap()
+	if !config.GlobalAppConfig.TrustedProxies.Contains(addr) {
+		return addr, true
+	}
+
+	forwarded := strings.Join(r.Header.Values("X-Forwarded-For"), ",")
+	if forwarded == "" {
+		return addr, true
+	}
+	hops := strings.Split(forwarded, ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		addr = hop.Unmap()
+		if !config.GlobalAppConfig.TrustedProxies.Contains(addr) {
+			break
+		}
+	}
+	return addr, true
+}
+
+// trustedClientKey returns trustedClientAddr as the key a client's rate limits and
+// bans are kept under. Clients whose address cannot be parsed all share one key, so
+// they cannot escape a limit by sending garbage.
+func trustedClientKey(r *http.Request) string {
+	addr, ok := trustedClientAddr(r)
+	if !ok {
+		return "unknown"
+	}
+	return addr.String()
+}
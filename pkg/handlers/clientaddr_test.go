@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/config"
+)
+
+func TestTrustedClientAddr(t *testing.T) {
+	t.Cleanup(func() { config.GlobalAppConfig.TrustedProxies = nil })
+	addr := func(remote string, forwarded ...string) string {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = remote
+		for _, header := range forwarded {
+			req.Header.Add("X-Forwarded-For", header)
+		}
+		client, ok := trustedClientAddr(req)
+		if !ok {
+			return "unparseable"
+		}
+		return client.String()
+	}
+
+	assert.Equal(t, "203.0.113.7", addr("203.0.113.7:51234", "198.51.100.1"), "without trusted proxies the connection's address is used")
+	assert.Equal(t, "unparseable", addr("garbage"))
+
+	config.GlobalAppConfig.TrustedProxies = config.Networks{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.0.2.1/32")}
+	assert.Equal(t, "203.0.113.7", addr("203.0.113.7:51234", "198.51.100.1"), "only trusted proxies may forward")
+	assert.Equal(t, "10.0.0.2", addr("10.0.0.2:51234"))
+	assert.Equal(t, "198.51.100.1", addr("10.0.0.2:51234", "1.1.1.1, 198.51.100.1"))
+	assert.Equal(t, "198.51.100.1", addr("10.0.0.2:51234", "1.1.1.1, 198.51.100.1, 192.0.2.1"), "further trusted proxies are skipped")
+	assert.Equal(t, "198.51.100.1", addr("10.0.0.2:51234", "1.1.1.1", "198.51.100.1"), "repeated headers are read as one list")
+	assert.Equal(t, "10.0.0.3", addr("10.0.0.2:51234", "10.0.0.3"), "a chain of trusted proxies ends at the first")
+	assert.Equal(t, "unparseable", addr("10.0.0.2:51234", "1.1.1.1, x"))
+}
+
+func TestTrustedClientKeyIgnoresSpoofedForwardedFor(t *testing.T) {
+	t.Cleanup(func() { config.GlobalAppConfig.TrustedProxies = nil })
+	key := func(remote, forwarded string) string {
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", forwarded)
+		return trustedClientKey(req)
+	}
+
+	assert.Equal(t, key("203.0.113.7:51234", "1.1.1.1"), key("203.0.113.7:51234", "1.1.1.2"), "a new X-Forwarded-For does not make a new client")
+
+	config.GlobalAppConfig.TrustedProxies = config.Networks{netip.MustParsePrefix("10.0.0.0/8")}
+	assert.Equal(t, "203.0.113.7", key("10.0.0.2:51234", "1.1.1.1, 203.0.113.7"))
+	assert.Equal(t, key("10.0.0.2:51234", "x"), key("10.0.0.2:51234", "y"), "unparseable clients share one limit")
+}
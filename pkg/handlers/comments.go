@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// maxCommentAuthorLength is the maximum length of a comment author name.
+	maxCommentAuthorLength = 100
+	// maxCommentBodyLength is the maximum length of a comment body.
+	maxCommentBodyLength = 2000
+)
+
+// ListLinkCommentsHandler returns the comments left on a link.
+// Comments are internal notes, so only the link's creator and admins may read them.
+func ListLinkCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+
+	comments, err := storage.ListComments(r.Context(), shortCode)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to list link comments")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve comments")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comments)
+}
+
+// AddLinkCommentHandler adds a comment to a link so its creator and admins can
+// coordinate changes.
+func AddLinkCommentHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+
+	var req models.LinkCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Invalid request body for AddLinkComment")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Author = strings.TrimSpace(req.Author)
+	req.Body = strings.TrimSpace(req.Body)
+	if req.Author == "" || len(req.Author) > maxCommentAuthorLength {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Author is required and must be at most %d characters.", maxCommentAuthorLength))
+		return
+	}
+	if req.Body == "" || len(req.Body) > maxCommentBodyLength {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Comment body is required and must be at most %d characters.", maxCommentBodyLength))
+		return
+	}
+
+	comment, err := storage.AddComment(r.Context(), shortCode, req.Author, req.Body)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to store link comment")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store comment")
+		return
+	}
+
+	customlogger.FromContext(r.Context()).Info().Int64("comment_id", comment.ID).Msg("Link comment added")
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+// DeleteLinkCommentHandler removes a comment from a link, e.g. once the requested change is done.
+func DeleteLinkCommentHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	shortCode := requestedShortCode(r)
+
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid comment ID")
+		return
+	}
+
+	if err := storage.DeleteComment(r.Context(), shortCode, id); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(r.Context()).Error().Err(err).Int64("comment_id", id).Msg("Failed to delete link comment")
+		}
+		writeStorageError(w, err, "Failed to delete comment")
+		return
+	}
+
+	customlogger.FromContext(r.Context()).Info().Int64("comment_id", id).Msg("Link comment deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestLinkComments(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.AdminAuthCodes = []string{"admin"}
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member", "other"}
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	serveFakeRedis(t, map[string]string{"docs": "https://example.com/docs"}, nil)
+	require.NoError(t, storage.SaveLink(context.Background(), models.Link{
+		ShortCode: "docs", LongURL: "https://example.com/docs", CreatedAt: time.Now().UTC(), Owner: linkOwner("member"),
+	}))
+
+	call := func(handler http.HandlerFunc, method, code, body string, vars map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/links/docs/comments", strings.NewReader(body))
+		if code != "" {
+			req.Header.Set("Authorization", "Bearer "+code)
+		}
+		rr := httptest.NewRecorder()
+		handler(rr, mux.SetURLVars(req, vars))
+		return rr
+	}
+	docs := map[string]string{"shortcode": "docs"}
+	comment := `{"author":"ana","body":"please repoint after Friday"}`
+
+	assert.Equal(t, http.StatusUnauthorized, call(AddLinkCommentHandler, http.MethodPost, "", comment, docs).Code)
+	assert.Equal(t, http.StatusForbidden, call(AddLinkCommentHandler, http.MethodPost, "other", comment, docs).Code, "comments are for the link's creator and admins")
+	assert.Equal(t, http.StatusBadRequest, call(AddLinkCommentHandler, http.MethodPost, "member", `{"author":"ana","body":"  "}`, docs).Code)
+
+	rr := call(AddLinkCommentHandler, http.MethodPost, "member", comment, docs)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	var added models.LinkComment
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &added))
+	assert.Equal(t, "please repoint after Friday", added.Body)
+
+	assert.Equal(t, http.StatusForbidden, call(ListLinkCommentsHandler, http.MethodGet, "other", "", docs).Code)
+	rr = call(ListLinkCommentsHandler, http.MethodGet, "admin", "", docs)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var comments []models.LinkComment
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &comments))
+	require.Len(t, comments, 1)
+	assert.Equal(t, "ana", comments[0].Author)
+
+	withID := map[string]string{"shortcode": "docs", "id": fmt.Sprint(added.ID)}
+	assert.Equal(t, http.StatusForbidden, call(DeleteLinkCommentHandler, http.MethodDelete, "other", "", withID).Code)
+	assert.Equal(t, http.StatusNoContent, call(DeleteLinkCommentHandler, http.MethodDelete, "member", "", withID).Code)
+	assert.Equal(t, http.StatusNotFound, call(DeleteLinkCommentHandler, http.MethodDelete, "member", "", withID).Code)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// minCompressBytes is the smallest response body worth compressing; below it the
+// encoding overhead outweighs the savings.
+const minCompressBytes = 1024
+
+// Supported content encodings, in order of preference.
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// compressibleTypes are the media types compressed: API responses, pages and the
+// assets they load. Images other than SVG, PDFs and uploaded archives are compressed
+// already.
+var compressibleTypes = map[string]bool{
+	"application/json":       true,
+	"application/javascript": true,
+	"application/xml":        true,
+	"application/rss+xml":    true,
+	"application/yaml":       true,
+	"image/svg+xml":          true,
+	"text/css":               true,
+	"text/html":              true,
+	"text/javascript":        true,
+	"text/plain":             true,
+	"text/xml":               true,
+}
+
+// acceptedEncoding picks the encoding to compress a response in from the request's
+// Accept-Encoding header, preferring brotli over gzip, or returns "" when the client
+// accepts neither.
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if key, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(key) == "q" {
+			if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[name] = q > 0
+	}
+	for _, encoding := range []string{encodingBrotli, encodingGzip} {
+		if accepted[encoding] {
+			return encoding
+		}
+	}
+	return ""
+}
+
+// Compress is middleware that compresses responses with brotli or gzip, as the client
+// accepts, when they are of a compressible type and at least minCompressBytes long.
+// Redirects and other bodiless responses, range requests and responses a handler
+// encoded itself are passed through unchanged.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter holds back the start of a response until it knows whether the body is
+// long enough to compress, then sends it either through an encoder or unchanged.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+	started  bool
+	encoder  io.WriteCloser
+}
+
+// WriteHeader records the status; the header is sent once the body is known.
+func (cw *compressWriter) WriteHeader(status int) {
+	if !cw.started && cw.status == 0 {
+		cw.status = status
+	}
+}
+
+// Write buffers the body until it reaches minCompressBytes.
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if cw.started {
+		if cw.encoder != nil {
+			return cw.encoder.Write(p)
+		}
+		return cw.ResponseWriter.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) < minCompressBytes {
+		return len(p), nil
+	}
+	if err := cw.start(true); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start sends the header, deciding whether to compress, and then the buffered body.
+func (cw *compressWriter) start(large bool) error {
+	cw.started = true
+	header := cw.Header()
+	if header.Get("Content-Type") == "" && len(cw.buf) > 0 {
+		// Sniff now, as net/http would, since it cannot after compression.
+		header.Set("Content-Type", http.DetectContentType(cw.buf))
+	}
+	compressible := cw.compressible()
+	if compressible {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	if compressible && large {
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		if cw.encoding == encodingBrotli {
+			cw.encoder = brotli.NewWriterLevel(cw.ResponseWriter, brotli.DefaultCompression)
+		} else {
+			cw.encoder, _ = gzip.NewWriterLevel(cw.ResponseWriter, gzip.DefaultCompression)
+		}
+	}
+	if cw.status != 0 {
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	_, err := cw.Write(buf)
+	return err
+}
+
+// compressible reports whether the response may be compressed, judging by its status,
+// headers and content type.
+func (cw *compressWriter) compressible() bool {
+	switch {
+	case cw.status >= 300 && cw.status < 400, cw.status == http.StatusNoContent, cw.status == http.StatusPartialContent:
+		return false
+	}
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	return err == nil && compressibleTypes[mediaType]
+}
+
+// Close sends what is still held back and finishes the compressed stream.
+func (cw *compressWriter) Close() error {
+	if !cw.started {
+		if cw.status == 0 && len(cw.buf) == 0 {
+			return nil
+		}
+		if err := cw.start(false); err != nil {
+			return err
+		}
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Close()
+	}
+	return nil
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
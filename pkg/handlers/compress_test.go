@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	assert.Equal(t, encodingBrotli, acceptedEncoding("gzip, deflate, br"))
+	assert.Equal(t, encodingGzip, acceptedEncoding("gzip, br;q=0"))
+	assert.Equal(t, encodingGzip, acceptedEncoding("GZIP"))
+	assert.Equal(t, "", acceptedEncoding("identity"))
+	assert.Equal(t, "", acceptedEncoding(""))
+}
+
+func serveCompressed(t *testing.T, acceptEncoding string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/stats/abc", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	rr := httptest.NewRecorder()
+	Compress(handler).ServeHTTP(rr, req)
+	return rr
+}
+
+func TestCompress(t *testing.T) {
+	body := `{"clicks":[` + strings.Repeat(`{"country":"LV"},`, 200) + `{}]}`
+	writeBody := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		// Written in pieces, like json.Encoder may, so the threshold is crossed mid-body.
+		io.WriteString(w, body[:100])
+		io.WriteString(w, body[100:])
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		rr := serveCompressed(t, "gzip", writeBody)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, encodingGzip, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+		assert.Less(t, rr.Body.Len(), len(body))
+		reader, err := gzip.NewReader(rr.Body)
+		require.NoError(t, err)
+		decoded, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, body, string(decoded))
+	})
+
+	t.Run("brotli", func(t *testing.T) {
+		rr := serveCompressed(t, "gzip, br", writeBody)
+		assert.Equal(t, encodingBrotli, rr.Header().Get("Content-Encoding"))
+		decoded, err := io.ReadAll(brotli.NewReader(rr.Body))
+		require.NoError(t, err)
+		assert.Equal(t, body, string(decoded))
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		rr := serveCompressed(t, "", writeBody)
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, body, rr.Body.String())
+	})
+
+	t.Run("small body", func(t *testing.T) {
+		rr := serveCompressed(t, "gzip", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusCreated, map[string]string{"short_url": "https://riid.me/abc"})
+		})
+		assert.Equal(t, http.StatusCreated, rr.Code)
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.JSONEq(t, `{"short_url":"https://riid.me/abc"}`, rr.Body.String())
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		rr := serveCompressed(t, "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusFound)
+			io.WriteString(w, strings.Repeat("<a href=\"https://example.com\">Found</a>", 50))
+		})
+		assert.Equal(t, http.StatusFound, rr.Code)
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	})
+
+	t.Run("image", func(t *testing.T) {
+		rr := serveCompressed(t, "gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(make([]byte, 4096))
+		})
+		assert.Empty(t, rr.Header().Get("Content-Encoding"))
+		assert.Equal(t, 4096, rr.Body.Len())
+	})
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// clickIDCookie is the cookie a tracked redirect hands its click ID to the visitor in.
+	clickIDCookie = "riidme_click"
+	// defaultConversionEvent is the event of a conversion posted without one.
+	defaultConversionEvent = "conversion"
+	// maxConversionBodyBytes caps the size of a conversion postback.
+	maxConversionBodyBytes = 4 << 10
+	// conversionClockSkew is how far in the future a conversion's occurred_at may be,
+	// allowing for the clocks of the server posting it.
+	conversionClockSkew = 5 * time.Minute
+)
+
+var (
+	// conversionEventPattern is what an event name may look like once lowercased.
+	conversionEventPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+	// currencyPattern matches an ISO 4217 currency code.
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+)
+
+// newClickID returns a random 32-character hex click ID.
+func newClickID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// attachClickID hands clickID to the visitor: as a cookie on the short link domain,
+// valid for the attribution window, and, when CLICK_ID_PARAM is set, as that query
+// parameter of the returned destination, so the destination site can post conversions
+// for it.
+func attachClickID(w http.ResponseWriter, destination, clickID string) string {
+	window := time.Duration(config.GlobalAppConfig.ConversionWindowHours) * time.Hour
+	http.SetCookie(w, &http.Cookie{
+		Name:     clickIDCookie,
+		Value:    clickID,
+		Path:     "/",
+		MaxAge:   int(window.Seconds()),
+		HttpOnly: true,
+		Secure:   config.GlobalAppConfig.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	param := config.GlobalAppConfig.ClickIDParam
+	if param == "" {
+		return destination
+	}
+	u, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+	query := u.Query()
+	query.Set(param, clickID)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// validateConversion normalizes a conversion postback, defaulting its event and
+// checking its value and currency.
+func validateConversion(req *models.ConversionRequest) error {
+	req.ClickID = strings.TrimSpace(req.ClickID)
+	if req.ClickID == "" {
+		return errors.New("click_id is required")
+	}
+	req.Event = strings.ToLower(strings.TrimSpace(req.Event))
+	if req.Event == "" {
+		req.Event = defaultConversionEvent
+	}
+	if !conversionEventPattern.MatchString(req.Event) {
+		return errors.New("event must be 1 to 64 letters, digits, '.', '_' or '-', starting with a letter or digit")
+	}
+	if req.Value != nil && (math.IsNaN(*req.Value) || math.IsInf(*req.Value, 0) || *req.Value < 0) {
+		return errors.New("value must be a non-negative number")
+	}
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency != "" && !currencyPattern.MatchString(req.Currency) {
+		return errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	return nil
+}
+
+// PostConversionHandler records a conversion postback for a click, identified by the
+// click ID tracked redirects hand out (see CONVERSION_TRACKING), and attributes it to
+// the link clicked if it happened within the attribution window. Only the link's
+// creator and admins may post conversions for it. A click's conversion of the same
+// event is recorded once: a repeated postback answers 200 instead of 201.
+func PostConversionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := bearerToken(r)
+	if !isAdminAuthCode(token) && !isValidAuthCode(token) {
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return
+	}
+
+	var req models.ConversionRequest
+	if err := decodeJSONOrYAML(r, maxConversionBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for PostConversion")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validateConversion(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	shortCode, clickedAt, err := storage.GetClickByID(ctx, req.ClickID)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSONError(w, http.StatusNotFound, "Unknown click_id")
+		return
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up click for conversion")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to record conversion")
+		return
+	}
+	link, err := storage.GetLink(ctx, shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		link = models.Link{ShortCode: shortCode}
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to record conversion")
+		return
+	}
+	if !ownsLink(token, link) {
+		writeJSONError(w, http.StatusForbidden, "Only the link's creator or an admin may post its conversions.")
+		return
+	}
+
+	now := time.Now().UTC()
+	convertedAt := now
+	if req.OccurredAt != nil {
+		convertedAt = req.OccurredAt.UTC()
+	}
+	if convertedAt.After(now.Add(conversionClockSkew)) || convertedAt.Before(clickedAt) {
+		writeJSONError(w, http.StatusBadRequest, "occurred_at must be between the click and now")
+		return
+	}
+	window := time.Duration(config.GlobalAppConfig.ConversionWindowHours) * time.Hour
+	if convertedAt.Sub(clickedAt) > window {
+		writeJSONError(w, http.StatusUnprocessableEntity, "The click is outside the attribution window")
+		return
+	}
+
+	conversion := models.Conversion{
+		ClickID:     req.ClickID,
+		ShortCode:   shortCode,
+		Event:       req.Event,
+		Value:       req.Value,
+		Currency:    req.Currency,
+		ClickedAt:   clickedAt,
+		ConvertedAt: convertedAt,
+	}
+	recorded, err := storage.RecordConversion(ctx, conversion)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to record conversion")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to record conversion")
+		return
+	}
+
+	status := http.StatusOK
+	if recorded {
+		status = http.StatusCreated
+		customlogger.FromContext(ctx).Info().Str("short_code", shortCode).Str("event", req.Event).Msg("Conversion recorded")
+	}
+	writeJSON(w, status, conversion)
+}
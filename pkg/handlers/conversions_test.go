@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestAttachClickID(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ConversionWindowHours = 24
+	config.GlobalAppConfig.Scheme = "https"
+
+	rr := httptest.NewRecorder()
+	assert.Equal(t, "https://example.com/?a=1", attachClickID(rr, "https://example.com/?a=1", "c1"))
+	cookie := rr.Result().Cookies()[0]
+	assert.Equal(t, clickIDCookie, cookie.Name)
+	assert.Equal(t, "c1", cookie.Value)
+	assert.Equal(t, 86400, cookie.MaxAge)
+	assert.True(t, cookie.Secure)
+
+	config.GlobalAppConfig.ClickIDParam = "rid"
+	assert.Equal(t, "https://example.com/?a=1&rid=c1", attachClickID(httptest.NewRecorder(), "https://example.com/?a=1", "c1"))
+}
+
+func TestPostConversionHandler(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"owner", "other"}
+	config.GlobalAppConfig.ConversionWindowHours = 24
+	ctx := context.Background()
+
+	clickedAt := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "abc", LongURL: "https://example.com", CreatedAt: clickedAt, Owner: linkOwner("owner")}))
+	require.NoError(t, storage.RecordClick(ctx, models.ClickRecord{ShortCode: "abc", Destination: "https://example.com", ClickID: "c1", At: clickedAt}))
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/conversions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		PostConversionHandler(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post("", `{"click_id": "c1"}`).Code)
+	assert.Equal(t, http.StatusForbidden, post("other", `{"click_id": "c1"}`).Code)
+	assert.Equal(t, http.StatusNotFound, post("owner", `{"click_id": "nope"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("owner", `{"click_id": "c1", "currency": "euro"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post("owner", `{"click_id": "c1", "value": -1}`).Code)
+
+	rr := post("owner", `{"click_id": "c1", "event": "Purchase", "value": 20, "currency": "eur"}`)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	assert.Contains(t, rr.Body.String(), `"short_code":"abc"`)
+	assert.Contains(t, rr.Body.String(), `"event":"purchase"`)
+	assert.Equal(t, http.StatusOK, post("owner", `{"click_id": "c1", "event": "purchase"}`).Code)
+
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	assert.Equal(t, http.StatusBadRequest, post("owner", `{"click_id": "c1", "occurred_at": "`+future+`"}`).Code)
+
+	// The click was an hour ago, outside a one-hour window by now.
+	config.GlobalAppConfig.ConversionWindowHours = 1
+	assert.Equal(t, http.StatusUnprocessableEntity, post("owner", `{"click_id": "c1", "event": "signup"}`).Code)
+	within := clickedAt.Add(30 * time.Minute).Format(time.RFC3339)
+	assert.Equal(t, http.StatusCreated, post("owner", `{"click_id": "c1", "event": "signup", "occurred_at": "`+within+`"}`).Code)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+)
+
+// socialCrawlers are lowercased fragments of the user agents of the bots chat apps and
+// social networks send to build link previews.
+var socialCrawlers = []string{
+	"twitterbot", "facebookexternalhit", "facebot", "slackbot", "linkedinbot", "discordbot",
+	"telegrambot", "whatsapp", "skypeuripreview", "redditbot", "pinterestbot", "mastodon",
+	"embedly", "iframely",
+}
+
+// isSocialCrawler reports whether userAgent belongs to a link preview bot.
+func isSocialCrawler(userAgent string) bool {
+	userAgent = strings.ToLower(userAgent)
+	for _, crawler := range socialCrawlers {
+		if strings.Contains(userAgent, crawler) {
+			return true
+		}
+	}
+	return false
+}
+
+// crawlerPreviewTemplate is the page link preview bots get instead of a redirect: the
+// destination's Open Graph tags, and a refresh to the destination for anyone else.
+var crawlerPreviewTemplate = template.Must(template.New("crawlerpreview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex{{if .NoFollow}}, nofollow{{end}}">
+<title>{{.Title}}</title>
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.ShortURL}}">
+<meta property="og:site_name" content="{{.SiteName}}">
+<meta property="og:title" content="{{.Title}}">
+{{if .Description}}<meta property="og:description" content="{{.Description}}">
+<meta name="description" content="{{.Description}}">
+{{end}}{{if .Image}}<meta property="og:image" content="{{.Image}}">
+<meta name="twitter:card" content="summary_large_image">
+{{else}}<meta name="twitter:card" content="summary">
+{{end}}<meta http-equiv="refresh" content="0;url={{.Destination}}">
+</head>
+<body>
+<p>Redirecting to <a href="{{.Destination}}"{{if .NoFollow}} rel="nofollow"{{end}}>{{.Destination}}</a>.</p>
+</body>
+</html>
+`))
+
+// crawlerPreview is what crawlerPreviewTemplate renders. NoFollow marks noindex links,
+// whose destination search engines are asked not to follow.
+type crawlerPreview struct {
+	ShortURL, Destination, SiteName string
+	Title, Description, Image       string
+	NoFollow                        bool
+}
+
+// newCrawlerPreview lays out the crawler page of a link leading to destination, from the
+// metadata of its page. The link's own title, if set, wins over the page's.
+func newCrawlerPreview(shortCode, destination, title string, meta models.PageMeta) crawlerPreview {
+	preview := crawlerPreview{
+		ShortURL:    shortURLFor(shortCode),
+		Destination: destination,
+		SiteName:    destination,
+		Title:       meta.Title,
+		Description: meta.Description,
+		Image:       meta.ImageURL,
+	}
+	if u, err := url.Parse(destination); err == nil && u.Host != "" {
+		preview.SiteName = u.Hostname()
+	}
+	if title != "" {
+		preview.Title = title
+	}
+	if preview.Title == "" {
+		preview.Title = preview.SiteName
+	}
+	return preview
+}
+
+// serveCrawlerPreview answers a link preview bot with the Open Graph tags of the page a
+// link leads to, so previews in chat apps show the destination even when its site
+// turns bots away, and the bot's visit is not counted as a click. The page is not
+// cached.
+func serveCrawlerPreview(w http.ResponseWriter, r *http.Request, preview crawlerPreview) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	if err := crawlerPreviewTemplate.Execute(w, preview); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to render crawler preview page")
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+func TestIsSocialCrawler(t *testing.T) {
+	assert.True(t, isSocialCrawler("Twitterbot/1.0"))
+	assert.True(t, isSocialCrawler("facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)"))
+	assert.True(t, isSocialCrawler("Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"))
+	assert.False(t, isSocialCrawler("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"))
+	assert.False(t, isSocialCrawler(""))
+}
+
+func TestServeCrawlerPreview(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain = "https", "riid.me"
+
+	preview := newCrawlerPreview("abc", "https://www.example.com/post", "", models.PageMeta{
+		Title: "A post", Description: "About <things>", ImageURL: "https://www.example.com/cover.png",
+	})
+	rr := httptest.NewRecorder()
+	serveCrawlerPreview(rr, httptest.NewRequest("GET", "/abc", nil), preview)
+	body := rr.Body.String()
+	assert.Equal(t, 200, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	assert.Contains(t, body, `<meta property="og:title" content="A post">`)
+	assert.Contains(t, body, `<meta property="og:description" content="About &lt;things&gt;">`)
+	assert.Contains(t, body, `<meta property="og:image" content="https://www.example.com/cover.png">`)
+	assert.Contains(t, body, `<meta property="og:url" content="https://riid.me/abc">`)
+	assert.Contains(t, body, `<meta property="og:site_name" content="www.example.com">`)
+	assert.Contains(t, body, `content="summary_large_image"`)
+
+	// The link's own title wins; without an image the card is a summary.
+	preview = newCrawlerPreview("abc", "https://www.example.com/post", "Our post", models.PageMeta{Title: "A post"})
+	rr = httptest.NewRecorder()
+	serveCrawlerPreview(rr, httptest.NewRequest("GET", "/abc", nil), preview)
+	body = rr.Body.String()
+	assert.Contains(t, body, `<meta property="og:title" content="Our post">`)
+	assert.Contains(t, body, `<meta name="twitter:card" content="summary">`)
+	assert.NotContains(t, body, "og:image")
+	assert.NotContains(t, body, "og:description")
+	assert.NotContains(t, body, "nofollow")
+
+	preview.NoFollow = true
+	rr = httptest.NewRecorder()
+	serveCrawlerPreview(rr, httptest.NewRequest("GET", "/abc", nil), preview)
+	body = rr.Body.String()
+	assert.Contains(t, body, `<meta name="robots" content="noindex, nofollow">`)
+	assert.Contains(t, body, `rel="nofollow"`)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+)
+
+// DebugRedirectHandler reports which destination and rule a redirect would use for
+// the described visitor, without redirecting or recording a click. Only the link's
+// creator and admins may debug it.
+//
+// Query parameters: ua (User-Agent), device (overrides ua), country, lang
+// (Accept-Language syntax), time (RFC3339, defaults to now), query (the query
+// string the visitor would append to the short URL, URL-encoded) and bucket (the
+// visitor's rollout percentile, 0-99). No bucket is drawn at random: without one the
+// visitor is kept out of the rollout, and the split is reported instead.
+func DebugRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+
+	params := r.URL.Query()
+	evalReq := rules.Request{
+		Country:   strings.ToUpper(strings.TrimSpace(params.Get("country"))),
+		Device:    strings.ToLower(params.Get("device")),
+		Languages: rules.ParseAcceptLanguage(params.Get("lang")),
+		Time:      time.Now().UTC(),
+		Query:     url.Values{},
+	}
+	if evalReq.Device == "" {
+		evalReq.Device = rules.DetectDevice(params.Get("ua"))
+	}
+	if t := params.Get("time"); t != "" {
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "time must be an RFC3339 timestamp")
+			return
+		}
+		evalReq.Time = parsed.UTC()
+	}
+	bucket := outsideRollouts
+	if raw := params.Get("bucket"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 || parsed > 99 {
+			writeJSONError(w, http.StatusBadRequest, "bucket must be an integer between 0 and 99")
+			return
+		}
+		bucket = parsed
+	}
+	if q := params.Get("query"); q != "" {
+		parsed, err := url.ParseQuery(q)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "query must be a URL-encoded query string")
+			return
+		}
+		evalReq.Query = parsed
+	}
+
+	defaultURL, err := storage.GetDestination(ctx, shortCode)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis for redirect debug")
+		}
+		writeStorageError(w, err, "Error retrieving URL")
+		return
+	}
+
+	decision := decideRedirectInBucket(ctx, shortCode, defaultURL, evalReq, bucket)
+	writeJSON(w, http.StatusOK, models.RedirectDebugResponse{
+		ShortCode:          shortCode,
+		Destination:        decision.Destination,
+		DefaultDestination: defaultURL,
+		MatchedRule:        decision.RuleIndex >= 0,
+		RuleIndex:          decision.RuleIndex,
+		RuleName:           decision.RuleName,
+		InRollout:          decision.InRollout,
+		Rollout:            decision.Rollout,
+		CacheMaxAge:        decision.CacheMaxAge,
+		ResponseHeaders:    decision.Headers,
+		HideReferrer:       decision.HideReferrer,
+		NoIndex:            decision.NoIndex,
+		DeviceTarget:       decision.DeviceTarget,
+		Variant:            decision.Variant,
+		Request:            evalReq,
+	})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+)
+
+func TestDebugRedirectHandler(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member", "other"}
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	serveFakeRedis(t, map[string]string{"docs": "https://example.com/docs"}, nil)
+	ctx := context.Background()
+	require.NoError(t, storage.SaveLink(ctx, models.Link{
+		ShortCode: "docs", LongURL: "https://example.com/docs", CreatedAt: time.Now().UTC(), Owner: linkOwner("member"),
+	}))
+	require.NoError(t, storage.SaveLinkRules(ctx, "docs", rules.RuleSet{Rules: []rules.Rule{
+		{Name: "germany", When: rules.Condition{Country: []string{"DE"}}, Destination: "https://example.de/docs"},
+	}}))
+	require.NoError(t, storage.SaveLinkRollout(ctx, "docs", models.LinkRollout{Destination: "https://beta.example.com/docs", Percent: 10}))
+
+	originalBucket := rolloutBucket
+	defer func() { rolloutBucket = originalBucket }()
+	rolloutBucket = func() int { panic("debugging draws no bucket") }
+
+	debug := func(code, query string) (int, models.RedirectDebugResponse) {
+		req := httptest.NewRequest(http.MethodGet, "/api/debug/redirect/docs?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+code)
+		rr := httptest.NewRecorder()
+		DebugRedirectHandler(rr, mux.SetURLVars(req, map[string]string{"shortcode": "docs"}))
+		var resp models.RedirectDebugResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		}
+		return rr.Code, resp
+	}
+
+	status, _ := debug("other", "")
+	assert.Equal(t, http.StatusForbidden, status, "only the link's creator and admins may debug it")
+
+	status, resp := debug("member", "country=de")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, resp.MatchedRule)
+	assert.Equal(t, "germany", resp.RuleName)
+	assert.Equal(t, "https://example.de/docs", resp.Destination)
+	assert.Equal(t, "https://example.com/docs", resp.DefaultDestination)
+	assert.Equal(t, "DE", resp.Request.Country)
+
+	status, resp = debug("member", "country=FR")
+	require.Equal(t, http.StatusOK, status)
+	assert.False(t, resp.MatchedRule)
+	assert.Equal(t, "https://example.com/docs", resp.Destination, "without a bucket the visitor is kept out of the rollout")
+	require.NotNil(t, resp.Rollout)
+	assert.Equal(t, 10, resp.Rollout.Percent)
+
+	status, resp = debug("member", "country=FR&bucket=9")
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, resp.InRollout)
+	assert.Equal(t, "https://beta.example.com/docs", resp.Destination)
+
+	for _, query := range []string{"bucket=100", "bucket=x", "time=yesterday"} {
+		status, _ = debug("member", query)
+		assert.Equal(t, http.StatusBadRequest, status, query)
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+// dedupable reports whether DEDUPE_LINKS may answer req with a link created earlier
+// for the same URL. Only owned requests without options qualify: a link with its own
+// handle, title, expiry or redirect behaviour is not interchangeable with another.
+// UTM parameters are part of the URL, so they are allowed.
+func dedupable(req models.URLRequest, owner string) bool {
+	return config.GlobalAppConfig.DedupeLinks && owner != "" &&
+		req.CustomHandle == "" && req.ExpirationDays == nil && req.Title == "" && !req.Public &&
+		req.CacheMaxAge == nil && !req.NotifyEachClick && !req.Draft && len(req.ResponseHeaders) == 0 &&
+		!req.HideReferrer && req.Analytics == nil && req.MaxClicks == nil && req.DeviceTargets == nil &&
+		len(req.Variants) == 0 && !req.ForwardQuery && !req.NoIndex
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+func TestDedupable(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+
+	plain := models.URLRequest{LongURL: "https://example.com", UTMSource: "newsletter"}
+	assert.False(t, dedupable(plain, "alice"), "disabled by default")
+
+	config.GlobalAppConfig.DedupeLinks = true
+	assert.True(t, dedupable(plain, "alice"))
+	assert.False(t, dedupable(plain, ""), "anonymous links have no owner to dedupe by")
+
+	days := 7
+	for _, req := range []models.URLRequest{
+		{LongURL: "https://example.com", CustomHandle: "mine"},
+		{LongURL: "https://example.com", ExpirationDays: &days},
+		{LongURL: "https://example.com", Title: "Launch"},
+		{LongURL: "https://example.com", Draft: true},
+		{LongURL: "https://example.com", NoIndex: true},
+	} {
+		assert.False(t, dedupable(req, "alice"), "%+v", req)
+	}
+}
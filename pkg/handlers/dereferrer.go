@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	customlogger "riid.me/pkg/logger"
+)
+
+// dereferrerTemplate is the page visitors of links hiding their referrer are sent
+// through. Browsers follow its meta refresh without a Referer, so the destination
+// cannot tell which page, e.g. on an intranet, the link was clicked on.
+var dereferrerTemplate = template.Must(template.New("dereferrer").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="referrer" content="no-referrer">
+<meta name="robots" content="noindex{{if .NoFollow}}, nofollow{{end}}">
+<meta http-equiv="refresh" content="0;url={{.Destination}}">
+<title>Redirecting</title>
+</head>
+<body>
+<p>Redirecting to <a href="{{.Destination}}" rel="noreferrer{{if .NoFollow}} nofollow{{end}}">{{.Destination}}</a>.</p>
+</body>
+</html>
+`))
+
+// dereferredPage is what dereferrerTemplate renders. NoFollow marks noindex links.
+type dereferredPage struct {
+	Destination string
+	NoFollow    bool
+}
+
+// serveDereferred sends the visitor on to destination through dereferrerTemplate
+// instead of a redirect, which would pass the Referer on. The page is cached like the
+// redirect it replaces; noFollow asks search engines not to follow its link.
+func serveDereferred(w http.ResponseWriter, r *http.Request, destination string, cacheMaxAge int, noFollow bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	setRedirectCacheHeaders(w, cacheMaxAge)
+	w.WriteHeader(http.StatusOK)
+	if err := dereferrerTemplate.Execute(w, dereferredPage{destination, noFollow}); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to render dereferrer page")
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeDereferred(t *testing.T) {
+	rr := httptest.NewRecorder()
+	serveDereferred(rr, httptest.NewRequest(http.MethodGet, "/abc", nil), `https://example.com/a?b=1&c="><script>`, 0, false)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "no-referrer", rr.Header().Get("Referrer-Policy"))
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	body := rr.Body.String()
+	assert.Contains(t, body, `<meta name="referrer" content="no-referrer">`)
+	assert.Contains(t, body, `<meta http-equiv="refresh" content="0;url=https://example.com/a?b=1&amp;c=&#34;&gt;&lt;script&gt;">`)
+	assert.NotContains(t, body, "<script>")
+	assert.Contains(t, body, `<meta name="robots" content="noindex">`)
+
+	rr = httptest.NewRecorder()
+	serveDereferred(rr, httptest.NewRequest(http.MethodGet, "/abc", nil), "https://example.com/", 0, true)
+	body = rr.Body.String()
+	assert.Contains(t, body, `<meta name="robots" content="noindex, nofollow">`)
+	assert.Contains(t, body, `rel="noreferrer nofollow"`)
+}
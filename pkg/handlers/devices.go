@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"fmt"
+
+	"riid.me/pkg/models"
+	"riid.me/pkg/validation"
+)
+
+// validateDeviceTargets normalizes a link's per-device destinations and vets them
+// against the destination policy like its default destination. It returns nil when no
+// target is set.
+func validateDeviceTargets(targets *models.DeviceTargets) (*models.DeviceTargets, error) {
+	if targets == nil || *targets == (models.DeviceTargets{}) {
+		return nil, nil
+	}
+	normalized := *targets
+	for device, target := range map[string]*string{"ios": &normalized.IOS, "android": &normalized.Android, "desktop": &normalized.Desktop} {
+		if *target == "" {
+			continue
+		}
+		*target = NormalizeURL(*target)
+		if err := validation.ValidateDestination(*target); err != nil {
+			return nil, fmt.Errorf("device_targets.%s: %w", device, err)
+		}
+	}
+	return &normalized, nil
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+)
+
+func TestValidateDeviceTargets(t *testing.T) {
+	targets, err := validateDeviceTargets(&models.DeviceTargets{})
+	require.NoError(t, err)
+	assert.Nil(t, targets)
+
+	targets, err = validateDeviceTargets(&models.DeviceTargets{IOS: "apps.apple.com/app/id1"})
+	require.NoError(t, err)
+	assert.Equal(t, &models.DeviceTargets{IOS: "https://apps.apple.com/app/id1"}, targets)
+
+	_, err = validateDeviceTargets(&models.DeviceTargets{Android: "javascript:alert(1)"})
+	assert.ErrorContains(t, err, "device_targets.android")
+}
+
+func TestDecideRedirectUsesDeviceTargets(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{
+		ShortCode: "app", LongURL: "https://example.com", CreatedAt: time.Now().UTC(),
+		DeviceTargets: &models.DeviceTargets{IOS: "https://apps.apple.com/app/id1", Android: "https://play.google.com/store/apps/details?id=app"},
+	}))
+
+	decision := decideRedirect(ctx, "app", "https://example.com", rules.Request{Device: rules.DeviceIOS})
+	assert.Equal(t, "https://apps.apple.com/app/id1", decision.Destination)
+	assert.Equal(t, rules.DeviceIOS, decision.DeviceTarget)
+	assert.Equal(t, 0, decision.CacheMaxAge)
+
+	decision = decideRedirect(ctx, "app", "https://example.com", rules.Request{Device: rules.DeviceAndroid})
+	assert.Equal(t, "https://play.google.com/store/apps/details?id=app", decision.Destination)
+
+	// Devices without a target get the default destination.
+	decision = decideRedirect(ctx, "app", "https://example.com", rules.Request{Device: rules.DeviceDesktop})
+	assert.Equal(t, "https://example.com", decision.Destination)
+	assert.Empty(t, decision.DeviceTarget)
+
+	// A matching rule takes precedence over device targets.
+	require.NoError(t, storage.SaveLinkRules(ctx, "app", rules.RuleSet{Rules: []rules.Rule{
+		{Name: "all", Destination: "https://rule.example.com"},
+	}}))
+	decision = decideRedirect(ctx, "app", "https://example.com", rules.Request{Device: rules.DeviceIOS})
+	assert.Equal(t, "https://rule.example.com", decision.Destination)
+}
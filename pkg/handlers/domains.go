@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+// maxNotFoundHTMLBytes caps the size of a domain's custom 404 page.
+const maxNotFoundHTMLBytes = 64 << 10
+
+// requestHost returns the lowercased host of a request without its port.
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// lookupDomainRoute returns the routing entry for the request's host, if any.
+func lookupDomainRoute(r *http.Request) (models.DomainRoute, bool) {
+	route, err := storage.GetDomainRoute(r.Context(), requestHost(r))
+	if errors.Is(err, storage.ErrNotFound) {
+		return models.DomainRoute{}, false
+	} else if err != nil {
+		customlogger.Error().Err(err).Str("host", r.Host).Msg("Failed to look up domain route")
+		return models.DomainRoute{}, false
+	}
+	return route, true
+}
+
+// RootHandler serves the root path. Domains with a configured root destination are
+// redirected there; all others get the regular homepage.
+func RootHandler(w http.ResponseWriter, r *http.Request) {
+	if route, ok := lookupDomainRoute(r); ok && route.RootDestination != "" {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, route.RootDestination, http.StatusFound)
+		return
+	}
+	http.ServeFile(w, r, "./static/index.html")
+}
+
+// ListDomainRoutesHandler returns all domain routing entries.
+func ListDomainRoutesHandler(w http.ResponseWriter, r *http.Request) {
+	domains, err := storage.ListDomainRoutes(r.Context())
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Failed to list domain routes")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve domains")
+		return
+	}
+	writeJSON(w, http.StatusOK, domains)
+}
+
+// PutDomainRouteHandler creates or replaces the routing entry for a domain.
+func PutDomainRouteHandler(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(strings.TrimSpace(mux.Vars(r)["host"]))
+
+	var req models.DomainRoute
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxNotFoundHTMLBytes+4096)).Decode(&req); err != nil {
+		customlogger.Error().Err(err).Msg("Invalid request body for PutDomainRoute")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Host = host
+
+	if req.RootDestination != "" {
+		req.RootDestination = NormalizeURL(req.RootDestination)
+		if err := validation.ValidateDestination(req.RootDestination); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if len(req.NotFoundHTML) > maxNotFoundHTMLBytes {
+		writeJSONError(w, http.StatusBadRequest, "not_found_html is too large")
+		return
+	}
+
+	route, err := storage.SaveDomainRoute(r.Context(), req)
+	if err != nil {
+		customlogger.Error().Err(err).Str("host", host).Msg("Failed to store domain route")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store domain")
+		return
+	}
+
+	customlogger.Info().Str("host", host).Str("root_destination", route.RootDestination).Msg("Domain route saved")
+	writeJSON(w, http.StatusOK, route)
+}
+
+// DeleteDomainRouteHandler removes the routing entry for a domain.
+func DeleteDomainRouteHandler(w http.ResponseWriter, r *http.Request) {
+	host := strings.ToLower(mux.Vars(r)["host"])
+
+	if err := storage.DeleteDomainRoute(r.Context(), host); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.Error().Err(err).Str("host", host).Msg("Failed to delete domain route")
+		}
+		writeStorageError(w, err, "Failed to delete domain")
+		return
+	}
+
+	customlogger.Info().Str("host", host).Msg("Domain route deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
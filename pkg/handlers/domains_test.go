@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/storage"
+)
+
+func TestDomainRoutes(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.NotFoundMode = config.NotFoundMode404
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+
+	put := func(host, body string) int {
+		req := httptest.NewRequest(http.MethodPut, "/api/admin/domains/"+host, strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		PutDomainRouteHandler(rr, mux.SetURLVars(req, map[string]string{"host": host}))
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, put("go.example.com", `{"root_destination":"javascript:alert(1)"}`))
+	assert.Equal(t, http.StatusBadRequest, put("go.example.com", `{"not_found_html":"`+strings.Repeat("x", maxNotFoundHTMLBytes+1)+`"}`))
+	require.Equal(t, http.StatusOK, put("Go.Example.com", `{"root_destination":"intranet.example.com/home","not_found_html":"<h1>No such go link</h1>"}`))
+
+	// The root of a routed domain redirects, whatever the case or port it is requested with.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "GO.example.com:8080"
+	rr := httptest.NewRecorder()
+	RootHandler(rr, req)
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, "https://intranet.example.com/home", rr.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/nope", nil)
+	req.Host = "go.example.com"
+	rr = httptest.NewRecorder()
+	serveNotFound(rr, req, "Short URL not found")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "<h1>No such go link</h1>", rr.Body.String())
+
+	// Other domains keep the homepage and the regular not-found response.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "riid.me"
+	rr = httptest.NewRecorder()
+	RootHandler(rr, req)
+	assert.Empty(t, rr.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/nope", nil)
+	req.Host = "riid.me"
+	rr = httptest.NewRecorder()
+	serveNotFound(rr, req, "Short URL not found")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "Short URL not found\n", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	DeleteDomainRouteHandler(rr, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/", nil), map[string]string{"host": "GO.EXAMPLE.COM"}))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+	rr = httptest.NewRecorder()
+	DeleteDomainRouteHandler(rr, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/", nil), map[string]string{"host": "go.example.com"}))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// previewQueryParam is the query parameter a draft link's preview token is passed in.
+const previewQueryParam = "preview"
+
+// newLinkPreview issues a fresh preview token for a draft link, revoking the previous one.
+func newLinkPreview(ctx context.Context, shortCode string) (*models.LinkPreview, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(raw)
+	expiresAt := time.Now().UTC().Add(time.Duration(config.GlobalAppConfig.PreviewTokenHours) * time.Hour).Truncate(time.Second)
+	if err := storage.SavePreviewToken(ctx, shortCode, token, expiresAt); err != nil {
+		return nil, err
+	}
+	return &models.LinkPreview{
+		Token:     token,
+		URL:       shortURLFor(shortCode) + "?" + previewQueryParam + "=" + token,
+		ExpiresAt: expiresAt,
+	}, nil
+}
+
+// CreateLinkPreviewHandler issues a new preview token for a draft link, e.g. after
+// the previous one expired.
+func CreateLinkPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+	ctx := r.Context()
+
+	link, err := storage.GetLink(ctx, shortCode)
+	if err == nil && !link.Draft {
+		err = storage.ErrDraftNotFound
+	}
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load link for preview")
+		}
+		writeStorageError(w, err, "Error retrieving link")
+		return
+	}
+
+	preview, err := newLinkPreview(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to issue preview token")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to issue preview token")
+		return
+	}
+	writeJSON(w, http.StatusCreated, preview)
+}
+
+// PublishLinkHandler takes a draft link live and revokes its preview token.
+func PublishLinkHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+	ctx := r.Context()
+
+	link, err := storage.PublishLink(ctx, shortCode)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to publish link")
+		}
+		writeStorageError(w, err, "Failed to publish link")
+		return
+	}
+	indexNewLink(link)
+
+	customlogger.FromContext(ctx).Info().Msg("Draft link published")
+	writeJSON(w, http.StatusOK, models.LinkDetailResponse{
+		Link:     link,
+		ShortURL: shortURLFor(shortCode),
+	})
+}
+
+// servePreview redirects a visitor of a draft link presenting its preview token, and
+// answers everyone else as if the link did not exist. Previews are neither cached nor
+// counted as clicks.
+func servePreview(w http.ResponseWriter, r *http.Request, shortCode, destination string) {
+	ctx := r.Context()
+	ok, err := storage.ValidPreviewToken(ctx, shortCode, r.URL.Query().Get(previewQueryParam))
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to check preview token")
+		http.Error(w, "Error retrieving URL", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		customlogger.FromContext(ctx).Info().Msg("Draft link requested without a valid preview token")
+		serveNotFound(w, r, "Short URL not found")
+		return
+	}
+	customlogger.FromContext(ctx).Info().Str("long_url", destination).Msg("Redirecting preview of draft link")
+	setRedirectCacheHeaders(w, 0)
+	http.Redirect(w, r, destination, http.StatusFound)
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"riid.me/pkg/config"
+	"riid.me/pkg/drops"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// dropFormOverhead is the allowance for multipart framing and form fields on top
+	// of the file size limit.
+	dropFormOverhead = 64 << 10
+	// dropFormMemory is how much of an upload is buffered in memory before spilling
+	// to a temporary file.
+	dropFormMemory = 1 << 20
+	// dropTag marks the links created for drops.
+	dropTag = "drop"
+)
+
+// DropStore keeps the files of drops; nil when DROPS_DIR is not configured and drops
+// are disabled.
+var DropStore drops.Store
+
+// inlineDropTypes are the content types browsers may display in place. Anything else,
+// notably HTML and SVG which could run script on our domain, is served as a download.
+var inlineDropTypes = map[string]bool{
+	"application/pdf": true,
+	"image/gif":       true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/webp":      true,
+	"text/plain":      true,
+	"audio/mpeg":      true,
+	"video/mp4":       true,
+}
+
+// sanitizeFilename reduces an uploaded file's name to a safe base name for URLs and
+// Content-Disposition headers.
+func sanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' || r == '/' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." {
+		return "file"
+	}
+	if len(name) > 200 {
+		name = name[len(name)-200:]
+	}
+	return name
+}
+
+// dropContentType determines the content type of an upload: from the file name's
+// extension when known, and by sniffing the first bytes of content otherwise.
+func dropContentType(filename string, content io.ReadSeeker) (string, error) {
+	if byExt := mime.TypeByExtension(path.Ext(filename)); byExt != "" {
+		return byExt, nil
+	}
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// dropDownloadURL is the address a drop's file is served at; the drop's short link
+// redirects there.
+func dropDownloadURL(shortCode, filename string) string {
+	return fmt.Sprintf("%s://%s/d/%s/%s", config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain, shortCode, url.PathEscape(filename))
+}
+
+// newDropFileKey returns a random key to store a dropped file under.
+func newDropFileKey() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// dropResponse builds the description of a drop, with the link's expiry and clicks.
+func dropResponse(ctx context.Context, drop models.Drop) (models.DropResponse, error) {
+	expiresAt, err := storage.LinkExpiry(ctx, drop.ShortCode)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return models.DropResponse{}, err
+	}
+	clicks, err := storage.CountClicks(ctx, drop.ShortCode)
+	if err != nil {
+		return models.DropResponse{}, err
+	}
+	drop.CreatedAt = drop.CreatedAt.UTC().Truncate(time.Second)
+	return models.DropResponse{
+		Drop:        drop,
+		ShortURL:    shortURLFor(drop.ShortCode),
+		DownloadURL: dropDownloadURL(drop.ShortCode, drop.Filename),
+		ExpiresAt:   expiresAt,
+		Clicks:      clicks,
+	}, nil
+}
+
+// CreateDropHandler accepts a multipart upload of a single file (form field "file",
+// optional "custom_handle" and "expiration_days") and creates a short link serving it.
+func CreateDropHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if DropStore == nil {
+		writeJSONError(w, http.StatusNotFound, "File drops are not enabled.")
+		return
+	}
+	if !isValidAuthCode(bearerToken(r)) {
+		customlogger.FromContext(ctx).Warn().Msg("Unauthorized attempt to drop a file")
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return
+	}
+
+	maxBytes := config.GlobalAppConfig.DropMaxBytes
+	tooLarge := fmt.Sprintf("Files may be at most %d bytes.", maxBytes)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+dropFormOverhead)
+	if err := r.ParseMultipartForm(dropFormMemory); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "Expected a multipart/form-data upload")
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "A file is required in the \"file\" field.")
+		return
+	}
+	defer file.Close()
+	if header.Size > maxBytes {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+
+	var days *int
+	if raw := r.FormValue("expiration_days"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "expiration_days must be an integer")
+			return
+		}
+		days = &parsed
+	}
+
+	filename := sanitizeFilename(header.Filename)
+	contentType, err := dropContentType(filename, file)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to read uploaded file")
+		writeJSONError(w, http.StatusBadRequest, "Failed to read uploaded file")
+		return
+	}
+
+	link, ok := createHostedLink(w, r, hostedLinkRequest{
+		CustomHandle:   strings.TrimSpace(r.FormValue("custom_handle")),
+		ExpirationDays: days,
+		Title:          filename,
+		Tag:            dropTag,
+		Target:         func(code string) string { return dropDownloadURL(code, filename) },
+	})
+	if !ok {
+		return
+	}
+	ctx = customlogger.WithContext(ctx, customlogger.ShortCodeField, link.ShortCode)
+
+	drop := models.Drop{
+		ShortCode:   link.ShortCode,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        header.Size,
+		CreatedAt:   link.CreatedAt,
+	}
+	drop.FileKey, err = newDropFileKey()
+	if err == nil {
+		err = DropStore.Save(ctx, drop.FileKey, file)
+	}
+	if err != nil {
+		removeHostedLink(ctx, link)
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store dropped file")
+		writeJSONError(w, http.StatusInternalServerError, "Error storing file")
+		return
+	}
+	if err := storage.SaveDrop(ctx, drop); err != nil {
+		removeHostedLink(ctx, link)
+		if err := DropStore.Delete(ctx, drop.FileKey); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to remove file of failed drop")
+		}
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to record drop")
+		writeJSONError(w, http.StatusInternalServerError, "Error storing file")
+		return
+	}
+
+	resp, err := dropResponse(ctx, drop)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to describe drop")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving drop")
+		return
+	}
+	customlogger.FromContext(ctx).Info().Str("filename", filename).Int64("size", header.Size).Msg("File dropped")
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// GetDropHandler returns a drop's file details, expiry, clicks and downloads.
+func GetDropHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+	ctx := r.Context()
+
+	drop, err := storage.GetDrop(ctx, shortCode)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load drop")
+		}
+		writeStorageError(w, err, "Error retrieving drop")
+		return
+	}
+	resp, err := dropResponse(ctx, drop)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to describe drop")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving drop")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// dropOfCurrentLink reports whether drop was uploaded with the link now stored under
+// its short code, rather than with an expired one whose code was registered again
+// before the drop was cleaned up. Drops are created along with their link, and take
+// its creation time.
+func dropOfCurrentLink(ctx context.Context, drop models.Drop) (bool, error) {
+	link, err := storage.GetLink(ctx, drop.ShortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return link.CreatedAt.Truncate(time.Second).Equal(drop.CreatedAt.Truncate(time.Second)), nil
+}
+
+// ServeDropHandler serves the file of a drop for as long as its link is live, with
+// its content type, and counts the download. Range requests are supported.
+func ServeDropHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+	if DropStore == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The link's TTL is the drop's expiry.
+	if _, err := storage.GetDestination(ctx, shortCode); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis for drop")
+			http.Error(w, "Error retrieving file", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, errorMessage(err), storageErrorStatus(err))
+		return
+	}
+	if serveTakedown(w, r, shortCode) {
+		return
+	}
+	drop, err := storage.GetDrop(ctx, shortCode)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load drop")
+			http.Error(w, "Error retrieving file", http.StatusInternalServerError)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	current, err := dropOfCurrentLink(ctx, drop)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve link metadata for drop")
+		http.Error(w, "Error retrieving file", http.StatusInternalServerError)
+		return
+	}
+	if !current {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, err := DropStore.Open(ctx, drop.FileKey)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to open dropped file")
+		http.Error(w, "Error retrieving file", http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+
+	disposition := "attachment"
+	if mediaType, _, err := mime.ParseMediaType(drop.ContentType); err == nil && inlineDropTypes[mediaType] {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", drop.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": drop.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	// Follow-up range requests of the same download are not counted again.
+	if r.Method == http.MethodGet && r.Header.Get("Range") == "" {
+		if err := storage.IncrementDropDownloads(ctx, shortCode); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count drop download")
+		}
+	}
+	http.ServeContent(w, r, drop.Filename, drop.CreatedAt, content)
+}
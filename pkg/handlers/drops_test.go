@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	assert.Equal(t, "report.pdf", sanitizeFilename("report.pdf"))
+	assert.Equal(t, "passwd", sanitizeFilename("../../etc/passwd"))
+	assert.Equal(t, "notes.txt", sanitizeFilename(`C:\Users\me\notes.txt`))
+	assert.Equal(t, "evil.html", sanitizeFilename("\"evil\r\n.html"))
+	assert.Equal(t, "file", sanitizeFilename(".."))
+	assert.Equal(t, "file", sanitizeFilename(""))
+}
+
+func TestDropContentType(t *testing.T) {
+	contentType, err := dropContentType("photo.png", bytes.NewReader(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "image/png", contentType)
+
+	content := bytes.NewReader([]byte("%PDF-1.7\n"))
+	contentType, err = dropContentType("no-extension", content)
+	require.NoError(t, err)
+	assert.Equal(t, "application/pdf", contentType)
+	assert.EqualValues(t, 0, content.Size()-int64(content.Len()), "sniffing rewinds the content")
+}
+
+func TestCreateDropHandlerDisabled(t *testing.T) {
+	DropStore = nil
+	rr := httptest.NewRecorder()
+	CreateDropHandler(rr, httptest.NewRequest(http.MethodPost, "/api/drops", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
+func TestDropOfCurrentLink(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+	created := time.Now().UTC().Add(-48 * time.Hour)
+	drop := models.Drop{ShortCode: "report", FileKey: "0123456789abcdef", Filename: "report.pdf", CreatedAt: created}
+
+	current, err := dropOfCurrentLink(ctx, drop)
+	require.NoError(t, err)
+	assert.False(t, current, "drops without a link are not served")
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "report", LongURL: "https://riid.me/d/report", CreatedAt: created}))
+	current, err = dropOfCurrentLink(ctx, drop)
+	require.NoError(t, err)
+	assert.True(t, current)
+
+	// The code expired and was registered again before the drop was cleaned up.
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "report", LongURL: "https://example.com", CreatedAt: time.Now().UTC()}))
+	current, err = dropOfCurrentLink(ctx, drop)
+	require.NoError(t, err)
+	assert.False(t, current, "the new link must not serve the previous owner's file")
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// defaultEventsPageSize is the number of outbox events returned when no limit is given.
+	defaultEventsPageSize = 100
+	// maxEventsPageSize caps the number of outbox events returned per request.
+	maxEventsPageSize = 1000
+)
+
+// ListLinkEventsHandler exports the outbox of link lifecycle events, oldest first.
+// Consumers page through it with the "after" and "limit" query parameters, passing
+// back next_after from each response.
+func ListLinkEventsHandler(w http.ResponseWriter, r *http.Request) {
+	after, limit, err := parseEventsPage(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := storage.ListLinkEvents(r.Context(), after, limit)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to list link events")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve events")
+		return
+	}
+
+	next := after
+	if len(events) > 0 {
+		next = events[len(events)-1].ID
+	}
+	writeJSON(w, http.StatusOK, models.LinkEventsResponse{Events: events, NextAfter: next})
+}
+
+// parseEventsPage reads the "after" and "limit" query parameters of an event log
+// export, defaulting to the start of the log and defaultEventsPageSize events.
+func parseEventsPage(query url.Values) (int64, int, error) {
+	var after int64
+	if v := query.Get("after"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			return 0, 0, errors.New("after must be a non-negative event ID")
+		}
+		after = parsed
+	}
+
+	limit := defaultEventsPageSize
+	if v := query.Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > maxEventsPageSize {
+			return 0, 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxEventsPageSize))
+		}
+		limit = parsed
+	}
+	return after, limit, nil
+}
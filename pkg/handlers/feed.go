@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/storage"
+)
+
+// feedSize is the number of recently created public links listed in the feed.
+const feedSize = 50
+
+// atomFeed is the root element of an Atom 1.0 feed (RFC 4287).
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomLink is an Atom link element.
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+// atomEntry is a single Atom feed entry describing one public link.
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Updated string     `xml:"updated"`
+	Links   []atomLink `xml:"link"`
+	Summary string     `xml:"summary"`
+}
+
+// GetFeedHandler serves an Atom feed of recently created public links,
+// giving community deployments a "what's new" stream.
+func GetFeedHandler(w http.ResponseWriter, r *http.Request) {
+	links, err := storage.RecentPublicLinks(r.Context(), feedSize)
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Failed to query public links for feed")
+		http.Error(w, "Failed to generate feed", http.StatusInternalServerError)
+		return
+	}
+
+	siteURL := config.GlobalAppConfig.Scheme + "://" + config.GlobalAppConfig.Domain + "/"
+	feed := atomFeed{
+		Title:   config.GlobalAppConfig.Domain + " - new links",
+		ID:      siteURL + "feed.xml",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: siteURL + "feed.xml", Rel: "self"},
+			{Href: siteURL},
+		},
+	}
+	if len(links) > 0 {
+		feed.Updated = links[0].CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	for _, link := range links {
+		shortURL := shortURLFor(link.ShortCode)
+		title := link.Title
+		if title == "" {
+			title = shortURL
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title:   title,
+			ID:      shortURL,
+			Updated: link.CreatedAt.UTC().Format(time.RFC3339),
+			Links:   []atomLink{{Href: shortURL}},
+			Summary: link.LongURL,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(feed); err != nil {
+		customlogger.Error().Err(err).Msg("Failed to encode feed")
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestGetFeedListsPublicLinks(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain = "https", "riid.me"
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	expired := now.Add(-time.Minute)
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "old", LongURL: "https://example.com/old", CreatedAt: now.Add(-time.Hour), Public: true}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "new", LongURL: "https://example.com/new", Title: "New docs", CreatedAt: now, Public: true}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "private", LongURL: "https://example.com/p", CreatedAt: now}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "wip", LongURL: "https://example.com/w", CreatedAt: now, Public: true, Draft: true}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "gone", LongURL: "https://example.com/g", CreatedAt: now, Public: true, ExpiresAt: &expired}))
+
+	w := httptest.NewRecorder()
+	GetFeedHandler(w, httptest.NewRequest(http.MethodGet, "/feed.xml", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &feed))
+	assert.Equal(t, now.Format(time.RFC3339), feed.Updated, "the feed is as recent as its newest link")
+	require.Len(t, feed.Entries, 2, "private links, drafts and expired links are left out")
+	assert.Equal(t, "New docs", feed.Entries[0].Title)
+	assert.Equal(t, "https://riid.me/new", feed.Entries[0].ID)
+	assert.Equal(t, "https://example.com/new", feed.Entries[0].Summary)
+	assert.Equal(t, "https://riid.me/old", feed.Entries[1].Title, "untitled links are listed by their short URL")
+}
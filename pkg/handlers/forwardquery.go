@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ownQueryParams are query parameters the redirect itself consumes, never forwarded.
+var ownQueryParams = map[string]bool{
+	clickSourceParam:    true,
+	previewQueryParam:   true,
+	challengeQueryParam: true,
+}
+
+// forwardQuery adds the query parameters of a short URL request, rawQuery, to
+// destination, for links created with forward_query. Parameters the destination
+// already has keep its value, so visitors cannot override e.g. an affiliate tag, and
+// the redirect's own parameters, such as src, are left out.
+func forwardQuery(destination, rawQuery string) string {
+	if rawQuery == "" {
+		return destination
+	}
+	u, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+	existing := u.Query()
+	var added []string
+	for _, pair := range strings.Split(rawQuery, "&") {
+		key, _, _ := strings.Cut(pair, "=")
+		name, err := url.QueryUnescape(key)
+		if pair == "" || err != nil || name == "" || ownQueryParams[name] || existing.Has(name) {
+			continue
+		}
+		added = append(added, pair)
+	}
+	if len(added) == 0 {
+		return destination
+	}
+	if u.RawQuery != "" {
+		added = append([]string{u.RawQuery}, added...)
+	}
+	u.RawQuery = strings.Join(added, "&")
+	return u.String()
+}
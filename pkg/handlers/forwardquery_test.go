@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+)
+
+func TestForwardQuery(t *testing.T) {
+	for _, tc := range []struct{ destination, query, want string }{
+		{"https://example.com/page", "", "https://example.com/page"},
+		{"https://example.com/page", "ref=twitter", "https://example.com/page?ref=twitter"},
+		{"https://example.com/page?a=1#top", "ref=twitter&q=a%20b", "https://example.com/page?a=1&ref=twitter&q=a%20b#top"},
+		// The destination's own parameters win, and the redirect's are not forwarded.
+		{"https://shop.example.com/?tag=owner-20", "tag=someone-else&src=lobby&preview=x&utm_source=mail", "https://shop.example.com/?tag=owner-20&utm_source=mail"},
+		{"https://example.com/", "src=lobby", "https://example.com/"},
+	} {
+		assert.Equal(t, tc.want, forwardQuery(tc.destination, tc.query), tc.query)
+	}
+}
+
+func TestDecideRedirectForwardQuery(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "aff", LongURL: "https://example.com", CreatedAt: time.Now().UTC(), ForwardQuery: true}))
+	assert.True(t, decideRedirect(ctx, "aff", "https://example.com", rules.Request{}).ForwardQuery)
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "plain", LongURL: "https://example.com", CreatedAt: time.Now().UTC()}))
+	assert.False(t, decideRedirect(ctx, "plain", "https://example.com", rules.Request{}).ForwardQuery)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// requireUnfrozen refuses changes to where a frozen link leads, and its deletion. It
+// writes the error response and returns false when the request should not proceed.
+func requireUnfrozen(w http.ResponseWriter, r *http.Request, shortCode string) bool {
+	link, err := storage.GetLink(r.Context(), shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		return true
+	} else if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to retrieve link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return false
+	}
+	if link.Frozen {
+		customlogger.FromContext(r.Context()).Warn().Msg("Attempt to change a frozen link")
+		writeJSONError(w, http.StatusConflict, "Link is frozen; an admin must unfreeze it before it can be changed.")
+		return false
+	}
+	return true
+}
+
+// FreezeLinkHandler marks a link as frozen, e.g. once it is printed on physical
+// materials, so its destination, redirect rules and rollout cannot be changed and it
+// cannot be deleted until an admin unfreezes it.
+func FreezeLinkHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+	setLinkFrozen(w, r, shortCode, true)
+}
+
+// UnfreezeLinkHandler lets a frozen link be changed again. Only admins may unfreeze.
+func UnfreezeLinkHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !isAdminAuthCode(bearerToken(r)) {
+		customlogger.FromContext(r.Context()).Warn().Msg("Unauthorized attempt to unfreeze link")
+		writeJSONError(w, http.StatusForbidden, "Only an admin may unfreeze a link.")
+		return
+	}
+	setLinkFrozen(w, r, shortCode, false)
+}
+
+// setLinkFrozen stores the frozen flag of a link and responds with its details. Links
+// created before metadata was recorded get a record, so they can be frozen too.
+func setLinkFrozen(w http.ResponseWriter, r *http.Request, shortCode string, frozen bool) {
+	ctx := r.Context()
+	link, err := storage.GetLink(ctx, shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		longURL, err := storage.GetDestination(ctx, shortCode)
+		if err != nil {
+			if storageErrorStatus(err) == http.StatusInternalServerError {
+				customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis")
+			}
+			writeStorageError(w, err, "Error retrieving link")
+			return
+		}
+		link = models.Link{ShortCode: shortCode, LongURL: longURL, CreatedAt: time.Now().UTC()}
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return
+	}
+
+	link.Frozen = frozen
+	if err := storage.UpdateLink(ctx, link); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store frozen flag of link")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to update link")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Bool("frozen", frozen).Msg("Link freeze updated")
+	writeJSON(w, http.StatusOK, models.LinkDetailResponse{Link: link, ShortURL: shortURLFor(shortCode)})
+}
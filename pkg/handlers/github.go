@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// maxGitHubWebhookBytes caps the size of a GitHub webhook payload.
+	maxGitHubWebhookBytes = 5 << 20
+	// githubReleaseTag marks links maintained by the GitHub release integration.
+	// Only links carrying it are ever overwritten by a webhook.
+	githubReleaseTag = "github-release"
+	// latestLinkPrefix prefixes the code of each repository's latest-release link.
+	latestLinkPrefix = "latest-"
+)
+
+// githubReleaseAsset is a downloadable file attached to a GitHub release.
+type githubReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// githubReleaseEvent is the subset of a GitHub "release" webhook payload we use.
+type githubReleaseEvent struct {
+	Action  string `json:"action"`
+	Release struct {
+		HTMLURL    string               `json:"html_url"`
+		TagName    string               `json:"tag_name"`
+		Name       string               `json:"name"`
+		Draft      bool                 `json:"draft"`
+		Prerelease bool                 `json:"prerelease"`
+		Assets     []githubReleaseAsset `json:"assets"`
+	} `json:"release"`
+	Repository struct {
+		Name     string `json:"name"`
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// validGitHubSignature checks the X-Hub-Signature-256 header of a webhook delivery
+// against the HMAC-SHA256 of its body.
+func validGitHubSignature(secret string, body []byte, header string) bool {
+	sig, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// latestLinkCode returns the short code of a repository's latest-release link,
+// e.g. "latest-my-tool" for "My_Tool".
+func latestLinkCode(repo string) string {
+	var b strings.Builder
+	for _, c := range strings.ToLower(repo) {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-':
+			b.WriteRune(c)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	return latestLinkPrefix + b.String()
+}
+
+// releaseDestination picks what a latest-release link points to: the first asset
+// whose name matches pattern (any asset when pattern is empty), or the release page
+// when there is none.
+func releaseDestination(event githubReleaseEvent, pattern string) string {
+	for _, asset := range event.Release.Assets {
+		if pattern == "" {
+			return asset.BrowserDownloadURL
+		}
+		if ok, _ := path.Match(pattern, asset.Name); ok {
+			return asset.BrowserDownloadURL
+		}
+	}
+	return event.Release.HTMLURL
+}
+
+// GitHubWebhookHandler receives GitHub release webhooks and points the repository's
+// /latest-{repo} link at the newest published release asset, creating the link on
+// the first release. Deliveries must be signed with GITHUB_WEBHOOK_SECRET, or while it
+// is rotated with GITHUB_WEBHOOK_PREVIOUS_SECRET.
+func GitHubWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	secret := config.GlobalAppConfig.GitHubWebhookSecret
+	if secret == "" {
+		writeJSONError(w, http.StatusNotFound, "GitHub integration is not configured")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxGitHubWebhookBytes))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to read payload")
+		return
+	}
+	signature := r.Header.Get("X-Hub-Signature-256")
+	previous := config.GlobalAppConfig.GitHubWebhookPreviousSecret
+	if !validGitHubSignature(secret, body, signature) && (previous == "" || !validGitHubSignature(previous, body, signature)) {
+		customlogger.FromContext(ctx).Warn().Str("remote", r.RemoteAddr).Msg("GitHub webhook with invalid signature")
+		writeJSONError(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	switch r.Header.Get("X-GitHub-Event") {
+	case "ping":
+		writeJSON(w, http.StatusOK, map[string]string{"status": "pong"})
+		return
+	case "release":
+	default:
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "ignored"})
+		return
+	}
+
+	var event githubReleaseEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid release payload")
+		return
+	}
+	if event.Action != "published" || event.Release.Draft || event.Release.Prerelease || event.Repository.Name == "" {
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "ignored"})
+		return
+	}
+
+	destination := releaseDestination(event, config.GlobalAppConfig.GitHubReleaseAsset)
+	code := latestLinkCode(event.Repository.Name)
+	ctx = customlogger.WithContext(ctx, customlogger.ShortCodeField, code)
+
+	link, err := storage.GetLink(ctx, code)
+	exists := err == nil
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load latest-release link")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to update link")
+		return
+	}
+	if exists && !hasTag(link.Tags, githubReleaseTag) {
+		writeJSONError(w, http.StatusConflict, "Short code '"+code+"' is used by a link not managed by the GitHub integration.")
+		return
+	}
+	if link.Frozen {
+		writeJSONError(w, http.StatusConflict, "Link '"+code+"' is frozen; an admin must unfreeze it before it can be updated.")
+		return
+	}
+
+	title := event.Release.Name
+	if title == "" {
+		title = event.Release.TagName
+	}
+	link.ShortCode = code
+	link.LongURL = destination
+	link.Title = event.Repository.FullName + " " + title
+	link.Tags = []string{githubReleaseTag, event.Repository.FullName}
+	link.ExpiresAt = nil
+
+	if exists {
+		err = storage.SetDestination(ctx, code, destination, 0)
+		if err == nil {
+			err = storage.UpdateLink(ctx, link)
+		}
+	} else {
+		link.CreatedAt = time.Now().UTC()
+		err = storage.CreateDestination(ctx, code, destination, 0)
+		if err == nil {
+			err = storage.CreateLink(ctx, link)
+		}
+	}
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store latest-release link")
+		}
+		writeStorageError(w, err, "Failed to update link")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Str("repository", event.Repository.FullName).Str("tag", event.Release.TagName).Str("long_url", destination).Msg("Latest-release link updated")
+	writeJSON(w, http.StatusOK, models.LinkDetailResponse{Link: link, ShortURL: shortURLFor(code)})
+}
+
+// hasTag reports whether tags contains tag.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/config"
+)
+
+func signGitHub(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidGitHubSignature(t *testing.T) {
+	body := []byte(`{"action":"published"}`)
+	assert.True(t, validGitHubSignature("s3cret", body, signGitHub("s3cret", string(body))))
+	assert.False(t, validGitHubSignature("other", body, signGitHub("s3cret", string(body))))
+	assert.False(t, validGitHubSignature("s3cret", body, "sha1=abc"))
+	assert.False(t, validGitHubSignature("s3cret", body, ""))
+}
+
+func TestLatestLinkCode(t *testing.T) {
+	assert.Equal(t, "latest-riid-me", latestLinkCode("riid.me"))
+	assert.Equal(t, "latest-my-tool", latestLinkCode("My_Tool"))
+}
+
+func TestReleaseDestination(t *testing.T) {
+	var event githubReleaseEvent
+	event.Release.HTMLURL = "https://github.com/o/r/releases/tag/v1"
+	assert.Equal(t, event.Release.HTMLURL, releaseDestination(event, ""))
+
+	event.Release.Assets = []githubReleaseAsset{
+		{Name: "tool-darwin.tar.gz", BrowserDownloadURL: "https://example.com/darwin"},
+		{Name: "tool-linux.tar.gz", BrowserDownloadURL: "https://example.com/linux"},
+	}
+	assert.Equal(t, "https://example.com/darwin", releaseDestination(event, ""))
+	assert.Equal(t, "https://example.com/linux", releaseDestination(event, "*linux*"))
+	assert.Equal(t, event.Release.HTMLURL, releaseDestination(event, "*.zip"))
+}
+
+func TestGitHubWebhookHandlerRejectsUnsigned(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.GitHubWebhookSecret = "s3cret"
+
+	body := `{"zen":"Keep it logically awesome."}`
+	req := httptest.NewRequest(http.MethodPost, "/api/integrations/github", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "ping")
+	rr := httptest.NewRecorder()
+	GitHubWebhookHandler(rr, req)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/integrations/github", strings.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "ping")
+	req.Header.Set("X-Hub-Signature-256", signGitHub("s3cret", body))
+	rr = httptest.NewRecorder()
+	GitHubWebhookHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// defaultGrafanaRange is the time range charted when a request gives no from.
+	defaultGrafanaRange = 24 * time.Hour
+	// defaultGrafanaInterval is the bucket size of a time series request giving none.
+	defaultGrafanaInterval = time.Hour
+	// maxGrafanaPoints caps the points of a time series response, over all its links.
+	maxGrafanaPoints = 10000
+	// maxGrafanaSeriesCodes is the most links a time series request may chart.
+	maxGrafanaSeriesCodes = 20
+	// defaultGrafanaTableLimit is how many links the table endpoint lists by default.
+	defaultGrafanaTableLimit = 20
+	// maxGrafanaTableLimit is the most links the table endpoint may list.
+	maxGrafanaTableLimit = 1000
+)
+
+// parseGrafanaTime reads a time given as Unix milliseconds, like Grafana's ${__from}
+// and ${__to}, or as RFC3339, like ${__from:date:iso}. An empty value yields fallback.
+func parseGrafanaTime(raw string, fallback time.Time) (time.Time, error) {
+	if raw == "" {
+		return fallback, nil
+	}
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("'%s' is neither Unix milliseconds nor an RFC3339 time", raw)
+	}
+	return t.UTC(), nil
+}
+
+// parseGrafanaInterval reads a bucket size given as milliseconds, like Grafana's
+// ${__interval_ms}, or as a duration such as "5m". Buckets are whole seconds.
+func parseGrafanaInterval(raw string) (time.Duration, error) {
+	if raw == "" {
+		return defaultGrafanaInterval, nil
+	}
+	var interval time.Duration
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		interval = time.Duration(ms) * time.Millisecond
+	} else if d, err := time.ParseDuration(raw); err == nil {
+		interval = d
+	}
+	interval = interval.Truncate(time.Second)
+	if interval < time.Second {
+		return 0, errors.New("interval must be at least one second, as milliseconds or a duration like 5m")
+	}
+	return interval, nil
+}
+
+// grafanaRange reads the from and to query parameters of r, writing the error response
+// and returning false if they are unusable. They default to the last 24 hours.
+func grafanaRange(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	to, err := parseGrafanaTime(r.URL.Query().Get("to"), time.Now().UTC())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to: "+err.Error())
+		return time.Time{}, time.Time{}, false
+	}
+	from, err := parseGrafanaTime(r.URL.Query().Get("from"), to.Add(-defaultGrafanaRange))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from: "+err.Error())
+		return time.Time{}, time.Time{}, false
+	}
+	if !from.Before(to) {
+		writeJSONError(w, http.StatusBadRequest, "from must be before to")
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// requireStatsRole lets admins and viewers through, writing a 403 for anyone else.
+// Grafana endpoints span all links, so they are for operators, not link owners.
+func requireStatsRole(w http.ResponseWriter, r *http.Request) bool {
+	if role := callerRole(r); role == roleAdmin || role == roleViewer {
+		return true
+	}
+	writeJSONError(w, http.StatusForbidden, "An admin or viewer authorization code is required.")
+	return false
+}
+
+// GrafanaTimeSeriesHandler returns click counts over time as a flat JSON array of
+// {time, short_code, clicks} rows, for Grafana's Infinity or JSON datasources. It
+// charts the links given as ?code= (repeatable) or, without codes, all links together,
+// between ?from= and ?to= in buckets of ?interval=.
+func GrafanaTimeSeriesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !requireStatsRole(w, r) {
+		return
+	}
+	from, to, ok := grafanaRange(w, r)
+	if !ok {
+		return
+	}
+	interval, err := parseGrafanaInterval(r.URL.Query().Get("interval"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var codes []string
+	seen := map[string]bool{}
+	for _, code := range r.URL.Query()["code"] {
+		for _, code := range strings.Split(code, ",") {
+			if code = strings.TrimSpace(code); code != "" && !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	if len(codes) > maxGrafanaSeriesCodes {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d codes may be charted at once.", maxGrafanaSeriesCodes))
+		return
+	}
+	if buckets := int64(to.Sub(from)/interval) + 1; buckets*int64(max(len(codes), 1)) > maxGrafanaPoints {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("The range would need more than %d points; use a larger interval.", maxGrafanaPoints))
+		return
+	}
+
+	points, err := storage.ClickSeries(ctx, codes, from, to, interval)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to query click series")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	writeJSON(w, http.StatusOK, points)
+}
+
+// GrafanaTableHandler returns the links clicked most between ?from= and ?to= as a flat
+// JSON array of {short_code, total_clicks, unique_clicks} rows, up to ?limit=, for
+// Grafana table panels.
+func GrafanaTableHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !requireStatsRole(w, r) {
+		return
+	}
+	from, to, ok := grafanaRange(w, r)
+	if !ok {
+		return
+	}
+	limit := defaultGrafanaTableLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxGrafanaTableLimit {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxGrafanaTableLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	links, err := storage.TopLinksByClicks(ctx, from, to, limit)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to query top links")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	writeJSON(w, http.StatusOK, links)
+}
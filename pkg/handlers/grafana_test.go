@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+)
+
+func TestParseGrafanaTime(t *testing.T) {
+	fallback := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	got, err := parseGrafanaTime("", fallback)
+	require.NoError(t, err)
+	assert.Equal(t, fallback, got)
+
+	got, err = parseGrafanaTime("1767225600000", fallback)
+	require.NoError(t, err)
+	assert.Equal(t, fallback, got)
+
+	got, err = parseGrafanaTime("2026-01-01T01:00:00+01:00", fallback)
+	require.NoError(t, err)
+	assert.Equal(t, fallback, got)
+
+	_, err = parseGrafanaTime("yesterday", fallback)
+	assert.Error(t, err)
+}
+
+func TestParseGrafanaInterval(t *testing.T) {
+	for raw, want := range map[string]time.Duration{"": time.Hour, "5m": 5 * time.Minute, "30000": 30 * time.Second, "1500": time.Second} {
+		got, err := parseGrafanaInterval(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, got, raw)
+	}
+	for _, raw := range []string{"0", "500", "-1m", "soon"} {
+		_, err := parseGrafanaInterval(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestGrafanaEndpointsRequireStatsRole(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member"}
+	config.GlobalAppConfig.ViewerAuthCodes = []string{"viewer"}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/grafana/timeseries", nil)
+	req.Header.Set("Authorization", "Bearer member")
+	rr := httptest.NewRecorder()
+	GrafanaTimeSeriesHandler(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/grafana/timeseries?from=2026-01-02T00:00:00Z&to=2026-01-01T00:00:00Z", nil)
+	req.Header.Set("Authorization", "Bearer viewer")
+	rr = httptest.NewRecorder()
+	GrafanaTimeSeriesHandler(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/grafana/timeseries?from=0&to=1767225600000&interval=1s", nil)
+	req.Header.Set("Authorization", "Bearer viewer")
+	rr = httptest.NewRecorder()
+	GrafanaTimeSeriesHandler(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "larger interval")
+}
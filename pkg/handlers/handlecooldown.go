@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"riid.me/pkg/config"
+	"riid.me/pkg/storage"
+)
+
+// handleCooldownUntil returns when an expired custom handle may be registered by the
+// caller authenticated with token, or the zero time if it may be now. For
+// HANDLE_COOLDOWN_DAYS after a handle expires only its former owner and admins may
+// register it again, so a branded link cannot be sniped the moment it lapses. Handles
+// whose links were deleted rather than expired are released at once.
+func handleCooldownUntil(ctx context.Context, handle, token string) (time.Time, error) {
+	cooldown := time.Duration(config.GlobalAppConfig.HandleCooldownDays) * 24 * time.Hour
+	if cooldown == 0 {
+		return time.Time{}, nil
+	}
+	link, err := storage.GetLink(ctx, handle)
+	if errors.Is(err, storage.ErrNotFound) {
+		return time.Time{}, nil
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	if link.ExpiresAt == nil || ownsLink(token, link) {
+		return time.Time{}, nil
+	}
+	until := link.ExpiresAt.Add(cooldown)
+	if !until.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return until.UTC(), nil
+}
+
+// handleCooldownMessage tells a caller when a handle in cooldown becomes available.
+func handleCooldownMessage(handle string, until time.Time) string {
+	return fmt.Sprintf("Custom handle '%s' expired recently and is reserved for its previous owner until %s.", handle, until.Format(time.RFC3339))
+}
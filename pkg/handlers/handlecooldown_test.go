@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestHandleCooldownUntil(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"owner", "other"}
+	config.GlobalAppConfig.AdminAuthCodes = []string{"admin"}
+	config.GlobalAppConfig.HandleCooldownDays = 30
+	ctx := context.Background()
+
+	expiredAt := time.Now().UTC().Add(-24 * time.Hour).Truncate(time.Second)
+	require.NoError(t, storage.SaveLink(ctx, models.Link{
+		ShortCode: "brand", LongURL: "https://example.com", CreatedAt: expiredAt.Add(-time.Hour),
+		ExpiresAt: &expiredAt, Owner: linkOwner("owner"),
+	}))
+
+	until, err := handleCooldownUntil(ctx, "brand", "other")
+	require.NoError(t, err)
+	assert.Equal(t, expiredAt.Add(30*24*time.Hour), until)
+
+	for _, token := range []string{"owner", "admin"} {
+		until, err = handleCooldownUntil(ctx, "brand", token)
+		require.NoError(t, err)
+		assert.True(t, until.IsZero(), token)
+	}
+
+	// Unknown handles, and handles whose cooldown is over or disabled, are free.
+	until, err = handleCooldownUntil(ctx, "fresh", "other")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+
+	config.GlobalAppConfig.HandleCooldownDays = 1
+	until, err = handleCooldownUntil(ctx, "brand", "other")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+
+	config.GlobalAppConfig.HandleCooldownDays = 0
+	until, err = handleCooldownUntil(ctx, "brand", "other")
+	require.NoError(t, err)
+	assert.True(t, until.IsZero())
+}
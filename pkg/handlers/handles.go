@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/validation"
+)
+
+// CheckHandleHandler tells whether a custom handle is available to the caller, with
+// the reason it is not (the same message creating a link with it would fail with),
+// and the handle policy, so the frontend can show the rules as users type.
+func CheckHandleHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	handle := foldShortCode(mux.Vars(r)["handle"])
+	minLength, maxLength := validation.HandleLengthLimits()
+	resp := models.HandleAvailability{
+		Handle: handle,
+		Policy: models.HandlePolicy{
+			Pattern:   config.GlobalAppConfig.HandlePattern,
+			MinLength: minLength,
+			MaxLength: maxLength,
+		},
+	}
+
+	if err := validation.ValidateHandle(handle); err != nil {
+		resp.Reason = err.Error()
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	exists, err := shortCodeTaken(ctx, handle)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Str("custom_handle", handle).Msg("Redis error checking custom handle availability")
+		writeJSONError(w, http.StatusInternalServerError, "Error checking custom handle availability.")
+		return
+	}
+	if exists {
+		resp.Reason = fmt.Sprintf("Custom handle '%s' is already taken.", handle)
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	until, err := handleCooldownUntil(ctx, handle, bearerToken(r))
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Str("custom_handle", handle).Msg("Failed to check custom handle cooldown")
+		writeJSONError(w, http.StatusInternalServerError, "Error checking custom handle availability.")
+		return
+	}
+	if !until.IsZero() {
+		resp.Reason = handleCooldownMessage(handle, until)
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp.Available = true
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+func TestCheckHandleHandlerRejectsLength(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.HandlePattern = `[a-zA-Z0-9_-]+`
+
+	req := mux.SetURLVars(httptest.NewRequest("GET", "/api/handles/ab", nil), map[string]string{"handle": "ab"})
+	rr := httptest.NewRecorder()
+	CheckHandleHandler(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp models.HandleAvailability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.Equal(t, models.HandleAvailability{
+		Handle: "ab",
+		Reason: "Custom handle must be between 3 and 30 characters.",
+		Policy: models.HandlePolicy{Pattern: `[a-zA-Z0-9_-]+`, MinLength: 3, MaxLength: 30},
+	}, resp)
+}
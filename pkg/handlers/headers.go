@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// maxResponseHeaders is the most extra headers a link's redirects may carry.
+	maxResponseHeaders = 10
+	// maxResponseHeaderValueLength caps the length of an extra header's value.
+	maxResponseHeaderValueLength = 256
+	// maxResponseHeadersBodyBytes caps the size of a request replacing a link's headers.
+	maxResponseHeadersBodyBytes = 8 << 10
+	// noIndexRobots is the X-Robots-Tag sent with the redirects of noindex links. A link's
+	// own X-Robots-Tag response header replaces it.
+	noIndexRobots = "noindex, nofollow"
+)
+
+// responseHeaderName matches a well-formed header name.
+var responseHeaderName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// referrerPolicies are the values the Referrer-Policy header may take.
+var referrerPolicies = map[string]bool{
+	"no-referrer":                     true,
+	"no-referrer-when-downgrade":      true,
+	"origin":                          true,
+	"origin-when-cross-origin":        true,
+	"same-origin":                     true,
+	"strict-origin":                   true,
+	"strict-origin-when-cross-origin": true,
+	"unsafe-url":                      true,
+}
+
+// reservedResponseHeaderPrefixes are X- headers set by the service or its proxies,
+// which links may not override.
+var reservedResponseHeaderPrefixes = []string{"X-Request-Id", "X-Ratelimit-", "X-Forwarded-", "X-Real-Ip", "X-Riidme-"}
+
+// validateResponseHeaders checks the extra headers a link's redirects are to carry and
+// returns them with canonical names. Only Referrer-Policy, X-Robots-Tag and custom X-
+// headers are allowed, so a link can never set cookies, change caching or the redirect
+// itself on our domain.
+func validateResponseHeaders(headers map[string]string) (map[string]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	if len(headers) > maxResponseHeaders {
+		return nil, fmt.Errorf("At most %d response headers may be set.", maxResponseHeaders)
+	}
+	canonical := make(map[string]string, len(headers))
+	for name, value := range headers {
+		if !responseHeaderName.MatchString(name) {
+			return nil, fmt.Errorf("'%s' is not a valid header name.", name)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if _, dup := canonical[name]; dup {
+			return nil, fmt.Errorf("Header '%s' is set more than once.", name)
+		}
+		if !allowedResponseHeader(name) {
+			return nil, fmt.Errorf("Header '%s' cannot be set; only Referrer-Policy, X-Robots-Tag and custom X- headers are allowed.", name)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" || len(value) > maxResponseHeaderValueLength || strings.IndexFunc(value, func(c rune) bool { return c < 0x20 || c > 0x7e }) >= 0 {
+			return nil, fmt.Errorf("Header '%s' needs a value of 1 to %d printable ASCII characters.", name, maxResponseHeaderValueLength)
+		}
+		if name == "Referrer-Policy" && !referrerPolicies[strings.ToLower(value)] {
+			return nil, fmt.Errorf("'%s' is not a valid Referrer-Policy.", value)
+		}
+		canonical[name] = value
+	}
+	return canonical, nil
+}
+
+// allowedResponseHeader reports whether links may set the header with canonical name.
+func allowedResponseHeader(name string) bool {
+	if name == "Referrer-Policy" || name == "X-Robots-Tag" {
+		return true
+	}
+	if !strings.HasPrefix(name, "X-") {
+		return false
+	}
+	for _, prefix := range reservedResponseHeaderPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// setRedirectResponseHeaders adds a link's extra headers to its redirect response.
+func setRedirectResponseHeaders(w http.ResponseWriter, headers map[string]string) {
+	for name, value := range headers {
+		w.Header().Set(name, value)
+	}
+}
+
+// PutLinkHeadersHandler replaces the extra headers sent with a link's redirects, e.g.
+// {"Referrer-Policy": "no-referrer"}. An empty object removes them all.
+func PutLinkHeadersHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+
+	var headers map[string]string
+	if err := decodeJSONOrYAML(r, maxResponseHeadersBodyBytes, &headers); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for PutLinkHeaders")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	headers, err := validateResponseHeaders(headers)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	link, err := storage.GetLink(ctx, shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		longURL, err := storage.GetDestination(ctx, shortCode)
+		if err != nil {
+			if storageErrorStatus(err) == http.StatusInternalServerError {
+				customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis")
+			}
+			writeStorageError(w, err, "Error retrieving link")
+			return
+		}
+		link = models.Link{ShortCode: shortCode, LongURL: longURL, CreatedAt: time.Now().UTC()}
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return
+	}
+
+	link.ResponseHeaders = headers
+	if err := storage.UpdateLink(ctx, link); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store response headers of link")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to update link")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Int("headers", len(headers)).Msg("Link response headers updated")
+	writeJSON(w, http.StatusOK, models.LinkDetailResponse{Link: link, ShortURL: shortURLFor(shortCode)})
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateResponseHeaders(t *testing.T) {
+	headers, err := validateResponseHeaders(map[string]string{"referrer-policy": "no-referrer", "x-campaign": " spring "})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"Referrer-Policy": "no-referrer", "X-Campaign": "spring"}, headers)
+
+	headers, err = validateResponseHeaders(map[string]string{})
+	require.NoError(t, err)
+	assert.Nil(t, headers)
+
+	for name, invalid := range map[string]map[string]string{
+		"cookie":          {"Set-Cookie": "session=1"},
+		"caching":         {"Cache-Control": "max-age=31536000"},
+		"location":        {"Location": "https://evil.example"},
+		"reserved":        {"X-Request-ID": "forged"},
+		"rate limit":      {"X-RateLimit-Remaining": "1000"},
+		"bad policy":      {"Referrer-Policy": "sometimes"},
+		"bad name":        {"X Campaign": "spring"},
+		"empty value":     {"X-Campaign": " "},
+		"control chars":   {"X-Campaign": "spring\r\nSet-Cookie: a=b"},
+		"duplicate names": {"X-Campaign": "a", "x-campaign": "b"},
+	} {
+		_, err := validateResponseHeaders(invalid)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSetRedirectResponseHeaders(t *testing.T) {
+	rr := httptest.NewRecorder()
+	setRedirectResponseHeaders(rr, map[string]string{"Referrer-Policy": "no-referrer"})
+	assert.Equal(t, "no-referrer", rr.Header().Get("Referrer-Policy"))
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+// hostedLinkRequest describes a short link to content riid.me serves itself, such as
+// dropped files and snippets, rather than to an external destination.
+type hostedLinkRequest struct {
+	CustomHandle   string
+	ExpirationDays *int
+	Title          string
+	Tag            string
+	// Target returns the URL the link redirects to once its short code is known.
+	Target func(shortCode string) string
+}
+
+// link returns the metadata record of the hosted link req created under code. It is
+// never public, so the link is not listed or suggested anywhere.
+func (req hostedLinkRequest) link(code, target string, expiresAt *time.Time, owner string) models.Link {
+	return models.Link{
+		ShortCode: code,
+		LongURL:   target,
+		Title:     req.Title,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+		Tags:      []string{req.Tag},
+		Owner:     owner,
+	}
+}
+
+// createHostedLink validates req, reserves its short code and records the link. On
+// failure it writes the error response and reports false; nothing is left behind.
+// Hosted links are never public nor suggested for near-miss codes, as knowing the
+// code is all it takes to fetch the content.
+func createHostedLink(w http.ResponseWriter, r *http.Request, req hostedLinkRequest) (models.Link, bool) {
+	ctx := r.Context()
+	ttl, err := expirationTTL(req.ExpirationDays)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, errorMessage(err))
+		return models.Link{}, false
+	}
+
+	code := foldShortCode(req.CustomHandle)
+	var target string
+	if code != "" {
+		if err := validation.ValidateHandle(code); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return models.Link{}, false
+		}
+		if taken, err := shortCodeTaken(ctx, code); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Str("custom_handle", code).Msg("Failed to check custom handle availability")
+			writeJSONError(w, http.StatusInternalServerError, "Error checking custom handle availability.")
+			return models.Link{}, false
+		} else if taken {
+			writeJSONError(w, http.StatusConflict, fmt.Sprintf("Custom handle '%s' is already taken.", code))
+			return models.Link{}, false
+		}
+		if until, err := handleCooldownUntil(ctx, code, bearerToken(r)); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Str("custom_handle", code).Msg("Failed to check custom handle cooldown")
+			writeJSONError(w, http.StatusInternalServerError, "Error checking custom handle availability.")
+			return models.Link{}, false
+		} else if !until.IsZero() {
+			writeJSONError(w, http.StatusConflict, handleCooldownMessage(code, until))
+			return models.Link{}, false
+		}
+		target = req.Target(code)
+		err = storage.CreateDestination(ctx, code, target, ttl)
+	} else {
+		code, target, err = storeGeneratedCode(ctx, ttl, func(code string) (string, error) { return req.Target(code), nil })
+	}
+	ctx = customlogger.WithContext(ctx, customlogger.ShortCodeField, code)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store URL in Redis")
+		}
+		writeStorageError(w, err, "Error storing URL")
+		return models.Link{}, false
+	}
+
+	expiresAt, err := storage.LinkExpiry(ctx, code)
+	if err != nil {
+		customlogger.FromContext(ctx).Warn().Err(err).Msg("Failed to read TTL of stored URL")
+	}
+	link := req.link(code, target, expiresAt, linkOwner(bearerToken(r)))
+	// Expired content is found through the link's metadata, so it must not be missing.
+	if err := storage.CreateLink(ctx, link); err != nil {
+		if err := storage.DeleteDestination(ctx, code); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to remove destination of failed hosted link")
+		}
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to record link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Error storing URL")
+		return models.Link{}, false
+	}
+	return link, true
+}
+
+// removeHostedLink deletes a hosted link whose content could not be stored.
+func removeHostedLink(ctx context.Context, link models.Link) {
+	if err := storage.DeleteDestination(ctx, link.ShortCode); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to remove destination of failed hosted link")
+	}
+	if err := storage.DeleteLink(ctx, link); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to remove metadata of failed hosted link")
+	}
+}
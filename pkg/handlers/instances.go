@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"riid.me/pkg/config"
+	"riid.me/pkg/instances"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// ListInstancesHandler lists the instances in the instance registry that sent a
+// heartbeat recently, with their versions and health. ?healthy=true leaves out
+// unhealthy instances, e.g. for tooling that keeps DNS or a load balancer in sync.
+func ListInstancesHandler(w http.ResponseWriter, r *http.Request) {
+	maxAge := time.Duration(config.GlobalAppConfig.InstanceHeartbeatSeconds*instances.MissedHeartbeats) * time.Second
+	registered, err := storage.ListInstances(r.Context(), maxAge)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to list instances")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve instances")
+		return
+	}
+	if r.URL.Query().Get("healthy") == "true" {
+		healthy := []models.Instance{}
+		for _, instance := range registered {
+			if instance.Healthy {
+				healthy = append(healthy, instance)
+			}
+		}
+		registered = healthy
+	}
+	writeJSON(w, http.StatusOK, registered)
+}
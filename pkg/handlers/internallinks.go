@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"riid.me/pkg/config"
+	"riid.me/pkg/storage"
+)
+
+var (
+	// errRedirectLoop is returned for destinations that lead back to a link they
+	// passed through, or through too many of our own short links.
+	errRedirectLoop = errors.New("destination would redirect in a loop through short links of this site")
+	// errInternalLinkRejected is returned for destinations pointing at another of our
+	// short links with INTERNAL_LINK_ACTION=reject.
+	errInternalLinkRejected = errors.New("links to other short links of this site are not accepted; use their destination instead")
+)
+
+// ownShortCode returns the short code a destination points at when it is a short link
+// of this instance, or "" for anything else.
+func ownShortCode(destination string) string {
+	u, err := url.Parse(destination)
+	if err != nil || !strings.EqualFold(u.Host, config.GlobalAppConfig.Domain) {
+		return ""
+	}
+	code := strings.TrimPrefix(u.Path, "/")
+	if code == "" || strings.Contains(code, "/") {
+		return ""
+	}
+	return code
+}
+
+// followOwnLinks follows destination through our own short links, without HTTP
+// requests, and returns where the chain leaves this instance and how many of our links
+// it passed through. shortCode is the link destination belongs to, empty for a link
+// not yet created. Chains coming back to a link they passed, or passing through more
+// than INTERNAL_LINK_MAX_HOPS links, return errRedirectLoop. A chain ends early at a short code that does not exist, which answers with a 404.
+func followOwnLinks(ctx context.Context, shortCode, destination string) (string, int, error) {
+	seen := map[string]bool{}
+	if shortCode != "" {
+		seen[shortCode] = true
+	}
+	for hops := 0; ; hops++ {
+		code := ownShortCode(destination)
+		if code == "" {
+			return destination, hops, nil
+		}
+		if seen[code] {
+			return "", hops, errRedirectLoop
+		}
+		if hops == config.GlobalAppConfig.InternalLinkMaxHops {
+			return "", hops, fmt.Errorf("%w (more than %d hops)", errRedirectLoop, config.GlobalAppConfig.InternalLinkMaxHops)
+		}
+		seen[code] = true
+
+		next, err := storage.GetDestination(ctx, code)
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrExpired) {
+			return destination, hops, nil
+		} else if err != nil {
+			return "", hops, err
+		}
+		destination = next
+	}
+}
+
+// internalDestination applies INTERNAL_LINK_ACTION to the destination of a link being
+// created or changed: destinations pointing at another of our short links are
+// replaced with where that chain leads, or rejected. Loops are always rejected.
+// Errors other than those of isInternalLinkError come from storage.
+func internalDestination(ctx context.Context, shortCode, destination string) (string, error) {
+	if ownShortCode(destination) == "" {
+		return destination, nil
+	}
+	if config.GlobalAppConfig.InternalLinkAction == config.InternalLinkReject {
+		return "", errInternalLinkRejected
+	}
+	final, _, err := followOwnLinks(ctx, shortCode, destination)
+	return final, err
+}
+
+// isInternalLinkError reports whether err from internalDestination rejects the
+// destination, rather than being a storage failure.
+func isInternalLinkError(err error) bool {
+	return errors.Is(err, errRedirectLoop) || errors.Is(err, errInternalLinkRejected)
+}
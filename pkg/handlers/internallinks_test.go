@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/config"
+)
+
+func TestOwnShortCode(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Domain = "riid.me"
+
+	for destination, want := range map[string]string{
+		"https://riid.me/abc":         "abc",
+		"http://RIID.me/abc?src=x":    "abc",
+		"https://riid.me/":            "",
+		"https://riid.me/d/abc/f.pdf": "",
+		"https://example.com/abc":     "",
+		"https://riid.me.evil/abc":    "",
+	} {
+		assert.Equal(t, want, ownShortCode(destination), destination)
+	}
+}
+
+func TestInternalDestination(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Domain = "riid.me"
+	config.GlobalAppConfig.InternalLinkAction = config.InternalLinkResolve
+	config.GlobalAppConfig.InternalLinkMaxHops = 3
+	ctx := context.Background()
+
+	destination, err := internalDestination(ctx, "abc", "https://example.com/")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/", destination)
+
+	_, err = internalDestination(ctx, "abc", "https://riid.me/abc")
+	assert.ErrorIs(t, err, errRedirectLoop, "a link pointing at itself")
+	assert.True(t, isInternalLinkError(err))
+
+	config.GlobalAppConfig.InternalLinkAction = config.InternalLinkReject
+	_, err = internalDestination(ctx, "abc", "https://riid.me/other")
+	assert.ErrorIs(t, err, errInternalLinkRejected)
+	assert.True(t, isInternalLinkError(err))
+}
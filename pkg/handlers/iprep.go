@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"riid.me/pkg/config"
+	"riid.me/pkg/iprep"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+)
+
+const (
+	// challengeQueryParam carries the token of a passed IP reputation challenge.
+	challengeQueryParam = "ipcheck"
+	// challengeWindow is how long a challenge token is valid: the window it was issued
+	// in and the next.
+	challengeWindow = time.Hour
+)
+
+// IPBlocklist holds the networks whose clients are denied or challenged on redirects;
+// nil when IP_BLOCKLIST_SOURCES is not configured.
+var IPBlocklist *iprep.Blocklist
+
+// ipReputationCounts counts what the IP reputation check did since startup.
+var ipReputationCounts struct {
+	denied, challenged, passed atomic.Int64
+}
+
+// challengeSecret signs challenge tokens. It is generated at startup, so tokens do not
+// survive a restart, which only means listed clients are challenged again.
+var challengeSecret = func() []byte {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return secret
+}()
+
+// ipChallengeTemplate is the page clients in a blocklisted network must confirm before
+// they are redirected.
+var ipChallengeTemplate = template.Must(template.New("ipchallenge").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Confirm to continue</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #333; }
+h1 { font-size: 1.5rem; }
+button { font-size: 1rem; padding: 0.5rem 1rem; }
+</style>
+</head>
+<body>
+<h1>Confirm to continue</h1>
+<p>Your network is listed for abuse, so we ask you to confirm that you want to follow this link.</p>
+<form method="get" action="{{.Path}}">
+{{range $name, $values := .Query}}{{range $values}}<input type="hidden" name="{{$name}}" value="{{.}}">
+{{end}}{{end}}<input type="hidden" name="{{.Param}}" value="{{.Token}}">
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// challengeToken returns the token proving client passed the challenge for path in
+// the given window.
+func challengeToken(client, path string, window int64) string {
+	mac := hmac.New(sha256.New, challengeSecret)
+	mac.Write([]byte(client + "|" + path + "|" + strconv.FormatInt(window, 10)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// validChallengeToken reports whether token was issued to client for path in the
+// current or the previous window.
+func validChallengeToken(client, path, token string, now time.Time) bool {
+	if token == "" {
+		return false
+	}
+	window := now.Unix() / int64(challengeWindow.Seconds())
+	for _, w := range []int64{window, window - 1} {
+		if hmac.Equal([]byte(token), []byte(challengeToken(client, path, w))) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPReputation is middleware for redirect routes that denies or challenges clients
+// whose address, per trustedClientAddr, lies in a network of IPBlocklist, per
+// IP_BLOCKLIST_ACTION. Clients whose address cannot be parsed are treated as listed.
+func IPReputation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr, ok := trustedClientAddr(r)
+		if IPBlocklist == nil || (ok && !IPBlocklist.Contains(addr)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := "unknown"
+		if ok {
+			client = addr.String()
+		}
+
+		if config.GlobalAppConfig.IPBlocklistAction != config.IPBlocklistActionChallenge {
+			ipReputationCounts.denied.Add(1)
+			customlogger.FromContext(r.Context()).Warn().Str("client", client).Msg("Denied redirect to blocklisted client")
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		now := time.Now()
+		if validChallengeToken(client, r.URL.Path, r.URL.Query().Get(challengeQueryParam), now) {
+			ipReputationCounts.passed.Add(1)
+			next.ServeHTTP(w, r)
+			return
+		}
+		ipReputationCounts.challenged.Add(1)
+		customlogger.FromContext(r.Context()).Info().Str("client", client).Msg("Challenged blocklisted client")
+		query := r.URL.Query()
+		query.Del(challengeQueryParam)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusForbidden)
+		err := ipChallengeTemplate.Execute(w, struct {
+			Path  string
+			Query map[string][]string
+			Param string
+			Token string
+		}{r.URL.Path, query, challengeQueryParam, challengeToken(client, r.URL.Path, now.Unix()/int64(challengeWindow.Seconds()))})
+		if err != nil {
+			customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to render IP challenge page")
+		}
+	})
+}
+
+// GetIPReputationHandler reports the state of the IP blocklist and how many clients
+// were denied, challenged and let through after a challenge.
+func GetIPReputationHandler(w http.ResponseWriter, r *http.Request) {
+	status := models.IPReputationStatus{
+		Enabled:          IPBlocklist != nil,
+		Action:           config.GlobalAppConfig.IPBlocklistAction,
+		Denied:           ipReputationCounts.denied.Load(),
+		Challenged:       ipReputationCounts.challenged.Load(),
+		ChallengesPassed: ipReputationCounts.passed.Load(),
+	}
+	if IPBlocklist != nil {
+		ranges, updatedAt := IPBlocklist.Status()
+		status.Ranges = ranges
+		if !updatedAt.IsZero() {
+			status.UpdatedAt = &updatedAt
+		}
+	}
+	writeJSON(w, http.StatusOK, status)
+}
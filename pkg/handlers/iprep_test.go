@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/iprep"
+)
+
+func TestIPReputation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "drop.txt")
+	require.NoError(t, os.WriteFile(file, []byte("203.0.113.0/24 ; SBL1\n"), 0o644))
+	IPBlocklist = iprep.NewBlocklist([]string{file})
+	require.NoError(t, IPBlocklist.Refresh(context.Background()))
+	t.Cleanup(func() {
+		IPBlocklist = nil
+		config.GlobalAppConfig.IPBlocklistAction = ""
+	})
+
+	reached := false
+	handler := IPReputation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+	request := func(client, target string) *httptest.ResponseRecorder {
+		reached = false
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.RemoteAddr = client + ":51234"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	request("198.51.100.7", "/abc")
+	assert.True(t, reached, "unlisted clients are redirected")
+
+	config.GlobalAppConfig.IPBlocklistAction = config.IPBlocklistActionDeny
+	rr := request("203.0.113.7", "/abc")
+	assert.False(t, reached)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	config.GlobalAppConfig.IPBlocklistAction = config.IPBlocklistActionChallenge
+	rr = request("203.0.113.7", "/abc?preview=tok")
+	assert.False(t, reached)
+	assert.Contains(t, rr.Body.String(), `name="preview" value="tok"`, "the challenge keeps the query")
+
+	token := challengeToken("203.0.113.7", "/abc", time.Now().Unix()/int64(challengeWindow.Seconds()))
+	request("203.0.113.7", "/abc?ipcheck="+token)
+	assert.True(t, reached, "a passed challenge lets the client through")
+	request("203.0.113.8", "/abc?ipcheck="+token)
+	assert.False(t, reached, "tokens are bound to the client")
+}
+
+func TestIPReputationIgnoresSpoofedForwardedFor(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "drop.txt")
+	require.NoError(t, os.WriteFile(file, []byte("203.0.113.0/24 ; SBL1\n"), 0o644))
+	IPBlocklist = iprep.NewBlocklist([]string{file})
+	require.NoError(t, IPBlocklist.Refresh(context.Background()))
+	config.GlobalAppConfig.IPBlocklistAction = config.IPBlocklistActionDeny
+	t.Cleanup(func() {
+		IPBlocklist = nil
+		config.GlobalAppConfig.IPBlocklistAction = ""
+		config.GlobalAppConfig.TrustedProxies = nil
+	})
+
+	handler := IPReputation(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(remote, forwarded string) int {
+		req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+		req.RemoteAddr = remote + ":51234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, request("203.0.113.7", "1.1.1.1"), "X-Forwarded-For of untrusted clients is ignored")
+
+	config.GlobalAppConfig.TrustedProxies = config.Networks{netip.MustParsePrefix("10.0.0.0/8")}
+	assert.Equal(t, http.StatusForbidden, request("10.0.0.2", "1.1.1.1, 203.0.113.7"), "entries the client put in front of the proxy's are ignored")
+	assert.Equal(t, http.StatusForbidden, request("10.0.0.2", "x"), "unparseable addresses are treated as listed")
+	assert.Equal(t, http.StatusOK, request("10.0.0.2", "203.0.113.7, 198.51.100.7"))
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// linkInfoTemplate is the page shown at a short URL followed by "+", telling visitors
+// where a link leads before they follow it.
+var linkInfoTemplate = template.Must(template.New("linkinfo").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Preview of {{.ShortURL}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 36rem; margin: 4rem auto; padding: 0 1rem; color: #333; }
+h1 { font-size: 1.5rem; margin-bottom: 0.25rem; }
+.meta { color: #777; margin-top: 0; }
+.destination { background: #f4f6f8; border-radius: 6px; padding: 0.75rem 1rem; word-break: break-all; }
+.destination strong { display: block; margin-bottom: 0.25rem; }
+.destination img { width: 16px; height: 16px; vertical-align: -2px; margin-right: 0.35rem; }
+.page { margin: 0.75rem 0 0; color: #555; word-break: normal; }
+a.button { display: inline-block; background: #3498db; color: #fff; padding: 0.5rem 1rem; border-radius: 4px; text-decoration: none; }
+</style>
+</head>
+<body>
+<h1>{{or .Title .ShortURL}}</h1>
+<p class="meta">{{.ShortURL}}{{if .Created}} &middot; created {{.Created}}{{end}}{{if .CountKnown}} &middot; {{.Clicks}} click{{if ne .Clicks 1}}s{{end}}{{end}}</p>
+<p>This short link leads to:</p>
+<div class="destination"><strong>{{if .Favicon}}<img src="{{.Favicon}}" alt="" referrerpolicy="no-referrer">{{end}}{{.Host}}</strong>{{.Destination}}
+{{if or .PageTitle .PageDescription}}<p class="page">{{if .PageTitle}}<strong>{{.PageTitle}}</strong>{{end}}{{.PageDescription}}</p>{{end}}</div>
+<p><a class="button" href="{{.ShortURL}}" rel="nofollow">Continue</a></p>
+</body>
+</html>
+`))
+
+// linkInfoView is what linkInfoTemplate renders.
+type linkInfoView struct {
+	ShortURL, Title, Created            string
+	Destination, Host                   string
+	PageTitle, PageDescription, Favicon string
+	Clicks                              int
+	CountKnown                          bool
+}
+
+// newLinkInfoView lays out the preview page of link, leading to destination.
+func newLinkInfoView(link models.Link, destination string) linkInfoView {
+	view := linkInfoView{
+		ShortURL:    shortURLFor(link.ShortCode),
+		Title:       link.Title,
+		Destination: destination,
+		Host:        destination,
+	}
+	if !link.CreatedAt.IsZero() {
+		view.Created = link.CreatedAt.UTC().Format("January 2, 2006")
+	}
+	if u, err := url.Parse(destination); err == nil && u.Host != "" {
+		view.Host = u.Hostname()
+	}
+	if link.PageMeta != nil {
+		view.PageTitle = link.PageMeta.Title
+		view.PageDescription = link.PageMeta.Description
+		view.Favicon = link.PageMeta.FaviconURL
+	}
+	return view
+}
+
+// LinkInfoPageHandler serves the preview page of a short link, requested as its short
+// URL followed by "+": its destination, with the title, description and favicon of the
+// page if fetched, creation date and click count, instead of a redirect. The visit is not counted as a click. Links that would not redirect, such
+// as drafts and expired links, answer as their redirect would.
+func LinkInfoPageHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := mux.Vars(r)["shortcode"]
+	ctx := r.Context()
+
+	shortCode, destination, err := resolveShortCode(ctx, shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		serveNotFound(w, r, "Short URL not found")
+		return
+	} else if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis for preview page")
+			http.Error(w, "Error retrieving URL", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, errorMessage(err), storageErrorStatus(err))
+		return
+	}
+	if serveTakedown(w, r, shortCode) {
+		return
+	}
+
+	link, err := storage.GetLink(ctx, shortCode)
+	if errors.Is(err, storage.ErrLinkNotFound) {
+		// Links created before metadata was recorded have none to show.
+		link = models.Link{ShortCode: shortCode}
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve link metadata for preview page")
+		http.Error(w, "Error retrieving URL", http.StatusInternalServerError)
+		return
+	}
+	if link.Draft {
+		serveNotFound(w, r, "Short URL not found")
+		return
+	}
+
+	view := newLinkInfoView(link, destination)
+	if clicks, err := storage.CountClicks(ctx, shortCode); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count clicks for preview page")
+	} else {
+		view.Clicks, view.CountKnown = clicks, true
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if err := linkInfoTemplate.Execute(w, view); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to render preview page")
+	}
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+func TestNewLinkInfoView(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain = "https", "riid.me"
+
+	view := newLinkInfoView(models.Link{
+		ShortCode: "abc", Title: "Docs", CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+	}, "https://www.example.com/docs?x=1")
+	view.Clicks, view.CountKnown = 1, true
+	assert.Equal(t, "https://riid.me/abc", view.ShortURL)
+	assert.Equal(t, "www.example.com", view.Host)
+	assert.Equal(t, "March 1, 2024", view.Created)
+
+	var page bytes.Buffer
+	require.NoError(t, linkInfoTemplate.Execute(&page, view))
+	assert.Contains(t, page.String(), "<h1>Docs</h1>")
+	assert.Contains(t, page.String(), "https://www.example.com/docs?x=1")
+	assert.Contains(t, page.String(), "1 click</p>")
+	assert.Contains(t, page.String(), `href="https://riid.me/abc"`)
+	assert.NotContains(t, page.String(), "<img")
+
+	view.Favicon, view.PageTitle, view.PageDescription = "https://www.example.com/favicon.ico", "Example Docs", "How to use it."
+	page.Reset()
+	require.NoError(t, linkInfoTemplate.Execute(&page, view))
+	assert.Contains(t, page.String(), `<img src="https://www.example.com/favicon.ico"`)
+	assert.Contains(t, page.String(), "<strong>Example Docs</strong>How to use it.")
+
+	// Destinations are shown, never interpreted.
+	view = newLinkInfoView(models.Link{ShortCode: "xss", PageMeta: &models.PageMeta{
+		Title: "<script>alert(2)</script>", FaviconURL: "javascript:alert(3)",
+	}}, `https://example.com/"><script>alert(1)</script>`)
+	page.Reset()
+	require.NoError(t, linkInfoTemplate.Execute(&page, view))
+	assert.NotContains(t, page.String(), "<script>")
+	assert.NotContains(t, page.String(), "javascript:")
+	assert.NotContains(t, page.String(), "created")
+	assert.NotContains(t, page.String(), "click")
+}
+
+func TestLinkInfoRoute(t *testing.T) {
+	router := mux.NewRouter()
+	var matched string
+	router.HandleFunc("/{shortcode}+", func(w http.ResponseWriter, r *http.Request) {
+		matched = mux.Vars(r)["shortcode"]
+	})
+	router.HandleFunc("/{shortcode}", func(http.ResponseWriter, *http.Request) { matched = "redirect" })
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abc+", nil))
+	assert.Equal(t, "abc", matched)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abc", nil))
+	assert.Equal(t, "redirect", matched)
+}
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// GetLinkHandler returns the details of a link. Its expires_at is derived from the
+// TTL currently set on the link, so clients never have to re-derive it from "days".
+func GetLinkHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+
+	longURL, err := storage.GetDestination(ctx, shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, models.NotFoundResponse{
+			Error:       errorMessage(err),
+			Suggestions: suggestCodes(ctx, shortCode),
+		})
+		return
+	} else if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis for link details")
+		}
+		writeStorageError(w, err, "Error retrieving URL")
+		return
+	}
+
+	link, err := storage.GetLink(ctx, shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Links created before metadata was recorded only exist in Redis.
+		link = models.Link{ShortCode: shortCode}
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return
+	}
+	if link.Draft && !isMemberAuthCode(bearerToken(r)) && !ownsLink(bearerToken(r), link) {
+		// Drafts are not public until published.
+		writeStorageError(w, storage.ErrLinkNotFound, "Error retrieving link")
+		return
+	}
+	link.LongURL = longURL
+
+	expiresAt, err := storage.LinkExpiry(ctx, shortCode)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to read TTL for link details")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return
+	}
+	link.ExpiresAt = expiresAt
+	if !link.CreatedAt.IsZero() {
+		link.CreatedAt = link.CreatedAt.UTC().Truncate(time.Second)
+	}
+
+	resp := models.LinkDetailResponse{
+		Link:     link,
+		ShortURL: shortURLFor(shortCode),
+	}
+	if ownsLink(bearerToken(r), link) {
+		takedown, err := storage.GetLinkTakedown(ctx, shortCode)
+		if err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up link takedown for link details")
+		} else if takedown != nil {
+			resp.DisabledReason = takedown.Reason
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+const (
+	// defaultLinksPageSize is the number of links listed when no limit is given.
+	defaultLinksPageSize = 50
+	// maxLinksPageSize caps the number of links listed per request.
+	maxLinksPageSize = 200
+)
+
+// ListLinksHandler lists live links, newest first, a page at a time: their
+// destinations, creation times and remaining TTLs. Admins get all links; any other
+// valid auth code, including viewer codes, only the links created with it.
+func ListLinksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	role := callerRole(r)
+	if role == roleAnonymous {
+		customlogger.FromContext(ctx).Warn().Msg("Unauthorized attempt to list links")
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return
+	}
+	query := r.URL.Query()
+
+	page := 1
+	if v := query.Get("page"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 {
+			writeJSONError(w, http.StatusBadRequest, "page must be a positive integer")
+			return
+		}
+		page = parsed
+	}
+	limit := defaultLinksPageSize
+	if v := query.Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > maxLinksPageSize {
+			writeJSONError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxLinksPageSize))
+			return
+		}
+		limit = parsed
+	}
+
+	var links []models.Link
+	var total int
+	var err error
+	if role != roleAdmin {
+		links, total, err = storage.ListOwnedLinks(ctx, linkOwner(bearerToken(r)), (page-1)*limit, limit)
+	} else {
+		links, total, err = storage.ListLinks(ctx, (page-1)*limit, limit)
+	}
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to list links")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve links")
+		return
+	}
+
+	now := time.Now()
+	resp := models.LinkListResponse{
+		Links:   make([]models.LinkListItem, 0, len(links)),
+		Page:    page,
+		Limit:   limit,
+		Total:   total,
+		HasMore: (page-1)*limit+len(links) < total,
+	}
+	for _, link := range links {
+		item := models.LinkListItem{LinkDetailResponse: models.LinkDetailResponse{Link: link, ShortURL: shortURLFor(link.ShortCode)}}
+		if link.ExpiresAt != nil {
+			ttl := int64(link.ExpiresAt.Sub(now).Seconds())
+			item.TTLSeconds = &ttl
+		}
+		resp.Links = append(resp.Links, item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// DeleteLinkHandler removes a link: its destination, so it stops redirecting, its
+// metadata and a file dropped or snippet shared under it. With ?purge_clicks=true its click history is deleted as well. Only the
+// link's creator (the auth code it was created with) or an admin may delete it.
+func DeleteLinkHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+	token := bearerToken(r)
+	if !isAdminAuthCode(token) && !isValidAuthCode(token) {
+		customlogger.FromContext(ctx).Warn().Msg("Unauthorized attempt to delete link")
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return
+	}
+	purgeClicks := false
+	if raw := r.URL.Query().Get("purge_clicks"); raw != "" {
+		var err error
+		if purgeClicks, err = strconv.ParseBool(raw); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "purge_clicks must be true or false")
+			return
+		}
+	}
+
+	exists, err := storage.LinkExists(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Redis error checking link")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return
+	}
+	if !exists {
+		writeStorageError(w, storage.ErrLinkNotFound, "Error retrieving link")
+		return
+	}
+	link, err := storage.GetLink(ctx, shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Links created before metadata was recorded have no known creator.
+		link = models.Link{ShortCode: shortCode}
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return
+	}
+	if !ownsLink(token, link) {
+		customlogger.FromContext(ctx).Warn().Msg("Attempt to delete a link created by someone else")
+		writeJSONError(w, http.StatusForbidden, "Only the link's creator or an admin may delete it.")
+		return
+	}
+	if link.Frozen {
+		writeJSONError(w, http.StatusConflict, "Link is frozen; an admin must unfreeze it before it can be deleted.")
+		return
+	}
+
+	drop, err := storage.GetDrop(ctx, shortCode)
+	hasDrop := err == nil
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load drop of link")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete link")
+		return
+	}
+
+	if err := storage.DeleteDestination(ctx, shortCode); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to delete URL from Redis")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete link")
+		return
+	}
+	if err := storage.DeleteLink(ctx, link); err != nil {
+		// The link no longer redirects; only its metadata is left behind.
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to delete link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete link")
+		return
+	}
+	// The drop's record went with the link, so its file can no longer be served.
+	if hasDrop && DropStore != nil {
+		if err := DropStore.Delete(ctx, drop.FileKey); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to remove file of deleted drop")
+		}
+	}
+	var purged int64
+	if purgeClicks {
+		if purged, err = storage.DeleteClicks(ctx, shortCode); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to purge clicks of deleted link")
+			writeJSONError(w, http.StatusInternalServerError, "Link deleted, but its clicks could not be purged")
+			return
+		}
+	}
+
+	customlogger.FromContext(ctx).Info().Bool("purge_clicks", purgeClicks).Int64("purged_clicks", purged).Msg("Link deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownsLink reports whether the holder of token may manage link: an admin, or whoever
+// created it (see linkOwner). Links without a known creator, such as anonymous ones,
+// can only be managed by admins.
+func ownsLink(token string, link models.Link) bool {
+	if isAdminAuthCode(token) {
+		return true
+	}
+	return link.Owner != "" && link.Owner == linkOwner(token)
+}
+
+// requireLinkAccess checks the auth code of a link management request, that the link
+// exists and that the caller owns it. It writes the error response and returns false
+// when the request should not proceed. Ownership is checked first, so the links of
+// others are refused without revealing whether they are still live.
+func requireLinkAccess(w http.ResponseWriter, r *http.Request, shortCode string) bool {
+	token := bearerToken(r)
+	if !isAdminAuthCode(token) && !isValidAuthCode(token) {
+		customlogger.FromContext(r.Context()).Warn().Msg("Unauthorized attempt to manage link")
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return false
+	}
+
+	link, err := storage.GetLink(r.Context(), shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Links created before metadata was recorded have no known creator.
+		link = models.Link{ShortCode: shortCode}
+	} else if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to retrieve link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return false
+	}
+	known := err == nil
+	if known && !ownsLink(token, link) {
+		customlogger.FromContext(r.Context()).Warn().Msg("Attempt to manage a link created by someone else")
+		writeJSONError(w, http.StatusForbidden, "Only the link's creator or an admin may manage it.")
+		return false
+	}
+
+	exists, err := storage.LinkExists(r.Context(), shortCode)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Redis error checking link")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+		return false
+	}
+	if !exists {
+		writeStorageError(w, storage.ErrLinkNotFound, "Error retrieving link")
+		return false
+	}
+	if !known && !ownsLink(token, link) {
+		customlogger.FromContext(r.Context()).Warn().Msg("Attempt to manage a link created by someone else")
+		writeJSONError(w, http.StatusForbidden, "Only the link's creator or an admin may manage it.")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestDeleteLinkHandlerRequiresAuth(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member"}
+	config.GlobalAppConfig.ViewerAuthCodes = []string{"viewer"}
+
+	for _, code := range []string{"", "viewer", "bogus"} {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/links/abc", nil), map[string]string{"shortcode": "abc"})
+		if code != "" {
+			req.Header.Set("Authorization", "Bearer "+code)
+		}
+		rr := httptest.NewRecorder()
+		DeleteLinkHandler(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, code)
+	}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/links/abc?purge_clicks=maybe", nil), map[string]string{"shortcode": "abc"})
+	req.Header.Set("Authorization", "Bearer member")
+	rr := httptest.NewRecorder()
+	DeleteLinkHandler(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestUnfreezeLinkHandlerRequiresAdmin(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member"}
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/links/abc/freeze", nil), map[string]string{"shortcode": "abc"})
+	req.Header.Set("Authorization", "Bearer member")
+	rr := httptest.NewRecorder()
+	UnfreezeLinkHandler(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+func TestRequireUnfrozen(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+	now := time.Now().UTC()
+	require.NoError(t, storage.CreateLink(ctx, models.Link{ShortCode: "poster", LongURL: "https://example.com", CreatedAt: now, Frozen: true}))
+	require.NoError(t, storage.CreateLink(ctx, models.Link{ShortCode: "draft", LongURL: "https://example.com", CreatedAt: now}))
+
+	for code, want := range map[string]bool{"poster": false, "draft": true, "legacy": true} {
+		rr := httptest.NewRecorder()
+		assert.Equal(t, want, requireUnfrozen(rr, httptest.NewRequest(http.MethodPut, "/api/links/"+code+"/rules", nil), code), code)
+		if !want {
+			assert.Equal(t, http.StatusConflict, rr.Code)
+		}
+	}
+}
+
+func TestLinkTeamFeaturesRequireOwnership(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"alice", "bob"}
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	require.NoError(t, storage.SaveLink(context.Background(), models.Link{ShortCode: "abc", LongURL: "https://example.com", CreatedAt: time.Now().UTC(), Owner: linkOwner("alice")}))
+
+	vars := map[string]string{"shortcode": "abc", "id": "1"}
+	for name, handler := range map[string]http.HandlerFunc{
+		"list comments":  ListLinkCommentsHandler,
+		"add comment":    AddLinkCommentHandler,
+		"delete comment": DeleteLinkCommentHandler,
+		"debug redirect": DebugRedirectHandler,
+	} {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/links/abc", nil), vars)
+		req.Header.Set("Authorization", "Bearer bob")
+		rr := httptest.NewRecorder()
+		handler(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code, name)
+	}
+}
+
+func TestGetLinkHandlerReportsExpiry(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain = "https", "riid.me"
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	serveFakeRedis(t,
+		map[string]string{"week": "https://example.com/week", "forever": "https://example.com/forever"},
+		map[string]time.Duration{"week": 7 * 24 * time.Hour},
+	)
+	// The expiry recorded at creation is ignored in favour of the TTL actually set.
+	recorded := time.Now().UTC().Add(time.Hour)
+	require.NoError(t, storage.SaveLink(context.Background(), models.Link{
+		ShortCode: "week", LongURL: "https://example.com/week", CreatedAt: time.Now().UTC(), ExpiresAt: &recorded,
+	}))
+
+	get := func(shortCode string) (int, models.LinkDetailResponse) {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/links/"+shortCode, nil), map[string]string{"shortcode": shortCode})
+		rr := httptest.NewRecorder()
+		GetLinkHandler(rr, req)
+		var resp models.LinkDetailResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		}
+		return rr.Code, resp
+	}
+
+	status, resp := get("week")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "https://riid.me/week", resp.ShortURL)
+	require.NotNil(t, resp.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(7*24*time.Hour), *resp.ExpiresAt, 2*time.Second)
+
+	status, resp = get("forever")
+	require.Equal(t, http.StatusOK, status, "links created before metadata was recorded are still described")
+	assert.Equal(t, "https://example.com/forever", resp.LongURL)
+	assert.Nil(t, resp.ExpiresAt)
+
+	status, _ = get("missing")
+	assert.Equal(t, http.StatusNotFound, status)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+)
+
+// isAPIPath reports whether path belongs to the management and statistics API.
+func isAPIPath(path string) bool {
+	return path == "/api" || strings.HasPrefix(path, "/api/")
+}
+
+// PublicListener is middleware for the public listener when API_LISTEN serves the API
+// on a listener of its own: API requests get a plain 404, so the public edge exposes
+// redirects and the pages around them only.
+func PublicListener(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAPIPath(r.URL.Path) {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// APIListener is middleware for the API_LISTEN listener, which serves the API and the
+// health check only and answers everything else, redirects included, with a 404.
+func APIListener(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAPIPath(r.URL.Path) && r.URL.Path != "/health" {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerSeparation(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	public, api := PublicListener(ok), APIListener(ok)
+
+	for path, want := range map[string][2]int{
+		"/abc":                 {http.StatusNoContent, http.StatusNotFound},
+		"/health":              {http.StatusNoContent, http.StatusNoContent},
+		"/api/shorten":         {http.StatusNotFound, http.StatusNoContent},
+		"/api":                 {http.StatusNotFound, http.StatusNoContent},
+		"/apidocs":             {http.StatusNoContent, http.StatusNotFound},
+		"/api/admin/instances": {http.StatusNotFound, http.StatusNoContent},
+	} {
+		rr := httptest.NewRecorder()
+		public.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, want[0], rr.Code, "public "+path)
+		rr = httptest.NewRecorder()
+		api.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, want[1], rr.Code, "api "+path)
+	}
+}
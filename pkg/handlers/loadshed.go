@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+)
+
+const (
+	// redirectLatencyWeight is the weight of the newest redirect in the moving average
+	// of redirect latency.
+	redirectLatencyWeight = 0.1
+	// redirectLatencyStale is how long the average is trusted without new redirects; an
+	// instance nobody is redirected through is not saturated by redirects.
+	redirectLatencyStale = 10 * time.Second
+	// shedRetryAfter is the Retry-After, in seconds, of shed requests.
+	shedRetryAfter = 5
+)
+
+// inFlight counts the requests this instance is serving.
+var inFlight atomic.Int64
+
+// redirectLatency is the exponentially weighted moving average of how long redirects
+// take on this instance.
+var redirectLatency = struct {
+	sync.Mutex
+	average time.Duration
+	updated time.Time
+}{}
+
+// observeRedirectLatency adds a redirect that took d to the moving average.
+func observeRedirectLatency(d time.Duration, now time.Time) {
+	redirectLatency.Lock()
+	defer redirectLatency.Unlock()
+	if redirectLatency.updated.IsZero() || now.Sub(redirectLatency.updated) > redirectLatencyStale {
+		redirectLatency.average = d
+	} else {
+		redirectLatency.average += time.Duration(redirectLatencyWeight * float64(d-redirectLatency.average))
+	}
+	redirectLatency.updated = now
+}
+
+// averageRedirectLatency returns the moving average of redirect latency, or 0 if no
+// redirect was served recently.
+func averageRedirectLatency(now time.Time) time.Duration {
+	redirectLatency.Lock()
+	defer redirectLatency.Unlock()
+	if now.Sub(redirectLatency.updated) > redirectLatencyStale {
+		return 0
+	}
+	return redirectLatency.average
+}
+
+// overloaded reports why the instance is saturated, or "" if it is not: too many
+// requests in flight, or redirects slowing down past LOAD_SHED_REDIRECT_LATENCY_MS.
+func overloaded(now time.Time) string {
+	cfg := config.GlobalAppConfig
+	if cfg.LoadShedMaxInFlight > 0 && inFlight.Load() > int64(cfg.LoadShedMaxInFlight) {
+		return "in_flight"
+	}
+	if cfg.LoadShedRedirectLatencyMs > 0 && averageRedirectLatency(now) > time.Duration(cfg.LoadShedRedirectLatencyMs)*time.Millisecond {
+		return "redirect_latency"
+	}
+	return ""
+}
+
+// TrackLoad is middleware counting the requests in flight, which LoadShed decides
+// on. It wraps the whole router so requests no route matched count too.
+func TrackLoad(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// MeasureRedirects is middleware feeding the time redirects take into the moving
+// average LoadShed watches.
+func MeasureRedirects(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		observeRedirectLatency(time.Since(start), time.Now())
+	})
+}
+
+// LoadShed is middleware for low-priority endpoints, such as statistics and QR codes,
+// that answers them 503 with Retry-After while the instance is saturated, so heavy
+// consumers of those cannot slow down redirects. It does nothing unless
+// LOAD_SHEDDING is enabled.
+func LoadShed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.GlobalAppConfig.LoadShedding {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if reason := overloaded(time.Now()); reason != "" {
+			customlogger.FromContext(r.Context()).Warn().Str("reason", reason).
+				Int64("in_flight", inFlight.Load()).Msg("Shedding low-priority request")
+			w.Header().Set("Retry-After", strconv.Itoa(shedRetryAfter))
+			writeJSONError(w, http.StatusServiceUnavailable, "The server is busy, try again later.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
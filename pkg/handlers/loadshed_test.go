@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/config"
+)
+
+func TestRedirectLatencyAverage(t *testing.T) {
+	now := time.Now()
+	observeRedirectLatency(100*time.Millisecond, now.Add(-time.Hour))
+	observeRedirectLatency(10*time.Millisecond, now)
+	assert.Equal(t, 10*time.Millisecond, averageRedirectLatency(now), "a stale average starts over")
+
+	observeRedirectLatency(110*time.Millisecond, now)
+	assert.Equal(t, 20*time.Millisecond, averageRedirectLatency(now))
+	assert.Zero(t, averageRedirectLatency(now.Add(time.Minute)), "without recent redirects there is no latency")
+}
+
+func TestLoadShed(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.LoadShedding = true
+	config.GlobalAppConfig.LoadShedMaxInFlight = 1
+	config.GlobalAppConfig.LoadShedRedirectLatencyMs = 0
+
+	var status int
+	stats := LoadShed(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }))
+	// The stats request is the only one in flight, so it is served.
+	TrackLoad(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rr := httptest.NewRecorder()
+		stats.ServeHTTP(rr, r)
+		status = rr.Code
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/stats/abc", nil))
+	assert.Equal(t, http.StatusNoContent, status)
+
+	// With a redirect also in flight, it is shed.
+	rr := httptest.NewRecorder()
+	TrackLoad(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		TrackLoad(stats).ServeHTTP(rr, httptest.NewRequest("GET", "/api/stats/abc", nil))
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abc", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "5", rr.Header().Get("Retry-After"))
+
+	// Slow redirects make the instance saturated too.
+	config.GlobalAppConfig.LoadShedMaxInFlight = 0
+	config.GlobalAppConfig.LoadShedRedirectLatencyMs = 50
+	observeRedirectLatency(time.Second, time.Now())
+	rr = httptest.NewRecorder()
+	stats.ServeHTTP(rr, httptest.NewRequest("GET", "/api/stats/abc", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	config.GlobalAppConfig.LoadShedding = false
+	rr = httptest.NewRecorder()
+	stats.ServeHTTP(rr, httptest.NewRequest("GET", "/api/stats/abc", nil))
+	assert.Equal(t, http.StatusNoContent, rr.Code)
+}
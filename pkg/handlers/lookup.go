@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// maxLookupResults caps how many existing links a lookup returns.
+	maxLookupResults = 100
+	// maxLookupBodyBytes caps the size of a lookup request.
+	maxLookupBodyBytes = 8 << 10
+)
+
+// LookupLinksHandler lists the existing links pointing at the destination given as
+// ?url=, so a URL is not shortened twice. Anonymous callers see public links; callers
+// with a valid auth code also see the links they created.
+func LookupLinksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := bearerToken(r)
+	if token != "" && !isValidAuthCode(token) {
+		writeJSONError(w, http.StatusUnauthorized, "Invalid auth code")
+		return
+	}
+	rawURL := strings.TrimSpace(r.URL.Query().Get("url"))
+	if rawURL == "" {
+		writeJSONError(w, http.StatusBadRequest, "url is required")
+		return
+	}
+
+	destination := NormalizeURL(rawURL)
+	owner := linkOwner(token)
+	links, err := storage.LinksByDestination(ctx, destination, owner, maxLookupResults)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up links by destination")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to look up links")
+		return
+	}
+	writeLookupResponse(w, destination, owner, links)
+}
+
+// LookupOwnLinksHandler lists the caller's own links pointing at the long_url of the
+// request body, drafts included, for tooling that checks for an existing link before
+// shortening. Unlike LookupLinksHandler it requires an auth code, API key or session,
+// and never returns other people's links.
+func LookupOwnLinksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	owner := linkOwner(bearerToken(r))
+	if owner == "" {
+		writeJSONError(w, http.StatusUnauthorized, "An auth code, API key or session that can own links is required.")
+		return
+	}
+
+	var req models.LinkLookupRequest
+	if err := decodeJSONOrYAML(r, maxLookupBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for LookupOwnLinks")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.LongURL = strings.TrimSpace(req.LongURL)
+	if req.LongURL == "" {
+		writeJSONError(w, http.StatusBadRequest, "long_url is required")
+		return
+	}
+
+	destination := NormalizeURL(req.LongURL)
+	links, err := storage.OwnedLinksByDestination(ctx, destination, owner, maxLookupResults)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up own links by destination")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to look up links")
+		return
+	}
+	writeLookupResponse(w, destination, owner, links)
+}
+
+// writeLookupResponse writes the links found for destination, marking those of owner.
+func writeLookupResponse(w http.ResponseWriter, destination, owner string, links []models.Link) {
+	resp := models.LinkLookupResponse{URL: destination, Links: make([]models.LinkLookupMatch, 0, len(links))}
+	for _, link := range links {
+		resp.Links = append(resp.Links, models.LinkLookupMatch{
+			LinkDetailResponse: models.LinkDetailResponse{Link: link, ShortURL: shortURLFor(link.ShortCode)},
+			Owned:              owner != "" && link.Owner == owner,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
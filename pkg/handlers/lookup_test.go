@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestLookupOwnLinks(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member-code", "other-code"}
+	ctx := context.Background()
+	dest := NormalizeURL("https://example.com/page")
+
+	for _, link := range []models.Link{
+		{ShortCode: "mine", LongURL: dest, Owner: linkOwner("member-code"), CreatedAt: time.Now().UTC()},
+		{ShortCode: "public", LongURL: dest, Owner: linkOwner("other-code"), Public: true, CreatedAt: time.Now().UTC()},
+	} {
+		require.NoError(t, storage.CreateLink(ctx, link))
+	}
+
+	lookup := func(authCode, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/lookup", strings.NewReader(body))
+		if authCode != "" {
+			req.Header.Set("Authorization", "Bearer "+authCode)
+		}
+		rr := httptest.NewRecorder()
+		LookupOwnLinksHandler(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, lookup("", `{"long_url":"https://example.com/page"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, lookup("member-code", `{}`).Code)
+
+	rr := lookup("member-code", `{"long_url":"https://example.com/page"}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+	var resp models.LinkLookupResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Links, 1, "other people's links are left out, even public ones")
+	assert.Equal(t, "mine", resp.Links[0].ShortCode)
+	assert.True(t, resp.Links[0].Owned)
+}
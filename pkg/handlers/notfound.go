@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/suggest"
+)
+
+// maxCodeSuggestions is the most similar codes offered for a missing short code.
+const maxCodeSuggestions = 5
+
+// notFoundSearchTemplate is the page served for unknown short codes in search mode.
+var notFoundSearchTemplate = template.Must(template.New("notfound").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Link not found</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #333; }
+h1 { font-size: 1.5rem; }
+code { background: #f3f3f3; padding: 0.1rem 0.3rem; border-radius: 3px; }
+li { margin: 0.4rem 0; }
+</style>
+</head>
+<body>
+<h1>Link not found</h1>
+<p>{{if .Code}}There is no link at <code>/{{.Code}}</code>.{{else}}This page does not exist.{{end}}</p>
+{{if .Suggestions}}<p>Did you mean one of these?</p>
+<ul>{{range .Suggestions}}
+<li><a href="/{{.}}">{{$.Domain}}/{{.}}</a></li>{{end}}
+</ul>{{end}}
+<p><a href="/">Go to the homepage</a></p>
+</body>
+</html>
+`))
+
+// codeIndexTTL is how long the in-memory index of public codes is used before it is
+// rebuilt from storage, so expired links stop being suggested.
+const codeIndexTTL = 5 * time.Minute
+
+// codeIndex holds the short codes of public links searched for near matches of a
+// missing code. Other links are never suggested, so their codes cannot be discovered
+// by probing near misses.
+var codeIndex struct {
+	sync.Mutex
+	index    *suggest.Index
+	loadedAt time.Time
+}
+
+// activeCodeIndex returns the index of public codes, rebuilding it when stale.
+func activeCodeIndex(ctx context.Context) (*suggest.Index, error) {
+	codeIndex.Lock()
+	defer codeIndex.Unlock()
+	if codeIndex.index != nil && time.Since(codeIndex.loadedAt) < codeIndexTTL {
+		return codeIndex.index, nil
+	}
+	codes, err := storage.ActiveCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	codeIndex.index = suggest.NewIndex(codes)
+	codeIndex.loadedAt = time.Now()
+	return codeIndex.index, nil
+}
+
+// indexNewLink adds a newly created or published link to the index if it is loaded
+// and the link is public, so it can be suggested before the next rebuild.
+func indexNewLink(link models.Link) {
+	if !link.Public || link.Draft {
+		return
+	}
+	codeIndex.Lock()
+	defer codeIndex.Unlock()
+	if codeIndex.index != nil {
+		codeIndex.index.Add(link.ShortCode)
+	}
+}
+
+// suggestCodes returns the codes of public links one typo (insertion, deletion or
+// substitution) away from a missing code. Suggestions are only offered with
+// NOT_FOUND_MODE=search; otherwise it returns none.
+func suggestCodes(ctx context.Context, code string) []string {
+	if config.GlobalAppConfig.NotFoundMode != config.NotFoundModeSearch {
+		return nil
+	}
+	idx, err := activeCodeIndex(ctx)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load short code index")
+		return nil
+	}
+	return idx.Similar(code, maxCodeSuggestions)
+}
+
+// missingCode returns the short code a not-found request path refers to, or "" when
+// the path is not a single segment.
+func missingCode(path string) string {
+	code := strings.TrimPrefix(path, "/")
+	if code == "" || strings.Contains(code, "/") {
+		return ""
+	}
+	return code
+}
+
+// serveNotFound answers a request for an unknown short code or path. A domain-specific
+// page takes precedence; otherwise the deployment's NOT_FOUND_MODE decides between a
+// plain 404, a redirect to the fallback URL and a page suggesting similar codes.
+func serveNotFound(w http.ResponseWriter, r *http.Request, message string) {
+	if route, ok := lookupDomainRoute(r); ok && route.NotFoundHTML != "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(route.NotFoundHTML))
+		return
+	}
+
+	switch config.GlobalAppConfig.NotFoundMode {
+	case config.NotFoundModeRedirect:
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, config.GlobalAppConfig.NotFoundRedirectURL, http.StatusFound)
+	case config.NotFoundModeSearch:
+		code := missingCode(r.URL.Path)
+		var suggestions []string
+		if code != "" {
+			suggestions = suggestCodes(r.Context(), code)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusNotFound)
+		err := notFoundSearchTemplate.Execute(w, struct {
+			Code        string
+			Domain      string
+			Suggestions []string
+		}{code, config.GlobalAppConfig.Domain, suggestions})
+		if err != nil {
+			customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to render not-found page")
+		}
+	default:
+		http.Error(w, message, http.StatusNotFound)
+	}
+}
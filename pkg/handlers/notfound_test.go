@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// resetCodeIndex drops the cached suggestion index so it is rebuilt from the test database.
+func resetCodeIndex(t *testing.T) {
+	codeIndex.Lock()
+	codeIndex.index = nil
+	codeIndex.Unlock()
+	t.Cleanup(func() {
+		codeIndex.Lock()
+		codeIndex.index = nil
+		codeIndex.Unlock()
+	})
+}
+
+func TestSuggestCodesOnlyPublicLinks(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	resetCodeIndex(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "promo1", LongURL: "https://example.com/1", CreatedAt: now, Public: true}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "promo2", LongURL: "https://example.com/2", CreatedAt: now}))
+
+	config.GlobalAppConfig.NotFoundMode = config.NotFoundMode404
+	assert.Empty(t, suggestCodes(ctx, "promo3"), "suggestions are only offered in search mode")
+
+	config.GlobalAppConfig.NotFoundMode = config.NotFoundModeSearch
+	assert.Equal(t, []string{"promo1"}, suggestCodes(ctx, "promo3"), "private links are never suggested")
+
+	// Newly created private links are not added to a loaded index either.
+	indexNewLink(models.Link{ShortCode: "promo4", CreatedAt: now})
+	indexNewLink(models.Link{ShortCode: "promo5", CreatedAt: now, Public: true})
+	assert.Equal(t, []string{"promo1", "promo5"}, suggestCodes(ctx, "promo3"))
+
+	// Codes further than one typo away are not suggested, whatever prefix they share.
+	assert.Empty(t, suggestCodes(ctx, "promo2x1"))
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/pagemeta"
+	"riid.me/pkg/storage"
+)
+
+// pageMetaTimeout bounds fetching and storing a destination page's metadata.
+const pageMetaTimeout = 20 * time.Second
+
+// PageMetaFetcher fetches the metadata of new links' destination pages; nil when
+// FETCH_PAGE_META is off.
+var PageMetaFetcher *pagemeta.Fetcher
+
+// fetchPageMeta fetches, in the background, the title, description and images of the
+// page a new link leads to and stores them with the link. Failures are logged; the
+// link simply has no page metadata.
+func fetchPageMeta(ctx context.Context, shortCode, destination string) {
+	if PageMetaFetcher == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, pageMetaTimeout)
+		defer cancel()
+		page, err := PageMetaFetcher.Fetch(ctx, destination)
+		if err != nil {
+			customlogger.FromContext(ctx).Info().Err(err).Msg("Failed to fetch destination page metadata")
+			return
+		}
+		meta := models.PageMeta{
+			Title:       page.Title,
+			Description: page.Description,
+			FaviconURL:  page.Favicon,
+			ImageURL:    page.Image,
+			FetchedAt:   time.Now().UTC().Truncate(time.Second),
+		}
+		if err := storage.SetLinkPageMeta(ctx, shortCode, meta); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store destination page metadata")
+		}
+	}()
+}
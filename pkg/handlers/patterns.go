@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+// reservedPatternPrefixes are path prefixes that redirect patterns may not shadow.
+var reservedPatternPrefixes = []string{"/api/", "/static/", "/badge/"}
+
+// compilePattern turns a wildcard path pattern into an anchored regular expression in
+// which each "*" becomes a capture group matching any run of characters.
+func compilePattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, "(.*)") + "$")
+}
+
+// validatePattern checks that a pattern and its destination are usable together.
+func validatePattern(pattern, destination string) error {
+	if !strings.HasPrefix(pattern, "/") || pattern == "/" || pattern == "/*" {
+		return errors.New("Pattern must be a path below the root, e.g. /docs/*")
+	}
+	for _, prefix := range reservedPatternPrefixes {
+		if strings.HasPrefix(pattern, prefix) || pattern+"/" == prefix {
+			return errors.New("Pattern may not shadow built-in routes")
+		}
+	}
+	if strings.Count(destination, "*") > strings.Count(pattern, "*") {
+		return errors.New("Destination uses more wildcards than the pattern captures")
+	}
+	return validation.ValidateDestination(strings.ReplaceAll(destination, "*", ""))
+}
+
+// expandPattern substitutes the captured path segments into the destination, one per "*".
+func expandPattern(destination string, captures []string) string {
+	var b strings.Builder
+	for i, part := range strings.Split(destination, "*") {
+		if i > 0 {
+			if i-1 < len(captures) {
+				b.WriteString(captures[i-1])
+			}
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// patternSpecificity orders patterns so that longer literal text wins, e.g.
+// "/docs/api/*" is tried before "/docs/*".
+func patternSpecificity(pattern string) int {
+	return len(strings.ReplaceAll(pattern, "*", ""))
+}
+
+// matchRedirectPattern finds the most specific admin-defined pattern matching path and
+// returns its expanded destination. The visitor's query string is carried over.
+func matchRedirectPattern(ctx context.Context, path, rawQuery string) (string, bool) {
+	patterns, err := storage.ListRedirectPatterns(ctx)
+	if err != nil {
+		customlogger.Error().Err(err).Str("path", path).Msg("Failed to load redirect patterns")
+		return "", false
+	}
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patternSpecificity(patterns[i].Pattern) > patternSpecificity(patterns[j].Pattern)
+	})
+
+	for _, p := range patterns {
+		m := compilePattern(p.Pattern).FindStringSubmatch(path)
+		if m == nil {
+			continue
+		}
+		destination := expandPattern(p.Destination, m[1:])
+		if rawQuery != "" {
+			if strings.Contains(destination, "?") {
+				destination += "&" + rawQuery
+			} else {
+				destination += "?" + rawQuery
+			}
+		}
+		customlogger.Info().Str("path", path).Str("pattern", p.Pattern).Str("destination", destination).Msg("Redirect pattern matched")
+		return destination, true
+	}
+	return "", false
+}
+
+// PatternRedirectHandler serves paths that no route matched, such as multi-segment vanity
+// paths, by trying the admin-defined redirect patterns before responding 404.
+func PatternRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.NotFound(w, r)
+		return
+	}
+	if destination, ok := matchRedirectPattern(r.Context(), r.URL.Path, r.URL.RawQuery); ok {
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, destination, http.StatusFound)
+		return
+	}
+	serveNotFound(w, r, "404 page not found")
+}
+
+// ListRedirectPatternsHandler returns all redirect patterns.
+func ListRedirectPatternsHandler(w http.ResponseWriter, r *http.Request) {
+	patterns, err := storage.ListRedirectPatterns(r.Context())
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Failed to list redirect patterns")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve patterns")
+		return
+	}
+	writeJSON(w, http.StatusOK, patterns)
+}
+
+// SaveRedirectPatternHandler creates a redirect pattern, or updates the destination of an
+// existing one with the same path.
+func SaveRedirectPatternHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.RedirectPattern
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		customlogger.Error().Err(err).Msg("Invalid request body for SaveRedirectPattern")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	req.Pattern = strings.TrimSpace(req.Pattern)
+	req.Destination = NormalizeURL(req.Destination)
+	if err := validatePattern(req.Pattern, req.Destination); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	pattern, err := storage.SaveRedirectPattern(r.Context(), req.Pattern, req.Destination)
+	if err != nil {
+		customlogger.Error().Err(err).Str("pattern", req.Pattern).Msg("Failed to store redirect pattern")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store pattern")
+		return
+	}
+
+	customlogger.Info().Str("pattern", pattern.Pattern).Str("destination", pattern.Destination).Msg("Redirect pattern saved")
+	writeJSON(w, http.StatusOK, pattern)
+}
+
+// DeleteRedirectPatternHandler removes a redirect pattern.
+func DeleteRedirectPatternHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid pattern ID")
+		return
+	}
+
+	if err := storage.DeleteRedirectPattern(r.Context(), id); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.Error().Err(err).Int64("pattern_id", id).Msg("Failed to delete redirect pattern")
+		}
+		writeStorageError(w, err, "Failed to delete pattern")
+		return
+	}
+
+	customlogger.Info().Int64("pattern_id", id).Msg("Redirect pattern deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternExpansion(t *testing.T) {
+	re := compilePattern("/docs/*")
+	m := re.FindStringSubmatch("/docs/guide/intro")
+	assert.Equal(t, []string{"guide/intro"}, m[1:])
+	assert.Equal(t, "https://docs.example.com/guide/intro", expandPattern("https://docs.example.com/*", m[1:]))
+
+	assert.Nil(t, re.FindStringSubmatch("/documents/x"))
+
+	multi := compilePattern("/u/*/repo/*")
+	m = multi.FindStringSubmatch("/u/alice/repo/tools.git")
+	assert.Equal(t, "https://git.example.com/alice/tools.git", expandPattern("https://git.example.com/*/*", m[1:]))
+
+	dotted := compilePattern("/v1.0/*")
+	assert.Nil(t, dotted.FindStringSubmatch("/v1x0/a"))
+}
+
+func TestValidatePattern(t *testing.T) {
+	assert.NoError(t, validatePattern("/docs/*", "https://docs.example.com/*"))
+	assert.Error(t, validatePattern("docs/*", "https://docs.example.com/*"))
+	assert.Error(t, validatePattern("/*", "https://docs.example.com/*"))
+	assert.Error(t, validatePattern("/api/*", "https://docs.example.com/*"))
+	assert.Error(t, validatePattern("/docs", "https://docs.example.com/*"))
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"strings"
+
+	"riid.me/pkg/config"
+)
+
+// defaultProfanityWords are the words generated short codes must not contain. Short
+// codes are a handful of characters, so the list only needs short words and stems.
+var defaultProfanityWords = []string{
+	"anal", "anus", "arse", "ass", "bitch", "boob", "butt", "cock", "coon", "cum",
+	"cunt", "dick", "dildo", "dyke", "fag", "fuck", "gay", "hitler", "homo", "jizz",
+	"kike", "nazi", "nigg", "penis", "piss", "poop", "porn", "puss", "rape", "retard",
+	"sex", "shit", "slut", "spic", "tit", "twat", "vagin", "wank", "whore",
+}
+
+// leetReplacer undoes the digit-for-letter substitutions shortid's alphabet allows,
+// and drops its separators, so "5h1t" and "s-h_it" are caught like "shit".
+var leetReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "8", "b", "-", "", "_", "",
+)
+
+// isProfane reports whether code contains one of the built-in or PROFANITY_WORDS
+// words, ignoring case and leetspeak.
+func isProfane(code string) bool {
+	normalized := leetReplacer.Replace(strings.ToLower(code))
+	for _, words := range [][]string{defaultProfanityWords, config.GlobalAppConfig.ProfanityWords} {
+		for _, word := range words {
+			if strings.Contains(normalized, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+)
+
+func TestIsProfane(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ProfanityWords = []string{"meh"}
+
+	for _, code := range []string{"xSHiTq", "a5h1t", "Pf-u_cK", "zm3h2"} {
+		assert.True(t, isProfane(code), code)
+	}
+	for _, code := range []string{"Kq3xZ9", "abc_DEF", "s-hx"} {
+		assert.False(t, isProfane(code), code)
+	}
+}
+
+func TestGenerateShortCodeSkipsProfanity(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	require.NoError(t, InitShortIDService())
+
+	config.GlobalAppConfig.ProfanityFilter = true
+	for i := 0; i < 200; i++ {
+		code, err := generateShortCode(context.Background())
+		require.NoError(t, err)
+		assert.False(t, isProfane(code), code)
+	}
+}
@@ -1,18 +1,21 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"image"
 	"image/color"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
-	"github.com/gorilla/mux"
+	"github.com/HugoSmits86/nativewebp"
 	qrcode "github.com/yeqown/go-qrcode/v2"
 	"github.com/yeqown/go-qrcode/writer/standard"
-	customlogger "riid.me/pkg/logger"
 	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
 )
 
 // hexToNRGBA converts a hex color string (e.g., "#RRGGBB") to a color.NRGBA object.
@@ -49,37 +52,180 @@ type nopCloser struct {
 // It's a no-operation method because http.ResponseWriter doesn't need explicit closing in this context.
 func (nopCloser) Close() error { return nil }
 
+// defaultQRModuleWidth is the module width, in pixels, of QR codes rendered with the
+// default 256px size.
+const defaultQRModuleWidth = 256 / 35
+
+// QR code image formats the QR endpoint can serve.
+const (
+	qrFormatPNG  = "png"
+	qrFormatWebP = "webp"
+)
+
+// qrContentTypes maps each QR code image format to its Content-Type.
+var qrContentTypes = map[string]string{
+	qrFormatPNG:  "image/png",
+	qrFormatWebP: "image/webp",
+}
+
+// webpEncoder encodes QR code images as lossless WebP, which is considerably smaller
+// than PNG for the flat two-color images QR codes are.
+type webpEncoder struct{}
+
+// Encode implements standard.ImageEncoder.
+func (webpEncoder) Encode(w io.Writer, img image.Image) error {
+	return nativewebp.Encode(w, img, nil)
+}
+
+// writeQRCode encodes content as a QR code image in format (qrFormatPNG or
+// qrFormatWebP) with the given module width and colors.
+func writeQRCode(out io.Writer, content, format string, moduleWidth uint8, fg, bg color.Color) error {
+	qrc, err := qrcode.New(content)
+	if err != nil {
+		return err
+	}
+
+	encoder := standard.WithBuiltinImageEncoder(standard.PNG_FORMAT)
+	if format == qrFormatWebP {
+		encoder = standard.WithCustomImageEncoder(webpEncoder{})
+	}
+
+	// Prepare QR code image styling options for the standard writer
+	stWriterOptions := []standard.ImageOption{
+		standard.WithBgColor(bg),
+		standard.WithFgColor(fg),
+		standard.WithQRWidth(moduleWidth),
+		encoder,
+	}
+
+	// standard.NewWithWriter expects an io.WriteCloser. We wrap out with nopCloser.
+	return qrc.Save(standard.NewWithWriter(nopCloser{Writer: out}, stWriterOptions...))
+}
+
+// writeQRCodePNG encodes content as a QR code PNG with the given module width and colors.
+func writeQRCodePNG(out io.Writer, content string, moduleWidth uint8, fg, bg color.Color) error {
+	return writeQRCode(out, content, qrFormatPNG, moduleWidth, fg, bg)
+}
+
+// negotiateQRFormat picks the image format of a QR code response: the format query
+// parameter when given, otherwise WebP for clients whose Accept header lists
+// image/webp, and PNG for everyone else.
+func negotiateQRFormat(r *http.Request) (string, error) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		if _, ok := qrContentTypes[format]; !ok {
+			return "", fmt.Errorf("format must be %s or %s", qrFormatPNG, qrFormatWebP)
+		}
+		return format, nil
+	}
+	if acceptsMediaType(r.Header.Get("Accept"), "image/webp") {
+		return qrFormatWebP, nil
+	}
+	return qrFormatPNG, nil
+}
+
+// acceptsMediaType reports whether an Accept header explicitly lists mediaType with
+// a non-zero quality. Wildcards do not count, since browsers send "*/*" for images
+// regardless of the formats they can decode.
+func acceptsMediaType(accept, mediaType string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), mediaType) {
+			continue
+		}
+		for _, param := range fields[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// qrThumbnailModuleWidth is the module width, in pixels, of QR code thumbnails, less
+// than half the size of the default QR code.
+const qrThumbnailModuleWidth = 3
+
+// qrURLFor returns the URL of the QR code endpoint for a short code.
+func qrURLFor(code string) string {
+	return fmt.Sprintf("%s://%s/api/qr/%s", config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain, code)
+}
+
+// qrThumbnailDataURI renders a small black-on-white QR code for content as a PNG data
+// URI, for embedding in exports.
+func qrThumbnailDataURI(content string) (string, error) {
+	var buf bytes.Buffer
+	if err := writeQRCodePNG(&buf, content, qrThumbnailModuleWidth, color.Black, color.White); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// qrCodePNGBase64 renders the default black-on-white QR code for content as a
+// base64-encoded PNG.
+func qrCodePNGBase64(content string) (string, error) {
+	var buf bytes.Buffer
+	err := writeQRCodePNG(&buf, content, defaultQRModuleWidth, color.Black, color.White)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// qrModuleWidth converts the requested QR code size in pixels into the module width
+// to render with. An empty size means the default 256px; anything that is not an
+// integer between config.MinQRSize and maxSize is rejected, so untrusted input cannot
+// drive the image dimensions.
+func qrModuleWidth(size string, maxSize int) (uint8, error) {
+	if size == "" {
+		return defaultQRModuleWidth, nil
+	}
+	pixels, err := strconv.Atoi(size)
+	if err != nil || pixels < config.MinQRSize || pixels > maxSize {
+		return 0, fmt.Errorf("size must be an integer between %d and %d", config.MinQRSize, maxSize)
+	}
+	return uint8(pixels / config.MinQRSize), nil
+}
+
 // GenerateQRCodeHandler generates and serves a QR code image for a given shortcode.
-// It supports query parameters for customization: size, fg (foreground color),
-// bg (background color), and level (error correction level).
+// It supports query parameters for customization: size (pixels, up to QR_MAX_SIZE),
+// fg (foreground color), bg (background color), level (error correction level),
+// format (png or webp; by default negotiated from the Accept header) and src (a source
+// tag added to the encoded short URL, see CreateQRVariantsHandler).
+// Requests are rate limited per client to QR_RATE_LIMIT per minute.
 func GenerateQRCodeHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	shortCode := vars["shortcode"]
+	shortCode := requestedShortCode(r)
 
 	if shortCode == "" {
-		customlogger.Warn().Msg("generateQRCodeHandler: shortcode parameter is missing")
+		customlogger.FromContext(r.Context()).Warn().Msg("generateQRCodeHandler: shortcode parameter is missing")
 		http.Error(w, "Shortcode parameter is missing", http.StatusBadRequest)
 		return
 	}
 
-	appScheme := config.GlobalAppConfig.Scheme
-	appDomain := config.GlobalAppConfig.Domain
-	fullURL := fmt.Sprintf("%s://%s/%s", appScheme, appDomain, shortCode)
+	if !allowRateLimited(w, r, "qr", config.GlobalAppConfig.QRRateLimit) {
+		return
+	}
 
 	query := r.URL.Query()
-	desiredPixelSize := 256 // Default size
-	if sizeStr := query.Get("size"); sizeStr != "" {
-		if parsedSize, err := strconv.Atoi(sizeStr); err == nil && parsedSize > 0 {
-			desiredPixelSize = parsedSize
-		}
+	source, err := clickSource(query.Get(clickSourceParam))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	fullURL := sourceURLFor(shortCode, source)
 
-	modulePixelWidth := uint8(desiredPixelSize / 35) // Approximate module width
-	if modulePixelWidth < 1 {
-		modulePixelWidth = 1
+	modulePixelWidth, err := qrModuleWidth(query.Get("size"), config.GlobalAppConfig.QRMaxSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	if modulePixelWidth > 20 { // Cap module size
-		modulePixelWidth = 20
+	format, err := negotiateQRFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	fgColorHex := query.Get("fg")
@@ -88,7 +234,7 @@ func GenerateQRCodeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	fgColor, err := hexToNRGBA(fgColorHex)
 	if err != nil {
-		customlogger.Warn().Err(err).Str("color_hex", fgColorHex).Msg("Failed to parse foreground color, using default")
+		customlogger.FromContext(r.Context()).Warn().Err(err).Str("color_hex", fgColorHex).Msg("Failed to parse foreground color, using default")
 		fgColor = color.NRGBA{R: 0, G: 0, B: 0, A: 255}
 	}
 
@@ -98,40 +244,25 @@ func GenerateQRCodeHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	bgColor, err := hexToNRGBA(bgColorHex)
 	if err != nil {
-		customlogger.Warn().Err(err).Str("color_hex", bgColorHex).Msg("Failed to parse background color, using default")
+		customlogger.FromContext(r.Context()).Warn().Err(err).Str("color_hex", bgColorHex).Msg("Failed to parse background color, using default")
 		bgColor = color.NRGBA{R: 255, G: 255, B: 255, A: 255}
 	}
 
 	_ = query.Get("level") // Keep levelStr for now, but don't use qrLevel directly if it causes issues
 
-	// Create the QR code object
-	qrc, err := qrcode.New(fullURL) // Simplified: only content string
-	if err != nil {
-		customlogger.Error().Err(err).Str("url", fullURL).Msg("Failed to generate QR code object")
+	var img bytes.Buffer
+	if err := writeQRCode(&img, fullURL, format, modulePixelWidth, fgColor, bgColor); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Str("url", fullURL).Msg("Failed to generate QR code")
 		http.Error(w, "Failed to generate QR code", http.StatusInternalServerError)
 		return
 	}
 
-	// Prepare QR code image styling options for the standard writer
-	stWriterOptions := []standard.ImageOption{
-		standard.WithBgColor(bgColor), // Assumes bgColor is color.Color
-		standard.WithFgColor(fgColor), // Assumes fgColor is color.Color
-		standard.WithQRWidth(modulePixelWidth),
-		standard.WithBuiltinImageEncoder(standard.PNG_FORMAT),
-	}
-
-	// For writing directly to http.ResponseWriter, use standard.NewWithWriter
-	// The http.ResponseWriter (w) implements io.Writer.
-	// standard.NewWithWriter expects an io.WriteCloser. We wrap 'w' with nopCloser.
-	stWriter := standard.NewWithWriter(nopCloser{Writer: w}, stWriterOptions...)
-
-	w.Header().Set("Content-Type", "image/png") // Set content type before writing
-
-	if err := qrc.Save(stWriter); err != nil {
-		customlogger.Error().Err(err).Msg("Failed to write QR code to response")
-		// Avoid writing http.Error here if headers are already sent
+	w.Header().Set("Content-Type", qrContentTypes[format])
+	w.Header().Set("Vary", "Accept")
+	if _, err := w.Write(img.Bytes()); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to write QR code to response")
 		return
 	}
 
-	customlogger.Info().Str("shortcode", shortCode).Str("url", fullURL).Msg("Successfully generated and served QR code")
+	customlogger.FromContext(r.Context()).Info().Str("url", fullURL).Str("format", format).Msg("Successfully generated and served QR code")
 }
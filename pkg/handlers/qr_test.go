@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+	"riid.me/pkg/config"
+)
+
+func TestQRCodePNGBase64(t *testing.T) {
+	encoded, err := qrCodePNGBase64("http://localhost:3000/abc123")
+	require.NoError(t, err)
+
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Greater(t, img.Bounds().Dx(), 0)
+}
+
+func TestQRThumbnailDataURI(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain = "https", "riid.me"
+	assert.Equal(t, "https://riid.me/api/qr/abc123", qrURLFor("abc123"))
+
+	uri, err := qrThumbnailDataURI("https://riid.me/abc123")
+	require.NoError(t, err)
+	encoded, ok := strings.CutPrefix(uri, "data:image/png;base64,")
+	require.True(t, ok)
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.Less(t, img.Bounds().Dx(), 200, "thumbnails are smaller than the default QR code")
+}
+
+func TestQRModuleWidth(t *testing.T) {
+	width, err := qrModuleWidth("", 1024)
+	require.NoError(t, err)
+	assert.EqualValues(t, defaultQRModuleWidth, width)
+
+	width, err = qrModuleWidth("1024", 1024)
+	require.NoError(t, err)
+	assert.EqualValues(t, 29, width)
+
+	for _, size := range []string{"1025", "34", "0", "-256", "256px", "1e3", "99999999999999999999"} {
+		_, err := qrModuleWidth(size, 1024)
+		assert.Error(t, err, size)
+	}
+}
+
+func TestAllowRateLimitedDisabled(t *testing.T) {
+	rr := httptest.NewRecorder()
+	assert.True(t, allowRateLimited(rr, httptest.NewRequest(http.MethodGet, "/api/qr/abc", nil), "qr", 0))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestAllowTokenBucketDisabled(t *testing.T) {
+	rr := httptest.NewRecorder()
+	assert.True(t, allowTokenBucket(rr, httptest.NewRequest(http.MethodPost, "/api/shorten", nil), "shorten", 0))
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestNegotiateQRFormat(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/qr/abc", nil)
+	req.Header.Set("Accept", "image/avif,image/webp,image/apng,*/*;q=0.8")
+	format, err := negotiateQRFormat(req)
+	require.NoError(t, err)
+	assert.Equal(t, qrFormatWebP, format)
+
+	req.Header.Set("Accept", "image/webp;q=0, */*")
+	format, _ = negotiateQRFormat(req)
+	assert.Equal(t, qrFormatPNG, format)
+
+	req.Header.Set("Accept", "*/*")
+	format, _ = negotiateQRFormat(req)
+	assert.Equal(t, qrFormatPNG, format, "wildcards do not opt in to WebP")
+
+	req = httptest.NewRequest(http.MethodGet, "/api/qr/abc?format=PNG", nil)
+	req.Header.Set("Accept", "image/webp")
+	format, _ = negotiateQRFormat(req)
+	assert.Equal(t, qrFormatPNG, format, "the format parameter wins over Accept")
+
+	_, err = negotiateQRFormat(httptest.NewRequest(http.MethodGet, "/api/qr/abc?format=gif", nil))
+	assert.Error(t, err)
+}
+
+func TestWriteQRCodeWebP(t *testing.T) {
+	var webpBuf, pngBuf bytes.Buffer
+	require.NoError(t, writeQRCode(&webpBuf, "http://localhost:3000/abc123", qrFormatWebP, 8, color.Black, color.White))
+	require.NoError(t, writeQRCode(&pngBuf, "http://localhost:3000/abc123", qrFormatPNG, 8, color.Black, color.White))
+
+	img, err := webp.Decode(bytes.NewReader(webpBuf.Bytes()))
+	require.NoError(t, err)
+	reference, err := png.Decode(bytes.NewReader(pngBuf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, reference.Bounds(), img.Bounds())
+	assert.Less(t, webpBuf.Len(), pngBuf.Len())
+}
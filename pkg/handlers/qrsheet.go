@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// maxQRSheetBodyBytes caps the size of a QR sheet request.
+	maxQRSheetBodyBytes = 64 << 10
+	// maxQRSheetLinks caps how many QR codes one sheet request may lay out.
+	maxQRSheetLinks = 120
+	// maxQRSheetColumns is the most QR codes placed side by side; beyond it codes get
+	// too small to scan from a distance.
+	maxQRSheetColumns = 4
+	// defaultQRSheetColumns is the number of columns when a request does not set one.
+	defaultQRSheetColumns = 3
+	// qrSheetModuleWidth is the module width, in pixels, of the QR code images embedded
+	// in sheets, large enough to stay sharp when printed.
+	qrSheetModuleWidth = 12
+	// maxQRSheetCaptionLines is how many lines of a caption are printed before it is cut.
+	maxQRSheetCaptionLines = 2
+)
+
+// Sheet layout in millimetres.
+const (
+	qrSheetMargin      = 15.0
+	qrSheetGutter      = 6.0
+	qrSheetMaxCodeSize = 100.0
+	qrSheetCaptionLine = 5.0
+	qrSheetURLLine     = 4.5
+)
+
+// qrSheetPaper maps the supported paper names to their fpdf size names.
+var qrSheetPaper = map[string]string{
+	"a4":     "A4",
+	"letter": "Letter",
+}
+
+// qrSheetItem is one QR code on a sheet.
+type qrSheetItem struct {
+	URL     string
+	Caption string
+}
+
+// writeQRSheet renders items as a PDF with their QR codes in a grid of columns on
+// paper ("a4" or "letter"), each above its caption and short URL, adding pages as needed.
+func writeQRSheet(out io.Writer, paper string, columns int, items []qrSheetItem) error {
+	pdf := fpdf.New("P", "mm", qrSheetPaper[paper], "")
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetCreator("riid.me", false)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pageWidth, pageHeight := pdf.GetPageSize()
+	cellWidth := (pageWidth - 2*qrSheetMargin - float64(columns-1)*qrSheetGutter) / float64(columns)
+	codeSize := math.Min(cellWidth, qrSheetMaxCodeSize)
+	cellHeight := codeSize + 2 + maxQRSheetCaptionLines*qrSheetCaptionLine + qrSheetURLLine + qrSheetGutter
+	rows := int((pageHeight - 2*qrSheetMargin + qrSheetGutter) / cellHeight)
+	if rows < 1 {
+		rows = 1
+	}
+
+	for i, item := range items {
+		slot := i % (rows * columns)
+		if slot == 0 {
+			pdf.AddPage()
+		}
+		x := qrSheetMargin + float64(slot%columns)*(cellWidth+qrSheetGutter)
+		y := qrSheetMargin + float64(slot/columns)*cellHeight
+
+		var img bytes.Buffer
+		if err := writeQRCodePNG(&img, item.URL, qrSheetModuleWidth, color.Black, color.White); err != nil {
+			return fmt.Errorf("rendering QR code for %s: %w", item.URL, err)
+		}
+		name := fmt.Sprintf("qr%d", i)
+		pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, &img)
+		pdf.ImageOptions(name, x+(cellWidth-codeSize)/2, y, codeSize, codeSize, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+
+		pdf.SetXY(x, y+codeSize+2)
+		pdf.SetFont("Helvetica", "B", 11)
+		// The translated caption is in the font's single-byte encoding, so it is split as bytes.
+		lines := pdf.SplitLines([]byte(tr(item.Caption)), cellWidth)
+		if len(lines) > maxQRSheetCaptionLines {
+			lines = lines[:maxQRSheetCaptionLines]
+			lines[len(lines)-1] = append(bytes.TrimSpace(lines[len(lines)-1]), "..."...)
+		}
+		for _, line := range lines {
+			pdf.CellFormat(cellWidth, qrSheetCaptionLine, string(line), "", 2, "C", false, 0, "")
+		}
+
+		pdf.SetFont("Helvetica", "", 9)
+		_, printed, _ := strings.Cut(item.URL, "://")
+		pdf.CellFormat(cellWidth, qrSheetURLLine, tr(printed), "", 2, "C", false, 0, "")
+	}
+	return pdf.Output(out)
+}
+
+// CreateQRSheetHandler renders a printable PDF sheet with the QR codes of the
+// requested links, each captioned with a note or the link's title and its short URL,
+// for signage at events.
+func CreateQRSheetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !isValidAuthCode(bearerToken(r)) {
+		customlogger.FromContext(ctx).Warn().Msg("Unauthorized attempt to create a QR sheet")
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return
+	}
+
+	var req models.QRSheetRequest
+	if err := decodeJSONOrYAML(r, maxQRSheetBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for CreateQRSheet")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	paper := strings.ToLower(req.Paper)
+	if paper == "" {
+		paper = "a4"
+	}
+	if _, ok := qrSheetPaper[paper]; !ok {
+		writeJSONError(w, http.StatusBadRequest, "paper must be a4 or letter")
+		return
+	}
+	columns := req.Columns
+	if columns == 0 {
+		columns = defaultQRSheetColumns
+	}
+	if columns < 1 || columns > maxQRSheetColumns {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("columns must be between 1 and %d", maxQRSheetColumns))
+		return
+	}
+	if len(req.Links) == 0 || len(req.Links) > maxQRSheetLinks {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("links must list between 1 and %d links", maxQRSheetLinks))
+		return
+	}
+
+	// Account users may only print their own links.
+	token := bearerToken(r)
+	ownLinksOnly := callerRole(r) == roleUser
+	items := make([]qrSheetItem, 0, len(req.Links))
+	for _, sheetLink := range req.Links {
+		exists, err := storage.LinkExists(ctx, sheetLink.ShortCode)
+		if err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Str("code", sheetLink.ShortCode).Msg("Redis error checking link")
+			writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+			return
+		}
+		if !exists {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("Link '%s' not found", sheetLink.ShortCode))
+			return
+		}
+		caption := strings.TrimSpace(sheetLink.Caption)
+		if caption == "" || ownLinksOnly {
+			link, err := storage.GetLink(ctx, sheetLink.ShortCode)
+			if err != nil && !errors.Is(err, storage.ErrLinkNotFound) {
+				customlogger.FromContext(ctx).Warn().Err(err).Str("code", sheetLink.ShortCode).Msg("Failed to load link for QR sheet")
+				if ownLinksOnly {
+					writeJSONError(w, http.StatusInternalServerError, "Error retrieving link")
+					return
+				}
+			}
+			if ownLinksOnly && !ownsLink(token, link) {
+				writeJSONError(w, http.StatusForbidden, fmt.Sprintf("Link '%s' was created by someone else.", sheetLink.ShortCode))
+				return
+			}
+			if caption == "" {
+				caption = link.Title
+			}
+		}
+		items = append(items, qrSheetItem{URL: shortURLFor(sheetLink.ShortCode), Caption: caption})
+	}
+
+	var out bytes.Buffer
+	if err := writeQRSheet(&out, paper, columns, items); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to render QR sheet")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to render QR sheet")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Int("links", len(items)).Str("paper", paper).Msg("QR sheet created")
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", `attachment; filename="qr-codes.pdf"`)
+	w.Write(out.Bytes())
+}
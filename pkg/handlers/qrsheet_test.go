@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pdfPagePattern matches the page objects of a PDF, but not its page tree.
+var pdfPagePattern = regexp.MustCompile(`/Type /Page\b[^s]`)
+
+func TestWriteQRSheet(t *testing.T) {
+	items := []qrSheetItem{
+		{URL: "https://riid.me/booth4", Caption: "Booth 4 – demos"},
+		{URL: "https://riid.me/talk", Caption: strings.Repeat("A very long caption ", 20)},
+	}
+	var out bytes.Buffer
+	require.NoError(t, writeQRSheet(&out, "a4", 3, items))
+	assert.True(t, bytes.HasPrefix(out.Bytes(), []byte("%PDF-")))
+	assert.Len(t, pdfPagePattern.FindAll(out.Bytes(), -1), 1)
+
+	// A single column of large codes on Letter paper fits two per page.
+	out.Reset()
+	require.NoError(t, writeQRSheet(&out, "letter", 1, append(items, items[0], items[1], items[0])))
+	assert.Len(t, pdfPagePattern.FindAll(out.Bytes(), -1), 3)
+}
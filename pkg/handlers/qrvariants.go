@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/color"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+)
+
+const (
+	// clickSourceParam is the query parameter of a short URL that tags where a click
+	// came from, e.g. ?src=lobby for the poster in the lobby.
+	clickSourceParam = "src"
+	// maxQRVariants caps how many QR code variants one request may generate.
+	maxQRVariants = 50
+	// maxQRVariantsBodyBytes caps the size of a QR variants request.
+	maxQRVariantsBodyBytes = 16 << 10
+)
+
+// clickSourcePattern is what a source tag may look like once lowercased, so tags stay
+// readable in stats and need no escaping in URLs.
+var clickSourcePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// clickSource normalizes a source tag. An empty tag is valid and means untagged.
+func clickSource(raw string) (string, error) {
+	source := strings.ToLower(strings.TrimSpace(raw))
+	if source == "" || clickSourcePattern.MatchString(source) {
+		return source, nil
+	}
+	return "", errors.New("source must be 1 to 64 letters, digits, '.', '_' or '-', starting with a letter or digit")
+}
+
+// sourceURLFor returns the short URL of shortCode tagged with source, or the plain
+// short URL for an empty source.
+func sourceURLFor(shortCode, source string) string {
+	if source == "" {
+		return shortURLFor(shortCode)
+	}
+	return shortURLFor(shortCode) + "?" + clickSourceParam + "=" + url.QueryEscape(source)
+}
+
+// CreateQRVariantsHandler generates one QR code per source of a link, each pointing at
+// the short URL tagged with ?src=<source>, so clicks from e.g. different poster
+// placements can be told apart in the link's stats.
+func CreateQRVariantsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+
+	var req models.QRVariantsRequest
+	if err := decodeJSONOrYAML(r, maxQRVariantsBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for CreateQRVariants")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	sources, err := qrVariantSources(req.Sources)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	moduleWidth := uint8(defaultQRModuleWidth)
+	if req.Size != 0 {
+		if moduleWidth, err = qrModuleWidth(strconv.Itoa(req.Size), config.GlobalAppConfig.QRMaxSize); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	resp := models.QRVariantsResponse{ShortCode: shortCode, Variants: make([]models.QRVariant, 0, len(sources))}
+	for _, source := range sources {
+		variantURL := sourceURLFor(shortCode, source)
+		var img bytes.Buffer
+		if err := writeQRCodePNG(&img, variantURL, moduleWidth, color.Black, color.White); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Str("url", variantURL).Msg("Failed to generate QR code variant")
+			writeJSONError(w, http.StatusInternalServerError, "Failed to generate QR code")
+			return
+		}
+		resp.Variants = append(resp.Variants, models.QRVariant{
+			Source:      source,
+			URL:         variantURL,
+			QRPNGBase64: base64.StdEncoding.EncodeToString(img.Bytes()),
+		})
+	}
+
+	customlogger.FromContext(ctx).Info().Int("variants", len(resp.Variants)).Msg("QR code variants generated")
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// qrVariantSources validates and normalizes the sources of a QR variants request,
+// dropping duplicates.
+func qrVariantSources(raw []string) ([]string, error) {
+	seen := make(map[string]bool, len(raw))
+	var sources []string
+	for _, r := range raw {
+		source, err := clickSource(r)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q", err, r)
+		}
+		if source != "" && !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) == 0 || len(sources) > maxQRVariants {
+		return nil, fmt.Errorf("sources must list between 1 and %d sources", maxQRVariants)
+	}
+	return sources, nil
+}
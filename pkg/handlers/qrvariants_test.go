@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+)
+
+func TestClickSource(t *testing.T) {
+	for raw, want := range map[string]string{"": "", " Lobby ": "lobby", "flyer-b": "flyer-b", "hall_2.east": "hall_2.east"} {
+		source, err := clickSource(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, source)
+	}
+	for _, raw := range []string{"-lobby", "a b", "lobby?x=1", "ünï", strings.Repeat("a", 65)} {
+		_, err := clickSource(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestSourceURLFor(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Scheme = "https"
+	config.GlobalAppConfig.Domain = "riid.me"
+
+	assert.Equal(t, "https://riid.me/abc", sourceURLFor("abc", ""))
+	assert.Equal(t, "https://riid.me/abc?src=lobby", sourceURLFor("abc", "lobby"))
+}
+
+func TestQRVariantSources(t *testing.T) {
+	sources, err := qrVariantSources([]string{"Lobby", "flyer-b", "lobby", ""})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"lobby", "flyer-b"}, sources)
+
+	_, err = qrVariantSources(nil)
+	assert.Error(t, err)
+	_, err = qrVariantSources([]string{"bad source"})
+	assert.Error(t, err)
+	_, err = qrVariantSources(make([]string, maxQRVariants+1))
+	assert.Error(t, err, "empty sources are dropped, leaving none")
+}
+
+func TestCreateQRVariantsRequiresAuth(t *testing.T) {
+	rr := httptest.NewRecorder()
+	CreateQRVariantsHandler(rr, httptest.NewRequest(http.MethodPost, "/api/qr/abc/variants", strings.NewReader(`{"sources":["lobby"]}`)))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
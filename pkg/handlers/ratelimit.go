@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// rateLimitWindow is the fixed window request counts are kept for.
+const rateLimitWindow = time.Minute
+
+// allowRateLimited counts a request of the client against its per-minute limit for
+// scope. Once the limit is exceeded it writes a 429 response with Retry-After and
+// returns false. A limit of 0 disables the check, and counting failures let the
+// request through so a Redis problem never takes the endpoint down.
+func allowRateLimited(w http.ResponseWriter, r *http.Request, scope string, limit int) bool {
+	if limit <= 0 {
+		return true
+	}
+	now := time.Now()
+	window := now.Truncate(rateLimitWindow)
+	key := "ratelimit:" + scope + ":" + trustedClientKey(r) + ":" + strconv.FormatInt(window.Unix(), 10)
+
+	count, err := storage.IncrementCounter(r.Context(), key, rateLimitWindow)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Warn().Err(err).Str("scope", scope).Msg("Failed to count request for rate limit")
+		return true
+	}
+	if count <= int64(limit) {
+		return true
+	}
+
+	retryAfter := int(window.Add(rateLimitWindow).Sub(now).Seconds()) + 1
+	customlogger.FromContext(r.Context()).Warn().Str("scope", scope).Str("client", trustedClientKey(r)).Msg("Rate limit exceeded")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	writeJSONError(w, http.StatusTooManyRequests, "Too many requests, try again later.")
+	return false
+}
+
+// allowTokenBucket takes a token from the client's bucket for scope, which holds
+// perHour tokens and refills evenly over an hour, so clients may burst up to the limit
+// but not exceed it on average. Without a token it writes a 429 response with
+// Retry-After and returns false. Like allowRateLimited, a limit of 0 disables the check
+// and Redis failures let the request through.
+func allowTokenBucket(w http.ResponseWriter, r *http.Request, scope string, perHour int) bool {
+	if perHour <= 0 {
+		return true
+	}
+	key := "tokenbucket:" + scope + ":" + trustedClientKey(r)
+	ok, wait, err := storage.TakeToken(r.Context(), key, perHour, time.Hour, time.Now())
+	if err != nil {
+		customlogger.FromContext(r.Context()).Warn().Err(err).Str("scope", scope).Msg("Failed to take rate limit token")
+		return true
+	}
+	if ok {
+		return true
+	}
+
+	customlogger.FromContext(r.Context()).Warn().Str("scope", scope).Str("client", trustedClientKey(r)).Msg("Rate limit exceeded")
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+	writeJSONError(w, http.StatusTooManyRequests, "Too many requests, try again later.")
+	return false
+}
+
+// Scopes of the daily API key quotas.
+const (
+	QuotaShorten = "shorten"
+	QuotaStats   = "stats"
+)
+
+// quotaWindow is the period API key quotas apply to: a UTC day.
+const quotaWindow = 24 * time.Hour
+
+// apiKeyQuota returns the daily quota of key for scope, 0 for unlimited.
+func apiKeyQuota(key models.APIKey, scope string) int {
+	switch scope {
+	case QuotaShorten:
+		if key.ShortenQuota != nil {
+			return *key.ShortenQuota
+		}
+		return config.GlobalAppConfig.APIKeyShortenQuota
+	case QuotaStats:
+		if key.StatsQuota != nil {
+			return *key.StatsQuota
+		}
+		return config.GlobalAppConfig.APIKeyStatsQuota
+	}
+	return 0
+}
+
+// APIKeyQuota is middleware counting requests sent with an API key as bearer token
+// against the key's daily quota for scope. Once the quota is used up it answers 429
+// with Retry-After until the next UTC day. Requests without an API key, such as those
+// with configured auth codes, are not counted, and counting failures let the request
+// through like allowRateLimited.
+func APIKeyQuota(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := lookupAPIKey(bearerToken(r))
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit := apiKeyQuota(key, scope)
+		if limit <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now().UTC()
+		day := now.Truncate(quotaWindow)
+		counter := "quota:" + scope + ":" + key.ID + ":" + day.Format("2006-01-02")
+		count, err := storage.IncrementCounter(r.Context(), counter, quotaWindow+time.Hour)
+		if err != nil {
+			customlogger.FromContext(r.Context()).Warn().Err(err).Str("scope", scope).Msg("Failed to count request for API key quota")
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(limit))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(max(int64(limit)-count, 0), 10))
+		if count > int64(limit) {
+			retryAfter := int(day.Add(quotaWindow).Sub(now).Seconds()) + 1
+			customlogger.FromContext(r.Context()).Warn().Str("scope", scope).Str("key_id", key.ID).Msg("API key quota exceeded")
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			writeJSONError(w, http.StatusTooManyRequests, "Daily quota of this API key exceeded, try again tomorrow.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
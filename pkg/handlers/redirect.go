@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"riid.me/pkg/cdnpurge"
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+)
+
+// redirectDecision describes where a redirect for a link goes and why.
+type redirectDecision struct {
+	Destination     string              // where the visitor is sent
+	RuleIndex       int                 // -1 when no rule matched and the link's default destination is used
+	RuleName        string              // name of the matched rule, if it has one
+	InRollout       bool                // the visitor was sent to the destination of the link's rollout
+	CacheMaxAge     int                 // how long, in seconds, the redirect may be cached; 0 means no-store
+	NotifyEachClick bool                // the link wants a click webhook for every redirect
+	Draft           bool                // only visitors with a preview token may reach the link
+	Headers         map[string]string   // the link's extra response headers
+	HideReferrer    bool                // the visitor is sent on without a Referer, for the link or the whole deployment
+	NoAnalytics     bool                // the link keeps no click data, only a click count
+	MaxClicks       int                 // redirects the link allows before it is gone, 0 for no limit
+	DeviceTarget    string              // the device whose target the visitor is sent to, "" if none is
+	Variant         string              // the A/B split variant the visitor is sent to, "" if none is
+	ForwardQuery    bool                // the short URL's query parameters are added to the destination
+	Title           string              // the link's title, for crawler previews
+	PageMeta        *models.PageMeta    // the destination's page metadata, for crawler previews
+	Tags            []string            // the link's tags, for campaign click counts
+	NoIndex         bool                // search engines are asked not to index or follow the link, for the link or the whole deployment
+	Rollout         *models.LinkRollout // the link's rollout, when the visitor's bucket decided between it and the default destination
+}
+
+// rolloutBucket picks the percentile, 0-99, a visitor falls into for a rollout.
+// It is a variable so tests can make the split deterministic.
+var rolloutBucket = func() int { return rand.IntN(100) }
+
+// outsideRollouts is a rollout bucket no rollout reaches, not even one at 100%.
+const outsideRollouts = 100
+
+// decideRedirect resolves the destination for a link given the request attributes,
+// putting the visitor in a random rollout bucket (see decideRedirectInBucket).
+func decideRedirect(ctx context.Context, shortCode, defaultURL string, req rules.Request) redirectDecision {
+	return decideRedirectInBucket(ctx, shortCode, defaultURL, req, rolloutBucket())
+}
+
+// decideRedirectInBucket resolves the destination for a link given the request
+// attributes, sending the visitor to the link's rollout if bucket is below its
+// percentage. It is shared by the real redirect and the debug endpoint, which passes
+// the bucket it is asked about instead of drawing one, so both agree on every
+// destination but the A/B split variant, which is drawn by weight.
+// Metadata and rule lookup failures are logged and fall back to defaults so they
+// never block a redirect.
+func decideRedirectInBucket(ctx context.Context, shortCode, defaultURL string, req rules.Request, bucket int) redirectDecision {
+	decision := redirectDecision{
+		Destination:  defaultURL,
+		RuleIndex:    -1,
+		CacheMaxAge:  config.GlobalAppConfig.RedirectCacheMaxAge,
+		HideReferrer: config.GlobalAppConfig.HideReferrer,
+		NoIndex:      config.GlobalAppConfig.NoIndex,
+	}
+
+	link, err := storage.GetLink(ctx, shortCode)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		customlogger.Error().Err(err).Str("short_code", shortCode).Msg("Failed to load link metadata, using defaults")
+	} else if err == nil {
+		decision.NotifyEachClick = link.NotifyEachClick
+		decision.Draft = link.Draft
+		decision.Headers = link.ResponseHeaders
+		decision.HideReferrer = decision.HideReferrer || link.HideReferrer
+		decision.NoIndex = decision.NoIndex || link.NoIndex
+		decision.NoAnalytics = link.NoAnalytics
+		decision.ForwardQuery = link.ForwardQuery
+		decision.Title = link.Title
+		decision.PageMeta = link.PageMeta
+		decision.Tags = link.Tags
+		if link.CacheMaxAge != nil {
+			decision.CacheMaxAge = *link.CacheMaxAge
+		}
+		if link.MaxClicks != nil {
+			decision.MaxClicks = *link.MaxClicks
+			// Each redirect must reach us to be counted.
+			decision.CacheMaxAge = 0
+		}
+		// Never let a cached redirect outlive the link itself.
+		if link.ExpiresAt != nil {
+			remaining := int(time.Until(*link.ExpiresAt).Seconds())
+			if remaining < decision.CacheMaxAge {
+				decision.CacheMaxAge = remaining
+			}
+		}
+	}
+
+	rs, err := storage.GetLinkRules(ctx, shortCode)
+	if err != nil {
+		customlogger.Error().Err(err).Str("short_code", shortCode).Msg("Failed to load link rules, using default destination")
+	} else if rs != nil && len(rs.Rules) > 0 {
+		// The destination depends on who is asking, so browsers must not cache it.
+		decision.CacheMaxAge = 0
+		if i := rs.Evaluate(req); i >= 0 {
+			decision.Destination = rs.Rules[i].Destination
+			decision.RuleIndex = i
+			decision.RuleName = rs.Rules[i].Name
+			return decision
+		}
+	}
+
+	// Visitors no rule matched go to their device's target, if the link has one.
+	if target := link.DeviceTargets.For(req.Device); target != "" {
+		// The destination depends on the User-Agent, so browsers must not cache it.
+		decision.CacheMaxAge = 0
+		decision.Destination = target
+		decision.DeviceTarget = req.Device
+		return decision
+	}
+
+	// Others are split between the rollout and the default destination.
+	rollout, err := storage.GetLinkRollout(ctx, shortCode)
+	if err != nil {
+		customlogger.Error().Err(err).Str("short_code", shortCode).Msg("Failed to load link rollout, using default destination")
+	} else if rollout != nil {
+		// A cached redirect would pin the visitor to one side of the split.
+		decision.CacheMaxAge = 0
+		decision.Rollout = rollout
+		if bucket < rollout.Percent {
+			decision.Destination = rollout.Destination
+			decision.InRollout = true
+			return decision
+		}
+	}
+
+	// On an A/B split link, the default destination is one of its variants, by weight.
+	if variant := pickVariant(link.Variants); variant != nil {
+		decision.CacheMaxAge = 0
+		decision.Destination = variant.Destination
+		decision.Variant = variant.Name
+	}
+	return decision
+}
+
+// setRedirectCacheHeaders sets explicit Cache-Control and Expires headers on a redirect
+// response so browser caching is predictable: no-store for a zero max-age, and a public
+// max-age with a matching Expires otherwise.
+func setRedirectCacheHeaders(w http.ResponseWriter, maxAge int) {
+	if maxAge <= 0 {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Expires", "0")
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	w.Header().Set("Expires", time.Now().UTC().Add(time.Duration(maxAge)*time.Second).Format(http.TimeFormat))
+}
+
+// CachePurger invalidates redirects cached by the CDN in front of the service; nil when
+// CDN_PURGE_PROVIDER is not set. Link updates and deletions are purged from the event
+// outbox by cdnpurge.Run.
+var CachePurger cdnpurge.Purger
+
+// purgeCachedRedirect asks the CDN, in the background, to drop the cached redirect of
+// shortCode after a change that records no link event, such as new redirect rules or a
+// takedown.
+func purgeCachedRedirect(ctx context.Context, shortCode string) {
+	if CachePurger == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		ctx, cancel := context.WithTimeout(ctx, cdnpurge.Timeout)
+		defer cancel()
+		if err := CachePurger.Purge(ctx, []string{shortURLFor(shortCode)}); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to purge cached redirect")
+		}
+	}()
+}
+
+// countRedirect counts a redirect of a link with max_clicks. It is a variable so tests
+// can run without Redis.
+var countRedirect = storage.CountRedirect
+
+// serveClickLimit counts a redirect of a link allowing maxClicks of them and, once the
+// count goes past the limit, answers 410 Gone and reports true. Counting fails open
+// like click recording: a Redis hiccup should not take the link down.
+func serveClickLimit(w http.ResponseWriter, ctx context.Context, code string, maxClicks int) bool {
+	clicks, err := countRedirect(ctx, code)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count redirect of link with max_clicks")
+		return false
+	}
+	if clicks <= int64(maxClicks) {
+		return false
+	}
+	customlogger.FromContext(ctx).Info().Int("max_clicks", maxClicks).Msg("Short URL has reached its click limit")
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, "Short URL has reached its click limit", http.StatusGone)
+	return true
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+)
+
+func TestDecideRedirectNoIndex(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	ctx := context.Background()
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "secret", LongURL: "https://example.com/s", CreatedAt: time.Now().UTC(), NoIndex: true}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "plain", LongURL: "https://example.com/p", CreatedAt: time.Now().UTC()}))
+
+	config.GlobalAppConfig.NoIndex = false
+	assert.True(t, decideRedirect(ctx, "secret", "https://example.com/s", rules.Request{}).NoIndex)
+	assert.False(t, decideRedirect(ctx, "plain", "https://example.com/p", rules.Request{}).NoIndex)
+
+	// The deployment default covers every link.
+	config.GlobalAppConfig.NoIndex = true
+	assert.True(t, decideRedirect(ctx, "plain", "https://example.com/p", rules.Request{}).NoIndex)
+}
+
+func TestRedirectGoneAfterMaxClicks(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	originalCount := countRedirect
+	t.Cleanup(func() { countRedirect = originalCount })
+	ctx := context.Background()
+
+	maxClicks := 2
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "limited", LongURL: "https://example.com", CreatedAt: time.Now().UTC(), MaxClicks: &maxClicks}))
+	decision := decideRedirect(ctx, "limited", "https://example.com", rules.Request{})
+	require.Equal(t, 2, decision.MaxClicks)
+
+	var clicks int64
+	countRedirect = func(context.Context, string) (int64, error) {
+		clicks++
+		return clicks, nil
+	}
+	for i := 1; i <= maxClicks; i++ {
+		rr := httptest.NewRecorder()
+		assert.False(t, serveClickLimit(rr, ctx, "limited", decision.MaxClicks), "redirect %d is within the limit", i)
+	}
+	rr := httptest.NewRecorder()
+	assert.True(t, serveClickLimit(rr, ctx, "limited", decision.MaxClicks))
+	assert.Equal(t, http.StatusGone, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+
+	// Counting failures let the redirect through.
+	countRedirect = func(context.Context, string) (int64, error) { return 0, errors.New("redis down") }
+	assert.False(t, serveClickLimit(httptest.NewRecorder(), ctx, "limited", decision.MaxClicks))
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// defaultReportRange is the period a report covers when the request gives no from.
+	defaultReportRange = 30 * 24 * time.Hour
+	// maxReportRange is the longest period a report may cover, one row per day.
+	maxReportRange = 366 * 24 * time.Hour
+	// reportTopLimit is how many links, referrers and countries a report lists.
+	reportTopLimit = 20
+	// reportDateLayout is how report dates are given and shown.
+	reportDateLayout = "2006-01-02"
+	// reportChartHeight is the height, in SVG units, of the tallest timeline bar.
+	reportChartHeight = 100
+)
+
+// reportTemplate renders a ClickReport as a self-contained page: no scripts, fonts or
+// images to fetch, so it can be mailed around and opened offline.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Click report {{.From}} to {{.To}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; color: #333; }
+h1 { font-size: 1.5rem; margin-bottom: 0.25rem; }
+h2 { font-size: 1.15rem; margin-top: 2rem; }
+.meta { color: #777; margin-top: 0; }
+.totals { display: flex; gap: 2rem; }
+.totals div { background: #f4f6f8; border-radius: 6px; padding: 0.75rem 1.25rem; }
+.totals strong { display: block; font-size: 1.5rem; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.35rem 0.5rem; border-bottom: 1px solid #e5e5e5; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+svg { width: 100%; height: 10rem; background: #f4f6f8; border-radius: 6px; }
+svg rect { fill: #3498db; }
+.axis { display: flex; justify-content: space-between; color: #777; font-size: 0.85rem; }
+.empty { color: #777; }
+</style>
+</head>
+<body>
+<h1>Click report</h1>
+<p class="meta">{{.From}} to {{.To}} (UTC), generated {{.Generated}}</p>
+<div class="totals">
+<div><strong>{{.TotalClicks}}</strong>clicks</div>
+<div><strong>{{.UniqueClicks}}</strong>unique visitors</div>
+</div>
+
+<h2>Clicks per day</h2>
+<svg viewBox="0 0 {{.ChartWidth}} {{.ChartHeight}}" preserveAspectRatio="none" role="img" aria-label="Clicks per day">
+{{range .Bars}}<rect x="{{.X}}" y="{{.Y}}" width="8" height="{{.Height}}"><title>{{.Day}}: {{.Clicks}}</title></rect>
+{{end}}</svg>
+<div class="axis"><span>{{.From}}</span><span>{{.To}}</span></div>
+
+<h2>Top links</h2>
+{{if .Links}}<table>
+<tr><th>Link</th><th class="num">Clicks</th><th class="num">Unique</th></tr>
+{{range .Links}}<tr><td>{{.ShortURL}}</td><td class="num">{{.TotalClicks}}</td><td class="num">{{.UniqueClicks}}</td></tr>
+{{end}}</table>
+{{else}}<p class="empty">No clicks in this period.</p>
+{{end}}
+<h2>Referrers</h2>
+{{if .Referrers}}<table>
+<tr><th>Referrer</th><th class="num">Clicks</th><th class="num">Share</th></tr>
+{{range .Referrers}}<tr><td>{{or .Label "Direct"}}</td><td class="num">{{.Clicks}}</td><td class="num">{{.Share}}</td></tr>
+{{end}}</table>
+{{else}}<p class="empty">No clicks in this period.</p>
+{{end}}
+<h2>Countries</h2>
+{{if .Countries}}<table>
+<tr><th>Country</th><th class="num">Clicks</th><th class="num">Share</th></tr>
+{{range .Countries}}<tr><td>{{or .Label "Unknown"}}</td><td class="num">{{.Clicks}}</td><td class="num">{{.Share}}</td></tr>
+{{end}}</table>
+{{else}}<p class="empty">No clicks in this period.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// reportLink is a row of a report's top links.
+type reportLink struct {
+	ShortURL     string
+	TotalClicks  int
+	UniqueClicks int
+}
+
+// reportRow is a row of a report's referrer or country table.
+type reportRow struct {
+	Label  string
+	Clicks int
+	Share  string
+}
+
+// reportBar is a day of a report's timeline chart, in SVG units.
+type reportBar struct {
+	X, Y, Height int
+	Day          string
+	Clicks       int
+}
+
+// reportView is what reportTemplate renders.
+type reportView struct {
+	From, To, Generated       string
+	TotalClicks, UniqueClicks int
+	ChartWidth, ChartHeight   int
+	Bars                      []reportBar
+	Links                     []reportLink
+	Referrers, Countries      []reportRow
+}
+
+// newReportView lays out a report for reportTemplate.
+func newReportView(report models.ClickReport, generated time.Time) reportView {
+	view := reportView{
+		From:         report.From.Format(reportDateLayout),
+		To:           report.To.Add(-time.Second).Format(reportDateLayout),
+		Generated:    generated.UTC().Format("2006-01-02 15:04 UTC"),
+		TotalClicks:  report.TotalClicks,
+		UniqueClicks: report.UniqueClicks,
+		ChartWidth:   max(len(report.Timeline), 1) * 10,
+		ChartHeight:  reportChartHeight,
+	}
+
+	peak := 0
+	for _, point := range report.Timeline {
+		peak = max(peak, point.Clicks)
+	}
+	for i, point := range report.Timeline {
+		height := 0
+		if peak > 0 {
+			height = point.Clicks * reportChartHeight / peak
+		}
+		view.Bars = append(view.Bars, reportBar{
+			X: i * 10, Y: reportChartHeight - height, Height: height,
+			Day: point.Time.Format(reportDateLayout), Clicks: point.Clicks,
+		})
+	}
+	for _, link := range report.TopLinks {
+		view.Links = append(view.Links, reportLink{shortURLFor(link.ShortCode), link.TotalClicks, link.UniqueClicks})
+	}
+	view.Referrers = reportRows(report.Referrers, report.TotalClicks)
+	view.Countries = reportRows(report.Countries, report.TotalClicks)
+	return view
+}
+
+// reportRows turns counts into table rows with their share of total.
+func reportRows(counts []models.ReportCount, total int) []reportRow {
+	var rows []reportRow
+	for _, count := range counts {
+		share := "-"
+		if total > 0 {
+			share = strconv.FormatFloat(float64(count.Clicks)*100/float64(total), 'f', 1, 64) + "%"
+		}
+		rows = append(rows, reportRow{count.Value, count.Clicks, share})
+	}
+	return rows
+}
+
+// parseReportDate reads a report boundary given as a date, 2006-01-02, meaning the
+// start of that day in UTC, or like parseGrafanaTime. An empty value yields fallback.
+func parseReportDate(raw string, fallback time.Time) (time.Time, error) {
+	if day, err := time.Parse(reportDateLayout, raw); err == nil {
+		return day, nil
+	}
+	return parseGrafanaTime(raw, fallback)
+}
+
+// ClickReportHandler renders a self-contained HTML report of the clicks on all links,
+// with totals, the top links, referrers and countries, and clicks per day, as a file
+// download for stakeholders who want a document rather than a dashboard. It covers
+// ?from= up to ?to=, dates (the to day included) or times like the Grafana endpoints,
+// defaulting to the last 30 days, and whole days in UTC.
+func ClickReportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	to, err := parseReportDate(r.URL.Query().Get("to"), today)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "to: "+err.Error())
+		return
+	}
+	// Reports cover whole days, the to day included.
+	to = to.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	from, err := parseReportDate(r.URL.Query().Get("from"), to.Add(-defaultReportRange))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "from: "+err.Error())
+		return
+	}
+	from = from.Truncate(24 * time.Hour)
+	if !from.Before(to) {
+		writeJSONError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+	if to.Sub(from) > maxReportRange {
+		writeJSONError(w, http.StatusBadRequest, "A report may cover at most 366 days.")
+		return
+	}
+
+	report, err := storage.BuildClickReport(ctx, from, to, reportTopLimit)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to build click report")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build report")
+		return
+	}
+	view := newReportView(report, time.Now())
+	var page bytes.Buffer
+	if err := reportTemplate.Execute(&page, view); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to render click report")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="riidme-report-%s-to-%s.html"`, view.From, view.To))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(page.Bytes())
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestClickReportHandler(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.Scheme = "https"
+	config.GlobalAppConfig.Domain = "riid.me"
+
+	at := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, storage.RecordClick(context.Background(), models.ClickRecord{ShortCode: "abc", UserAgent: "ua", Referrer: `https://evil.example/"><script>`, Destination: "https://example.com", Visitor: "v1", Country: "DE", At: at}))
+
+	rr := httptest.NewRecorder()
+	ClickReportHandler(rr, httptest.NewRequest(http.MethodGet, "/api/admin/report?from=2026-03-01&to=2026-03-02", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="riidme-report-2026-03-01-to-2026-03-02.html"`, rr.Header().Get("Content-Disposition"))
+	body := rr.Body.String()
+	assert.Contains(t, body, "https://riid.me/abc")
+	assert.Contains(t, body, "evil.example")
+	assert.NotContains(t, body, "<script>")
+	assert.Contains(t, body, "<title>2026-03-02: 1</title>")
+	assert.Contains(t, body, "100.0%")
+
+	for _, query := range []string{"from=2026-03-03&to=2026-03-01", "from=2024-01-01&to=2026-03-01", "to=soon"} {
+		rr = httptest.NewRecorder()
+		ClickReportHandler(rr, httptest.NewRequest(http.MethodGet, "/api/admin/report?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	customlogger "riid.me/pkg/logger"
+)
+
+// requestIDHeader carries the request ID in both directions, so IDs assigned by a
+// proxy in front of the service are kept and clients can quote them in bug reports.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs accepted from clients.
+const maxRequestIDLength = 64
+
+// newRequestID returns a random 16-character hex request ID.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validRequestID reports whether a client-supplied request ID is safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestContext is middleware that attaches a request-scoped logger to the request
+// context. The logger carries the request ID and, on routes with one, the shortcode,
+// so handlers log through customlogger.FromContext instead of adding them by hand.
+func RequestContext(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+
+		ctx := customlogger.WithContext(r.Context(), customlogger.RequestIDField, requestID)
+		if shortCode := mux.Vars(r)["shortcode"]; shortCode != "" {
+			ctx = customlogger.WithContext(ctx, customlogger.ShortCodeField, shortCode)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
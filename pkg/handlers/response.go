@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// decodeJSONOrYAML reads at most maxBytes of the request body into v, as YAML when
+// the Content-Type says so and as JSON otherwise.
+func decodeJSONOrYAML(r *http.Request, maxBytes int64, v interface{}) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBytes))
+	if err != nil {
+		return err
+	}
+	if strings.Contains(r.Header.Get("Content-Type"), "yaml") {
+		return yaml.Unmarshal(body, v)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// writeJSON writes v as a JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes a JSON error response in the {"error": "..."} shape used across the API.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+// fieldErrors collects the problems found with the fields of a request.
+type fieldErrors []models.FieldError
+
+// add records message as a problem with field.
+func (e *fieldErrors) add(field, message string) {
+	*e = append(*e, models.FieldError{Field: field, Message: message})
+}
+
+// fields returns the names of the fields with problems, for logging.
+func (e fieldErrors) fields() []string {
+	names := make([]string, 0, len(e))
+	for _, fe := range e {
+		names = append(names, fe.Field)
+	}
+	return names
+}
+
+// writeFieldErrors answers a request with invalid fields with a 400 listing all of
+// them. The first problem is also the error, so clients reading only that keep working.
+func writeFieldErrors(w http.ResponseWriter, errs fieldErrors) {
+	writeJSON(w, http.StatusBadRequest, models.ValidationErrorResponse{Error: errs[0].Message, Errors: errs})
+}
+
+// storageErrorStatus maps the storage sentinel errors to HTTP status codes. Errors
+// that are not storage sentinels are treated as internal failures.
+func storageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrExpired):
+		return http.StatusGone
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeStorageError writes the JSON error response for an error returned by the storage
+// layer. Sentinel errors are reported with their own message; anything else is logged
+// by the caller and answered with fallback as a 500.
+func writeStorageError(w http.ResponseWriter, err error, fallback string) {
+	status := storageErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		writeJSONError(w, status, fallback)
+		return
+	}
+	writeJSONError(w, status, errorMessage(err))
+}
+
+// errorMessage capitalizes an error's message for use in API responses.
+func errorMessage(err error) string {
+	msg := err.Error()
+	if msg == "" {
+		return msg
+	}
+	return strings.ToUpper(msg[:1]) + msg[1:]
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/storage"
+)
+
+func TestStorageErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{storage.ErrLinkNotFound, http.StatusNotFound},
+		{storage.ErrCommentNotFound, http.StatusNotFound},
+		{storage.ErrLinkExpired, http.StatusGone},
+		{storage.ErrLinkExists, http.StatusConflict},
+		{fmt.Errorf("saving link: %w", storage.ErrConflict), http.StatusConflict},
+		{errors.New("connection refused"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, storageErrorStatus(tt.err), tt.err.Error())
+	}
+}
+
+func TestWriteStorageError(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeStorageError(rr, storage.ErrLinkNotFound, "Error retrieving URL")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	var body map[string]string
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	assert.Equal(t, "Short URL not found", body["error"])
+
+	rr = httptest.NewRecorder()
+	writeStorageError(rr, errors.New("dial tcp: connection refused"), "Error retrieving URL")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	assert.Equal(t, "Error retrieving URL", body["error"])
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+// maxRolloutBodyBytes caps the size of a rollout update.
+const maxRolloutBodyBytes = 8 << 10
+
+// writeLinkRollout responds with the rollout of a link and its clicks per destination.
+func writeLinkRollout(w http.ResponseWriter, r *http.Request, shortCode string) {
+	ctx := r.Context()
+	rollout, err := storage.GetLinkRollout(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load link rollout")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve rollout")
+		return
+	}
+	clicks, err := storage.ClickCountsByDestination(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count clicks per destination")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve rollout")
+		return
+	}
+	writeJSON(w, http.StatusOK, models.LinkRolloutResponse{
+		ShortCode: shortCode,
+		Rollout:   rollout,
+		Clicks:    clicks,
+	})
+}
+
+// GetLinkRolloutHandler returns the rollout of a link, if any, and its clicks per destination.
+func GetLinkRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+	writeLinkRollout(w, r, shortCode)
+}
+
+// PutLinkRolloutHandler starts or adjusts the rollout of a link.
+func PutLinkRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) || !requireUnfrozen(w, r, shortCode) {
+		return
+	}
+	ctx := r.Context()
+
+	var rollout models.LinkRollout
+	if err := decodeJSONOrYAML(r, maxRolloutBodyBytes, &rollout); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for PutLinkRollout")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if rollout.Percent < 0 || rollout.Percent > 100 {
+		writeJSONError(w, http.StatusBadRequest, "percent must be between 0 and 100")
+		return
+	}
+	if strings.TrimSpace(rollout.Destination) == "" {
+		writeJSONError(w, http.StatusBadRequest, "destination is required")
+		return
+	}
+	rollout.Destination = NormalizeURL(rollout.Destination)
+	if err := validation.ValidateDestination(rollout.Destination); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := storage.SaveLinkRollout(ctx, shortCode, rollout); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store link rollout")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store rollout")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Int("percent", rollout.Percent).Msg("Link rollout updated")
+	purgeCachedRedirect(ctx, shortCode)
+	writeLinkRollout(w, r, shortCode)
+}
+
+// DeleteLinkRolloutHandler ends the rollout of a link, sending all traffic to its regular destination.
+func DeleteLinkRolloutHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) || !requireUnfrozen(w, r, shortCode) {
+		return
+	}
+
+	if err := storage.DeleteLinkRollout(r.Context(), shortCode); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to delete link rollout")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete rollout")
+		return
+	}
+
+	customlogger.FromContext(r.Context()).Info().Msg("Link rollout deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+)
+
+func TestDecideRedirectSplitsRolloutTraffic(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+
+	originalBucket := rolloutBucket
+	defer func() { rolloutBucket = originalBucket }()
+
+	require.NoError(t, storage.SaveLinkRollout(ctx, "abc", models.LinkRollout{Destination: "https://new.example.com", Percent: 30}))
+
+	rolloutBucket = func() int { return 29 }
+	decision := decideRedirect(ctx, "abc", "https://old.example.com", rules.Request{})
+	assert.Equal(t, "https://new.example.com", decision.Destination)
+	assert.True(t, decision.InRollout)
+	assert.Equal(t, 0, decision.CacheMaxAge)
+
+	rolloutBucket = func() int { return 30 }
+	decision = decideRedirect(ctx, "abc", "https://old.example.com", rules.Request{})
+	assert.Equal(t, "https://old.example.com", decision.Destination)
+	assert.False(t, decision.InRollout)
+
+	// Redirect debugging picks the bucket instead of drawing one, and reports the split.
+	rolloutBucket = func() int { panic("no bucket is drawn") }
+	for i := 0; i < 3; i++ {
+		decision = decideRedirectInBucket(ctx, "abc", "https://old.example.com", rules.Request{}, outsideRollouts)
+		assert.Equal(t, "https://old.example.com", decision.Destination)
+		assert.False(t, decision.InRollout)
+		require.NotNil(t, decision.Rollout)
+		assert.Equal(t, 30, decision.Rollout.Percent)
+		assert.Equal(t, "https://new.example.com", decision.Rollout.Destination)
+	}
+	decision = decideRedirectInBucket(ctx, "abc", "https://old.example.com", rules.Request{}, 29)
+	assert.True(t, decision.InRollout)
+
+	// A matching rule takes precedence over the rollout.
+	rolloutBucket = func() int { return 0 }
+	require.NoError(t, storage.SaveLinkRules(ctx, "abc", rules.RuleSet{Rules: []rules.Rule{
+		{Name: "all", Destination: "https://rule.example.com"},
+	}}))
+	decision = decideRedirect(ctx, "abc", "https://old.example.com", rules.Request{})
+	assert.Equal(t, "https://rule.example.com", decision.Destination)
+	assert.False(t, decision.InRollout)
+	assert.Nil(t, decision.Rollout, "the rollout played no part")
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+// maxRulesBodyBytes caps the size of an uploaded rule set.
+const maxRulesBodyBytes = 64 << 10
+
+// decodeRuleSet reads a rule set from the request body as YAML when the Content-Type
+// says so, and as JSON otherwise.
+func decodeRuleSet(r *http.Request) (rules.RuleSet, error) {
+	var rs rules.RuleSet
+	err := decodeJSONOrYAML(r, maxRulesBodyBytes, &rs)
+	return rs, err
+}
+
+// validateRuleSet checks a rule set's structure and normalizes and vets every
+// destination against the deployment destination policy.
+func validateRuleSet(rs *rules.RuleSet) error {
+	for i := range rs.Rules {
+		rs.Rules[i].Destination = NormalizeURL(rs.Rules[i].Destination)
+	}
+	if err := rs.Validate(); err != nil {
+		return err
+	}
+	for _, rule := range rs.Rules {
+		if err := validation.ValidateDestination(rule.Destination); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetLinkRulesHandler returns the redirect rules attached to a link.
+func GetLinkRulesHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+
+	rs, err := storage.GetLinkRules(r.Context(), shortCode)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to load link rules")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve rules")
+		return
+	}
+	if rs == nil {
+		rs = &rules.RuleSet{Rules: []rules.Rule{}}
+	}
+	writeJSON(w, http.StatusOK, rs)
+}
+
+// PutLinkRulesHandler validates and replaces the redirect rules of a link.
+// The rule set may be sent as JSON or, with a YAML Content-Type, as YAML.
+func PutLinkRulesHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) || !requireUnfrozen(w, r, shortCode) {
+		return
+	}
+
+	rs, err := decodeRuleSet(r)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Invalid request body for PutLinkRules")
+		writeJSONError(w, http.StatusBadRequest, "Invalid rules document")
+		return
+	}
+	if err := validateRuleSet(&rs); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := storage.SaveLinkRules(r.Context(), shortCode, rs); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to store link rules")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store rules")
+		return
+	}
+
+	customlogger.FromContext(r.Context()).Info().Int("rules", len(rs.Rules)).Msg("Link rules updated")
+	purgeCachedRedirect(r.Context(), shortCode)
+	writeJSON(w, http.StatusOK, rs)
+}
+
+// DeleteLinkRulesHandler removes all redirect rules from a link.
+func DeleteLinkRulesHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) || !requireUnfrozen(w, r, shortCode) {
+		return
+	}
+
+	if err := storage.DeleteLinkRules(r.Context(), shortCode); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to delete link rules")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to delete rules")
+		return
+	}
+
+	customlogger.FromContext(r.Context()).Info().Msg("Link rules deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TestLinkRulesHandler evaluates rules against a described request without redirecting.
+// It tests the rules in the payload when present, and the link's stored rules otherwise.
+func TestLinkRulesHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+	ctx := r.Context()
+
+	var req models.RuleTestRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRulesBodyBytes)).Decode(&req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for TestLinkRules")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	rs := req.Rules
+	if rs != nil {
+		if err := validateRuleSet(rs); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else {
+		stored, err := storage.GetLinkRules(ctx, shortCode)
+		if err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load link rules")
+			writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve rules")
+			return
+		}
+		rs = stored
+		if rs == nil {
+			rs = &rules.RuleSet{}
+		}
+	}
+
+	defaultURL, err := storage.GetDestination(ctx, shortCode)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis for rule test")
+		}
+		writeStorageError(w, err, "Error retrieving URL")
+		return
+	}
+
+	evalReq := req.Request
+	if evalReq.Device == "" {
+		evalReq.Device = rules.DetectDevice(req.UserAgent)
+	}
+	if evalReq.Time.IsZero() {
+		evalReq.Time = time.Now().UTC()
+	}
+
+	resp := models.RuleTestResponse{RuleIndex: -1, Destination: defaultURL}
+	if i := rs.Evaluate(evalReq); i >= 0 {
+		resp = models.RuleTestResponse{
+			Matched:     true,
+			RuleIndex:   i,
+			RuleName:    rs.Rules[i].Name,
+			Destination: rs.Rules[i].Destination,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/storage"
+)
+
+// maxTarpitted is how many scanner probes may be held at once. Further probes are
+// answered immediately so tarpitting can never tie up the server itself.
+const maxTarpitted = 64
+
+// scannerSuffixes are file extensions scanners probe for that this service never serves.
+var scannerSuffixes = []string{".php", ".php7", ".asp", ".aspx", ".jsp", ".cgi", ".env", ".sql", ".bak", ".old", ".ini", ".swp"}
+
+// scannerFragments are path fragments of well-known vulnerable applications.
+var scannerFragments = []string{
+	"wp-login", "wp-admin", "wp-content", "wp-includes", "xmlrpc", "phpmyadmin",
+	"/cgi-bin/", "/vendor/phpunit", "/boaform", "/hnap1",
+}
+
+// tarpitSlots limits how many scanner probes are held at once.
+var tarpitSlots = make(chan struct{}, maxTarpitted)
+
+// scannerBans holds the clients banned for probing, until when. Bans are kept in
+// memory, so each instance only refuses the clients it has banned itself.
+var scannerBans = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: map[string]time.Time{}}
+
+// isScannerPath reports whether path is an obvious vulnerability scanner probe: a
+// dot-segment such as /.env or /.git (except /.well-known), a script extension, a
+// well-known fragment or one of the configured extra fragments.
+func isScannerPath(path string, extra []string) bool {
+	path = strings.ToLower(path)
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ".") && segment != ".well-known" {
+			return true
+		}
+	}
+	for _, suffix := range scannerSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	for _, fragment := range scannerFragments {
+		if strings.Contains(path, fragment) {
+			return true
+		}
+	}
+	for _, fragment := range extra {
+		if strings.Contains(path, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// scannerBannedUntil returns when the ban of client ends, or the zero time if it is
+// not banned.
+func scannerBannedUntil(client string, now time.Time) time.Time {
+	scannerBans.Lock()
+	defer scannerBans.Unlock()
+	until, ok := scannerBans.until[client]
+	if ok && !now.Before(until) {
+		delete(scannerBans.until, client)
+		return time.Time{}
+	}
+	return until
+}
+
+// banScanner refuses client until the given time, dropping bans that have ended.
+func banScanner(client string, until time.Time) {
+	scannerBans.Lock()
+	defer scannerBans.Unlock()
+	now := time.Now()
+	for banned, end := range scannerBans.until {
+		if !now.Before(end) {
+			delete(scannerBans.until, banned)
+		}
+	}
+	scannerBans.until[client] = until
+}
+
+// countScannerProbe counts a probe of client and bans it once it has made
+// ScannerBanThreshold probes within ScannerBanMinutes. Counting failures are logged
+// and ignored.
+func countScannerProbe(ctx context.Context, client string) {
+	threshold := config.GlobalAppConfig.ScannerBanThreshold
+	if threshold <= 0 {
+		return
+	}
+	window := time.Duration(config.GlobalAppConfig.ScannerBanMinutes) * time.Minute
+	count, err := storage.IncrementCounter(ctx, "scanner:"+client, window)
+	if err != nil {
+		customlogger.Warn().Err(err).Msg("Failed to count scanner probe")
+		return
+	}
+	if count >= int64(threshold) {
+		banScanner(client, time.Now().Add(window))
+		customlogger.Warn().Str("client", client).Int64("probes", count).Msg("Banned vulnerability scanner")
+	}
+}
+
+// tarpit holds a scanner probe for ScannerTarpitSeconds, unless the client gives up
+// first or too many probes are already held.
+func tarpit(ctx context.Context) {
+	delay := time.Duration(config.GlobalAppConfig.ScannerTarpitSeconds) * time.Second
+	if delay <= 0 {
+		return
+	}
+	select {
+	case tarpitSlots <- struct{}{}:
+		defer func() { <-tarpitSlots }()
+	default:
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// ScannerGuard is middleware that answers vulnerability scanner probes with a plain
+// 404 before routing, so they never cost a Redis lookup or a line in the request log,
+// and refuses clients banned for probing. It wraps the whole router so probes of
+// paths no route matches are caught too. Clients are told apart by trustedClientKey, so
+// neither can a scanner escape its ban nor anyone get another client banned by
+// rotating X-Forwarded-For.
+func ScannerGuard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.GlobalAppConfig.ScannerGuard {
+			next.ServeHTTP(w, r)
+			return
+		}
+		client := trustedClientKey(r)
+		if until := scannerBannedUntil(client, time.Now()); !until.IsZero() {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		if !isScannerPath(r.URL.Path, config.GlobalAppConfig.ScannerPaths) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		customlogger.Debug().Str("client", client).Str("path", r.URL.Path).Msg("Scanner probe")
+		countScannerProbe(r.Context(), client)
+		tarpit(r.Context())
+		http.NotFound(w, r)
+	})
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/config"
+)
+
+func TestIsScannerPath(t *testing.T) {
+	for _, path := range []string{"/wp-login.php", "/.env", "/app/.git/config", "/WP-ADMIN/", "/index.php", "/cgi-bin/luci", "/backup.sql", "/vendor/phpunit/phpunit/src/Util/PHP/eval-stdin.php"} {
+		assert.True(t, isScannerPath(path, nil), path)
+	}
+	for _, path := range []string{"/", "/abc123", "/api/shorten", "/badge/abc.svg", "/feed.xml", "/.well-known/security.txt", "/d/abc/report.pdf", "/static/index.html"} {
+		assert.False(t, isScannerPath(path, nil), path)
+	}
+	assert.True(t, isScannerPath("/Solr/admin", []string{"solr/"}), "extra fragments are matched too")
+}
+
+func TestScannerGuard(t *testing.T) {
+	config.GlobalAppConfig.ScannerGuard = true
+	t.Cleanup(func() { config.GlobalAppConfig.ScannerGuard = false })
+	reached := false
+	guard := ScannerGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+
+	rr := httptest.NewRecorder()
+	guard.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abc123", nil))
+	assert.True(t, reached)
+
+	reached = false
+	rr = httptest.NewRecorder()
+	guard.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/wp-login.php", nil))
+	assert.False(t, reached, "probes never reach the router")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+	req.RemoteAddr = "203.0.113.9:51234"
+	banScanner("203.0.113.9", time.Now().Add(time.Minute))
+	rr = httptest.NewRecorder()
+	guard.ServeHTTP(rr, req)
+	assert.False(t, reached, "banned clients are refused everywhere")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+
+	assert.True(t, scannerBannedUntil("203.0.113.9", time.Now().Add(2*time.Minute)).IsZero(), "bans end")
+}
+
+func TestScannerGuardBansIgnoreSpoofedForwardedFor(t *testing.T) {
+	config.GlobalAppConfig.ScannerGuard = true
+	t.Cleanup(func() { config.GlobalAppConfig.ScannerGuard = false })
+	guard := ScannerGuard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(remote, forwarded string) int {
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", forwarded)
+		rr := httptest.NewRecorder()
+		guard.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	banScanner("198.51.100.9", time.Now().Add(time.Minute))
+	assert.Equal(t, http.StatusTooManyRequests, request("198.51.100.9:51234", "1.1.1.1"), "a banned scanner cannot escape by rotating X-Forwarded-For")
+	assert.Equal(t, http.StatusOK, request("198.51.100.10:51234", "198.51.100.9"), "nobody is refused for naming a banned client in X-Forwarded-For")
+}
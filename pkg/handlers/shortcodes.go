@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/teris-io/shortid"
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+const (
+	// defaultShortCodeAlphabet is what random short codes are drawn from, and
+	// sequential ones written in, when SHORTCODE_ALPHABET is not set.
+	defaultShortCodeAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	// defaultShortCodeLength is the length of random short codes when SHORTCODE_LENGTH
+	// is not set.
+	defaultShortCodeLength = 8
+	// minShortCodeLength and maxShortCodeLength bound SHORTCODE_LENGTH.
+	minShortCodeLength = 4
+	maxShortCodeLength = 32
+	// smallShortCodeSpace is the number of possible codes below which startup warns
+	// that links will soon run out of free ones.
+	smallShortCodeSpace = 1e9
+)
+
+// ShortCodeGenerator generates short codes, following one of the SHORTCODE_STRATEGY
+// strategies: *shortid.Shortid, randomCodes or sequentialCodes.
+type ShortCodeGenerator interface {
+	Generate() (string, error)
+}
+
+// validateShortCodeAlphabet checks that alphabet has at least two characters, each
+// once, all letters, digits, '-' or '_'.
+func validateShortCodeAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("SHORTCODE_ALPHABET must have at least 2 characters")
+	}
+	for i, c := range alphabet {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("SHORTCODE_ALPHABET may only contain letters, digits, '-' and '_', not %q", c)
+		}
+		if strings.IndexRune(alphabet[:i], c) >= 0 {
+			return fmt.Errorf("SHORTCODE_ALPHABET contains %q twice", c)
+		}
+	}
+	return nil
+}
+
+// randomCodes generates short codes of a fixed length with characters drawn uniformly
+// at random from an alphabet. Unlike shortid's time-based codes they can repeat, so
+// generateShortCode checks that each is free.
+type randomCodes struct {
+	alphabet string
+	length   int
+}
+
+// newRandomCodes returns a generator of codes of length characters of alphabet,
+// defaulting to defaultShortCodeLength and defaultShortCodeAlphabet. The alphabet
+// may only use letters, digits, '-' and '_', each once.
+func newRandomCodes(alphabet string, length int) (randomCodes, error) {
+	if alphabet == "" {
+		alphabet = defaultShortCodeAlphabet
+	}
+	if length == 0 {
+		length = defaultShortCodeLength
+	}
+	if length < minShortCodeLength || length > maxShortCodeLength {
+		return randomCodes{}, fmt.Errorf("SHORTCODE_LENGTH must be between %d and %d", minShortCodeLength, maxShortCodeLength)
+	}
+	if err := validateShortCodeAlphabet(alphabet); err != nil {
+		return randomCodes{}, err
+	}
+	if space := math.Pow(float64(len(alphabet)), float64(length)); space < smallShortCodeSpace {
+		customlogger.Warn().Float64("codes", space).Msg("Few short codes possible with SHORTCODE_LENGTH and SHORTCODE_ALPHABET; generating them slows down and fails as they are used up")
+	}
+	return randomCodes{alphabet: alphabet, length: length}, nil
+}
+
+// Generate implements ShortCodeGenerator.
+func (g randomCodes) Generate() (string, error) {
+	n := big.NewInt(int64(len(g.alphabet)))
+	code := make([]byte, g.length)
+	for i := range code {
+		k, err := rand.Int(rand.Reader, n)
+		if err != nil {
+			return "", err
+		}
+		code[i] = g.alphabet[k.Int64()]
+	}
+	return string(code), nil
+}
+
+// sequentialCodes numbers short codes from a counter shared through Redis, writing
+// the numbers in an alphabet as digits, e.g. 1, 2, ..., z, 10, 11 in base 62. Codes
+// are as short as the number of links allows, but easy to enumerate, so they suit
+// private deployments.
+type sequentialCodes struct {
+	alphabet string
+	length   int
+}
+
+// newSequentialCodes returns a generator of sequential codes written in alphabet,
+// defaulting to defaultShortCodeAlphabet, and padded to at least length characters
+// with its first character.
+func newSequentialCodes(alphabet string, length int) (sequentialCodes, error) {
+	if alphabet == "" {
+		alphabet = defaultShortCodeAlphabet
+	}
+	if length < 0 || length > maxShortCodeLength {
+		return sequentialCodes{}, fmt.Errorf("SHORTCODE_LENGTH must be between 0 and %d for sequential codes", maxShortCodeLength)
+	}
+	if err := validateShortCodeAlphabet(alphabet); err != nil {
+		return sequentialCodes{}, err
+	}
+	return sequentialCodes{alphabet: alphabet, length: length}, nil
+}
+
+// Generate implements ShortCodeGenerator. The interface has no context, so the
+// counter is read without one.
+func (g sequentialCodes) Generate() (string, error) {
+	n, err := storage.NextShortCodeSequence(context.Background())
+	if err != nil {
+		return "", err
+	}
+	return g.encode(n), nil
+}
+
+// encode writes n, a positive number, in the generator's alphabet. Padding keeps codes
+// unique, as only numbers with fewer digits than length are padded.
+func (g sequentialCodes) encode(n int64) string {
+	base := int64(len(g.alphabet))
+	var digits []byte
+	for ; n > 0; n /= base {
+		digits = append(digits, g.alphabet[n%base])
+	}
+	for len(digits) < g.length {
+		digits = append(digits, g.alphabet[0])
+	}
+	for i, j := 0, len(digits)-1; i < j; i, j = i+1, j-1 {
+		digits[i], digits[j] = digits[j], digits[i]
+	}
+	return string(digits)
+}
+
+// shortCodeAttempts returns how often a short code is generated for a new link before
+// giving up, SHORTCODE_ATTEMPTS or its default when it is not loaded.
+func shortCodeAttempts() int {
+	if config.GlobalAppConfig.ShortCodeAttempts < 1 {
+		return config.DefaultShortCodeAttempts
+	}
+	return config.GlobalAppConfig.ShortCodeAttempts
+}
+
+// generateShortCode returns a new short code from Sid, lowercased with
+// CASE_INSENSITIVE_CODES on. Codes that are reserved words or, with PROFANITY_FILTER
+// on, contain an offensive word are thrown away and regenerated, as are codes already
+// in use unless they come from shortid, whose codes never repeat as long as their case
+// is kept.
+func generateShortCode(ctx context.Context) (string, error) {
+	_, timeBased := Sid.(*shortid.Shortid)
+	unique := timeBased && !config.GlobalAppConfig.CaseInsensitiveCodes
+	attempts := shortCodeAttempts()
+	for attempt := 1; attempt <= attempts; attempt++ {
+		code, err := Sid.Generate()
+		if err != nil {
+			return "", err
+		}
+		code = foldShortCode(code)
+		if config.GlobalAppConfig.ProfanityFilter && isProfane(code) {
+			customlogger.FromContext(ctx).Debug().Msg("Regenerating short code containing an offensive word")
+			continue
+		}
+		if validation.IsReserved(code) {
+			customlogger.FromContext(ctx).Debug().Str("code", code).Msg("Regenerating short code that is a reserved word")
+			continue
+		}
+		if !unique {
+			if taken, err := shortCodeTaken(ctx, code); err != nil {
+				return "", err
+			} else if taken {
+				continue
+			}
+		}
+		return code, nil
+	}
+	return "", fmt.Errorf("no free, inoffensive, unreserved short code generated in %d attempts", attempts)
+}
+
+// storeGeneratedCode generates a short code and stores the destination destinationFor
+// returns for it. The write only succeeds if the code is still free, so a code taken
+// since it was generated is never overwritten but regenerated, up to SHORTCODE_ATTEMPTS
+// times. Errors of destinationFor are returned as they are.
+func storeGeneratedCode(ctx context.Context, ttl time.Duration, destinationFor func(code string) (string, error)) (code, destination string, err error) {
+	attempts := shortCodeAttempts()
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if code, err = generateShortCode(ctx); err != nil {
+			return "", "", err
+		}
+		if destination, err = destinationFor(code); err != nil {
+			return "", "", err
+		}
+		err = storage.CreateDestination(ctx, code, destination, ttl)
+		if !errors.Is(err, storage.ErrConflict) {
+			return code, destination, err
+		}
+		customlogger.FromContext(ctx).Warn().Str("code", code).Int("attempt", attempt).Msg("Generated short code collided with an existing one, regenerating")
+	}
+	return "", "", fmt.Errorf("every short code generated in %d attempts was taken", attempts)
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+)
+
+func TestNewRandomCodes(t *testing.T) {
+	g, err := newRandomCodes("", 0)
+	require.NoError(t, err)
+	assert.Equal(t, defaultShortCodeAlphabet, g.alphabet)
+	assert.Equal(t, defaultShortCodeLength, g.length)
+
+	for _, bad := range []struct {
+		alphabet string
+		length   int
+	}{
+		{"abc", 3},
+		{"abc", 33},
+		{"a", 8},
+		{"abca", 8},
+		{"ab/c", 8},
+		{"abcé", 8},
+	} {
+		_, err := newRandomCodes(bad.alphabet, bad.length)
+		assert.Error(t, err, "%q %d", bad.alphabet, bad.length)
+	}
+}
+
+func TestRandomCodesGenerate(t *testing.T) {
+	g, err := newRandomCodes("0123456789", 12)
+	require.NoError(t, err)
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		code, err := g.Generate()
+		require.NoError(t, err)
+		assert.Len(t, code, 12)
+		assert.Empty(t, strings.Trim(code, "0123456789"), "digits only")
+		seen[code] = true
+	}
+	assert.Greater(t, len(seen), 90, "codes are random")
+}
+
+// fixedCodes generates the same code every time, counting how often it was asked.
+type fixedCodes struct {
+	code  string
+	calls *int
+}
+
+func (g fixedCodes) Generate() (string, error) {
+	*g.calls++
+	return g.code, nil
+}
+
+func TestShortCodeAttempts(t *testing.T) {
+	original, originalSid := config.GlobalAppConfig, Sid
+	defer func() { config.GlobalAppConfig, Sid = original, originalSid }()
+	calls := 0
+	Sid = fixedCodes{code: "xshitx", calls: &calls}
+	config.GlobalAppConfig.ProfanityFilter = true
+
+	config.GlobalAppConfig.ShortCodeAttempts = 0
+	_, err := generateShortCode(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, config.DefaultShortCodeAttempts, calls, "the default applies when not loaded")
+
+	calls = 0
+	config.GlobalAppConfig.ShortCodeAttempts = 3
+	_, err = generateShortCode(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	// Destination errors are not collisions and are returned at once.
+	require.NoError(t, InitShortIDService())
+	config.GlobalAppConfig.ProfanityFilter = false
+	errLoop := errors.New("loop")
+	_, _, err = storeGeneratedCode(context.Background(), 0, func(string) (string, error) { return "", errLoop })
+	assert.ErrorIs(t, err, errLoop)
+}
+
+func TestSequentialCodes(t *testing.T) {
+	g, err := newSequentialCodes("", 0)
+	require.NoError(t, err)
+	for n, want := range map[int64]string{1: "1", 9: "9", 10: "a", 61: "Z", 62: "10", 62*62 + 5: "105"} {
+		assert.Equal(t, want, g.encode(n), n)
+	}
+
+	g, err = newSequentialCodes("01", 4)
+	require.NoError(t, err)
+	assert.Equal(t, "0001", g.encode(1))
+	assert.Equal(t, "1111", g.encode(15))
+	assert.Equal(t, "10000", g.encode(16), "longer numbers are not padded")
+
+	_, err = newSequentialCodes("abca", 0)
+	assert.Error(t, err)
+	_, err = newSequentialCodes("", 33)
+	assert.Error(t, err)
+}
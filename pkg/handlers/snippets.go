@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/snippets"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// maxSnippetBytes caps the size of a snippet's content.
+	maxSnippetBytes = 256 << 10
+	// maxSnippetBodyBytes caps the size of a snippet creation request, leaving room for
+	// the escaping of content in JSON.
+	maxSnippetBodyBytes = 2 * maxSnippetBytes
+	// snippetTag marks the links created for snippets.
+	snippetTag = "snippet"
+	// snippetPageCSP allows the snippet page its inline styles and images, and nothing
+	// else, so rendered Markdown can never run script.
+	snippetPageCSP = "default-src 'none'; style-src 'unsafe-inline'; img-src https: data:"
+)
+
+// snippetPageTemplate is the page a snippet is shown on.
+var snippetPageTemplate = template.Must(template.New("snippet").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 48rem; margin: 2rem auto; padding: 0 1rem; color: #333; line-height: 1.5; }
+pre { background: #f6f8fa; padding: 1rem; overflow-x: auto; border-radius: 4px; }
+code { font-family: SFMono-Regular, Consolas, "Liberation Mono", Menlo, monospace; }
+.notice { background: #fff8c5; padding: 0.5rem 1rem; border-radius: 4px; }
+footer { margin-top: 2rem; font-size: 0.85rem; color: #777; }
+</style>
+</head>
+<body>
+{{if .Burnt}}<p class="notice">This snippet was deleted after this view and cannot be opened again.</p>
+{{end}}{{.Body}}
+{{if not .Burnt}}<footer><a href="?raw=1">Raw</a></footer>{{end}}
+</body>
+</html>
+`))
+
+// snippetViewURL is the address a snippet is shown at; the snippet's short link
+// redirects there.
+func snippetViewURL(shortCode string) string {
+	return fmt.Sprintf("%s://%s/s/%s", config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain, shortCode)
+}
+
+// snippetResponse builds the description of a snippet, with the link's expiry and clicks.
+func snippetResponse(ctx context.Context, snippet models.Snippet) (models.SnippetResponse, error) {
+	expiresAt, err := storage.LinkExpiry(ctx, snippet.ShortCode)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return models.SnippetResponse{}, err
+	}
+	clicks, err := storage.CountClicks(ctx, snippet.ShortCode)
+	if err != nil {
+		return models.SnippetResponse{}, err
+	}
+	snippet.CreatedAt = snippet.CreatedAt.UTC().Truncate(time.Second)
+	return models.SnippetResponse{
+		Snippet:   snippet,
+		ShortURL:  shortURLFor(snippet.ShortCode),
+		ViewURL:   snippetViewURL(snippet.ShortCode),
+		ExpiresAt: expiresAt,
+		Clicks:    clicks,
+	}, nil
+}
+
+// CreateSnippetHandler stores a text, code or Markdown snippet and creates a short link
+// showing it.
+func CreateSnippetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if !isValidAuthCode(bearerToken(r)) {
+		customlogger.FromContext(ctx).Warn().Msg("Unauthorized attempt to create a snippet")
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return
+	}
+
+	var req models.SnippetRequest
+	if err := decodeJSONOrYAML(r, maxSnippetBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for CreateSnippet")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		writeJSONError(w, http.StatusBadRequest, "content is required")
+		return
+	}
+	if len(req.Content) > maxSnippetBytes {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Snippets may be at most %d bytes.", maxSnippetBytes))
+		return
+	}
+	language, err := snippets.NormalizeLanguage(req.Language)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	link, ok := createHostedLink(w, r, hostedLinkRequest{
+		CustomHandle:   strings.TrimSpace(req.CustomHandle),
+		ExpirationDays: req.ExpirationDays,
+		Title:          req.Title,
+		Tag:            snippetTag,
+		Target:         snippetViewURL,
+	})
+	if !ok {
+		return
+	}
+	ctx = customlogger.WithContext(ctx, customlogger.ShortCodeField, link.ShortCode)
+
+	snippet := models.Snippet{
+		ShortCode:     link.ShortCode,
+		Content:       req.Content,
+		Language:      language,
+		BurnAfterRead: req.BurnAfterRead,
+		CreatedAt:     link.CreatedAt,
+	}
+	if err := storage.SaveSnippet(ctx, snippet); err != nil {
+		removeHostedLink(ctx, link)
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store snippet")
+		writeJSONError(w, http.StatusInternalServerError, "Error storing snippet")
+		return
+	}
+
+	resp, err := snippetResponse(ctx, snippet)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to describe snippet")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving snippet")
+		return
+	}
+	customlogger.FromContext(ctx).Info().Str("language", language).Bool("burn_after_read", req.BurnAfterRead).Msg("Snippet created")
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// GetSnippetHandler returns a snippet with its expiry and clicks. Reading a snippet
+// through the API does not count as a view, so it does not burn it.
+func GetSnippetHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	if !requireLinkAccess(w, r, shortCode) {
+		return
+	}
+	ctx := r.Context()
+
+	snippet, err := storage.GetSnippet(ctx, shortCode)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load snippet")
+		}
+		writeStorageError(w, err, "Error retrieving snippet")
+		return
+	}
+	resp, err := snippetResponse(ctx, snippet)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to describe snippet")
+		writeJSONError(w, http.StatusInternalServerError, "Error retrieving snippet")
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// ServeSnippetHandler shows a snippet for as long as its link is live, rendered as a
+// page or, with ?raw=1, as plain text. Viewing a burn-after-read snippet deletes it
+// and its link.
+func ServeSnippetHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+
+	// The link's TTL is the snippet's expiry.
+	if _, err := storage.GetDestination(ctx, shortCode); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis for snippet")
+			http.Error(w, "Error retrieving snippet", http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, errorMessage(err), storageErrorStatus(err))
+		return
+	}
+	if serveTakedown(w, r, shortCode) {
+		return
+	}
+	snippet, err := storage.ViewSnippet(ctx, shortCode)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to load snippet")
+			http.Error(w, "Error retrieving snippet", http.StatusInternalServerError)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if snippet.BurnAfterRead {
+		if err := storage.DeleteDestination(ctx, shortCode); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to remove destination of burnt snippet")
+		}
+		customlogger.FromContext(ctx).Info().Msg("Snippet burnt after read")
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	if r.URL.Query().Get("raw") != "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(snippet.Content))
+		return
+	}
+
+	body, err := snippets.Render(snippet.Content, snippet.Language)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to render snippet")
+		http.Error(w, "Error rendering snippet", http.StatusInternalServerError)
+		return
+	}
+	title := shortCode
+	if link, err := storage.GetLink(ctx, shortCode); err == nil && link.Title != "" {
+		title = link.Title
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", snippetPageCSP)
+	err = snippetPageTemplate.Execute(w, struct {
+		Title string
+		Body  template.HTML
+		Burnt bool
+	}{title, body, snippet.BurnAfterRead})
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to render snippet page")
+	}
+}
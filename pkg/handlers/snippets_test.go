@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/storage"
+)
+
+func TestSnippetsAreNeverSuggested(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.NotFoundMode = config.NotFoundModeSearch
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	resetCodeIndex(t)
+	ctx := context.Background()
+
+	// The record CreateSnippetHandler stores, found by a rebuilt index or added to a loaded one.
+	req := hostedLinkRequest{Title: "secret", Tag: snippetTag, Target: snippetViewURL}
+	require.NoError(t, storage.CreateLink(ctx, req.link("snip1", snippetViewURL("snip1"), nil, "")))
+	assert.Empty(t, suggestCodes(ctx, "snip2"))
+
+	indexNewLink(req.link("snip3", snippetViewURL("snip3"), nil, ""))
+	assert.Empty(t, suggestCodes(ctx, "snip2"))
+}
@@ -1,8 +1,16 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	customlogger "riid.me/pkg/logger"
@@ -10,47 +18,250 @@ import (
 	"riid.me/pkg/storage"
 )
 
+const (
+	// maxBulkStatsCodes is the most short codes a single bulk stats request may ask for.
+	maxBulkStatsCodes = 100
+	// maxBulkStatsBodyBytes caps the size of a bulk stats request.
+	maxBulkStatsBodyBytes = 64 << 10
+	// defaultStatsClickLimit is how many of the newest clicks the stats endpoint lists by default.
+	defaultStatsClickLimit = 1000
+	// maxStatsClickLimit is the most clicks a single stats request may list.
+	maxStatsClickLimit = 10000
+)
+
 // GetLinkStatsHandler retrieves and returns click statistics for a given shortcode.
-// It queries the SQLite database for click details and aggregates them.
+// It queries the SQLite database for the total and unique click counts, overall, per
+// source and per A/B split variant, the conversions per event, and the newest click
+// details, up to the ?limit= query parameter. Only the link's owner, admins and viewers
+// may see a link's statistics (see requireStatsAccess), and viewers only get the click
+// details unless VIEWER_STATS_AGGREGATES_ONLY is set (see canSeeClickDetails).
 func GetLinkStatsHandler(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	shortCode := vars["shortcode"]
+	shortCode := requestedShortCode(r)
+
+	ctx := r.Context()
+
+	limit := defaultStatsClickLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxStatsClickLimit {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxStatsClickLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	link, err := storage.GetLink(ctx, shortCode)
+	if errors.Is(err, storage.ErrNotFound) {
+		link = models.Link{ShortCode: shortCode}
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve link metadata")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	if !requireStatsAccess(w, r, link) {
+		return
+	}
+
+	totals, err := storage.ClickTotalsByCode(ctx, []string{shortCode})
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count clicks")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	sources, err := storage.ClicksBySource(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count clicks per source")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	variants, err := storage.ClicksByVariant(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count clicks per variant")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	conversions, err := storage.ConversionsByEvent(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count conversions")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	response := models.LinkStatsResponse{
+		ShortCode:    shortCode,
+		TotalClicks:  totals[shortCode].TotalClicks,
+		UniqueClicks: totals[shortCode].UniqueClicks,
+		Sources:      sources,
+		Variants:     variants,
+		Conversions:  conversions,
+		Clicks:       []models.ClickDetail{},
+	}
+
+	response.AnalyticsDisabled = link.NoAnalytics
+
+	// What the response contains depends on the caller's auth code.
+	w.Header().Set("Vary", "Authorization")
+	if canSeeClickDetails(r, link) {
+		clicks, err := storage.ListClicks(ctx, shortCode, limit)
+		if err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to query click statistics")
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"Failed to retrieve statistics"}`, http.StatusInternalServerError)
+			return
+		}
+		response.Clicks = clicks
+	} else {
+		response.ClicksHidden = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// requireStatsAccess checks that the caller of r may see the statistics of link: its
+// owner, admins and viewers. Otherwise it writes a 401, or a 403 to callers who could
+// own links, and returns false. Links without a known creator, such as anonymous ones,
+// are only visible to admins and viewers.
+func requireStatsAccess(w http.ResponseWriter, r *http.Request, link models.Link) bool {
+	token := bearerToken(r)
+	if ownsLink(token, link) || callerRole(r) == roleViewer {
+		return true
+	}
+	if linkOwner(token) == "" {
+		writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+		return false
+	}
+	customlogger.FromContext(r.Context()).Warn().Msg("Attempt to view statistics of a link created by someone else")
+	writeJSONError(w, http.StatusForbidden, "Only the link's creator, admins and viewers may see its statistics.")
+	return false
+}
 
+// clientIP returns the visitor's IP address: the first X-Forwarded-For entry set by
+// the reverse proxy, or the connection's remote address.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// visitorID returns an anonymized identifier of the visitor, a hash of IP address and
+// user agent, so unique clicks can be counted without storing either.
+func visitorID(r *http.Request) string {
+	sum := sha256.Sum256([]byte(clientIP(r) + "|" + r.UserAgent()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// GetBulkStatsHandler returns the total and unique click counts of up to
+// maxBulkStatsCodes short codes in one call, e.g. for a dashboard listing many links.
+// Admins and viewers get all of them; other callers who can own links only get those
+// of their own links, the rest are left out.
+func GetBulkStatsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
+	owner := ""
+	if role := callerRole(r); role != roleAdmin && role != roleViewer {
+		if owner = linkOwner(bearerToken(r)); owner == "" {
+			writeJSONError(w, http.StatusUnauthorized, "Valid authorization code required.")
+			return
+		}
+	}
+
+	var req models.BulkStatsRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBulkStatsBodyBytes)).Decode(&req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for GetBulkStats")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	seen := make(map[string]bool, len(req.Codes))
+	var codes []string
+	for _, code := range req.Codes {
+		code = strings.TrimSpace(code)
+		if code != "" && !seen[code] {
+			seen[code] = true
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "codes must list at least one short code")
+		return
+	}
+	if len(codes) > maxBulkStatsCodes {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d codes may be requested at once.", maxBulkStatsCodes))
+		return
+	}
 
-	rows, err := storage.StatsDB.QueryContext(ctx, "SELECT timestamp, user_agent, referrer FROM clicks WHERE short_code = ? ORDER BY timestamp DESC", shortCode)
+	if owner != "" {
+		owned, err := storage.OwnedCodes(ctx, owner, codes)
+		if err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up link owners")
+			writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+			return
+		}
+		codes = owned
+	}
+
+	totals, err := storage.ClickTotalsByCode(ctx, codes)
 	if err != nil {
-		customlogger.Error().Err(err).Str("short_code", shortCode).Msg("Failed to query click statistics")
-		w.Header().Set("Content-Type", "application/json")
-		http.Error(w, `{"error":"Failed to retrieve statistics"}`, http.StatusInternalServerError)
+		customlogger.FromContext(ctx).Error().Err(err).Int("codes", len(codes)).Msg("Failed to query bulk click statistics")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	writeJSON(w, http.StatusOK, models.BulkStatsResponse{Stats: totals})
+}
+
+// GetTagStatsHandler rolls up the clicks of all links sharing a tag, e.g. the links of
+// an initiative, with their clicks over time between ?from= and ?to= (the last 24
+// hours by default) in buckets of ?interval= (an hour by default), taking the same
+// values as the Grafana endpoints. Admins and viewers get all links with the tag;
+// other callers who can own links get their own.
+func GetTagStatsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	tag := strings.TrimSpace(mux.Vars(r)["tag"])
+	if tag == "" {
+		writeJSONError(w, http.StatusBadRequest, "tag is required")
 		return
 	}
-	defer rows.Close()
 
-	var clicks []models.ClickDetail
-	for rows.Next() {
-		var cd models.ClickDetail
-		// Scan into sql.NullString for UserAgent and Referrer to handle potential NULLs from DB.
-		if err := rows.Scan(&cd.Timestamp, &cd.UserAgent, &cd.Referrer); err != nil {
-			customlogger.Error().Err(err).Str("short_code", shortCode).Msg("Failed to scan click detail row")
-			continue // Skipping problematic row
+	owner := ""
+	if role := callerRole(r); role != roleAdmin && role != roleViewer {
+		if owner = linkOwner(bearerToken(r)); owner == "" {
+			writeJSONError(w, http.StatusForbidden, "An auth code, API key or session is required.")
+			return
 		}
-		clicks = append(clicks, cd)
 	}
 
-	if err = rows.Err(); err != nil { // Check for errors during iteration
-		customlogger.Error().Err(err).Str("short_code", shortCode).Msg("Error iterating click detail rows")
-		w.Header().Set("Content-Type", "application/json")
-		http.Error(w, `{"error":"Failed to process statistics"}`, http.StatusInternalServerError)
+	from, to, ok := grafanaRange(w, r)
+	if !ok {
+		return
+	}
+	interval, err := parseGrafanaInterval(r.URL.Query().Get("interval"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if buckets := int64(to.Sub(from)/interval) + 1; buckets > maxGrafanaPoints {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("The range would need more than %d points; use a larger interval.", maxGrafanaPoints))
 		return
 	}
 
-	response := models.LinkStatsResponse{
-		ShortCode:   shortCode,
-		TotalClicks: len(clicks),
-		Clicks:      clicks,
+	response, err := storage.TagClickTotals(ctx, tag, owner)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Str("tag", tag).Msg("Failed to count clicks of tagged links")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
+	}
+	if response.Series, err = storage.TagClickSeries(ctx, tag, owner, from, to, interval); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Str("tag", tag).Msg("Failed to query click series of tagged links")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve statistics")
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	// What the response contains depends on the caller's auth code.
+	w.Header().Set("Vary", "Authorization")
+	writeJSON(w, http.StatusOK, response)
 }
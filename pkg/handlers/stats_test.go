@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestVisitorID(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	req.Header.Set("User-Agent", "Mozilla/5.0")
+	assert.Equal(t, "203.0.113.7", clientIP(req))
+	id := visitorID(req)
+	assert.Len(t, id, 32)
+	assert.NotContains(t, id, "203.0.113.7")
+
+	req.Header.Set("X-Forwarded-For", "198.51.100.1, 10.0.0.1")
+	assert.Equal(t, "198.51.100.1", clientIP(req))
+	assert.NotEqual(t, id, visitorID(req))
+}
+
+func TestGetBulkStatsHandlerRejectsTooManyCodes(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ViewerAuthCodes = []string{"viewer"}
+	post := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/stats/bulk", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer viewer")
+		return req
+	}
+
+	codes := make([]string, maxBulkStatsCodes+1)
+	for i := range codes {
+		codes[i] = `"c` + strings.Repeat("x", i) + `"`
+	}
+	body := `{"codes":[` + strings.Join(codes, ",") + `]}`
+	rr := httptest.NewRecorder()
+	GetBulkStatsHandler(rr, post(body))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	rr = httptest.NewRecorder()
+	GetBulkStatsHandler(rr, post(`{"codes":[" "]}`))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestStatsRequireOwnership(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.AdminAuthCodes = []string{"admin"}
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member", "other"}
+	config.GlobalAppConfig.ViewerAuthCodes = []string{"viewer"}
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+	now := time.Now().UTC()
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "mine", LongURL: "https://example.com/mine", CreatedAt: now, Owner: linkOwner("member")}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "theirs", LongURL: "https://example.com/theirs", CreatedAt: now, Owner: linkOwner("other")}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "anon", LongURL: "https://example.com/anon", CreatedAt: now}))
+
+	stats := func(code, shortCode string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/"+shortCode, nil)
+		if code != "" {
+			req.Header.Set("Authorization", "Bearer "+code)
+		}
+		rr := httptest.NewRecorder()
+		GetLinkStatsHandler(rr, mux.SetURLVars(req, map[string]string{"shortcode": shortCode}))
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, stats("", "mine"), "anonymous callers see no statistics")
+	assert.Equal(t, http.StatusUnauthorized, stats("", "anon"), "not even of anonymous links")
+	assert.Equal(t, http.StatusForbidden, stats("other", "mine"), "nor do other members")
+	assert.Equal(t, http.StatusForbidden, stats("member", "anon"))
+	for _, code := range []string{"member", "admin", "viewer"} {
+		assert.Equal(t, http.StatusOK, stats(code, "mine"), code)
+	}
+
+	bulk := func(code string) (int, models.BulkStatsResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/api/stats/bulk", strings.NewReader(`{"codes":["mine","theirs","anon"]}`))
+		if code != "" {
+			req.Header.Set("Authorization", "Bearer "+code)
+		}
+		rr := httptest.NewRecorder()
+		GetBulkStatsHandler(rr, req)
+		var resp models.BulkStatsResponse
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		}
+		return rr.Code, resp
+	}
+	code, _ := bulk("")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, resp := bulk("other")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, resp.Stats, 1, "members only get the counts of their own links")
+	assert.Contains(t, resp.Stats, "theirs")
+	for _, caller := range []string{"admin", "viewer"} {
+		_, resp = bulk(caller)
+		assert.Len(t, resp.Stats, 3, caller)
+	}
+}
+
+func TestCanSeeClickDetails(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.AdminAuthCodes = []string{"admin"}
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member"}
+	config.GlobalAppConfig.ViewerAuthCodes = []string{"viewer"}
+
+	request := func(code string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/abc", nil)
+		if code != "" {
+			req.Header.Set("Authorization", "Bearer "+code)
+		}
+		return req
+	}
+	assert.Equal(t, roleAdmin, callerRole(request("admin")))
+	assert.Equal(t, roleMember, callerRole(request("member")))
+	assert.Equal(t, roleViewer, callerRole(request("viewer")))
+	assert.Equal(t, roleAnonymous, callerRole(request("bogus")))
+
+	owned := models.Link{ShortCode: "abc", Owner: linkOwner("member")}
+	for code, want := range map[string]bool{"admin": true, "member": true, "viewer": true, "": false} {
+		assert.Equal(t, want, canSeeClickDetails(request(code), owned), code)
+	}
+	others := models.Link{ShortCode: "abc", Owner: "someone-else"}
+	assert.False(t, canSeeClickDetails(request("member"), others), "members only see the clicks of their own links")
+	assert.False(t, canSeeClickDetails(request(""), models.Link{ShortCode: "abc"}), "anonymous links are not owned by anonymous callers")
+	assert.True(t, canSeeClickDetails(request("admin"), others))
+
+	config.GlobalAppConfig.ViewerStatsAggregatesOnly = true
+	assert.False(t, canSeeClickDetails(request("viewer"), owned))
+	assert.True(t, canSeeClickDetails(request("member"), owned))
+}
+
+func TestGetTagStatsHandlerRequiresOwner(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member"}
+
+	request := func(code, query string) int {
+		req := httptest.NewRequest(http.MethodGet, "/api/stats/by-tag/launch"+query, nil)
+		if code != "" {
+			req.Header.Set("Authorization", "Bearer "+code)
+		}
+		rr := httptest.NewRecorder()
+		GetTagStatsHandler(rr, mux.SetURLVars(req, map[string]string{"tag": "launch"}))
+		return rr.Code
+	}
+	assert.Equal(t, http.StatusForbidden, request("", ""))
+	assert.Equal(t, http.StatusBadRequest, request("member", "?interval=1ms"))
+	assert.Equal(t, http.StatusBadRequest, request("member", "?from=2026-03-02T00:00:00Z&to=2026-03-01T00:00:00Z"))
+}
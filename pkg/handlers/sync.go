@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"riid.me/pkg/linksync"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+)
+
+// maxSyncBodyBytes caps the size of an uploaded sync file.
+const maxSyncBodyBytes = 1 << 20
+
+// SyncLinksHandler reconciles sync-managed links with the desired set in the request
+// body (JSON, or YAML with a YAML Content-Type). It always returns the plan; the plan
+// is only carried out with ?apply=true, and never when it has conflicts.
+func SyncLinksHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	now := time.Now().UTC()
+
+	var req models.SyncRequest
+	if err := decodeJSONOrYAML(r, maxSyncBodyBytes, &req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid sync file: "+err.Error())
+		return
+	}
+	if err := linksync.Validate(&req, now); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	plan, err := linksync.Plan(ctx, req, now)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to plan link sync")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to plan sync")
+		return
+	}
+
+	if r.URL.Query().Get("apply") != "true" {
+		writeJSON(w, http.StatusOK, plan)
+		return
+	}
+	if len(plan.Conflicts) > 0 {
+		writeJSON(w, http.StatusConflict, plan)
+		return
+	}
+
+	if err := linksync.Apply(ctx, plan, now); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to apply link sync")
+		writeJSONError(w, storageErrorStatus(err), "Sync stopped: "+err.Error())
+		return
+	}
+	for _, change := range plan.Changes {
+		if change.Action == models.SyncActionCreate && change.After != nil {
+			indexNewLink(*change.After)
+		}
+	}
+
+	plan.Applied = true
+	customlogger.FromContext(ctx).Info().Int("changes", len(plan.Changes)).Int("unchanged", plan.Unchanged).Msg("Link sync applied")
+	writeJSON(w, http.StatusOK, plan)
+}
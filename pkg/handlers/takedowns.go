@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	"riid.me/pkg/events"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// maxTakedownBodyBytes caps the size of a takedown request.
+	maxTakedownBodyBytes = 16 << 10
+	// maxTakedownCategoryLength bounds the category shown to visitors of a disabled link.
+	maxTakedownCategoryLength = 50
+)
+
+// TakedownNotifier notifies owners of links disabled for abuse; nil sends no notices.
+var TakedownNotifier *events.TakedownNotifier
+
+// takedownTemplate is the page served instead of a link disabled for abuse.
+var takedownTemplate = template.Must(template.New("takedown").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link disabled</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #333; }
+h1 { font-size: 1.5rem; }
+</style>
+</head>
+<body>
+<h1>Link disabled</h1>
+<p>This link has been disabled for violating our acceptable use policy{{if .Category}} ({{.Category}}){{end}}.</p>
+{{if .AppealURL}}<p>If you own this link and believe this is a mistake, you can <a href="{{.AppealURL}}">appeal the decision</a>.</p>
+{{end}}<p><a href="/">Go to the homepage</a></p>
+</body>
+</html>
+`))
+
+// takedownAppealURL returns where the owner of a disabled link can appeal, or "" if
+// TAKEDOWN_APPEAL_URL is not configured.
+func takedownAppealURL(shortCode string) string {
+	return strings.ReplaceAll(config.GlobalAppConfig.TakedownAppealURL, "{code}", shortCode)
+}
+
+// serveTakedown serves the policy violation page if the link has been disabled and
+// reports whether it did. Lookup failures are logged and let the request through.
+func serveTakedown(w http.ResponseWriter, r *http.Request, shortCode string) bool {
+	takedown, err := storage.GetLinkTakedown(r.Context(), shortCode)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to look up link takedown")
+		return false
+	}
+	if takedown == nil {
+		return false
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	err = takedownTemplate.Execute(w, struct {
+		Category  string
+		AppealURL string
+	}{takedown.Category, takedownAppealURL(shortCode)})
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to render takedown page")
+	}
+	return true
+}
+
+// ListLinkTakedownsHandler returns the links disabled for abuse, optionally only those
+// of ?category=, e.g. "policy" for the links rescans found violating the destination
+// policy or blocklists.
+func ListLinkTakedownsHandler(w http.ResponseWriter, r *http.Request) {
+	takedowns, err := storage.ListLinkTakedowns(r.Context(), strings.TrimSpace(r.URL.Query().Get("category")))
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to list link takedowns")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve takedowns")
+		return
+	}
+	writeJSON(w, http.StatusOK, takedowns)
+}
+
+// PutLinkTakedownHandler disables a link for abuse, recording the reason, and sends
+// the owner a notice with the appeal link. The link stays disabled if the notice
+// cannot be delivered.
+func PutLinkTakedownHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+
+	var req models.TakedownRequest
+	if err := decodeJSONOrYAML(r, maxTakedownBodyBytes, &req); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for PutLinkTakedown")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	req.Category = strings.TrimSpace(req.Category)
+	if req.Reason == "" {
+		writeJSONError(w, http.StatusBadRequest, "reason is required")
+		return
+	}
+	if len(req.Category) > maxTakedownCategoryLength || strings.ContainsAny(req.Category, "\r\n") {
+		writeJSONError(w, http.StatusBadRequest, "category must be a single line of at most 50 characters")
+		return
+	}
+	if req.NotifyEmail != "" {
+		if _, err := mail.ParseAddress(req.NotifyEmail); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "notify_email is not a valid email address")
+			return
+		}
+	}
+
+	exists, err := storage.LinkExists(ctx, shortCode)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to check link for takedown")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store takedown")
+		return
+	}
+	if !exists {
+		writeStorageError(w, storage.ErrLinkNotFound, "")
+		return
+	}
+
+	takedown := models.LinkTakedown{
+		ShortCode:   shortCode,
+		Reason:      req.Reason,
+		Category:    req.Category,
+		NotifyEmail: req.NotifyEmail,
+		CreatedAt:   time.Now().UTC().Truncate(time.Second),
+	}
+	if err := storage.SaveLinkTakedown(ctx, takedown); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store link takedown")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to store takedown")
+		return
+	}
+	customlogger.FromContext(ctx).Warn().Str("category", takedown.Category).Msg("Link disabled for abuse")
+	purgeCachedRedirect(ctx, shortCode)
+
+	resp := models.TakedownResponse{LinkTakedown: takedown, Notified: []string{}}
+	notified, err := notifyTakedown(ctx, takedown)
+	if notified != nil {
+		resp.Notified = notified
+	}
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to deliver takedown notice")
+		resp.NotificationError = err.Error()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// notifyTakedown sends the notice of a takedown to the link's owner through
+// TakedownNotifier, if configured, and returns where it was delivered.
+func notifyTakedown(ctx context.Context, takedown models.LinkTakedown) ([]string, error) {
+	if TakedownNotifier == nil {
+		return nil, nil
+	}
+	notice := models.TakedownNotice{
+		ShortCode:  takedown.ShortCode,
+		ShortURL:   shortURLFor(takedown.ShortCode),
+		Reason:     takedown.Reason,
+		Category:   takedown.Category,
+		DisabledAt: takedown.CreatedAt,
+		AppealURL:  takedownAppealURL(takedown.ShortCode),
+	}
+	return TakedownNotifier.Notify(ctx, notice, takedown.NotifyEmail)
+}
+
+// NotifyScanTakedown notifies the owner of a link disabled by a policy scan and purges
+// its cached redirect. It is a policyscan.Notify.
+func NotifyScanTakedown(ctx context.Context, takedown models.LinkTakedown) error {
+	purgeCachedRedirect(ctx, takedown.ShortCode)
+	_, err := notifyTakedown(ctx, takedown)
+	return err
+}
+
+// DeleteLinkTakedownHandler reinstates a disabled link, e.g. after a successful appeal.
+func DeleteLinkTakedownHandler(w http.ResponseWriter, r *http.Request) {
+	shortCode := requestedShortCode(r)
+	ctx := r.Context()
+
+	if err := storage.DeleteLinkTakedown(ctx, shortCode); err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to delete link takedown")
+		}
+		writeStorageError(w, err, "Failed to reinstate link")
+		return
+	}
+	customlogger.FromContext(ctx).Info().Msg("Link reinstated")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListTakedownLogHandler publishes the append-only log of links disabled for abuse and
+// reinstated, oldest first, so mirrors and researchers can track takedowns. Entries
+// name the short code and category only, never the destination or the reason. It is
+// paged like ListLinkEventsHandler and answers 404 unless TRANSPARENCY_LOG is on.
+func ListTakedownLogHandler(w http.ResponseWriter, r *http.Request) {
+	if !config.GlobalAppConfig.TransparencyLog {
+		http.NotFound(w, r)
+		return
+	}
+	after, limit, err := parseEventsPage(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, err := storage.ListTakedownLog(r.Context(), after, limit)
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to list takedown log")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to retrieve takedown log")
+		return
+	}
+
+	next := after
+	if len(entries) > 0 {
+		next = entries[len(entries)-1].ID
+	}
+	writeJSON(w, http.StatusOK, models.TakedownLogResponse{Entries: entries, NextAfter: next})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestServeTakedown(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+	config.GlobalAppConfig.TakedownAppealURL = "https://example.com/appeal?code={code}"
+	t.Cleanup(func() { config.GlobalAppConfig.TakedownAppealURL = "" })
+
+	rr := httptest.NewRecorder()
+	assert.False(t, serveTakedown(rr, httptest.NewRequest(http.MethodGet, "/abc", nil), "abc"))
+
+	require.NoError(t, storage.SaveLinkTakedown(ctx, models.LinkTakedown{
+		ShortCode: "abc",
+		Reason:    "Credential phishing page reported by two users",
+		Category:  "phishing",
+		CreatedAt: time.Now(),
+	}))
+	rr = httptest.NewRecorder()
+	assert.True(t, serveTakedown(rr, httptest.NewRequest(http.MethodGet, "/abc", nil), "abc"))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+	assert.Contains(t, rr.Body.String(), "acceptable use policy (phishing)")
+	assert.Contains(t, rr.Body.String(), `href="https://example.com/appeal?code=abc"`)
+	assert.NotContains(t, rr.Body.String(), "reported by two users", "the internal reason is not shown to visitors")
+
+	require.NoError(t, storage.DeleteLinkTakedown(ctx, "abc"))
+	assert.ErrorIs(t, storage.DeleteLinkTakedown(ctx, "abc"), storage.ErrTakedownNotFound)
+	assert.False(t, serveTakedown(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abc", nil), "abc"))
+}
+
+func TestListTakedownLogHandler(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	ctx := context.Background()
+
+	require.NoError(t, storage.SaveLinkTakedown(ctx, models.LinkTakedown{
+		ShortCode: "abc", Reason: "Phishing page at evil.example", Category: "phishing", CreatedAt: time.Now(),
+	}))
+	require.NoError(t, storage.DeleteLinkTakedown(ctx, "abc"))
+
+	list := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ListTakedownLogHandler(rr, httptest.NewRequest(http.MethodGet, "/api/transparency/takedowns"+query, nil))
+		return rr
+	}
+	assert.Equal(t, http.StatusNotFound, list("").Code, "off by default")
+
+	config.GlobalAppConfig.TransparencyLog = true
+	rr := list("?limit=1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"action":"disabled"`)
+	assert.Contains(t, rr.Body.String(), `"category":"phishing"`)
+	assert.NotContains(t, rr.Body.String(), "evil.example", "reasons are not published")
+	assert.Contains(t, rr.Body.String(), `"next_after":1`)
+
+	rr = list("?after=1")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"action":"reinstated"`)
+	assert.Equal(t, http.StatusBadRequest, list("?after=-1").Code)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/unwrap"
+	"riid.me/pkg/validation"
+)
+
+const (
+	// unwrapTimeout bounds resolving the whole redirect chain of a submitted URL.
+	unwrapTimeout = 15 * time.Second
+	// viaShortenerTag marks links whose submitted URL redirected through a shortener.
+	viaShortenerTag = "via-shortener"
+)
+
+// URLUnwrapper resolves the redirect chains of submitted URLs; nil when
+// UNWRAP_MAX_HOPS is 0 and URLs are stored as submitted.
+var URLUnwrapper *unwrap.Resolver
+
+// unwrapDestination follows the redirects of a submitted URL when unwrapping is
+// enabled and returns the destination to store, with a description of the chain if
+// the URL redirected. Returned errors are meant for the client: chains that are too
+// long, end at a destination the policy rejects or, with UNWRAP_SHORTENER_ACTION
+// reject, pass through a shortener. A URL that cannot be reached is kept as submitted.
+func unwrapDestination(ctx context.Context, longURL string) (string, *models.UnwrapInfo, error) {
+	if URLUnwrapper == nil {
+		return longURL, nil, nil
+	}
+	resolveCtx, cancel := context.WithTimeout(ctx, unwrapTimeout)
+	defer cancel()
+
+	result, err := URLUnwrapper.Resolve(resolveCtx, longURL)
+	if errors.Is(err, unwrap.ErrTooManyHops) {
+		return "", nil, fmt.Errorf("URL redirects more than %d times", URLUnwrapper.MaxHops)
+	} else if err != nil {
+		customlogger.FromContext(ctx).Warn().Err(err).Str("long_url", longURL).Msg("Failed to unwrap URL, storing it as submitted")
+		return longURL, nil, nil
+	}
+	if len(result.Hops) == 0 {
+		return longURL, nil, nil
+	}
+
+	if err := validation.ValidateDestination(result.Final); err != nil {
+		return "", nil, fmt.Errorf("URL redirects to a rejected destination: %w", err)
+	}
+	if len(result.Shorteners) > 0 && config.GlobalAppConfig.UnwrapShortenerAction == config.UnwrapShortenerReject {
+		return "", nil, fmt.Errorf("URLs redirecting through link shorteners (%s) are not accepted", strings.Join(result.Shorteners, ", "))
+	}
+	return result.Final, &models.UnwrapInfo{
+		OriginalURL:   longURL,
+		Hops:          result.Hops,
+		ViaShorteners: result.Shorteners,
+	}, nil
+}
@@ -1,35 +1,72 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/teris-io/shortid"
+	"riid.me/pkg/events"
 	customlogger "riid.me/pkg/logger"
 	"riid.me/pkg/models"
 	"riid.me/pkg/config"
+	"riid.me/pkg/rules"
 	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
 )
 
 var (
-	// Sid is the global shortid generator instance.
-	Sid *shortid.Shortid
+	// Sid generates the short codes of links created without a custom handle.
+	Sid ShortCodeGenerator
+	// ClickNotifier delivers click events of notify_each_click links; nil when no
+	// click webhook is configured.
+	ClickNotifier *events.ClickNotifier
 )
 
-// InitShortIDService initializes the shortid generator.
+// InitShortIDService initializes the short code generator of SHORTCODE_STRATEGY with
+// SHORTCODE_LENGTH and SHORTCODE_ALPHABET. Without a strategy it is shortid, with its
+// defaults, or random codes when either of the others is set.
 // It should be called once at application startup.
 func InitShortIDService() error {
-	generator, err := shortid.New(1, shortid.DefaultABC, 2342)
-	if err != nil {
-		customlogger.Error().Err(err).Msg("Failed to initialize shortid generator")
-		return err
+	cfg := config.GlobalAppConfig
+	strategy := cfg.ShortCodeStrategy
+	if strategy == "" {
+		strategy = config.ShortCodeShortID
+		if cfg.ShortCodeLength != 0 || cfg.ShortCodeAlphabet != "" {
+			strategy = config.ShortCodeNanoID
+		}
+	}
+
+	switch strategy {
+	case config.ShortCodeNanoID:
+		generator, err := newRandomCodes(cfg.ShortCodeAlphabet, cfg.ShortCodeLength)
+		if err != nil {
+			customlogger.Error().Err(err).Msg("Failed to initialize short code generator")
+			return err
+		}
+		Sid = generator
+		customlogger.Info().Int("length", generator.length).Str("alphabet", generator.alphabet).Msg("Random short code generator initialized")
+	case config.ShortCodeSequential:
+		generator, err := newSequentialCodes(cfg.ShortCodeAlphabet, cfg.ShortCodeLength)
+		if err != nil {
+			customlogger.Error().Err(err).Msg("Failed to initialize short code generator")
+			return err
+		}
+		Sid = generator
+		customlogger.Info().Int("min_length", generator.length).Str("alphabet", generator.alphabet).Msg("Sequential short code generator initialized")
+	default:
+		generator, err := shortid.New(1, shortid.DefaultABC, 2342)
+		if err != nil {
+			customlogger.Error().Err(err).Msg("Failed to initialize shortid generator")
+			return err
+		}
+		Sid = generator
+		customlogger.Info().Msg("Shortid generator initialized")
 	}
-	Sid = generator
-	customlogger.Info().Msg("Shortid generator initialized")
 	return nil
 }
 
@@ -43,130 +80,330 @@ func NormalizeURL(url string) string {
 	return url
 }
 
+// maxLinkTitleLength is the maximum length of the optional title attached to a link.
+const maxLinkTitleLength = 200
+
+// shortURLFor builds the public short URL for a code from the configured scheme and domain.
+func shortURLFor(code string) string {
+	return fmt.Sprintf("%s://%s/%s", config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain, code)
+}
+
 // CreateShortURL handles requests to shorten a long URL.
 // It supports custom handles and expiration times if an appropriate auth code is provided.
 func CreateShortURL(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 	var req models.URLRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		customlogger.Error().Err(err).Msg("Invalid request body for CreateShortURL")
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Invalid request body for CreateShortURL")
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid request body"})
 		return
 	}
 
-	if req.LongURL == "" {
-		customlogger.Error().Msg("Empty URL provided for CreateShortURL")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "URL is required"})
+	// An auth code may also be sent as bearer token. API keys must be, so their quota
+	// (see APIKeyQuota) cannot be sidestepped through the body.
+	if bearer := bearerToken(r); req.AuthCode == "" {
+		req.AuthCode = bearer
+	} else if strings.HasPrefix(req.AuthCode, apiKeyPrefix) && req.AuthCode != bearer {
+		writeJSONError(w, http.StatusBadRequest, "API keys must be sent in an \"Authorization: Bearer\" header.")
 		return
 	}
 
-	normalizedURL := NormalizeURL(req.LongURL)
-	var codeToUse string
-	var err error
+	if config.GlobalAppConfig.RequireAuthToShorten && !isValidAuthCode(req.AuthCode) {
+		customlogger.FromContext(ctx).Info().Msg("Attempt to shorten without a valid auth code while authentication is required")
+		writeJSONError(w, http.StatusUnauthorized, "A valid authorization code is required to shorten links.")
+		return
+	}
+	if !isValidAuthCode(req.AuthCode) && !allowTokenBucket(w, r, "shorten", config.GlobalAppConfig.AnonShortenLimitPerHour) {
+		return
+	}
 
-	redisExpirationDuration := time.Duration(config.DefaultExpirationDays) * 24 * time.Hour
-	isValidAuthCodeForCustomFeature := false
+	if req.NotifyEachClick && !isValidAuthCode(req.AuthCode) {
+		customlogger.FromContext(ctx).Info().Msg("Attempt to enable click notifications without a valid auth code")
+		writeJSONError(w, http.StatusUnauthorized, "A valid authorization code is required for notify_each_click.")
+		return
+	}
+
+	if req.Draft && !isValidAuthCode(req.AuthCode) {
+		customlogger.FromContext(ctx).Info().Msg("Attempt to create a draft link without a valid auth code")
+		writeJSONError(w, http.StatusUnauthorized, "A valid authorization code is required for draft links.")
+		return
+	}
 
 	if req.CustomHandle != "" {
 		if req.AuthCode == "" {
-			customlogger.Info().Str("custom_handle", req.CustomHandle).Msg("Attempt to use custom handle without auth code")
+			customlogger.FromContext(ctx).Info().Str("custom_handle", req.CustomHandle).Msg("Attempt to use custom handle without auth code")
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Authorization code required for custom handle."})
 			return
 		}
 
-		isValidAuthCode := false
-		for _, validCode := range config.GlobalAppConfig.ValidAuthCodes {
-			if req.AuthCode == validCode {
-				isValidAuthCode = true
-				break
-			}
-		}
-		if !isValidAuthCode {
-			customlogger.Info().Str("custom_handle", req.CustomHandle).Str("auth_code", req.AuthCode).Msg("Invalid auth code provided for custom handle")
+		if !isValidAuthCode(req.AuthCode) {
+			customlogger.FromContext(ctx).Info().Str("custom_handle", req.CustomHandle).Str("auth_code", req.AuthCode).Msg("Invalid auth code provided for custom handle")
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Invalid authorization code."})
 			return
 		}
-		isValidAuthCodeForCustomFeature = true
+	}
 
-		if len(req.CustomHandle) < 3 || len(req.CustomHandle) > 30 {
-			customlogger.Error().Str("custom_handle", req.CustomHandle).Msg("Invalid custom handle length")
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Custom handle must be between 3 and 30 characters."})
+	// Problems with the fields are collected and answered together, so a form can flag
+	// all of them at once.
+	var invalid fieldErrors
+
+	normalizedURL := NormalizeURL(req.LongURL)
+	var unwrapped *models.UnwrapInfo
+	if req.LongURL == "" {
+		invalid.add("long_url", "URL is required")
+	} else if err := validation.ValidateDestination(normalizedURL); err != nil {
+		customlogger.FromContext(ctx).Info().Err(err).Str("long_url", normalizedURL).Msg("Destination rejected by policy")
+		invalid.add("long_url", err.Error())
+	} else if normalizedURL, unwrapped, err = unwrapDestination(ctx, normalizedURL); err != nil {
+		customlogger.FromContext(ctx).Info().Err(err).Str("long_url", req.LongURL).Msg("Destination rejected after unwrapping")
+		invalid.add("long_url", err.Error())
+	}
+
+	if withUTM, err := withUTMParams(normalizedURL, req); err != nil {
+		invalid.add("utm", err.Error())
+	} else {
+		normalizedURL = withUTM
+	}
+
+	if req.CacheMaxAge != nil && (*req.CacheMaxAge < 0 || *req.CacheMaxAge > config.MaxRedirectCacheMaxAge) {
+		invalid.add("cache_max_age", fmt.Sprintf("cache_max_age must be between 0 and %d seconds.", config.MaxRedirectCacheMaxAge))
+	}
+
+	noAnalytics := req.Analytics != nil && !*req.Analytics
+	if noAnalytics && req.NotifyEachClick {
+		invalid.add("analytics", "notify_each_click cannot be combined with analytics: false, as it sends click data.")
+	}
+
+	if req.MaxClicks != nil && *req.MaxClicks < 1 {
+		invalid.add("max_clicks", "max_clicks must be at least 1.")
+	}
+
+	responseHeaders, err := validateResponseHeaders(req.ResponseHeaders)
+	if err != nil {
+		invalid.add("response_headers", err.Error())
+	}
+
+	req.Title = strings.TrimSpace(req.Title)
+	if len(req.Title) > maxLinkTitleLength {
+		invalid.add("title", fmt.Sprintf("Title must be at most %d characters.", maxLinkTitleLength))
+	}
+
+	deviceTargets, err := validateDeviceTargets(req.DeviceTargets)
+	if err != nil {
+		invalid.add("device_targets", err.Error())
+	}
+
+	variants, err := validateVariants(req.Variants)
+	if err != nil {
+		invalid.add("variants", err.Error())
+	}
+
+	redisExpirationDuration := time.Duration(config.DefaultExpirationDays) * 24 * time.Hour
+	if req.CustomHandle != "" {
+		req.CustomHandle = foldShortCode(req.CustomHandle)
+		if err := validation.ValidateHandle(req.CustomHandle); err != nil {
+			invalid.add("custom_handle", err.Error())
+		}
+
+		if req.ExpirationDays != nil {
+			days := *req.ExpirationDays
+			if days == config.NoExpirationValue {
+				redisExpirationDuration = 0
+			} else if days > 0 && days <= config.MaxExpirationDays {
+				redisExpirationDuration = time.Duration(days) * 24 * time.Hour
+			} else {
+				invalid.add("expiration_days", fmt.Sprintf("Expiration must be 0 (for no expiry) or between 1 and %d days.", config.MaxExpirationDays))
+			}
+		}
+	}
+
+	if len(invalid) > 0 {
+		customlogger.FromContext(ctx).Info().Strs("fields", invalid.fields()).Msg("Invalid request for CreateShortURL")
+		writeFieldErrors(w, invalid)
+		return
+	}
+
+	// Links to our own short links are stored resolved, so they are never deduplicated.
+	owner := linkOwner(req.AuthCode)
+	dedupe := dedupable(req, owner) && ownShortCode(normalizedURL) == ""
+	if dedupe {
+		if code, err := storage.DedupedLink(ctx, owner, normalizedURL); err == nil {
+			ctx = customlogger.WithContext(ctx, customlogger.ShortCodeField, code)
+			expiresAt, err := storage.LinkExpiry(ctx, code)
+			if err != nil {
+				customlogger.FromContext(ctx).Warn().Err(err).Msg("Failed to read TTL of existing URL")
+			}
+			customlogger.FromContext(ctx).Info().Str("long_url", normalizedURL).Msg("Returning existing short URL for duplicate")
+			writeShortenResponse(ctx, w, r, models.URLResponse{ShortURL: shortURLFor(code), ExpiresAt: expiresAt, Unwrapped: unwrapped, Existing: true})
 			return
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			// A failed lookup only costs a duplicate link.
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up existing short URL for duplicate")
 		}
+	}
 
-		ctx := r.Context()
-		exists, errDb := storage.Rdb.Exists(ctx, req.CustomHandle).Result()
+	var codeToUse string
+	if req.CustomHandle != "" {
+		exists, errDb := shortCodeTaken(ctx, req.CustomHandle)
 		if errDb != nil {
-			customlogger.Error().Err(errDb).Str("custom_handle", req.CustomHandle).Msg("Redis error checking custom handle availability")
+			customlogger.FromContext(ctx).Error().Err(errDb).Str("custom_handle", req.CustomHandle).Msg("Redis error checking custom handle availability")
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusInternalServerError)
 			json.NewEncoder(w).Encode(map[string]string{"error": "Error checking custom handle availability."})
 			return
 		}
-		if exists == 1 {
-			customlogger.Info().Str("custom_handle", req.CustomHandle).Msg("Custom handle already taken")
+		if exists {
+			customlogger.FromContext(ctx).Info().Str("custom_handle", req.CustomHandle).Msg("Custom handle already taken")
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusConflict)
 			json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Custom handle '%s' is already taken.", req.CustomHandle)})
 			return
 		}
+		if until, err := handleCooldownUntil(ctx, req.CustomHandle, req.AuthCode); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Str("custom_handle", req.CustomHandle).Msg("Failed to check custom handle cooldown")
+			writeJSONError(w, http.StatusInternalServerError, "Error checking custom handle availability.")
+			return
+		} else if !until.IsZero() {
+			customlogger.FromContext(ctx).Info().Str("custom_handle", req.CustomHandle).Time("available_at", until).Msg("Custom handle in cooldown after expiry")
+			writeJSONError(w, http.StatusConflict, handleCooldownMessage(req.CustomHandle, until))
+			return
+		}
 		codeToUse = req.CustomHandle
-		customlogger.Info().Str("custom_handle", codeToUse).Msg("Using user-provided custom handle")
+		customlogger.FromContext(ctx).Info().Str("custom_handle", codeToUse).Msg("Using user-provided custom handle")
+		if req.ExpirationDays != nil {
+			customlogger.FromContext(ctx).Info().Str("code", codeToUse).Dur("expiration", redisExpirationDuration).Msg("Setting custom URL expiration")
+		}
+	}
 
-		if isValidAuthCodeForCustomFeature && req.ExpirationDays != nil {
-			days := *req.ExpirationDays
-			if days == config.NoExpirationValue {
-				redisExpirationDuration = 0
-				customlogger.Info().Str("code", codeToUse).Msg("Setting custom URL with no expiration")
-			} else if days > 0 && days <= config.MaxExpirationDays {
-				redisExpirationDuration = time.Duration(days) * 24 * time.Hour
-				customlogger.Info().Str("code", codeToUse).Int("days", days).Msg("Setting custom URL with custom expiration")
-			} else {
-				customlogger.Error().Str("code", codeToUse).Int("days", days).Msg("Invalid expiration days provided")
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusBadRequest)
-				json.NewEncoder(w).Encode(map[string]string{"error": fmt.Sprintf("Expiration must be 0 (for no expiry) or between 1 and %d days.", config.MaxExpirationDays)})
-				return
-			}
+	submittedURL := normalizedURL
+	destinationFor := func(code string) (string, error) {
+		return internalDestination(ctx, code, submittedURL)
+	}
+	if codeToUse != "" {
+		normalizedURL, err = destinationFor(codeToUse)
+		if err == nil {
+			err = storage.CreateDestination(ctx, codeToUse, normalizedURL, redisExpirationDuration)
 		}
+	} else {
+		codeToUse, normalizedURL, err = storeGeneratedCode(ctx, redisExpirationDuration, destinationFor)
+	}
+	if isInternalLinkError(err) {
+		customlogger.FromContext(ctx).Info().Err(err).Str("long_url", req.LongURL).Msg("Destination points at our own short links")
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx = customlogger.WithContext(ctx, customlogger.ShortCodeField, codeToUse)
+	if errors.Is(err, storage.ErrConflict) && req.CustomHandle != "" {
+		// The handle was claimed between the availability check and the write.
+		customlogger.FromContext(ctx).Info().Str("custom_handle", codeToUse).Msg("Custom handle already taken")
+		writeJSONError(w, http.StatusConflict, fmt.Sprintf("Custom handle '%s' is already taken.", codeToUse))
+		return
+	} else if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store URL in Redis")
+		writeStorageError(w, err, "Error storing URL")
+		return
+	}
 
+	expiresAt, err := storage.LinkExpiry(ctx, codeToUse)
+	if err != nil {
+		customlogger.FromContext(ctx).Warn().Err(err).Msg("Failed to read TTL of stored URL")
+	}
+
+	link := models.Link{
+		ShortCode:       codeToUse,
+		LongURL:         normalizedURL,
+		Title:           req.Title,
+		Public:          req.Public,
+		CreatedAt:       time.Now().UTC(),
+		ExpiresAt:       expiresAt,
+		CacheMaxAge:     req.CacheMaxAge,
+		NotifyEachClick: req.NotifyEachClick,
+		Draft:           req.Draft,
+		Owner:           owner,
+		ResponseHeaders: responseHeaders,
+		HideReferrer:    req.HideReferrer,
+		NoAnalytics:     noAnalytics,
+		MaxClicks:       req.MaxClicks,
+		DeviceTargets:   deviceTargets,
+		Variants:        variants,
+		ForwardQuery:    req.ForwardQuery,
+		NoIndex:         req.NoIndex,
+	}
+	if unwrapped != nil && len(unwrapped.ViaShorteners) > 0 {
+		link.Tags = []string{viaShortenerTag}
+		customlogger.FromContext(ctx).Warn().Strs("shorteners", unwrapped.ViaShorteners).Msg("Shortened URL redirects through other shorteners")
+	}
+	claimToken := ""
+	if err := storage.CreateLink(ctx, link); err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to record link metadata")
+		if req.Draft {
+			// Without its metadata a draft would be live, so take it down again.
+			if err := storage.DeleteDestination(ctx, codeToUse); err != nil {
+				customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to remove destination of unrecorded draft")
+			}
+			writeJSONError(w, http.StatusInternalServerError, "Error storing URL")
+			return
+		}
 	} else {
-		codeToUse, err = Sid.Generate()
+		fetchPageMeta(ctx, codeToUse, normalizedURL)
+		if dedupe {
+			if err := storage.SaveDedupedLink(ctx, owner, normalizedURL, codeToUse); err != nil {
+				customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to index link for deduplication")
+			}
+		}
+		if link.Owner == "" {
+			// Anonymous creators can claim the link once they have an auth code or API key.
+			if claimToken, err = newClaimToken(ctx, codeToUse); err != nil {
+				customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to issue claim token for anonymous link")
+			}
+		}
+	}
+
+	var preview *models.LinkPreview
+	if req.Draft {
+		preview, err = newLinkPreview(ctx, codeToUse)
 		if err != nil {
-			customlogger.Error().Err(err).Msg("Failed to generate short code")
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Error generating short code"})
-			return
+			// The draft stays unreachable; a new token can be issued via the API.
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to issue preview token for draft link")
 		}
+	} else {
+		indexNewLink(link)
 	}
 
-	ctx := r.Context()
-	err = storage.Rdb.Set(ctx, codeToUse, normalizedURL, redisExpirationDuration).Err()
-	if err != nil {
-		customlogger.Error().Err(err).Str("code", codeToUse).Msg("Failed to store URL in Redis")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Error storing URL"})
-		return
+	shortURL := shortURLFor(codeToUse)
+	customlogger.FromContext(ctx).Info().Str("long_url", normalizedURL).Str("short_url", shortURL).Msg("URL shortened successfully")
+
+	resp := models.URLResponse{
+		ShortURL:   shortURL,
+		ExpiresAt:  expiresAt,
+		Preview:    preview,
+		Unwrapped:  unwrapped,
+		ClaimToken: claimToken,
 	}
+	writeShortenResponse(ctx, w, r, resp)
+}
 
-	shortURL := fmt.Sprintf("%s://%s/%s", config.GlobalAppConfig.Scheme, config.GlobalAppConfig.Domain, codeToUse)
-	customlogger.Info().Str("code", codeToUse).Str("long_url", normalizedURL).Str("short_url", shortURL).Msg("URL shortened successfully")
+// writeShortenResponse writes resp as the answer to /api/shorten, with the QR code of
+// the short URL when requested with ?include_qr=true.
+func writeShortenResponse(ctx context.Context, w http.ResponseWriter, r *http.Request, resp models.URLResponse) {
+	if r.URL.Query().Get("include_qr") == "true" {
+		// The link already exists, so a QR failure only drops the optional image.
+		if qr, err := qrCodePNGBase64(resp.ShortURL); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to render QR code for shorten response")
+		} else {
+			resp.QRPNGBase64 = qr
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.URLResponse{
-		ShortURL: shortURL,
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
 // RedirectToLongURL handles requests to a shortcode, retrieves the original long URL,
@@ -196,28 +433,143 @@ func RedirectToLongURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	longURL, err := storage.Rdb.Get(ctx, code).Result()
-	if err == redis.Nil {
-		customlogger.Error().Str("code", code).Msg("Short URL not found for redirection")
-		http.Error(w, "Short URL not found", http.StatusNotFound)
+	code, longURL, err := resolveShortCode(ctx, code)
+	if errors.Is(err, storage.ErrNotFound) {
+		if destination, ok := matchRedirectPattern(ctx, path, r.URL.RawQuery); ok {
+			w.Header().Set("Cache-Control", "no-store")
+			http.Redirect(w, r, destination, http.StatusFound)
+			return
+		}
+		customlogger.FromContext(ctx).Error().Msg("Short URL not found for redirection")
+		serveNotFound(w, r, "Short URL not found")
+		return
+	} else if errors.Is(err, storage.ErrExpired) {
+		customlogger.FromContext(ctx).Info().Msg("Short URL has expired")
+		http.Error(w, "Short URL has expired", storageErrorStatus(err))
 		return
 	} else if err != nil {
-		customlogger.Error().Err(err).Str("code", code).Msg("Failed to retrieve URL from Redis for redirection")
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to retrieve URL from Redis for redirection")
 		http.Error(w, "Error retrieving URL", http.StatusInternalServerError)
 		return
 	}
 
+	// Links disabled for abuse get the policy violation page instead
+	if serveTakedown(w, r, code) {
+		return
+	}
+
+	visitor := rules.RequestFromHTTP(r, config.GlobalAppConfig.CountryHeader)
+	decision := decideRedirect(ctx, code, longURL, visitor)
+	longURL = decision.Destination
+
+	if decision.Draft {
+		servePreview(w, r, code, longURL)
+		return
+	}
+	if decision.NoIndex {
+		w.Header().Set("X-Robots-Tag", noIndexRobots)
+	}
+
+	// Destinations changed after creation, or chosen by rules, may still lead back here.
+	if ownShortCode(longURL) != "" {
+		if _, _, err := followOwnLinks(ctx, code, longURL); errors.Is(err, errRedirectLoop) {
+			customlogger.FromContext(ctx).Warn().Err(err).Str("long_url", longURL).Msg("Redirect loop detected")
+			http.Error(w, "This short link redirects in a loop", http.StatusLoopDetected)
+			return
+		} else if err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to check short link chain, redirecting anyway")
+		}
+	}
+
+	// Link preview bots get the destination's Open Graph tags; their visits are not clicks.
+	if decision.PageMeta != nil && config.GlobalAppConfig.CrawlerPreviews {
+		w.Header().Add("Vary", "User-Agent")
+		if isSocialCrawler(r.UserAgent()) {
+			customlogger.FromContext(ctx).Info().Str("user_agent", r.UserAgent()).Msg("Serving crawler preview")
+			preview := newCrawlerPreview(code, longURL, decision.Title, *decision.PageMeta)
+			preview.NoFollow = decision.NoIndex
+			serveCrawlerPreview(w, r, preview)
+			return
+		}
+	}
+
+	if decision.MaxClicks > 0 && serveClickLimit(w, ctx, code, decision.MaxClicks) {
+		return
+	}
+
+	if decision.NoAnalytics {
+		// The link's creator opted out of click data; only the count is kept.
+		if err := storage.CountClick(ctx, code); err != nil {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to count click")
+		}
+	} else {
+		clickID := ""
+		if config.GlobalAppConfig.ConversionTracking {
+			clickID = newClickID()
+		}
+		recordClick(r, code, longURL, redirectVisitorID(w, r), clickID, decision, visitor)
+		if clickID != "" {
+			// Each visit gets its own click ID, so browsers must not cache the redirect.
+			decision.CacheMaxAge = 0
+			longURL = attachClickID(w, longURL, clickID)
+		}
+	}
+	countCampaignClick(ctx, decision.Tags)
+
+	if decision.ForwardQuery {
+		longURL = forwardQuery(longURL, r.URL.RawQuery)
+	}
+
+	customlogger.FromContext(ctx).Info().Str("long_url", longURL).Msg("Redirecting to long URL")
+	setRedirectResponseHeaders(w, decision.Headers)
+	if decision.HideReferrer {
+		serveDereferred(w, r, longURL, decision.CacheMaxAge, decision.NoIndex)
+		return
+	}
+	setRedirectCacheHeaders(w, decision.CacheMaxAge)
+	http.Redirect(w, r, longURL, http.StatusMovedPermanently)
+}
+
+// recordClick stores a click of visitorHash (see redirectVisitorID) on a redirect to
+// destination, under clickID if conversions are tracked, and, for links that want one,
+// sends its click webhook. Failures are logged and never block the redirect.
+func recordClick(r *http.Request, code, destination, visitorHash, clickID string, decision redirectDecision, visitor rules.Request) {
+	ctx := r.Context()
 	userAgent := r.UserAgent()
 	referrer := r.Referer()
+	// A malformed source tag is not worth failing the redirect over; the click counts as untagged.
+	source, _ := clickSource(r.URL.Query().Get(clickSourceParam))
 
-	insertSQL := `INSERT INTO clicks (short_code, user_agent, referrer) VALUES (?, ?, ?)`
-	_, errExec := storage.StatsDB.ExecContext(ctx, insertSQL, code, userAgent, referrer)
+	clickedAt := time.Now().UTC()
+	errExec := storage.RecordClick(ctx, models.ClickRecord{
+		ShortCode:   code,
+		UserAgent:   userAgent,
+		Referrer:    referrer,
+		Destination: destination,
+		Visitor:     visitorHash,
+		Source:      source,
+		Variant:     decision.Variant,
+		Country:     visitor.Country,
+		ClickID:     clickID,
+		At:          clickedAt,
+	})
 	if errExec != nil {
-		customlogger.Error().Err(errExec).Str("short_code", code).Msg("Failed to record click event")
+		customlogger.FromContext(ctx).Error().Err(errExec).Msg("Failed to record click event")
 	} else {
-		customlogger.Info().Str("short_code", code).Msg("Click event recorded")
+		customlogger.FromContext(ctx).Info().Msg("Click event recorded")
 	}
 
-	customlogger.Info().Str("code", code).Str("long_url", longURL).Msg("Redirecting to long URL")
-	http.Redirect(w, r, longURL, http.StatusMovedPermanently)
+	if decision.NotifyEachClick && ClickNotifier != nil {
+		ClickNotifier.Notify(models.ClickEvent{
+			ShortCode:   code,
+			Destination: destination,
+			Timestamp:   clickedAt,
+			UserAgent:   userAgent,
+			Referrer:    referrer,
+			Country:     visitor.Country,
+			Device:      visitor.Device,
+			Variant:     decision.Variant,
+			ClickID:     clickID,
+		})
+	}
 }
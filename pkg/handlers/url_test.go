@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+)
+
+func TestCreateShortURLReportsAllFieldErrors(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member-code"}
+
+	body := `{"long_url":"","custom_handle":"ab","expiration_days":99999,"max_clicks":0,"auth_code":"member-code"}`
+	rr := httptest.NewRecorder()
+	CreateShortURL(rr, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)))
+	require.Equal(t, http.StatusBadRequest, rr.Code)
+
+	var resp models.ValidationErrorResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	var fields []string
+	for _, fe := range resp.Errors {
+		fields = append(fields, fe.Field)
+		assert.NotEmpty(t, fe.Message, fe.Field)
+	}
+	assert.Equal(t, []string{"long_url", "max_clicks", "custom_handle", "expiration_days"}, fields)
+	assert.Equal(t, "URL is required", resp.Error, "the first problem stays in error for older clients")
+}
+
+func TestCreateShortURLRejectsInvalidMaxClicks(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member"}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten",
+		strings.NewReader(`{"long_url":"https://example.com","max_clicks":0}`))
+	req.Header.Set("Authorization", "Bearer member")
+	CreateShortURL(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "max_clicks")
+}
+
+func TestCreateShortURLRequiresAuth(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member-code"}
+	config.GlobalAppConfig.RequireAuthToShorten = true
+
+	for _, body := range []string{`{"long_url":"https://example.com"}`, `{"long_url":"https://example.com","auth_code":"wrong"}`} {
+		rr := httptest.NewRecorder()
+		CreateShortURL(rr, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, body)
+	}
+
+	// A valid code gets past the check, here to the validation of the empty URL.
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"long_url":""}`))
+	req.Header.Set("Authorization", "Bearer member-code")
+	CreateShortURL(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+}
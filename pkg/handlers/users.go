@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// userOwnerPrefix starts the owner of links created by a signed-in user, followed
+	// by the user's ID, so links stay attributed to the account across sessions.
+	userOwnerPrefix = "user:"
+	// minPasswordLength is the shortest password accepted at registration.
+	minPasswordLength = 8
+	// maxPasswordBytes is the longest password accepted; bcrypt ignores anything beyond.
+	maxPasswordBytes = 72
+	// maxEmailLength caps the length of an account's email address.
+	maxEmailLength = 254
+	// maxUserBodyBytes caps the size of a registration or login request.
+	maxUserBodyBytes = 4 << 10
+	// userAuthAttemptsPerMinute is how many registrations and logins a client may
+	// attempt per minute, to slow down password guessing.
+	userAuthAttemptsPerMinute = 10
+)
+
+// dummyPasswordHash is compared against on logins for unknown emails, so they take as
+// long as logins with a wrong password and do not reveal which emails are registered.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("riid.me dummy password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// issueSessionToken returns a session token for user, signed with JWT_SECRET and valid
+// for JWT_TTL_HOURS from now, and when it expires.
+func issueSessionToken(user models.User, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(time.Duration(config.GlobalAppConfig.JWTTTLHours) * time.Hour).UTC().Truncate(time.Second)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    config.GlobalAppConfig.Domain,
+		Subject:   strconv.FormatInt(user.ID, 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiresAt),
+	})
+	signed, err := token.SignedString([]byte(config.GlobalAppConfig.JWTSecret))
+	return signed, expiresAt, err
+}
+
+// sessionUserID returns the ID of the user a session token was issued to, if code is a
+// valid, unexpired one signed with JWT_SECRET or, while it is rotated,
+// JWT_PREVIOUS_SECRET. Tokens are verified by signature alone, without a database
+// lookup, since they are checked on every authorized request.
+func sessionUserID(code string) (int64, bool) {
+	if config.GlobalAppConfig.JWTSecret == "" || strings.Count(code, ".") != 2 {
+		return 0, false
+	}
+	var claims jwt.RegisteredClaims
+	_, err := jwt.ParseWithClaims(code, &claims, func(*jwt.Token) (interface{}, error) {
+		keys := jwt.VerificationKeySet{Keys: []jwt.VerificationKey{[]byte(config.GlobalAppConfig.JWTSecret)}}
+		// Tokens signed before JWT_SECRET was rotated stay valid until they expire.
+		if previous := config.GlobalAppConfig.JWTPreviousSecret; previous != "" {
+			keys.Keys = append(keys.Keys, []byte(previous))
+		}
+		return keys, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(config.GlobalAppConfig.Domain), jwt.WithExpirationRequired())
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// decodeUserCredentials reads and normalizes the credentials of a registration or
+// login request, writing the error response and returning false if they are unusable.
+func decodeUserCredentials(w http.ResponseWriter, r *http.Request) (models.UserCredentials, bool) {
+	if config.GlobalAppConfig.JWTSecret == "" {
+		writeJSONError(w, http.StatusNotFound, "User accounts are not enabled.")
+		return models.UserCredentials{}, false
+	}
+	if !allowRateLimited(w, r, "user-auth", userAuthAttemptsPerMinute) {
+		return models.UserCredentials{}, false
+	}
+	var creds models.UserCredentials
+	if err := decodeJSONOrYAML(r, maxUserBodyBytes, &creds); err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Invalid request body for user credentials")
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return models.UserCredentials{}, false
+	}
+	creds.Email = strings.ToLower(strings.TrimSpace(creds.Email))
+	if creds.Email == "" || creds.Password == "" {
+		writeJSONError(w, http.StatusBadRequest, "email and password are required")
+		return models.UserCredentials{}, false
+	}
+	return creds, true
+}
+
+// writeUserSession responds with a new session token for user.
+func writeUserSession(w http.ResponseWriter, r *http.Request, status int, user models.User) {
+	token, expiresAt, err := issueSessionToken(user, time.Now())
+	if err != nil {
+		customlogger.FromContext(r.Context()).Error().Err(err).Msg("Failed to sign session token")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	writeJSON(w, status, models.UserSessionResponse{Token: token, ExpiresAt: expiresAt, User: user})
+}
+
+// RegisterUserHandler creates a user account and signs it in. With
+// REQUIRE_AUTH_TO_SHORTEN set only admins and members may create accounts, as anyone
+// with a session may shorten.
+func RegisterUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	creds, ok := decodeUserCredentials(w, r)
+	if !ok {
+		return
+	}
+	if role := callerRole(r); config.GlobalAppConfig.RequireAuthToShorten && role != roleAdmin && role != roleMember {
+		customlogger.FromContext(ctx).Info().Msg("Attempt to register without a member auth code while authentication is required to shorten")
+		writeJSONError(w, http.StatusUnauthorized, "A member authorization code or API key is required to create accounts.")
+		return
+	}
+	if address, err := mail.ParseAddress(creds.Email); err != nil || address.Address != creds.Email || len(creds.Email) > maxEmailLength {
+		writeJSONError(w, http.StatusBadRequest, "email must be a valid email address")
+		return
+	}
+	if len(creds.Password) < minPasswordLength || len(creds.Password) > maxPasswordBytes {
+		writeJSONError(w, http.StatusBadRequest, "password must be between 8 and 72 bytes long")
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to hash password")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to create account")
+		return
+	}
+	user, err := storage.CreateUser(ctx, creds.Email, string(hash), time.Now())
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to store user")
+		}
+		writeStorageError(w, err, "Failed to create account")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Int64("user_id", user.ID).Msg("User registered")
+	writeUserSession(w, r, http.StatusCreated, user)
+}
+
+// LoginUserHandler signs a user in with their email and password.
+func LoginUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	creds, ok := decodeUserCredentials(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := storage.UserByEmail(ctx, creds.Email)
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up user")
+		writeJSONError(w, http.StatusInternalServerError, "Failed to sign in")
+		return
+	}
+	hash := []byte(user.PasswordHash)
+	if err != nil {
+		hash = dummyPasswordHash()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(creds.Password)) != nil || user.ID == 0 {
+		customlogger.FromContext(ctx).Warn().Msg("Failed login attempt")
+		writeJSONError(w, http.StatusUnauthorized, "Invalid email or password.")
+		return
+	}
+
+	customlogger.FromContext(ctx).Info().Int64("user_id", user.ID).Msg("User signed in")
+	writeUserSession(w, r, http.StatusOK, user)
+}
+
+// GetCurrentUserHandler returns the account of the session token the request carries.
+func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id, ok := sessionUserID(bearerToken(r))
+	if !ok {
+		writeJSONError(w, http.StatusUnauthorized, "Valid session token required.")
+		return
+	}
+	user, err := storage.UserByID(ctx, id)
+	if err != nil {
+		if storageErrorStatus(err) == http.StatusInternalServerError {
+			customlogger.FromContext(ctx).Error().Err(err).Msg("Failed to look up user")
+		}
+		writeStorageError(w, err, "Failed to look up account")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
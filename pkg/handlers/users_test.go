@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+func TestSessionToken(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.JWTSecret = strings.Repeat("s", config.MinJWTSecretLength)
+	config.GlobalAppConfig.JWTTTLHours = 1
+	config.GlobalAppConfig.Domain = "riid.me"
+
+	token, expiresAt, err := issueSessionToken(models.User{ID: 42}, time.Now())
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+
+	id, ok := sessionUserID(token)
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), id)
+	assert.True(t, isValidAuthCode(token))
+	assert.Equal(t, "user:42", linkOwner(token))
+
+	expired, _, err := issueSessionToken(models.User{ID: 42}, time.Now().Add(-2*time.Hour))
+	require.NoError(t, err)
+	_, ok = sessionUserID(expired)
+	assert.False(t, ok, "expired tokens are refused")
+
+	config.GlobalAppConfig.JWTSecret = strings.Repeat("x", config.MinJWTSecretLength)
+	_, ok = sessionUserID(token)
+	assert.False(t, ok, "tokens signed with another secret are refused")
+
+	config.GlobalAppConfig.JWTPreviousSecret = strings.Repeat("s", config.MinJWTSecretLength)
+	id, ok = sessionUserID(token)
+	assert.True(t, ok, "tokens signed with the previous secret are accepted while it is rotated")
+	assert.Equal(t, int64(42), id)
+	config.GlobalAppConfig.JWTPreviousSecret = ""
+
+	config.GlobalAppConfig.JWTSecret = ""
+	_, ok = sessionUserID(token)
+	assert.False(t, ok, "sessions are refused with accounts disabled")
+}
+
+func TestRegisterAndLogin(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	// Without a reachable Redis the login rate limit lets requests through.
+	originalRdb := storage.Rdb
+	storage.Rdb = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { storage.Rdb.Close(); storage.Rdb = originalRdb })
+
+	post := func(handler http.HandlerFunc, body string) (int, models.UserSessionResponse) {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodPost, "/api/auth", strings.NewReader(body)))
+		var resp models.UserSessionResponse
+		if rr.Code == http.StatusOK || rr.Code == http.StatusCreated {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		}
+		return rr.Code, resp
+	}
+
+	code, _ := post(RegisterUserHandler, `{"email":"ada@example.com","password":"correct horse"}`)
+	assert.Equal(t, http.StatusNotFound, code, "accounts are disabled without JWT_SECRET")
+
+	config.GlobalAppConfig.JWTSecret = strings.Repeat("s", config.MinJWTSecretLength)
+	config.GlobalAppConfig.JWTTTLHours = 24
+	for _, body := range []string{`{}`, `{"email":"not an email","password":"correct horse"}`, `{"email":"ada@example.com","password":"short"}`} {
+		code, _ := post(RegisterUserHandler, body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+
+	code, registered := post(RegisterUserHandler, `{"email":" Ada@Example.com ","password":"correct horse"}`)
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, "ada@example.com", registered.User.Email)
+	assert.True(t, isValidAuthCode(registered.Token))
+
+	code, _ = post(RegisterUserHandler, `{"email":"ada@example.com","password":"another one"}`)
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, _ = post(LoginUserHandler, `{"email":"ada@example.com","password":"wrong horse"}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _ = post(LoginUserHandler, `{"email":"bob@example.com","password":"correct horse"}`)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, session := post(LoginUserHandler, `{"email":"ada@example.com","password":"correct horse"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, registered.User.ID, session.User.ID)
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+	req.Header.Set("Authorization", "Bearer "+session.Token)
+	GetCurrentUserHandler(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"email":"ada@example.com"`)
+	assert.NotContains(t, rr.Body.String(), "password")
+}
+
+func TestRegisterRequiresMemberWhenAuthRequiredToShorten(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	originalRdb := storage.Rdb
+	storage.Rdb = redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { storage.Rdb.Close(); storage.Rdb = originalRdb })
+	config.GlobalAppConfig.JWTSecret = strings.Repeat("s", config.MinJWTSecretLength)
+	config.GlobalAppConfig.JWTTTLHours = 1
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member"}
+	config.GlobalAppConfig.RequireAuthToShorten = true
+
+	register := func(bearer, email string) int {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/register", strings.NewReader(`{"email":"`+email+`","password":"correct horse"}`))
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		RegisterUserHandler(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, register("", "eve@example.com"), "no self-registration while shortening requires authentication")
+	token, _, err := issueSessionToken(models.User{ID: 7}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, register(token, "eve@example.com"), "accounts cannot create further accounts")
+	assert.Equal(t, http.StatusCreated, register("member", "ada@example.com"))
+
+	config.GlobalAppConfig.RequireAuthToShorten = false
+	assert.Equal(t, http.StatusCreated, register("", "eve@example.com"))
+}
+
+func TestSessionUsersOnlySeeTheirOwnLinks(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.JWTSecret = strings.Repeat("s", config.MinJWTSecretLength)
+	config.GlobalAppConfig.JWTTTLHours = 1
+	config.GlobalAppConfig.ValidAuthCodes = []string{"member"}
+	config.GlobalAppConfig.AdminAuthCodes = []string{"admin"}
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+
+	// A freshly registered account.
+	token, _, err := issueSessionToken(models.User{ID: 7}, time.Now())
+	require.NoError(t, err)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "mine", LongURL: "https://example.com/mine", CreatedAt: now, Owner: linkOwner(token)}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "theirs", LongURL: "https://example.com/theirs", CreatedAt: now, Owner: linkOwner("member")}))
+	require.NoError(t, storage.SaveLink(ctx, models.Link{ShortCode: "legacy", LongURL: "https://example.com/legacy", CreatedAt: now}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/links", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	assert.Equal(t, roleUser, callerRole(req))
+
+	listed := func(token string) []string {
+		req := httptest.NewRequest(http.MethodGet, "/api/links", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		ListLinksHandler(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		var resp models.LinkListResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		var codes []string
+		for _, link := range resp.Links {
+			codes = append(codes, link.ShortCode)
+		}
+		return codes
+	}
+	assert.Equal(t, []string{"mine"}, listed(token))
+	assert.Equal(t, []string{"theirs"}, listed("member"))
+	assert.ElementsMatch(t, []string{"mine", "theirs", "legacy"}, listed("admin"))
+
+	// Comments and redirect debugging of other people's links are refused.
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/links/theirs/comments", nil), map[string]string{"shortcode": "theirs"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	ListLinkCommentsHandler(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/links/theirs/debug", nil), map[string]string{"shortcode": "theirs"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	DebugRedirectHandler(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	// As are their statistics and deleting them, including links without a known creator.
+	serveFakeRedis(t, map[string]string{"mine": "https://example.com/mine", "theirs": "https://example.com/theirs"}, nil)
+	for _, shortCode := range []string{"theirs", "legacy"} {
+		req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/stats/"+shortCode, nil), map[string]string{"shortcode": shortCode})
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr = httptest.NewRecorder()
+		GetLinkStatsHandler(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code, shortCode)
+	}
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/api/links/theirs", nil), map[string]string{"shortcode": "theirs"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	DeleteLinkHandler(rr, req)
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+
+	req = mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/api/stats/mine", nil), map[string]string{"shortcode": "mine"})
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr = httptest.NewRecorder()
+	GetLinkStatsHandler(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+}
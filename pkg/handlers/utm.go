@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"riid.me/pkg/models"
+)
+
+// maxUTMValueLength caps the length of each UTM parameter of a new link.
+const maxUTMValueLength = 200
+
+// withUTMParams adds the utm_source, utm_medium and utm_campaign fields of a shorten
+// request to destination's query, replacing parameters of the same name and leaving
+// the rest of the URL as it was given.
+func withUTMParams(destination string, req models.URLRequest) (string, error) {
+	params := []struct{ key, value string }{
+		{"utm_source", strings.TrimSpace(req.UTMSource)},
+		{"utm_medium", strings.TrimSpace(req.UTMMedium)},
+		{"utm_campaign", strings.TrimSpace(req.UTMCampaign)},
+	}
+	set := map[string]bool{}
+	var added []string
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		if len(param.value) > maxUTMValueLength {
+			return "", fmt.Errorf("%s must be at most %d characters", param.key, maxUTMValueLength)
+		}
+		set[param.key] = true
+		added = append(added, param.key+"="+url.QueryEscape(param.value))
+	}
+	if len(added) == 0 {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("cannot add UTM parameters to '%s': %w", destination, err)
+	}
+	var kept []string
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); pair != "" && (err != nil || !set[name]) {
+			kept = append(kept, pair)
+		}
+	}
+	u.RawQuery = strings.Join(append(kept, added...), "&")
+	return u.String(), nil
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestWithUTMParams(t *testing.T) {
+	got, err := withUTMParams("https://example.com/page?a=1#top", models.URLRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/page?a=1#top", got)
+
+	got, err = withUTMParams("https://example.com/page?a=1#top", models.URLRequest{UTMSource: "newsletter", UTMCampaign: "spring sale"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/page?a=1&utm_source=newsletter&utm_campaign=spring+sale#top", got)
+
+	// Parameters given replace those already in the URL; others are kept as they were.
+	got, err = withUTMParams("https://example.com/?utm_source=old&utm_medium=web&q=a%20b", models.URLRequest{UTMSource: "new"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/?utm_medium=web&q=a%20b&utm_source=new", got)
+
+	_, err = withUTMParams("https://example.com", models.URLRequest{UTMMedium: strings.Repeat("x", maxUTMValueLength+1)})
+	assert.ErrorContains(t, err, "utm_medium")
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+
+	"riid.me/pkg/models"
+	"riid.me/pkg/validation"
+)
+
+const (
+	// maxLinkVariants is the most destinations an A/B split may have.
+	maxLinkVariants = 10
+	// maxVariantNameLength caps the length of a variant name, which every click stores.
+	maxVariantNameLength = 64
+	// maxVariantWeight caps a variant's weight, keeping the sum of weights small.
+	maxVariantWeight = 10000
+)
+
+// variantPick picks a number in [0, total) to choose an A/B split variant by.
+// It is a variable so tests can make the split deterministic.
+var variantPick = func(total int) int { return rand.IntN(total) }
+
+// validateVariants normalizes the destinations of a link's A/B split and vets them
+// against the destination policy like its default destination. Unnamed variants are
+// named a, b, c and so on by position. It returns nil when no variant is given.
+func validateVariants(variants []models.LinkVariant) ([]models.LinkVariant, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	if len(variants) < 2 || len(variants) > maxLinkVariants {
+		return nil, fmt.Errorf("variants must list between 2 and %d destinations", maxLinkVariants)
+	}
+	normalized := make([]models.LinkVariant, len(variants))
+	seen := map[string]bool{}
+	for i, variant := range variants {
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" {
+			variant.Name = string(rune('a' + i))
+		}
+		if len(variant.Name) > maxVariantNameLength {
+			return nil, fmt.Errorf("variants[%d]: name must be at most %d characters", i, maxVariantNameLength)
+		}
+		if seen[variant.Name] {
+			return nil, fmt.Errorf("variants[%d]: name '%s' is used twice", i, variant.Name)
+		}
+		seen[variant.Name] = true
+		if variant.Weight < 1 || variant.Weight > maxVariantWeight {
+			return nil, fmt.Errorf("variants[%d]: weight must be between 1 and %d", i, maxVariantWeight)
+		}
+		if strings.TrimSpace(variant.Destination) == "" {
+			return nil, fmt.Errorf("variants[%d]: destination is required", i)
+		}
+		variant.Destination = NormalizeURL(variant.Destination)
+		if err := validation.ValidateDestination(variant.Destination); err != nil {
+			return nil, fmt.Errorf("variants[%d]: %w", i, err)
+		}
+		normalized[i] = variant
+	}
+	return normalized, nil
+}
+
+// pickVariant picks one of a link's variants, each with a probability of its weight
+// over the sum of all weights. It returns nil for a link without variants.
+func pickVariant(variants []models.LinkVariant) *models.LinkVariant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	n := variantPick(total)
+	for i := range variants {
+		if n < variants[i].Weight {
+			return &variants[i]
+		}
+		n -= variants[i].Weight
+	}
+	return &variants[len(variants)-1]
+}
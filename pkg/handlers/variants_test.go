@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+	"riid.me/pkg/storage"
+)
+
+func TestValidateVariants(t *testing.T) {
+	variants, err := validateVariants(nil)
+	require.NoError(t, err)
+	assert.Nil(t, variants)
+
+	variants, err = validateVariants([]models.LinkVariant{
+		{Destination: "example.com/a", Weight: 1},
+		{Name: " green ", Destination: "https://example.com/b", Weight: 3},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []models.LinkVariant{
+		{Name: "a", Destination: "https://example.com/a", Weight: 1},
+		{Name: "green", Destination: "https://example.com/b", Weight: 3},
+	}, variants)
+
+	_, err = validateVariants([]models.LinkVariant{{Destination: "https://example.com", Weight: 1}})
+	assert.ErrorContains(t, err, "between 2 and")
+
+	_, err = validateVariants([]models.LinkVariant{
+		{Name: "x", Destination: "https://example.com/a", Weight: 1},
+		{Name: "x", Destination: "https://example.com/b", Weight: 1},
+	})
+	assert.ErrorContains(t, err, "used twice")
+
+	_, err = validateVariants([]models.LinkVariant{
+		{Destination: "https://example.com/a", Weight: 1},
+		{Destination: "https://example.com/b"},
+	})
+	assert.ErrorContains(t, err, "variants[1]: weight")
+
+	_, err = validateVariants([]models.LinkVariant{
+		{Destination: "https://example.com/a", Weight: 1},
+		{Destination: "javascript:alert(1)", Weight: 1},
+	})
+	assert.ErrorContains(t, err, "variants[1]")
+}
+
+func TestDecideRedirectPicksVariantByWeight(t *testing.T) {
+	require.NoError(t, storage.InitSQLite(config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}))
+	t.Cleanup(func() { storage.StatsDB.Close() })
+	ctx := context.Background()
+
+	originalPick := variantPick
+	defer func() { variantPick = originalPick }()
+
+	require.NoError(t, storage.SaveLink(ctx, models.Link{
+		ShortCode: "ab", LongURL: "https://example.com", CreatedAt: time.Now().UTC(),
+		Variants: []models.LinkVariant{
+			{Name: "a", Destination: "https://example.com/a", Weight: 1},
+			{Name: "b", Destination: "https://example.com/b", Weight: 3},
+		},
+	}))
+
+	var total int
+	variantPick = func(n int) int { total = n; return 0 }
+	decision := decideRedirect(ctx, "ab", "https://example.com", rules.Request{})
+	assert.Equal(t, 4, total)
+	assert.Equal(t, "https://example.com/a", decision.Destination)
+	assert.Equal(t, "a", decision.Variant)
+	assert.Equal(t, 0, decision.CacheMaxAge)
+
+	variantPick = func(int) int { return 1 }
+	decision = decideRedirect(ctx, "ab", "https://example.com", rules.Request{})
+	assert.Equal(t, "https://example.com/b", decision.Destination)
+	assert.Equal(t, "b", decision.Variant)
+
+	// Visitors sent to the rollout see no variant.
+	originalBucket := rolloutBucket
+	defer func() { rolloutBucket = originalBucket }()
+	rolloutBucket = func() int { return 0 }
+	require.NoError(t, storage.SaveLinkRollout(ctx, "ab", models.LinkRollout{Destination: "https://new.example.com", Percent: 10}))
+	decision = decideRedirect(ctx, "ab", "https://example.com", rules.Request{})
+	assert.Equal(t, "https://new.example.com", decision.Destination)
+	assert.Empty(t, decision.Variant)
+}
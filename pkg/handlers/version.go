@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+
+	"riid.me/pkg/buildinfo"
+)
+
+// VersionHandler reports the version, commit and build date of the running binary,
+// so operators can tell which build is deployed where.
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildinfo.Get())
+}
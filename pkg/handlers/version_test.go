@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/buildinfo"
+)
+
+func TestVersionHandler(t *testing.T) {
+	originalVersion, originalCommit, originalDate := buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate
+	defer func() {
+		buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate = originalVersion, originalCommit, originalDate
+	}()
+	// As set with -ldflags "-X riid.me/pkg/buildinfo.Version=..." at build time.
+	buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate = "v1.2.0", "abc1234", "2024-03-01T10:00:00Z"
+
+	rr := httptest.NewRecorder()
+	VersionHandler(rr, httptest.NewRequest(http.MethodGet, "/api/version", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var info buildinfo.Info
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &info))
+	assert.Equal(t, buildinfo.Info{
+		Version: "v1.2.0", Commit: "abc1234", BuildDate: "2024-03-01T10:00:00Z", GoVersion: runtime.Version(),
+	}, info)
+}
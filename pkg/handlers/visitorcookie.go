@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"time"
+
+	"riid.me/pkg/config"
+)
+
+// visitorCookie is the first-party cookie identifying a visitor across links when
+// VISITOR_COOKIE is on.
+const visitorCookie = "riidme_vid"
+
+// visitorCookiePattern is what a visitor cookie issued by newClickID looks like.
+var visitorCookiePattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// privacyRequested reports whether the visitor asks not to be tracked, through Global
+// Privacy Control or Do Not Track.
+func privacyRequested(r *http.Request) bool {
+	return r.Header.Get("Sec-GPC") == "1" || r.Header.Get("DNT") == "1"
+}
+
+// redirectVisitorID returns the anonymized visitor ID a redirect's click is recorded
+// under. With VISITOR_COOKIE on, it derives from a random ID kept in a first-party
+// cookie, issued on the visitor's first redirect and renewed on each, so a visitor is
+// recognized across links and networks. Otherwise, and for visitors asking not to be
+// tracked, it is visitorID's hash of IP address and user agent.
+func redirectVisitorID(w http.ResponseWriter, r *http.Request) string {
+	if !config.GlobalAppConfig.VisitorCookie || privacyRequested(r) {
+		return visitorID(r)
+	}
+	id := newClickID()
+	if cookie, err := r.Cookie(visitorCookie); err == nil && visitorCookiePattern.MatchString(cookie.Value) {
+		id = cookie.Value
+	}
+	lifetime := time.Duration(config.GlobalAppConfig.VisitorCookieDays) * 24 * time.Hour
+	http.SetCookie(w, &http.Cookie{
+		Name:     visitorCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(lifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   config.GlobalAppConfig.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+	// The cookie itself is not stored, so the stats database cannot be used to forge it.
+	sum := sha256.Sum256([]byte("cookie|" + id))
+	return hex.EncodeToString(sum[:16])
+}
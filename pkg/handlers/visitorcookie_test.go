@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+)
+
+func TestRedirectVisitorID(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+	config.GlobalAppConfig.VisitorCookieDays = 30
+
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest("GET", "/abc", nil)
+		r.RemoteAddr = "203.0.113.7:1234"
+		r.Header.Set("User-Agent", "test")
+		return r
+	}
+
+	// Off by default: the IP address and user agent hash, no cookie.
+	r := newRequest()
+	w := httptest.NewRecorder()
+	assert.Equal(t, visitorID(r), redirectVisitorID(w, r))
+	assert.Empty(t, w.Result().Cookies())
+
+	config.GlobalAppConfig.VisitorCookie = true
+	w = httptest.NewRecorder()
+	first := redirectVisitorID(w, newRequest())
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, visitorCookie, cookies[0].Name)
+	assert.Regexp(t, visitorCookiePattern, cookies[0].Value)
+	assert.Equal(t, 30*24*60*60, cookies[0].MaxAge)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.NotEqual(t, cookies[0].Value, first, "the raw cookie is not stored")
+
+	// The same visitor is recognized from another network, and their cookie renewed.
+	r = newRequest()
+	r.RemoteAddr = "198.51.100.9:4321"
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	assert.Equal(t, first, redirectVisitorID(w, r))
+	require.Len(t, w.Result().Cookies(), 1)
+	assert.Equal(t, cookies[0].Value, w.Result().Cookies()[0].Value)
+
+	// A malformed cookie is replaced.
+	r = newRequest()
+	r.AddCookie(&http.Cookie{Name: visitorCookie, Value: "forged"})
+	w = httptest.NewRecorder()
+	assert.NotEqual(t, first, redirectVisitorID(w, r))
+	assert.NotEqual(t, "forged", w.Result().Cookies()[0].Value)
+
+	// Visitors asking not to be tracked get no cookie.
+	for _, header := range []string{"Sec-GPC", "DNT"} {
+		r = newRequest()
+		r.Header.Set(header, "1")
+		w = httptest.NewRecorder()
+		assert.Equal(t, visitorID(r), redirectVisitorID(w, r), header)
+		assert.Empty(t, w.Result().Cookies(), header)
+	}
+}
@@ -0,0 +1,191 @@
+// Package hostrep checks link destinations against blocklists of malicious hosts, such
+// as URLhaus or hosts-file style phishing lists, which are downloaded and refreshed
+// periodically.
+package hostrep
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+)
+
+// fetchTimeout bounds the download of a single blocklist source.
+const fetchTimeout = 30 * time.Second
+
+// Blocklist is a set of blocked hosts loaded from one or more sources. Lookups are
+// safe for concurrent use while the list is refreshed. It is a
+// validation.DestinationValidator, so links to listed hosts cannot be created.
+type Blocklist struct {
+	Sources []string
+	Client  *http.Client
+	// OnUpdate, when set, is called by Run after a refresh changed the list, e.g. to
+	// recheck existing links.
+	OnUpdate func(ctx context.Context)
+
+	mu        sync.RWMutex
+	hosts     map[string]bool
+	updatedAt time.Time
+}
+
+// NewBlocklist returns an empty Blocklist loading from sources, each an http(s) URL
+// or a local file path. Call Refresh to load it.
+func NewBlocklist(sources []string) *Blocklist {
+	return &Blocklist{
+		Sources: sources,
+		Client:  &http.Client{Timeout: fetchTimeout},
+		hosts:   map[string]bool{},
+	}
+}
+
+// Parse reads blocked hosts, one entry per line, where "#" starts a comment. An entry
+// is a host name, a URL (whose host is taken), or a hosts-file line such as
+// "0.0.0.0 evil.example", which lists one or more hosts after an address.
+func Parse(r io.Reader) ([]string, error) {
+	var hosts []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if comment := strings.IndexByte(text, '#'); comment >= 0 {
+			text = text[:comment]
+		}
+		fields := strings.Fields(text)
+		if len(fields) > 1 {
+			if _, err := netip.ParseAddr(fields[0]); err == nil {
+				fields = fields[1:]
+			}
+		}
+		for _, field := range fields {
+			if strings.Contains(field, "://") {
+				u, err := url.Parse(field)
+				if err != nil {
+					continue
+				}
+				field = u.Hostname()
+			}
+			if host := normalizeHost(field); host != "" && host != "localhost" {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	return hosts, scanner.Err()
+}
+
+// normalizeHost lowercases host and strips a trailing dot.
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// Refresh reloads the list from all sources and reports whether it changed. If any
+// source fails, the previous list is kept and the error is returned.
+func (b *Blocklist) Refresh(ctx context.Context) (bool, error) {
+	hosts := map[string]bool{}
+	for _, source := range b.Sources {
+		loaded, err := b.load(ctx, source)
+		if err != nil {
+			return false, fmt.Errorf("blocklist %s: %w", source, err)
+		}
+		for _, host := range loaded {
+			hosts[host] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	changed := !maps.Equal(b.hosts, hosts)
+	b.hosts = hosts
+	b.updatedAt = time.Now().UTC()
+	return changed, nil
+}
+
+// load reads the hosts of a single source.
+func (b *Blocklist) load(ctx context.Context, source string) ([]string, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return Parse(f)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download responded with status %d", resp.StatusCode)
+	}
+	return Parse(resp.Body)
+}
+
+// Contains reports whether host or any domain it belongs to is blocked.
+func (b *Blocklist) Contains(host string) bool {
+	host = normalizeHost(host)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for host != "" {
+		if b.hosts[host] {
+			return true
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			break
+		}
+		host = parent
+	}
+	return false
+}
+
+// ValidateDestination implements validation.DestinationValidator.
+func (b *Blocklist) ValidateDestination(destination *url.URL) error {
+	if b.Contains(destination.Hostname()) {
+		return fmt.Errorf("Destination host '%s' is on a blocklist of malicious sites.", normalizeHost(destination.Hostname()))
+	}
+	return nil
+}
+
+// Status returns how many hosts the list holds and when it was last loaded.
+func (b *Blocklist) Status() (hosts int, updatedAt time.Time) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.hosts), b.updatedAt
+}
+
+// Run refreshes the list every interval until ctx is cancelled, calling OnUpdate
+// whenever the list changed. The first refresh happens immediately.
+func (b *Blocklist) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if changed, err := b.Refresh(ctx); err != nil {
+			customlogger.Error().Err(err).Msg("Failed to refresh host blocklist")
+		} else {
+			hosts, _ := b.Status()
+			customlogger.Info().Int("hosts", hosts).Bool("changed", changed).Msg("Host blocklist refreshed")
+			if changed && b.OnUpdate != nil {
+				b.OnUpdate(ctx)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
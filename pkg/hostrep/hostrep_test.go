@@ -0,0 +1,59 @@
+package hostrep
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const hostList = `# URLhaus / hosts-file style blocklist
+127.0.0.1 localhost
+0.0.0.0 phish.example bank-login.example # two hosts on one line
+http://203.0.113.9/payload.exe
+https://Malware.Example./dropper
+evil.example
+`
+
+func TestParse(t *testing.T) {
+	hosts, err := Parse(strings.NewReader(hostList))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"phish.example", "bank-login.example", "203.0.113.9", "malware.example", "evil.example"}, hosts)
+}
+
+func TestBlocklistRefreshAndContains(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.txt")
+	require.NoError(t, os.WriteFile(path, []byte(hostList), 0o600))
+	list := NewBlocklist([]string{path})
+	ctx := context.Background()
+
+	changed, err := list.Refresh(ctx)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, list.Contains("evil.example"))
+	assert.True(t, list.Contains("cdn.EVIL.example."), "subdomains of listed hosts are blocked")
+	assert.False(t, list.Contains("notevil.example"))
+	assert.False(t, list.Contains("example"))
+	hosts, _ := list.Status()
+	assert.Equal(t, 5, hosts)
+
+	changed, err = list.Refresh(ctx)
+	require.NoError(t, err)
+	assert.False(t, changed, "an unchanged download is not an update")
+
+	destination, _ := url.Parse("https://login.phish.example/account")
+	assert.Error(t, list.ValidateDestination(destination))
+	destination, _ = url.Parse("https://example.com/")
+	assert.NoError(t, list.ValidateDestination(destination))
+
+	// A failing source keeps the previous list.
+	list.Sources = append(list.Sources, filepath.Join(t.TempDir(), "missing.txt"))
+	_, err = list.Refresh(ctx)
+	assert.Error(t, err)
+	assert.True(t, list.Contains("evil.example"))
+}
@@ -0,0 +1,48 @@
+// Package instances registers a running riid.me instance in a Redis-backed registry
+// with periodic heartbeats, so operators can see which instances are live, whether
+// they are healthy and which version they run, without external service discovery.
+package instances
+
+import (
+	"context"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+)
+
+// MissedHeartbeats is how many heartbeats an instance may miss before it is no
+// longer listed.
+const MissedHeartbeats = 3
+
+// heartbeat returns instance as of a heartbeat at now, with the result of check.
+func heartbeat(ctx context.Context, instance models.Instance, now time.Time, check func(context.Context) error) models.Instance {
+	instance.LastSeen = now.UTC().Truncate(time.Second)
+	instance.Healthy, instance.Error = true, ""
+	if err := check(ctx); err != nil {
+		instance.Healthy, instance.Error = false, err.Error()
+	}
+	return instance
+}
+
+// Run registers instance and renews its registration every interval until ctx is done,
+// recording the result of check with each heartbeat. An instance that cannot reach
+// Redis stops renewing and drops out of the registry after MissedHeartbeats intervals.
+func Run(ctx context.Context, instance models.Instance, interval time.Duration, check func(context.Context) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		beat := heartbeat(ctx, instance, time.Now(), check)
+		if err := storage.SaveInstance(ctx, beat); err != nil {
+			customlogger.Error().Err(err).Str("instance", instance.ID).Msg("Failed to send instance heartbeat")
+		} else if !beat.Healthy {
+			customlogger.Warn().Str("instance", instance.ID).Str("error", beat.Error).Msg("Instance registered as unhealthy")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
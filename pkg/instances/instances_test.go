@@ -0,0 +1,26 @@
+package instances
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/models"
+)
+
+func TestHeartbeat(t *testing.T) {
+	instance := models.Instance{ID: "web-1:8080", Version: "v1.2.0"}
+	now := time.Date(2026, 3, 1, 10, 0, 0, 500, time.UTC)
+
+	beat := heartbeat(context.Background(), instance, now, func(context.Context) error { return nil })
+	assert.True(t, beat.Healthy)
+	assert.Empty(t, beat.Error)
+	assert.Equal(t, now.Truncate(time.Second), beat.LastSeen)
+	assert.Equal(t, "v1.2.0", beat.Version)
+
+	beat = heartbeat(context.Background(), beat, now, func(context.Context) error { return errors.New("database is locked") })
+	assert.False(t, beat.Healthy)
+	assert.Equal(t, "database is locked", beat.Error)
+}
@@ -0,0 +1,173 @@
+// Package iprep checks client IP addresses against blocklists of networks with a bad
+// reputation, such as Spamhaus DROP, which are downloaded and refreshed periodically.
+package iprep
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+)
+
+// fetchTimeout bounds the download of a single blocklist source.
+const fetchTimeout = 30 * time.Second
+
+// Blocklist is a set of blocked networks loaded from one or more sources. Lookups are
+// safe for concurrent use while the list is refreshed.
+type Blocklist struct {
+	Sources []string
+	Client  *http.Client
+
+	mu        sync.RWMutex
+	prefixes  map[int]map[netip.Prefix]bool // keyed by prefix length, for exact lookups
+	ranges    int
+	updatedAt time.Time
+}
+
+// NewBlocklist returns an empty Blocklist loading from sources, each an http(s) URL
+// or a local file path. Call Refresh to load it.
+func NewBlocklist(sources []string) *Blocklist {
+	return &Blocklist{
+		Sources:  sources,
+		Client:   &http.Client{Timeout: fetchTimeout},
+		prefixes: map[int]map[netip.Prefix]bool{},
+	}
+}
+
+// Parse reads networks in CIDR notation, one per line. It accepts the plain text
+// DROP format, where ";" starts a comment, and the JSON lines format with a "cidr"
+// field; lines without a network, such as metadata records, are skipped.
+func Parse(r io.Reader) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(text, "{") {
+			var record struct {
+				CIDR string `json:"cidr"`
+			}
+			if err := json.Unmarshal([]byte(text), &record); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			text = record.CIDR
+		} else if comment := strings.IndexAny(text, ";#"); comment >= 0 {
+			text = strings.TrimSpace(text[:comment])
+		}
+		if text == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, scanner.Err()
+}
+
+// Refresh reloads the list from all sources. If any source fails, the previous list
+// is kept and the error is returned.
+func (b *Blocklist) Refresh(ctx context.Context) error {
+	prefixes := map[int]map[netip.Prefix]bool{}
+	ranges := 0
+	for _, source := range b.Sources {
+		loaded, err := b.load(ctx, source)
+		if err != nil {
+			return fmt.Errorf("blocklist %s: %w", source, err)
+		}
+		for _, prefix := range loaded {
+			if prefixes[prefix.Bits()] == nil {
+				prefixes[prefix.Bits()] = map[netip.Prefix]bool{}
+			}
+			if !prefixes[prefix.Bits()][prefix] {
+				prefixes[prefix.Bits()][prefix] = true
+				ranges++
+			}
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.prefixes = prefixes
+	b.ranges = ranges
+	b.updatedAt = time.Now().UTC()
+	return nil
+}
+
+// load reads the networks of a single source.
+func (b *Blocklist) load(ctx context.Context, source string) ([]netip.Prefix, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return Parse(f)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download responded with status %d", resp.StatusCode)
+	}
+	return Parse(resp.Body)
+}
+
+// Contains reports whether addr lies in a blocked network.
+func (b *Blocklist) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for bits, set := range b.prefixes {
+		if bits > addr.BitLen() {
+			continue
+		}
+		if prefix, err := addr.Prefix(bits); err == nil && set[prefix] {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns how many networks the list holds and when it was last loaded.
+func (b *Blocklist) Status() (ranges int, updatedAt time.Time) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.ranges, b.updatedAt
+}
+
+// Run refreshes the list every interval until ctx is cancelled. The first refresh
+// happens immediately.
+func (b *Blocklist) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := b.Refresh(ctx); err != nil {
+			customlogger.Error().Err(err).Msg("Failed to refresh IP blocklist")
+		} else {
+			ranges, _ := b.Status()
+			customlogger.Info().Int("ranges", ranges).Msg("IP blocklist refreshed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package iprep
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dropList = `; Spamhaus DROP List 2026/10/16 - (c) 2026 The Spamhaus Project
+; Last-Modified: Fri, 16 Oct 2026 08:00:00 GMT
+1.10.16.0/20 ; SBL256894
+2.56.192.0/22 ; SBL459831
+`
+
+func TestParse(t *testing.T) {
+	prefixes, err := Parse(strings.NewReader(dropList))
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.10.16.0/20"), netip.MustParsePrefix("2.56.192.0/22")}, prefixes)
+
+	prefixes, err = Parse(strings.NewReader(`{"cidr":"2001:db8::/32","sblid":"SBL1","rir":"ripencc"}
+{"type":"metadata","timestamp":1760601600}
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("2001:db8::/32")}, prefixes)
+
+	_, err = Parse(strings.NewReader("not-a-network\n"))
+	assert.Error(t, err)
+}
+
+func TestBlocklistRefreshAndContains(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(dropList))
+	}))
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "extra.txt")
+	require.NoError(t, os.WriteFile(file, []byte("2001:db8::/32\n1.10.16.0/20\n"), 0o644))
+
+	list := NewBlocklist([]string{server.URL, file})
+	assert.False(t, list.Contains(netip.MustParseAddr("1.10.20.1")), "an unloaded list blocks nothing")
+	require.NoError(t, list.Refresh(context.Background()))
+
+	ranges, updatedAt := list.Status()
+	assert.Equal(t, 3, ranges, "duplicate networks are counted once")
+	assert.False(t, updatedAt.IsZero())
+	for addr, want := range map[string]bool{
+		"1.10.20.1":         true,
+		"1.10.32.1":         false,
+		"2.56.195.255":      true,
+		"::ffff:2.56.192.1": true,
+		"2001:db8:1::1":     true,
+		"2001:db9::1":       false,
+		"8.8.8.8":           false,
+	} {
+		assert.Equal(t, want, list.Contains(netip.MustParseAddr(addr)), addr)
+	}
+
+	list.Sources = append(list.Sources, filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, list.Refresh(context.Background()))
+	ranges, _ = list.Status()
+	assert.Equal(t, 3, ranges, "a failed refresh keeps the previous list")
+}
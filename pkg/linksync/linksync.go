@@ -0,0 +1,233 @@
+// Package linksync reconciles live links with a declarative list of desired links,
+// so a set of evergreen links can be kept in version control and applied by
+// automation. Links created by sync are marked managed; only managed links are ever
+// updated or deleted, and codes already used by other links are reported as conflicts.
+package linksync
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+const (
+	// MaxLinks caps the number of links in one sync request.
+	MaxLinks = 5000
+	// maxTags caps the number of tags on a synced link.
+	maxTags = 20
+	// maxTagLength caps the length of a single tag.
+	maxTagLength = 50
+	// maxTitleLength matches the limit on titles of links created through the API.
+	maxTitleLength = 200
+)
+
+// Validate checks a sync request and normalizes it in place: tags are trimmed and
+// expiry times converted to whole seconds in UTC. now is used to reject expiry times
+// that have already passed.
+func Validate(req *models.SyncRequest, now time.Time) error {
+	if len(req.Links) > MaxLinks {
+		return fmt.Errorf("at most %d links can be synced at once", MaxLinks)
+	}
+	seen := make(map[string]bool, len(req.Links))
+	for i := range req.Links {
+		l := &req.Links[i]
+		if seen[l.Code] {
+			return fmt.Errorf("link %q: duplicate code", l.Code)
+		}
+		seen[l.Code] = true
+		if err := validation.ValidateHandle(l.Code); err != nil {
+			return fmt.Errorf("link %q: %w", l.Code, err)
+		}
+
+		u, err := url.Parse(l.Destination)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("link %q: destination must be an absolute http or https URL", l.Code)
+		}
+		if err := validation.ValidateDestination(l.Destination); err != nil {
+			return fmt.Errorf("link %q: %w", l.Code, err)
+		}
+
+		l.Title = strings.TrimSpace(l.Title)
+		if len(l.Title) > maxTitleLength {
+			return fmt.Errorf("link %q: title must be at most %d characters", l.Code, maxTitleLength)
+		}
+
+		if len(l.Tags) > maxTags {
+			return fmt.Errorf("link %q: at most %d tags are allowed", l.Code, maxTags)
+		}
+		for j, tag := range l.Tags {
+			tag = strings.TrimSpace(tag)
+			if tag == "" || len(tag) > maxTagLength {
+				return fmt.Errorf("link %q: tags must be between 1 and %d characters", l.Code, maxTagLength)
+			}
+			l.Tags[j] = tag
+		}
+
+		if l.ExpiresAt != nil {
+			t := l.ExpiresAt.UTC().Truncate(time.Second)
+			if !t.After(now) {
+				return fmt.Errorf("link %q: expires_at must be in the future", l.Code)
+			}
+			l.ExpiresAt = &t
+		}
+	}
+	return nil
+}
+
+// Plan compares a validated sync request with the live managed links and returns
+// the changes needed to reconcile them.
+func Plan(ctx context.Context, req models.SyncRequest, now time.Time) (models.SyncPlan, error) {
+	managed, err := storage.ManagedLinks(ctx)
+	if err != nil {
+		return models.SyncPlan{}, err
+	}
+
+	inUse := make(map[string]bool)
+	check := func(code string) error {
+		if _, ok := inUse[code]; ok {
+			return nil
+		}
+		exists, err := storage.LinkExists(ctx, code)
+		inUse[code] = exists
+		return err
+	}
+	for _, l := range req.Links {
+		if err := check(l.Code); err != nil {
+			return models.SyncPlan{}, err
+		}
+	}
+	for _, l := range managed {
+		if err := check(l.ShortCode); err != nil {
+			return models.SyncPlan{}, err
+		}
+	}
+
+	return diff(req.Links, managed, inUse, now), nil
+}
+
+// diff computes a sync plan from the desired links, the managed links currently on
+// record, and which codes currently have a live destination.
+func diff(desired []models.SyncLink, managed []models.Link, inUse map[string]bool, now time.Time) models.SyncPlan {
+	plan := models.SyncPlan{Changes: []models.SyncChange{}}
+	byCode := make(map[string]models.Link, len(managed))
+	for _, l := range managed {
+		byCode[l.ShortCode] = l
+	}
+
+	wanted := make(map[string]bool, len(desired))
+	for _, d := range desired {
+		wanted[d.Code] = true
+		current, isManaged := byCode[d.Code]
+		if !isManaged {
+			if inUse[d.Code] {
+				plan.Conflicts = append(plan.Conflicts, models.SyncConflict{
+					Code:   d.Code,
+					Reason: "code is in use by a link not managed by sync",
+				})
+				continue
+			}
+			after := desiredLink(d, models.Link{CreatedAt: now})
+			plan.Changes = append(plan.Changes, models.SyncChange{Action: models.SyncActionCreate, Code: d.Code, After: &after})
+			continue
+		}
+
+		after := desiredLink(d, current)
+		if inUse[d.Code] && sameLink(current, after) {
+			plan.Unchanged++
+			continue
+		}
+		if current.Frozen {
+			plan.Conflicts = append(plan.Conflicts, models.SyncConflict{Code: d.Code, Reason: "link is frozen"})
+			continue
+		}
+		before := current
+		plan.Changes = append(plan.Changes, models.SyncChange{Action: models.SyncActionUpdate, Code: d.Code, Before: &before, After: &after})
+	}
+
+	for _, l := range managed {
+		if wanted[l.ShortCode] {
+			continue
+		}
+		if l.Frozen {
+			plan.Conflicts = append(plan.Conflicts, models.SyncConflict{Code: l.ShortCode, Reason: "link is frozen"})
+			continue
+		}
+		before := l
+		plan.Changes = append(plan.Changes, models.SyncChange{Action: models.SyncActionDelete, Code: l.ShortCode, Before: &before})
+	}
+	return plan
+}
+
+// desiredLink builds the link record for a desired link, keeping the creation time
+// and settings sync does not manage from base.
+func desiredLink(d models.SyncLink, base models.Link) models.Link {
+	link := base
+	link.ShortCode = d.Code
+	link.LongURL = d.Destination
+	link.Title = d.Title
+	link.Tags = d.Tags
+	link.ExpiresAt = d.ExpiresAt
+	link.Managed = true
+	return link
+}
+
+// sameLink reports whether two links agree on every field sync manages.
+func sameLink(a, b models.Link) bool {
+	if a.LongURL != b.LongURL || a.Title != b.Title {
+		return false
+	}
+	if len(a.Tags) != len(b.Tags) || (len(a.Tags) > 0 && !reflect.DeepEqual(a.Tags, b.Tags)) {
+		return false
+	}
+	switch {
+	case a.ExpiresAt == nil || b.ExpiresAt == nil:
+		return a.ExpiresAt == nil && b.ExpiresAt == nil
+	default:
+		return a.ExpiresAt.Truncate(time.Second).Equal(b.ExpiresAt.Truncate(time.Second))
+	}
+}
+
+// Apply performs the changes of a plan in order. It refuses plans with conflicts.
+// Each change writes the destination first and the metadata record, with its outbox
+// event, second; a failure stops the run and the next sync resumes from there.
+func Apply(ctx context.Context, plan models.SyncPlan, now time.Time) error {
+	if len(plan.Conflicts) > 0 {
+		return fmt.Errorf("plan has %d conflicts: %w", len(plan.Conflicts), storage.ErrConflict)
+	}
+	for _, change := range plan.Changes {
+		var err error
+		switch change.Action {
+		case models.SyncActionCreate:
+			if err = storage.CreateDestination(ctx, change.Code, change.After.LongURL, ttl(change.After, now)); err == nil {
+				err = storage.CreateLink(ctx, *change.After)
+			}
+		case models.SyncActionUpdate:
+			if err = storage.SetDestination(ctx, change.Code, change.After.LongURL, ttl(change.After, now)); err == nil {
+				err = storage.UpdateLink(ctx, *change.After)
+			}
+		case models.SyncActionDelete:
+			if err = storage.DeleteDestination(ctx, change.Code); err == nil {
+				err = storage.DeleteLink(ctx, *change.Before)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s %q: %w", change.Action, change.Code, err)
+		}
+	}
+	return nil
+}
+
+// ttl returns the Redis TTL for a link, 0 for links that never expire.
+func ttl(link *models.Link, now time.Time) time.Duration {
+	if link.ExpiresAt == nil {
+		return 0
+	}
+	return link.ExpiresAt.Sub(now)
+}
@@ -0,0 +1,110 @@
+package linksync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	future := now.Add(48*time.Hour + 500*time.Millisecond)
+	past := now.Add(-time.Hour)
+
+	req := models.SyncRequest{Links: []models.SyncLink{
+		{Code: "docs", Destination: "https://docs.example.com", Tags: []string{" docs "}, ExpiresAt: &future},
+	}}
+	require.NoError(t, Validate(&req, now))
+	assert.Equal(t, []string{"docs"}, req.Links[0].Tags)
+	assert.Equal(t, now.Add(48*time.Hour), *req.Links[0].ExpiresAt)
+
+	invalid := []models.SyncLink{
+		{Code: "ab", Destination: "https://example.com"},
+		{Code: "docs", Destination: "example.com"},
+		{Code: "docs", Destination: "ftp://example.com"},
+		{Code: "docs", Destination: "https://example.com", ExpiresAt: &past},
+		{Code: "docs", Destination: "https://example.com", Tags: []string{" "}},
+	}
+	for _, l := range invalid {
+		req := models.SyncRequest{Links: []models.SyncLink{l}}
+		assert.Error(t, Validate(&req, now), "%+v", l)
+	}
+
+	dup := models.SyncRequest{Links: []models.SyncLink{
+		{Code: "docs", Destination: "https://example.com"},
+		{Code: "docs", Destination: "https://example.org"},
+	}}
+	assert.Error(t, Validate(&dup, now))
+}
+
+func TestDiff(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	created := now.Add(-24 * time.Hour)
+	managed := []models.Link{
+		{ShortCode: "same", LongURL: "https://example.com/same", Tags: []string{}, Managed: true, CreatedAt: created},
+		{ShortCode: "moved", LongURL: "https://example.com/old", Tags: []string{}, Managed: true, CreatedAt: created},
+		{ShortCode: "lapsed", LongURL: "https://example.com/lapsed", Tags: []string{}, Managed: true, CreatedAt: created},
+		{ShortCode: "gone", LongURL: "https://example.com/gone", Tags: []string{}, Managed: true, CreatedAt: created},
+	}
+	inUse := map[string]bool{"same": true, "moved": true, "gone": true, "taken": true}
+	desired := []models.SyncLink{
+		{Code: "same", Destination: "https://example.com/same"},
+		{Code: "moved", Destination: "https://example.com/new", Tags: []string{"docs"}},
+		{Code: "lapsed", Destination: "https://example.com/lapsed"},
+		{Code: "fresh", Destination: "https://example.com/fresh"},
+		{Code: "taken", Destination: "https://example.com/taken"},
+	}
+
+	plan := diff(desired, managed, inUse, now)
+
+	assert.Equal(t, 1, plan.Unchanged)
+	assert.Equal(t, []models.SyncConflict{{Code: "taken", Reason: "code is in use by a link not managed by sync"}}, plan.Conflicts)
+
+	actions := map[string]string{}
+	for _, c := range plan.Changes {
+		actions[c.Code] = c.Action
+	}
+	assert.Equal(t, map[string]string{
+		"moved":  models.SyncActionUpdate,
+		"lapsed": models.SyncActionUpdate,
+		"fresh":  models.SyncActionCreate,
+		"gone":   models.SyncActionDelete,
+	}, actions)
+
+	for _, c := range plan.Changes {
+		switch c.Code {
+		case "moved":
+			assert.Equal(t, "https://example.com/new", c.After.LongURL)
+			assert.Equal(t, created, c.After.CreatedAt, "updates keep the creation time")
+			assert.True(t, c.After.Managed)
+		case "fresh":
+			assert.Equal(t, now, c.After.CreatedAt)
+			assert.Nil(t, c.Before)
+		}
+	}
+}
+
+func TestDiffRefusesFrozenLinks(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	managed := []models.Link{
+		{ShortCode: "poster", LongURL: "https://example.com/old", Tags: []string{}, Managed: true, Frozen: true},
+		{ShortCode: "flyer", LongURL: "https://example.com/flyer", Tags: []string{}, Managed: true, Frozen: true},
+		{ShortCode: "sign", LongURL: "https://example.com/sign", Tags: []string{}, Managed: true, Frozen: true},
+	}
+	inUse := map[string]bool{"poster": true, "flyer": true, "sign": true}
+	desired := []models.SyncLink{
+		{Code: "poster", Destination: "https://example.com/new"},
+		{Code: "sign", Destination: "https://example.com/sign"},
+	}
+
+	plan := diff(desired, managed, inUse, now)
+	assert.Empty(t, plan.Changes)
+	assert.Equal(t, 1, plan.Unchanged, "unchanged frozen links are fine")
+	assert.Equal(t, []models.SyncConflict{
+		{Code: "poster", Reason: "link is frozen"},
+		{Code: "flyer", Reason: "link is frozen"},
+	}, plan.Conflicts)
+}
@@ -0,0 +1,75 @@
+// Package listener opens the sockets the server listens on: sockets inherited through
+// systemd socket activation, so the socket stays open and connections queue while the
+// service restarts, and Unix domain sockets, for a reverse proxy on the same host.
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+)
+
+// firstListenFD is the first file descriptor inherited sockets are passed in, by
+// systemd and by Reload alike.
+const firstListenFD = 3
+
+// Systemd returns the sockets systemd passed to this process under socket activation
+// (sd_listen_fds), in the order of the socket unit's ListenStream= lines, or nil if
+// there are none. The LISTEN_* variables are removed so child processes do not take
+// the sockets for theirs.
+func Systemd() ([]net.Listener, error) {
+	count, err := systemdFDs(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getpid())
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if err != nil || count == 0 {
+		return nil, err
+	}
+	return fileListeners(count)
+}
+
+// systemdFDs returns how many sockets systemd passed to the process pid, given the
+// LISTEN_PID and LISTEN_FDS variables. Variables meant for another process, such as
+// the one that started this one, count as none.
+func systemdFDs(listenPID, listenFDs string, pid int) (int, error) {
+	if listenPID == "" || listenFDs == "" {
+		return 0, nil
+	}
+	if p, err := strconv.Atoi(listenPID); err != nil || p != pid {
+		return 0, nil
+	}
+	count, err := strconv.Atoi(listenFDs)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid LISTEN_FDS %q", listenFDs)
+	}
+	return count, nil
+}
+
+// Unix listens on a Unix domain socket at path with the given permissions, replacing
+// the socket a previous run left behind. A file at path that is not a socket is left
+// alone and reported as an error.
+func Unix(path string, mode fs.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != fs.ModeSocket {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
@@ -0,0 +1,69 @@
+package listener
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemdFDs(t *testing.T) {
+	count, err := systemdFDs("", "", 42)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	count, err = systemdFDs("42", "2", 42)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = systemdFDs("41", "2", 42)
+	require.NoError(t, err)
+	assert.Zero(t, count, "sockets meant for another process")
+
+	_, err = systemdFDs("42", "many", 42)
+	assert.Error(t, err)
+}
+
+func TestUnix(t *testing.T) {
+	// Socket paths are limited to about 100 bytes, which test temp dirs may exceed.
+	dir, err := os.MkdirTemp("", "sock")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "riidme.sock")
+
+	l, err := Unix(path, 0o660)
+	require.NoError(t, err)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, fs.FileMode(0o660), info.Mode().Perm())
+
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conn.Write([]byte("ok"))
+			conn.Close()
+		}
+	}()
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	buf := make([]byte, 2)
+	_, err = conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", string(buf))
+	conn.Close()
+
+	// A socket left behind is replaced; other files are not touched.
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	l, err = Unix(path, 0o600)
+	require.NoError(t, err)
+	l.Close()
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, []byte("data"), 0o600))
+	_, err = Unix(file, 0o600)
+	assert.ErrorContains(t, err, "not a socket")
+}
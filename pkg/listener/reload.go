@@ -0,0 +1,189 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// inheritedFDsEnv tells a process started by Reload how many listeners it inherits,
+	// passed like systemd's from file descriptor 3 on.
+	inheritedFDsEnv = "RIIDME_LISTEN_FDS"
+	// readyFDEnv is the file descriptor a process started by Reload reports readiness on.
+	readyFDEnv = "RIIDME_READY_FD"
+	// readyTimeout is how long Reload waits for the new process to start serving.
+	readyTimeout = 30 * time.Second
+)
+
+// Inherited returns the listeners passed to this process, by systemd socket activation
+// or by Reload in the process it replaces, in order, or nil if there are none.
+func Inherited() ([]net.Listener, error) {
+	listeners, err := Systemd()
+	if err != nil || listeners != nil {
+		return listeners, err
+	}
+
+	countStr := os.Getenv(inheritedFDsEnv)
+	os.Unsetenv(inheritedFDsEnv)
+	if countStr == "" {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid %s %q", inheritedFDsEnv, countStr)
+	}
+	return fileListeners(count)
+}
+
+// fileListeners returns listeners for the count sockets starting at file descriptor 3.
+func fileListeners(count int) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, count)
+	for fd := firstListenFD; fd < firstListenFD+count; fd++ {
+		file := os.NewFile(uintptr(fd), "socket-"+strconv.Itoa(fd))
+		l, err := net.FileListener(file)
+		// FileListener dups the descriptor, so the original is closed either way.
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("inherited socket %d: %w", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// Ready reports that this process is serving: to systemd, for services of Type=notify,
+// and to the process that started this one through Reload, so that one can stop.
+// Systemd is also told this is now the service's main process, which it needs to know
+// after a reload (with NotifyAccess=all).
+func Ready() error {
+	err := notifySystemd("READY=1\nMAINPID=" + strconv.Itoa(os.Getpid()))
+
+	fdStr := os.Getenv(readyFDEnv)
+	os.Unsetenv(readyFDEnv)
+	if fdStr == "" {
+		return err
+	}
+	fd, parseErr := strconv.Atoi(fdStr)
+	if parseErr != nil {
+		return errors.Join(err, fmt.Errorf("invalid %s %q", readyFDEnv, fdStr))
+	}
+	pipe := os.NewFile(uintptr(fd), "ready")
+	defer pipe.Close()
+	_, writeErr := pipe.Write([]byte{1})
+	return errors.Join(err, writeErr)
+}
+
+// notifySystemd sends state to the notification socket of the systemd service this
+// process runs in, if it has one (sd_notify).
+func notifySystemd(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		// An abstract socket.
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// Reload starts a new process of the current executable, with the same arguments and
+// environment, handing it listeners, and waits until it reports with Ready that it
+// serves on them. The caller can then shut down gracefully: connections keep being
+// accepted by one process or the other throughout. If the new process fails to start
+// or to become ready, it is stopped and an error returned; the caller keeps serving.
+func Reload(listeners []net.Listener) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	files := make([]*os.File, 0, len(listeners)+1)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, l := range listeners {
+		filer, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s cannot be passed on", l.Addr())
+		}
+		if unix, ok := l.(*net.UnixListener); ok {
+			// The socket file now belongs to the new process too.
+			unix.SetUnlinkOnClose(false)
+		}
+		file, err := filer.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+	readyRead, readyWrite, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyRead.Close()
+	files = append(files, readyWrite)
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(reloadEnv(os.Environ()),
+		inheritedFDsEnv+"="+strconv.Itoa(len(listeners)),
+		readyFDEnv+"="+strconv.Itoa(firstListenFD+len(listeners)),
+	)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Only the new process may hold the write end, so its exit ends the read below.
+	readyWrite.Close()
+	files = files[:len(files)-1]
+
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		_, err := readyRead.Read(buf)
+		ready <- err
+	}()
+	select {
+	case err = <-ready:
+	case <-time.After(readyTimeout):
+		err = errors.New("timed out")
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("new process did not become ready: %w", err)
+	}
+	// The new process outlives this one; nothing waits for it here.
+	cmd.Process.Release()
+	return nil
+}
+
+// reloadEnv returns env without the variables describing inherited sockets, which
+// are set anew for the process Reload starts.
+func reloadEnv(env []string) []string {
+	kept := make([]string, 0, len(env))
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		switch name {
+		case "LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES", inheritedFDsEnv, readyFDEnv:
+			continue
+		}
+		kept = append(kept, kv)
+	}
+	return kept
+}
@@ -0,0 +1,54 @@
+package listener
+
+import (
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain plays the new process when started by Reload in TestReload: it reports
+// ready and answers one connection on the inherited listener.
+func TestMain(m *testing.M) {
+	if os.Getenv(inheritedFDsEnv) == "" {
+		os.Exit(m.Run())
+	}
+	listeners, err := Inherited()
+	if err != nil || len(listeners) != 1 {
+		os.Exit(2)
+	}
+	if err := Ready(); err != nil {
+		os.Exit(3)
+	}
+	conn, err := listeners[0].Accept()
+	if err != nil {
+		os.Exit(4)
+	}
+	conn.Write([]byte("new process"))
+	conn.Close()
+	os.Exit(0)
+}
+
+func TestReload(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	require.NoError(t, Reload([]net.Listener{l}))
+	// The old process stops listening; the socket stays open in the new one.
+	l.Close()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	reply, err := io.ReadAll(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "new process", string(reply))
+}
+
+func TestReloadEnv(t *testing.T) {
+	assert.Equal(t, []string{"PATH=/bin", "NOTIFY_SOCKET=/run/x"}, reloadEnv([]string{
+		"PATH=/bin", "LISTEN_PID=1", "LISTEN_FDS=2", inheritedFDsEnv + "=1", readyFDEnv + "=4", "NOTIFY_SOCKET=/run/x",
+	}))
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package listener
+
+import "os"
+
+// ReloadSignal is nil where there is no signal to ask for a reload.
+var ReloadSignal os.Signal
@@ -0,0 +1,12 @@
+//go:build unix
+
+package listener
+
+import (
+	"os"
+	"syscall"
+)
+
+// ReloadSignal is the signal asking the server to hand its listeners to a new process
+// of its binary and stop, e.g. after a deploy replaced the binary.
+var ReloadSignal os.Signal = syscall.SIGUSR2
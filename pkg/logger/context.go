@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Field names attached to request-scoped loggers.
+const (
+	RequestIDField = "request_id"
+	ShortCodeField = "short_code"
+	APIKeyIDField  = "api_key_id"
+)
+
+// WithContext returns a copy of ctx whose logger carries the given field in addition
+// to any fields already attached to ctx.
+func WithContext(ctx context.Context, key, value string) context.Context {
+	l := FromContext(ctx).With().Str(key, value).Logger()
+	return l.WithContext(ctx)
+}
+
+// FromContext returns the logger attached to ctx by WithContext, or the global logger
+// when ctx carries none. Events logged through it include the request's fields.
+func FromContext(ctx context.Context) *zerolog.Logger {
+	if l := zerolog.Ctx(ctx); l.GetLevel() != zerolog.Disabled {
+		return l
+	}
+	return &log.Logger
+}
@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromContextCarriesFields(t *testing.T) {
+	var buf bytes.Buffer
+	original := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = original }()
+
+	ctx := WithContext(context.Background(), RequestIDField, "abc123")
+	ctx = WithContext(ctx, ShortCodeField, "xyz")
+	FromContext(ctx).Info().Msg("hello")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "abc123", entry[RequestIDField])
+	assert.Equal(t, "xyz", entry[ShortCodeField])
+	assert.Equal(t, "hello", entry["message"])
+}
+
+func TestFromContextFallsBackToGlobalLogger(t *testing.T) {
+	assert.Equal(t, &log.Logger, FromContext(context.Background()))
+}
@@ -1,23 +1,119 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"time"
+
+	"riid.me/pkg/rules"
+)
 
 // URLRequest is the structure for incoming URL shortening requests.
 // It includes the original URL, an optional custom handle, an auth code for custom features,
 // and optional expiration days.
 // ExpirationDays is a pointer to distinguish between 0 (no expiry) and not provided (default expiry).
+// Public links are listed in the site feed under their optional Title.
+// CacheMaxAge sets how long, in seconds, browsers may cache the redirect (0 for no-store);
+// when omitted the deployment default applies.
 type URLRequest struct {
-	LongURL         string `json:"long_url"`
-	CustomHandle    string `json:"custom_handle,omitempty"`
-	AuthCode        string `json:"auth_code,omitempty"`
-	ExpirationDays  *int   `json:"expiration_days,omitempty"`
+	LongURL         string            `json:"long_url"`
+	CustomHandle    string            `json:"custom_handle,omitempty"`
+	AuthCode        string            `json:"auth_code,omitempty"`
+	ExpirationDays  *int              `json:"expiration_days,omitempty"`
+	Title           string            `json:"title,omitempty"`
+	Public          bool              `json:"public,omitempty"`
+	CacheMaxAge     *int              `json:"cache_max_age,omitempty"`
+	NotifyEachClick bool              `json:"notify_each_click,omitempty"` // requires a valid auth code
+	Draft           bool              `json:"draft,omitempty"`             // requires a valid auth code
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`  // extra headers sent with redirects
+	HideReferrer    bool              `json:"hide_referrer,omitempty"`     // send visitors on without a Referer
+	Analytics       *bool             `json:"analytics,omitempty"`         // false stores no click data, only a click count
+	MaxClicks       *int              `json:"max_clicks,omitempty"`        // the link answers 410 Gone after this many redirects
+	DeviceTargets   *DeviceTargets    `json:"device_targets,omitempty"`    // per-device destinations overriding long_url
+	Variants        []LinkVariant     `json:"variants,omitempty"`          // weighted destinations visitors are split between instead of long_url
+	UTMSource       string            `json:"utm_source,omitempty"`        // added to long_url as utm_source
+	UTMMedium       string            `json:"utm_medium,omitempty"`        // added to long_url as utm_medium
+	UTMCampaign     string            `json:"utm_campaign,omitempty"`      // added to long_url as utm_campaign
+	ForwardQuery    bool              `json:"forward_query,omitempty"`     // pass the short URL's query parameters on to the destination
+	NoIndex         bool              `json:"noindex,omitempty"`           // ask search engines not to index the link or follow it
+}
+
+// DeviceTargets are destinations a link sends visitors on particular devices to instead
+// of its default destination, by their User-Agent. Empty targets use the default.
+type DeviceTargets struct {
+	IOS     string `json:"ios,omitempty"`
+	Android string `json:"android,omitempty"`
+	Desktop string `json:"desktop,omitempty"`
+}
+
+// For returns the target for a device class detected by rules.DetectDevice, or "" if
+// there is none.
+func (d *DeviceTargets) For(device string) string {
+	if d == nil {
+		return ""
+	}
+	switch device {
+	case rules.DeviceIOS:
+		return d.IOS
+	case rules.DeviceAndroid:
+		return d.Android
+	case rules.DeviceDesktop:
+		return d.Desktop
+	}
+	return ""
+}
+
+// LinkVariant is one destination of an A/B split. Each redirect picks a variant with a
+// probability of its Weight over the sum of all weights, and records its Name with the
+// click.
+type LinkVariant struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Weight      int    `json:"weight"`
+}
+
+// PageMeta is the title, description and images a link's destination page declares,
+// fetched in the background when the link is created (see FETCH_PAGE_META).
+type PageMeta struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	FaviconURL  string    `json:"favicon_url,omitempty"`
+	ImageURL    string    `json:"image_url,omitempty"` // the page's Open Graph image
+	FetchedAt   time.Time `json:"fetched_at"`
 }
 
 // URLResponse is the structure for the response after successfully shortening a URL.
-// It contains the generated short URL.
-// Example: {"short_url": "http://localhost:3000/abcdef"}
+// It contains the generated short URL and, for expiring links, the absolute expiration
+// time derived from the TTL that was actually set.
+// Example: {"short_url": "http://localhost:3000/abcdef", "expires_at": "2026-01-01T00:00:00Z"}
 type URLResponse struct {
-	ShortURL string `json:"short_url"`
+	ShortURL    string       `json:"short_url"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty"`
+	QRPNGBase64 string       `json:"qr_png_base64,omitempty"` // set when requested with ?include_qr=true
+	Preview     *LinkPreview `json:"preview,omitempty"`       // set for drafts
+	Unwrapped   *UnwrapInfo  `json:"unwrapped,omitempty"`     // set when the submitted URL redirected
+	ClaimToken  string       `json:"claim_token,omitempty"`   // set for links created without an auth code, see LinkClaimRequest
+	Existing    bool         `json:"existing,omitempty"`      // set when DEDUPE_LINKS returned a link created earlier for the same URL
+}
+
+// FieldError is a problem with one field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is the 400 response listing every problem with the fields of
+// a request. Error repeats the first of Errors.
+type ValidationErrorResponse struct {
+	Error  string       `json:"error"`
+	Errors []FieldError `json:"errors"`
+}
+
+// UnwrapInfo describes the redirect chain a submitted URL was unwrapped from. The link
+// points at the last of Hops; ViaShorteners lists link shortener hosts in the chain.
+type UnwrapInfo struct {
+	OriginalURL   string   `json:"original_url"`
+	Hops          []string `json:"hops"`
+	ViaShorteners []string `json:"via_shorteners,omitempty"`
 }
 
 // URLCheckRequest is used for checking if a custom handle is available.
@@ -46,19 +142,752 @@ type AuthValidationResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// BulkStatsRequest asks for the click totals of several short codes at once.
+type BulkStatsRequest struct {
+	Codes []string `json:"codes"`
+}
+
+// ClickTotals are the total and unique click counts of a link. Unique clicks count
+// distinct anonymized visitors (a hash of IP address and user agent).
+type ClickTotals struct {
+	TotalClicks  int `json:"total_clicks"`
+	UniqueClicks int `json:"unique_clicks"`
+}
+
+// BulkStatsResponse maps each requested short code to its click totals.
+type BulkStatsResponse struct {
+	Stats map[string]ClickTotals `json:"stats"`
+}
+
+// ClickRecord is a click on a link, as it is stored. Visitor is an anonymized
+// identifier of who clicked, used for unique counts. Source tags where the click came
+// from, Variant names the A/B split variant served and Country is the visitor's ISO
+// country code from COUNTRY_HEADER; each is empty if there is none. ClickID identifies
+// the click to conversion postbacks, empty when conversions are not tracked.
+type ClickRecord struct {
+	ShortCode   string
+	UserAgent   string
+	Referrer    string
+	Destination string
+	Visitor     string
+	Source      string
+	Variant     string
+	Country     string
+	ClickID     string
+	At          time.Time
+}
+
 // ClickDetail stores information about a single click on a shortened URL.
-// It includes the timestamp of the click, the user agent of the client,
-// and the referrer URL if available.
+// It includes the timestamp of the click (RFC3339, always UTC), the user agent of
+// the client, and the referrer URL if available.
 type ClickDetail struct {
-	Timestamp string         `json:"timestamp"`
+	Timestamp time.Time      `json:"timestamp"`
 	UserAgent sql.NullString `json:"user_agent,omitempty"` // Use sql.NullString for fields that can be NULL in DB
 	Referrer  sql.NullString `json:"referrer,omitempty"`   // Use sql.NullString for fields that can be NULL in DB
 }
 
 // LinkStatsResponse is the structure for returning statistics for a shortened URL.
-// It includes the short code, the total number of clicks, and a list of individual click details.
+// It includes the short code, the total and unique number of clicks, those counts per
+// source (see QRVariant) and, for A/B split links, per variant, the conversions its
+// clicks led to, and a list of the newest individual click details. ClicksHidden is
+// set when the caller's role only allows aggregate counts, leaving Clicks empty.
 type LinkStatsResponse struct {
-	ShortCode   string        `json:"short_code"`
-	TotalClicks int           `json:"total_clicks"`
-	Clicks      []ClickDetail `json:"clicks"`
+	ShortCode    string            `json:"short_code"`
+	TotalClicks  int               `json:"total_clicks"`
+	UniqueClicks int               `json:"unique_clicks"`
+	Sources      []SourceClicks    `json:"sources"`
+	Variants     []VariantClicks   `json:"variants,omitempty"`
+	Conversions  []ConversionCount `json:"conversions,omitempty"`
+	Clicks       []ClickDetail     `json:"clicks"`
+	ClicksHidden bool              `json:"clicks_hidden,omitempty"`
+	// AnalyticsDisabled is set for links that keep no click data; their totals only
+	// count clicks and there are no unique counts, sources or click rows.
+	AnalyticsDisabled bool `json:"analytics_disabled,omitempty"`
+}
+
+// Link is the persisted metadata record of a shortened URL.
+// Redis remains the source of truth for redirects; this record is kept in SQLite
+// so links can be listed and described after creation.
+// ExpiresAt is nil for links that never expire; CacheMaxAge is nil when the link
+// uses the deployment's default redirect caching.
+type Link struct {
+	ShortCode       string            `json:"short_code"`
+	LongURL         string            `json:"long_url"`
+	Title           string            `json:"title,omitempty"`
+	Public          bool              `json:"public"`
+	CreatedAt       time.Time         `json:"created_at"`
+	ExpiresAt       *time.Time        `json:"expires_at,omitempty"`
+	CacheMaxAge     *int              `json:"cache_max_age,omitempty"`
+	Tags            []string          `json:"tags,omitempty"`
+	Managed         bool              `json:"managed,omitempty"`            // owned by declarative sync
+	NotifyEachClick bool              `json:"notify_each_click,omitempty"`  // send a click webhook for every redirect
+	Draft           bool              `json:"draft,omitempty"`              // only reachable with a preview token until published
+	Owner           string            `json:"-"`                            // hash of the auth code that created the link, "" if anonymous
+	Frozen          bool              `json:"frozen,omitempty"`             // destination changes and deletion need an admin to unfreeze first
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`   // extra headers sent with redirects, e.g. Referrer-Policy
+	HideReferrer    bool              `json:"hide_referrer,omitempty"`      // visitors are sent on through a page that strips the Referer
+	NoAnalytics     bool              `json:"analytics_disabled,omitempty"` // only a click count is kept, no click data
+	MaxClicks       *int              `json:"max_clicks,omitempty"`         // redirects allowed before the link answers 410 Gone, nil for no limit
+	DeviceTargets   *DeviceTargets    `json:"device_targets,omitempty"`     // destinations for iOS, Android or desktop visitors instead of LongURL
+	Variants        []LinkVariant     `json:"variants,omitempty"`           // A/B split: each visitor gets one variant, picked by weight, instead of LongURL
+	ForwardQuery    bool              `json:"forward_query,omitempty"`      // query parameters of the short URL are added to the destination on redirect
+	PageMeta        *PageMeta         `json:"page_meta,omitempty"`          // what the destination page says about itself, fetched after creation
+	NoIndex         bool              `json:"noindex,omitempty"`            // redirects and their pages ask search engines not to index or follow the link
+}
+
+// LinkDetailResponse is the structure returned by the link detail endpoint.
+type LinkDetailResponse struct {
+	Link
+	ShortURL       string `json:"short_url"`
+	DisabledReason string `json:"disabled_reason,omitempty"` // why the link was disabled for abuse, shown to its creator and admins
+}
+
+// LinkListItem is a link in the link listing. TTLSeconds is how long it has left,
+// omitted for links that never expire.
+type LinkListItem struct {
+	LinkDetailResponse
+	TTLSeconds *int64 `json:"ttl_seconds,omitempty"`
+}
+
+// LinkListResponse is one page of the link listing.
+type LinkListResponse struct {
+	Links   []LinkListItem `json:"links"`
+	Page    int            `json:"page"`
+	Limit   int            `json:"limit"`
+	Total   int            `json:"total"`
+	HasMore bool           `json:"has_more"`
+}
+
+// LinkLookupMatch is an existing link found by the lookup endpoint. Owned is set on
+// links created with the caller's auth code.
+type LinkLookupMatch struct {
+	LinkDetailResponse
+	Owned bool `json:"owned"`
+}
+
+// LinkLookupRequest is the body of POST /api/lookup, which finds the caller's own
+// links to LongURL.
+type LinkLookupRequest struct {
+	LongURL string `json:"long_url"`
+}
+
+// LinkLookupResponse lists the existing links pointing at a destination.
+type LinkLookupResponse struct {
+	URL   string            `json:"url"`
+	Links []LinkLookupMatch `json:"links"`
+}
+
+// LinkComment is a note left by a team member on a link, e.g. to coordinate a pending change.
+type LinkComment struct {
+	ID        int64     `json:"id"`
+	ShortCode string    `json:"short_code"`
+	Author    string    `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LinkCommentRequest is the structure for adding a comment to a link.
+type LinkCommentRequest struct {
+	Author string `json:"author"`
+	Body   string `json:"body"`
+}
+
+// RuleTestRequest is the payload of the rules dry-run endpoint.
+// Rules is optional; when omitted the link's stored rules are evaluated.
+// Request.Device is derived from UserAgent when only the latter is given.
+type RuleTestRequest struct {
+	Rules     *rules.RuleSet `json:"rules,omitempty"`
+	Request   rules.Request  `json:"request"`
+	UserAgent string         `json:"user_agent,omitempty"`
+}
+
+// RuleTestResponse reports which rule, if any, would decide a redirect and the resulting destination.
+type RuleTestResponse struct {
+	Matched     bool   `json:"matched"`
+	RuleIndex   int    `json:"rule_index"`
+	RuleName    string `json:"rule_name,omitempty"`
+	Destination string `json:"destination"`
+}
+
+// RedirectDebugResponse explains how a redirect would be resolved for a described visitor.
+// RuleIndex is -1 when no rule matched and the default destination would be used.
+type RedirectDebugResponse struct {
+	ShortCode          string            `json:"short_code"`
+	Destination        string            `json:"destination"`
+	DefaultDestination string            `json:"default_destination"`
+	MatchedRule        bool              `json:"matched_rule"`
+	RuleIndex          int               `json:"rule_index"`
+	RuleName           string            `json:"rule_name,omitempty"`
+	InRollout          bool              `json:"in_rollout"`
+	Rollout            *LinkRollout      `json:"rollout,omitempty"` // the split the visitor's bucket decided, if the link has a rollout and no rule or device target applied
+	CacheMaxAge        int               `json:"cache_max_age"`
+	ResponseHeaders    map[string]string `json:"response_headers,omitempty"`
+	HideReferrer       bool              `json:"hide_referrer"`
+	NoIndex            bool              `json:"noindex"`
+	DeviceTarget       string            `json:"device_target,omitempty"` // device whose target the visitor is sent to
+	Variant            string            `json:"variant,omitempty"`       // A/B split variant the visitor is sent to
+	Request            rules.Request     `json:"request"`
+}
+
+// RedirectPattern is an admin-defined vanity path rule such as "/docs/*" → "https://docs.example.com/*".
+// Each "*" in Pattern captures part of the request path and is substituted, in order,
+// for the corresponding "*" in Destination.
+type RedirectPattern struct {
+	ID          int64     `json:"id"`
+	Pattern     string    `json:"pattern"`
+	Destination string    `json:"destination"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DomainRoute configures how a domain pointed at this instance behaves outside of short codes:
+// RootDestination is where its root path redirects (empty serves the regular homepage) and
+// NotFoundHTML is the page served for unknown paths (empty uses the default 404).
+type DomainRoute struct {
+	Host            string    `json:"host"`
+	RootDestination string    `json:"root_destination,omitempty"`
+	NotFoundHTML    string    `json:"not_found_html,omitempty"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// NotFoundResponse is returned for unknown short codes, with existing codes the
+// caller may have meant.
+type NotFoundResponse struct {
+	Error       string   `json:"error"`
+	Suggestions []string `json:"suggestions,omitempty"`
+}
+
+// Link lifecycle event types recorded in the outbox.
+const (
+	LinkEventCreated = "created"
+	LinkEventUpdated = "updated"
+	LinkEventExpired = "expired"
+	LinkEventDeleted = "deleted"
+)
+
+// LinkEvent is an entry in the outbox of link lifecycle events. Link is the state of
+// the link at the time of the event. IDs increase monotonically, so consumers resume
+// from the last ID they processed.
+type LinkEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	ShortCode string    `json:"short_code"`
+	Link      Link      `json:"link"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LinkEventsResponse is a page of outbox events. NextAfter is the value to pass as
+// "after" to fetch the following page.
+type LinkEventsResponse struct {
+	Events    []LinkEvent `json:"events"`
+	NextAfter int64       `json:"next_after"`
+}
+
+// SyncLink is one desired link in a declarative sync file. A nil ExpiresAt means
+// the link never expires.
+type SyncLink struct {
+	Code        string     `json:"code" yaml:"code"`
+	Destination string     `json:"destination" yaml:"destination"`
+	Title       string     `json:"title,omitempty" yaml:"title,omitempty"`
+	Tags        []string   `json:"tags,omitempty" yaml:"tags,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" yaml:"expires_at,omitempty"`
+}
+
+// SyncRequest is the full desired set of sync-managed links. Managed links missing
+// from it are deleted.
+type SyncRequest struct {
+	Links []SyncLink `json:"links" yaml:"links"`
+}
+
+// Sync change actions.
+const (
+	SyncActionCreate = "create"
+	SyncActionUpdate = "update"
+	SyncActionDelete = "delete"
+)
+
+// SyncChange is one step of a sync plan. Before is the current state (nil on create)
+// and After the desired state (nil on delete).
+type SyncChange struct {
+	Action string `json:"action"`
+	Code   string `json:"code"`
+	Before *Link  `json:"before,omitempty"`
+	After  *Link  `json:"after,omitempty"`
+}
+
+// SyncConflict is a desired link that cannot be reconciled, e.g. because its code is
+// taken by a link that sync does not manage.
+type SyncConflict struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// SyncPlan describes the changes needed to make live links match a SyncRequest.
+// A plan with conflicts is never applied.
+type SyncPlan struct {
+	Changes   []SyncChange   `json:"changes"`
+	Conflicts []SyncConflict `json:"conflicts,omitempty"`
+	Unchanged int            `json:"unchanged"`
+	Applied   bool           `json:"applied"`
+}
+
+// LinkRollout sends Percent percent of a link's traffic to Destination and the rest
+// to the link's regular destination.
+type LinkRollout struct {
+	Destination string    `json:"destination"`
+	Percent     int       `json:"percent"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// LinkRolloutResponse is a link's rollout together with the clicks each destination
+// has received. Clicks recorded before destinations were tracked are counted under "".
+type LinkRolloutResponse struct {
+	ShortCode string         `json:"short_code"`
+	Rollout   *LinkRollout   `json:"rollout"`
+	Clicks    map[string]int `json:"clicks"`
+}
+
+// ClickEvent is sent to the click webhook for every redirect of a link flagged
+// notify_each_click.
+type ClickEvent struct {
+	ShortCode   string    `json:"short_code"`
+	Destination string    `json:"destination"`
+	Timestamp   time.Time `json:"timestamp"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	Referrer    string    `json:"referrer,omitempty"`
+	Country     string    `json:"country,omitempty"`
+	Device      string    `json:"device,omitempty"`
+	Variant     string    `json:"variant,omitempty"`
+	ClickID     string    `json:"click_id,omitempty"`
+}
+
+// CampaignAlertRequest sets a click-count threshold on a campaign, the links tagged
+// Campaign. Crossing it is reported to WebhookURL, Email, or both.
+type CampaignAlertRequest struct {
+	Campaign   string `json:"campaign" yaml:"campaign"`
+	Threshold  int    `json:"threshold" yaml:"threshold"`
+	WebhookURL string `json:"webhook_url,omitempty" yaml:"webhook_url,omitempty"`
+	Email      string `json:"email,omitempty" yaml:"email,omitempty"`
+}
+
+// CampaignAlert is a click-count threshold on a campaign. Clicks is the campaign's
+// click count, kept up to date by the redirects until the alert triggers once, at
+// TriggeredAt.
+type CampaignAlert struct {
+	ID          int64      `json:"id"`
+	Campaign    string     `json:"campaign"`
+	Threshold   int        `json:"threshold"`
+	Clicks      int        `json:"clicks"`
+	WebhookURL  string     `json:"webhook_url,omitempty"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	TriggeredAt *time.Time `json:"triggered_at,omitempty"`
+}
+
+// CampaignAlertEvent is sent to an alert's webhook, and emailed, when its campaign
+// crosses the threshold.
+type CampaignAlertEvent struct {
+	AlertID     int64     `json:"alert_id"`
+	Campaign    string    `json:"campaign"`
+	Threshold   int       `json:"threshold"`
+	Clicks      int       `json:"clicks"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+// LinkBatchRequest mints one personalized link per CSV row. Template is the destination
+// with {{column}} placeholders, filled in from the CSV, whose first row names the columns.
+// QRThumbnails adds a column with each link's QR code as a PNG data URI.
+type LinkBatchRequest struct {
+	Template       string   `json:"template" yaml:"template"`
+	CSV            string   `json:"csv" yaml:"csv"`
+	ExpirationDays *int     `json:"expiration_days,omitempty" yaml:"expiration_days,omitempty"`
+	Tags           []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	QRThumbnails   bool     `json:"qr_thumbnails,omitempty" yaml:"qr_thumbnails,omitempty"`
+}
+
+// QRSheetRequest lays out the QR codes of Links on a printable PDF sheet. Paper is
+// "a4" (the default) or "letter"; Columns defaults to 3.
+type QRSheetRequest struct {
+	Paper   string        `json:"paper,omitempty" yaml:"paper,omitempty"`
+	Columns int           `json:"columns,omitempty" yaml:"columns,omitempty"`
+	Links   []QRSheetLink `json:"links" yaml:"links"`
+}
+
+// QRSheetLink is one QR code on a sheet, printed above Caption and the short URL.
+// Without a Caption the link's title is used.
+type QRSheetLink struct {
+	ShortCode string `json:"short_code" yaml:"short_code"`
+	Caption   string `json:"caption,omitempty" yaml:"caption,omitempty"`
+}
+
+// QRVariantsRequest asks for one QR code per source of a link, e.g. per poster
+// placement. Size is the image size in pixels, 256 by default.
+type QRVariantsRequest struct {
+	Sources []string `json:"sources" yaml:"sources"`
+	Size    int      `json:"size,omitempty" yaml:"size,omitempty"`
+}
+
+// QRVariant is the QR code of a link tagged with Source: URL carries ?src=<Source>,
+// so clicks through it are counted per source.
+type QRVariant struct {
+	Source      string `json:"source"`
+	URL         string `json:"url"`
+	QRPNGBase64 string `json:"qr_png_base64"`
+}
+
+// QRVariantsResponse lists the QR code variants of a link.
+type QRVariantsResponse struct {
+	ShortCode string      `json:"short_code"`
+	Variants  []QRVariant `json:"variants"`
+}
+
+// SourceClicks is the total and unique number of clicks of a link from one source.
+type SourceClicks struct {
+	Source       string `json:"source"`
+	TotalClicks  int    `json:"total_clicks"`
+	UniqueClicks int    `json:"unique_clicks"`
+}
+
+// VariantClicks is the total and unique number of clicks a link's A/B split sent to one
+// variant.
+type VariantClicks struct {
+	Variant      string `json:"variant"`
+	TotalClicks  int    `json:"total_clicks"`
+	UniqueClicks int    `json:"unique_clicks"`
+}
+
+// ClickSeriesPoint is the number of clicks in the time bucket starting at Time, of one
+// link or, with ShortCode empty, of all links.
+type ClickSeriesPoint struct {
+	Time      time.Time `json:"time"`
+	ShortCode string    `json:"short_code,omitempty"`
+	Clicks    int       `json:"clicks"`
+}
+
+// TagStatsResponse rolls up the clicks of all links sharing a tag. Series counts their
+// clicks over time together; clicks of links keeping no click data have no time and
+// only count towards TotalClicks.
+type TagStatsResponse struct {
+	Tag          string             `json:"tag"`
+	Links        int                `json:"links"`
+	TotalClicks  int                `json:"total_clicks"`
+	UniqueClicks int                `json:"unique_clicks"`
+	Series       []ClickSeriesPoint `json:"series"`
+}
+
+// LinkClicks is the total and unique number of clicks of a link in a time range.
+type LinkClicks struct {
+	ShortCode    string `json:"short_code"`
+	TotalClicks  int    `json:"total_clicks"`
+	UniqueClicks int    `json:"unique_clicks"`
+}
+
+// ClickReport summarizes the clicks on all links between From and To: the totals, the
+// most clicked links, where visitors came from and clicks per day.
+type ClickReport struct {
+	From         time.Time          `json:"from"`
+	To           time.Time          `json:"to"`
+	TotalClicks  int                `json:"total_clicks"`
+	UniqueClicks int                `json:"unique_clicks"`
+	TopLinks     []LinkClicks       `json:"top_links"`
+	Referrers    []ReportCount      `json:"referrers"` // by referring host, "" for direct visits
+	Countries    []ReportCount      `json:"countries"` // by ISO country code, "" where unknown
+	Timeline     []ClickSeriesPoint `json:"timeline"`
+}
+
+// ReportCount is the number of clicks in a report sharing one value, such as a
+// referring host or a country.
+type ReportCount struct {
+	Value  string `json:"value"`
+	Clicks int    `json:"clicks"`
+}
+
+// ConversionRequest reports an outcome, such as a signup or purchase, of the click
+// ClickID identifies. Event defaults to "conversion"; OccurredAt, when the outcome
+// happened, defaults to when the request is received.
+type ConversionRequest struct {
+	ClickID    string     `json:"click_id"`
+	Event      string     `json:"event,omitempty"`
+	Value      *float64   `json:"value,omitempty"`
+	Currency   string     `json:"currency,omitempty"`
+	OccurredAt *time.Time `json:"occurred_at,omitempty"`
+}
+
+// Conversion is a conversion attributed to a click on ShortCode.
+type Conversion struct {
+	ClickID     string    `json:"click_id"`
+	ShortCode   string    `json:"short_code"`
+	Event       string    `json:"event"`
+	Value       *float64  `json:"value,omitempty"`
+	Currency    string    `json:"currency,omitempty"`
+	ClickedAt   time.Time `json:"clicked_at"`
+	ConvertedAt time.Time `json:"converted_at"`
+}
+
+// ConversionCount is how many conversions of one event a link's clicks led to, and
+// the sum of their values.
+type ConversionCount struct {
+	Event       string  `json:"event"`
+	Conversions int     `json:"conversions"`
+	Value       float64 `json:"value,omitempty"`
+}
+
+// LinkPreview grants access to a draft link until ExpiresAt by appending
+// ?preview=<Token> to its short URL, as done in URL.
+type LinkPreview struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LinkClaimRequest claims an anonymous link for the caller with the one-time claim
+// token returned when it was created.
+type LinkClaimRequest struct {
+	ClaimToken string `json:"claim_token" yaml:"claim_token"`
+}
+
+// MaintenanceResult reports what a stats database maintenance run did and how long each step took.
+type MaintenanceResult struct {
+	Reindexed       bool     `json:"reindexed"`
+	ReindexSeconds  float64  `json:"reindex_seconds,omitempty"`
+	AnalyzeSeconds  float64  `json:"analyze_seconds"`
+	ClickRows       int64    `json:"click_rows"`
+	IndexesOnClicks []string `json:"indexes_on_clicks"`
+}
+
+// KeyRotationResult counts the stored destinations a key rotation rewrote, per store.
+type KeyRotationResult struct {
+	Destinations int64 `json:"destinations"` // destinations in Redis
+	Links        int64 `json:"links"`        // links rows
+	Rollouts     int64 `json:"rollouts"`     // link_rollouts rows
+	Rules        int64 `json:"rules"`        // link_rules rows
+	Clicks       int64 `json:"clicks"`       // clicks rows
+	Events       int64 `json:"events"`       // outbox events
+}
+
+// Drop is a file shared through a short link. The file itself lives in the drops store
+// under FileKey; it is served for as long as the link has not expired.
+type Drop struct {
+	ShortCode   string    `json:"short_code"`
+	FileKey     string    `json:"-"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Downloads   int       `json:"downloads"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DropResponse describes a drop with its links, expiry and click statistics. Clicks
+// counts visits of the short URL; Downloads counts files actually served.
+type DropResponse struct {
+	Drop
+	ShortURL    string     `json:"short_url"`
+	DownloadURL string     `json:"download_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Clicks      int        `json:"clicks"`
+}
+
+// Snippet is a text, code or Markdown note served as a page through a short link.
+// A BurnAfterRead snippet is deleted the first time it is viewed.
+type Snippet struct {
+	ShortCode     string    `json:"short_code"`
+	Content       string    `json:"content"`
+	Language      string    `json:"language"`
+	BurnAfterRead bool      `json:"burn_after_read"`
+	Views         int       `json:"views"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SnippetRequest is the body of a snippet creation request. Language is "text" (the
+// default), "markdown" or the name of a programming language to highlight.
+type SnippetRequest struct {
+	Content        string `json:"content"`
+	Language       string `json:"language,omitempty"`
+	Title          string `json:"title,omitempty"`
+	CustomHandle   string `json:"custom_handle,omitempty"`
+	ExpirationDays *int   `json:"expiration_days,omitempty"`
+	BurnAfterRead  bool   `json:"burn_after_read,omitempty"`
+}
+
+// SnippetResponse describes a snippet with its links, expiry and click statistics.
+type SnippetResponse struct {
+	Snippet
+	ShortURL  string     `json:"short_url"`
+	ViewURL   string     `json:"view_url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Clicks    int        `json:"clicks"`
+}
+
+// IPReputationStatus reports the state of the IP blocklist checked on redirects and
+// what the check has done since the server started.
+type IPReputationStatus struct {
+	Enabled          bool       `json:"enabled"`
+	Action           string     `json:"action"`
+	Ranges           int        `json:"ranges"`
+	UpdatedAt        *time.Time `json:"updated_at,omitempty"`
+	Denied           int64      `json:"denied"`
+	Challenged       int64      `json:"challenged"`
+	ChallengesPassed int64      `json:"challenges_passed"`
+}
+
+// APIKey is an auth code issued by an admin through the API rather than configured in
+// the environment. Only a hash of the key is stored; the key itself is returned once,
+// when it is created. Links holds how many links were created with the key.
+type APIKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Links     int        `json:"links"`
+	APIKeyQuotas
+}
+
+// APIKeyQuotas are the daily request limits of an API key. A nil quota uses the
+// configured default; 0 means unlimited.
+type APIKeyQuotas struct {
+	ShortenQuota *int `json:"shorten_quota,omitempty" yaml:"shorten_quota,omitempty"`
+	StatsQuota   *int `json:"stats_quota,omitempty" yaml:"stats_quota,omitempty"`
+}
+
+// APIKeyRequest creates an API key. Role is "member" (the default), which may create
+// and manage links like VALID_AUTH_CODES, or "viewer", which may only read stats.
+type APIKeyRequest struct {
+	Name         string `json:"name" yaml:"name"`
+	Role         string `json:"role,omitempty" yaml:"role,omitempty"`
+	APIKeyQuotas `yaml:",inline"`
+}
+
+// APIKeyCreatedResponse is a new API key along with its secret Key.
+type APIKeyCreatedResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// User is a registered account. Users sign in with their email and password and get
+// a session token, which works like a member auth code.
+type User struct {
+	ID           int64     `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UserCredentials registers or signs in a user.
+type UserCredentials struct {
+	Email    string `json:"email" yaml:"email"`
+	Password string `json:"password" yaml:"password"`
+}
+
+// UserSessionResponse is a session token issued at registration or login, sent as
+// "Authorization: Bearer <token>" until ExpiresAt.
+type UserSessionResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	User      User      `json:"user"`
+}
+
+// LinkTakedown records that an admin disabled a link for abuse. Reason is kept for
+// the record and sent to the owner; visitors only see Category.
+type LinkTakedown struct {
+	ShortCode   string    `json:"short_code"`
+	Reason      string    `json:"reason"`
+	Category    string    `json:"category,omitempty"`     // e.g. "phishing", "malware", "spam"
+	NotifyEmail string    `json:"notify_email,omitempty"` // where the takedown notice was emailed
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PolicyViolation is a link whose destination breaks the destination policy.
+type PolicyViolation struct {
+	ShortCode string `json:"short_code"`
+	LongURL   string `json:"long_url"`
+	Reason    string `json:"reason"`
+}
+
+// PolicyScanResult reports a rescan of existing links against the destination policy.
+// Violating links are disabled with a takedown.
+type PolicyScanResult struct {
+	Scanned    int               `json:"scanned"`
+	Violations []PolicyViolation `json:"violations"`
+}
+
+// TakedownRequest is the body of a request disabling a link for abuse.
+type TakedownRequest struct {
+	Reason      string `json:"reason"`
+	Category    string `json:"category,omitempty"`
+	NotifyEmail string `json:"notify_email,omitempty"`
+}
+
+// TakedownNotice tells the owner of a link, by email or webhook, that it was disabled
+// and where to appeal.
+type TakedownNotice struct {
+	ShortCode  string    `json:"short_code"`
+	ShortURL   string    `json:"short_url"`
+	Reason     string    `json:"reason"`
+	Category   string    `json:"category,omitempty"`
+	DisabledAt time.Time `json:"disabled_at"`
+	AppealURL  string    `json:"appeal_url,omitempty"`
+}
+
+// Actions recorded in the public takedown log.
+const (
+	TakedownActionDisabled   = "disabled"
+	TakedownActionReinstated = "reinstated"
+)
+
+// TakedownLogEntry is an entry of the public takedown log: a link was disabled for
+// abuse, or reinstated. It carries neither the link's destination nor the reason given,
+// only the category shown to visitors. IDs increase monotonically.
+type TakedownLogEntry struct {
+	ID        int64     `json:"id"`
+	ShortCode string    `json:"short_code"`
+	Action    string    `json:"action"`
+	Category  string    `json:"category,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TakedownLogResponse is a page of the takedown log. NextAfter is the value to pass
+// as "after" to fetch the following page.
+type TakedownLogResponse struct {
+	Entries   []TakedownLogEntry `json:"entries"`
+	NextAfter int64              `json:"next_after"`
+}
+
+// TakedownResponse describes a recorded takedown and how the owner was notified.
+// NotificationError is set when a notice could not be delivered; the link is
+// disabled regardless.
+type TakedownResponse struct {
+	LinkTakedown
+	Notified          []string `json:"notified"`
+	NotificationError string   `json:"notification_error,omitempty"`
+}
+
+// Instance is a running riid.me instance in the instance registry. LastSeen is the
+// time of its latest heartbeat; Healthy and Error report its health check then.
+type Instance struct {
+	ID        string    `json:"id"`
+	Address   string    `json:"address,omitempty"` // where the instance can be reached, e.g. for DNS or a load balancer
+	Hostname  string    `json:"hostname"`
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit"`
+	StartedAt time.Time `json:"started_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// HandlePolicy describes the rules custom handles must follow, so clients can show
+// them before a handle is submitted. Pattern is a regular expression a whole handle
+// must match, empty if there is none; deployments may add rules of their own.
+type HandlePolicy struct {
+	Pattern   string `json:"pattern,omitempty"`
+	MinLength int    `json:"min_length"`
+	MaxLength int    `json:"max_length"`
+}
+
+// HandleAvailability tells whether a custom handle can be registered, and if not why.
+type HandleAvailability struct {
+	Handle    string       `json:"handle"`
+	Available bool         `json:"available"`
+	Reason    string       `json:"reason,omitempty"`
+	Policy    HandlePolicy `json:"policy"`
 }
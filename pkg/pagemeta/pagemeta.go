@@ -0,0 +1,220 @@
+// Package pagemeta fetches the title, description and favicon of a web page, so a
+// link can be shown with what it leads to.
+package pagemeta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"riid.me/pkg/unwrap"
+)
+
+const (
+	// requestTimeout bounds fetching a page, redirects included.
+	requestTimeout = 10 * time.Second
+	// maxRedirects is the most redirects followed to reach a page.
+	maxRedirects = 5
+	// maxBodyBytes is how much of a page is read looking for its metadata; it is all
+	// in the head, which comes first.
+	maxBodyBytes = 512 << 10
+	// MaxTitleLength and MaxDescriptionLength bound the metadata kept, in characters.
+	MaxTitleLength       = 300
+	MaxDescriptionLength = 500
+)
+
+// errNotHTML is returned for pages that are not HTML documents.
+var errNotHTML = errors.New("not an HTML page")
+
+// Page is the metadata of a web page. Fields the page does not declare are empty,
+// except Favicon, which defaults to /favicon.ico on the page's host. Image is its Open
+// Graph image.
+type Page struct {
+	Title       string
+	Description string
+	Favicon     string
+	Image       string
+}
+
+// Fetcher fetches page metadata with Client.
+type Fetcher struct {
+	Client *http.Client
+}
+
+// NewFetcher returns a Fetcher whose client follows up to 5 redirects, between http
+// and https URLs only, and refuses to connect to private, loopback and other
+// non-public addresses. It ignores HTTP_PROXY and HTTPS_PROXY, as through a proxy
+// that check would never see the fetched address.
+func NewFetcher() *Fetcher {
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: unwrap.PublicAddressesOnly}
+	transport := &http.Transport{
+		// No proxy: the dialer could then only check the proxy's address, not the destination's.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   requestTimeout,
+		ResponseHeaderTimeout: requestTimeout,
+	}
+	return &Fetcher{Client: &http.Client{
+		Transport:     transport,
+		Timeout:       requestTimeout,
+		CheckRedirect: checkRedirect,
+	}}
+}
+
+// checkRedirect limits the redirects a Fetcher's client follows.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > maxRedirects {
+		return fmt.Errorf("more than %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+	}
+	return nil
+}
+
+// Fetch requests the page at rawURL, an http or https URL, and reads its metadata.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Page, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Page{}, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return Page{}, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Page{}, err
+	}
+	req.Header.Set("User-Agent", "riid.me-pagemeta/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9")
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return Page{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Page{}, fmt.Errorf("page answered %s", resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Page{}, errNotHTML
+	}
+	// The page's own URL, after redirects, is what its relative favicon refers to.
+	return Parse(io.LimitReader(resp.Body, maxBodyBytes), resp.Request.URL), nil
+}
+
+// Parse reads the metadata from the head of the HTML document r, served at base.
+// Open Graph titles and descriptions are used when the page has no plain ones.
+func Parse(r io.Reader, base *url.URL) Page {
+	var page Page
+	var ogTitle, ogDescription, ogImage, icon, touchIcon string
+	z := html.NewTokenizer(r)
+head:
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			break
+		}
+		token := z.Token()
+		if tt == html.EndTagToken && token.DataAtom == atom.Head {
+			break
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		switch token.DataAtom {
+		case atom.Body:
+			// Pages without a closing head tag end it with the body.
+			break head
+		case atom.Title:
+			if page.Title == "" && z.Next() == html.TextToken {
+				page.Title = string(z.Text())
+			}
+		case atom.Meta:
+			name := strings.ToLower(attr(token, "name"))
+			property := strings.ToLower(attr(token, "property"))
+			switch {
+			case name == "description" && page.Description == "":
+				page.Description = attr(token, "content")
+			case property == "og:title" && ogTitle == "":
+				ogTitle = attr(token, "content")
+			case property == "og:description" && ogDescription == "":
+				ogDescription = attr(token, "content")
+			case property == "og:image" && ogImage == "":
+				ogImage = attr(token, "content")
+			}
+		case atom.Link:
+			href := attr(token, "href")
+			for _, rel := range strings.Fields(strings.ToLower(attr(token, "rel"))) {
+				if rel == "icon" && icon == "" {
+					icon = href
+				}
+				if rel == "apple-touch-icon" && touchIcon == "" {
+					touchIcon = href
+				}
+			}
+		}
+	}
+	page.Title = clean(page.Title, ogTitle, MaxTitleLength)
+	page.Description = clean(page.Description, ogDescription, MaxDescriptionLength)
+	page.Favicon = faviconURL(base, icon, touchIcon)
+	page.Image = resolveHTTP(base, ogImage)
+	return page
+}
+
+// attr returns the value of the attribute key of token, or "".
+func attr(token html.Token, key string) string {
+	for _, a := range token.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// clean returns value, or fallback if it is blank, with runs of whitespace collapsed
+// and cut to at most limit characters.
+func clean(value, fallback string, limit int) string {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		value = strings.Join(strings.Fields(fallback), " ")
+	}
+	if utf8.RuneCountInString(value) > limit {
+		value = string([]rune(value)[:limit-1]) + "…"
+	}
+	return value
+}
+
+// faviconURL resolves the first of hrefs that is set against base, defaulting to
+// /favicon.ico. Icons that are not http or https URLs, such as data: URLs, are skipped.
+func faviconURL(base *url.URL, hrefs ...string) string {
+	for _, href := range hrefs {
+		if icon := resolveHTTP(base, href); icon != "" {
+			return icon
+		}
+	}
+	return (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/favicon.ico"}).String()
+}
+
+// resolveHTTP resolves href against base, returning "" unless it is an http or https URL.
+func resolveHTTP(base *url.URL, href string) string {
+	if href == "" {
+		return ""
+	}
+	u, err := base.Parse(strings.TrimSpace(href))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	return u.String()
+}
@@ -0,0 +1,82 @@
+package pagemeta
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+
+	page := Parse(strings.NewReader(`<!DOCTYPE html><html><head>
+<title>
+  Hello &amp; welcome
+</title>
+<meta name="Description" content="A post about things.">
+<link rel="shortcut icon" href="../img/fav.png">
+<meta property="og:image" content="/img/cover.jpg">
+</head><body><title>Not this</title></body></html>`), base)
+	assert.Equal(t, Page{
+		Title:       "Hello & welcome",
+		Description: "A post about things.",
+		Favicon:     "https://example.com/img/fav.png",
+		Image:       "https://example.com/img/cover.jpg",
+	}, page)
+
+	// Open Graph fills in, and the icon defaults to /favicon.ico.
+	page = Parse(strings.NewReader(`<head><meta property="og:title" content="OG title">
+<meta property="og:description" content="OG description"><link rel="icon" href="data:image/png;base64,AAAA">`), base)
+	assert.Equal(t, Page{Title: "OG title", Description: "OG description", Favicon: "https://example.com/favicon.ico"}, page)
+
+	page = Parse(strings.NewReader(`<title>`+strings.Repeat("x", 400)+`</title>`), base)
+	assert.Equal(t, MaxTitleLength, len([]rune(page.Title)))
+	assert.True(t, strings.HasSuffix(page.Title, "…"))
+}
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<head><title>Landing</title><link rel="icon" href="/i.ico"></head>`))
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/file", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := server.Client()
+	client.CheckRedirect = checkRedirect
+	f := &Fetcher{Client: client}
+
+	page, err := f.Fetch(context.Background(), server.URL+"/moved")
+	require.NoError(t, err)
+	assert.Equal(t, "Landing", page.Title)
+	assert.Equal(t, server.URL+"/i.ico", page.Favicon)
+
+	_, err = f.Fetch(context.Background(), server.URL+"/file")
+	assert.ErrorIs(t, err, errNotHTML)
+	_, err = f.Fetch(context.Background(), server.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+	_, err = f.Fetch(context.Background(), "ftp://example.com/")
+	assert.Error(t, err)
+
+	// The real client never connects to the loopback test server.
+	_, err = NewFetcher().Fetch(context.Background(), server.URL+"/page")
+	assert.Error(t, err)
+}
+
+func TestNewFetcherBypassesProxies(t *testing.T) {
+	transport := NewFetcher().Client.Transport.(*http.Transport)
+	assert.Nil(t, transport.Proxy, "a proxy would hide the fetched address from the dial check")
+}
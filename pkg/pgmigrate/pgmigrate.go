@@ -0,0 +1,288 @@
+// Package pgmigrate copies the SQLite statistics database into PostgreSQL. Every
+// user table in the SQLite file is copied, so tables added by later schema migrations
+// are picked up without changes here. Each table is created in Postgres from its
+// SQLite definition, copied in batches inside one transaction, and verified by
+// comparing row counts.
+package pgmigrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// maxParams is the PostgreSQL limit on bind parameters in a single statement.
+const maxParams = 65535
+
+// Options controls a migration run.
+type Options struct {
+	// BatchSize is the number of rows inserted per statement.
+	BatchSize int
+	// Tables restricts the run to these tables; empty copies every table.
+	Tables []string
+	// Truncate empties target tables that already hold rows instead of failing.
+	Truncate bool
+	// Progress, if set, is called after each batch with the rows copied so far.
+	Progress func(table string, copied, total int64)
+}
+
+// Column describes a column of a SQLite table.
+type Column struct {
+	Name       string
+	Type       string
+	NotNull    bool
+	PrimaryKey bool
+}
+
+// TableResult reports the outcome of copying one table.
+type TableResult struct {
+	Table      string
+	SourceRows int64
+	CopiedRows int64
+	TargetRows int64
+}
+
+// Verified reports whether the target table holds exactly the source rows.
+func (r TableResult) Verified() bool {
+	return r.SourceRows == r.CopiedRows && r.CopiedRows == r.TargetRows
+}
+
+// Migrate copies the selected tables from src (SQLite) to dst (PostgreSQL).
+func Migrate(ctx context.Context, src, dst *sql.DB, opts Options) ([]TableResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	tables := opts.Tables
+	if len(tables) == 0 {
+		var err error
+		if tables, err = sourceTables(ctx, src); err != nil {
+			return nil, err
+		}
+	}
+
+	var results []TableResult
+	for _, table := range tables {
+		result, err := migrateTable(ctx, src, dst, table, opts)
+		if err != nil {
+			return results, fmt.Errorf("table %s: %w", table, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// sourceTables lists the user tables of the SQLite database.
+func sourceTables(ctx context.Context, src *sql.DB) ([]string, error) {
+	rows, err := src.QueryContext(ctx,
+		`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	return tables, rows.Err()
+}
+
+// sourceColumns returns the columns of a SQLite table, in order.
+func sourceColumns(ctx context.Context, src *sql.DB, table string) ([]Column, error) {
+	rows, err := src.QueryContext(ctx, `SELECT name, type, "notnull", pk FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []Column
+	for rows.Next() {
+		var c Column
+		var pk int
+		if err := rows.Scan(&c.Name, &c.Type, &c.NotNull, &pk); err != nil {
+			return nil, err
+		}
+		c.PrimaryKey = pk > 0
+		cols = append(cols, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("no such table")
+	}
+	return cols, nil
+}
+
+// postgresType maps a SQLite declared column type to a PostgreSQL type, following
+// SQLite's type affinity rules.
+func postgresType(declared string) string {
+	t := strings.ToUpper(declared)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "BIGINT"
+	case strings.Contains(t, "DATE"), strings.Contains(t, "TIME"):
+		return "TIMESTAMPTZ"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case strings.Contains(t, "BLOB"), t == "":
+		return "BYTEA"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "DOUBLE PRECISION"
+	default:
+		return "NUMERIC"
+	}
+}
+
+// quoteIdent quotes an identifier for PostgreSQL.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// createTableSQL builds the PostgreSQL CREATE TABLE statement for a SQLite table.
+func createTableSQL(table string, cols []Column) string {
+	var defs, pks []string
+	for _, c := range cols {
+		def := quoteIdent(c.Name) + " " + postgresType(c.Type)
+		if c.NotNull {
+			def += " NOT NULL"
+		}
+		defs = append(defs, def)
+		if c.PrimaryKey {
+			pks = append(pks, quoteIdent(c.Name))
+		}
+	}
+	if len(pks) > 0 {
+		defs = append(defs, "PRIMARY KEY ("+strings.Join(pks, ", ")+")")
+	}
+	return "CREATE TABLE IF NOT EXISTS " + quoteIdent(table) + " (\n\t" + strings.Join(defs, ",\n\t") + "\n)"
+}
+
+// insertSQL builds a multi-row INSERT for n rows of cols with $-numbered parameters.
+func insertSQL(table string, cols []Column, n int) string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = quoteIdent(c.Name)
+	}
+	var b strings.Builder
+	b.WriteString("INSERT INTO " + quoteIdent(table) + " (" + strings.Join(names, ", ") + ") VALUES ")
+	param := 1
+	for row := 0; row < n; row++ {
+		if row > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString("(")
+		for col := range cols {
+			if col > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "$%d", param)
+			param++
+		}
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// migrateTable creates, fills and verifies one table.
+func migrateTable(ctx context.Context, src, dst *sql.DB, table string, opts Options) (TableResult, error) {
+	result := TableResult{Table: table}
+	cols, err := sourceColumns(ctx, src, table)
+	if err != nil {
+		return result, err
+	}
+	if err := src.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+quoteIdent(table)).Scan(&result.SourceRows); err != nil {
+		return result, err
+	}
+
+	tx, err := dst.BeginTx(ctx, nil)
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, createTableSQL(table, cols)); err != nil {
+		return result, fmt.Errorf("create: %w", err)
+	}
+	var existing int64
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+quoteIdent(table)).Scan(&existing); err != nil {
+		return result, err
+	}
+	if existing > 0 {
+		if !opts.Truncate {
+			return result, fmt.Errorf("target already holds %d rows (use truncate to replace them)", existing)
+		}
+		if _, err := tx.ExecContext(ctx, `TRUNCATE `+quoteIdent(table)); err != nil {
+			return result, err
+		}
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize*len(cols) > maxParams {
+		batchSize = maxParams / len(cols)
+	}
+
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = quoteIdent(c.Name)
+	}
+	rows, err := src.QueryContext(ctx, `SELECT `+strings.Join(names, ", ")+` FROM `+quoteIdent(table))
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	batch := make([]interface{}, 0, batchSize*len(cols))
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		n := len(batch) / len(cols)
+		if _, err := tx.ExecContext(ctx, insertSQL(table, cols, n), batch...); err != nil {
+			return fmt.Errorf("insert: %w", err)
+		}
+		result.CopiedRows += int64(n)
+		batch = batch[:0]
+		if opts.Progress != nil {
+			opts.Progress(table, result.CopiedRows, result.SourceRows)
+		}
+		return nil
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return result, err
+		}
+		batch = append(batch, values...)
+		if len(batch) == batchSize*len(cols) {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return result, err
+	}
+	if err := flush(); err != nil {
+		return result, err
+	}
+
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+quoteIdent(table)).Scan(&result.TargetRows); err != nil {
+		return result, err
+	}
+	if !result.Verified() {
+		return result, fmt.Errorf("verification failed: %d source rows, %d copied, %d in target",
+			result.SourceRows, result.CopiedRows, result.TargetRows)
+	}
+	return result, tx.Commit()
+}
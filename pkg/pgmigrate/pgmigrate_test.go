@@ -0,0 +1,55 @@
+package pgmigrate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/storage"
+)
+
+func TestPostgresType(t *testing.T) {
+	assert.Equal(t, "BIGINT", postgresType("INTEGER"))
+	assert.Equal(t, "TIMESTAMPTZ", postgresType("DATETIME"))
+	assert.Equal(t, "TEXT", postgresType("text"))
+	assert.Equal(t, "TEXT", postgresType("VARCHAR(30)"))
+	assert.Equal(t, "BYTEA", postgresType(""))
+	assert.Equal(t, "DOUBLE PRECISION", postgresType("REAL"))
+}
+
+func TestCreateAndInsertSQL(t *testing.T) {
+	cols := []Column{
+		{Name: "id", Type: "INTEGER", PrimaryKey: true},
+		{Name: "short_code", Type: "TEXT", NotNull: true},
+		{Name: "timestamp", Type: "DATETIME"},
+	}
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS \"clicks\" (\n\t\"id\" BIGINT,\n\t\"short_code\" TEXT NOT NULL,\n\t\"timestamp\" TIMESTAMPTZ,\n\tPRIMARY KEY (\"id\")\n)",
+		createTableSQL("clicks", cols))
+	assert.Equal(t, `INSERT INTO "clicks" ("id", "short_code", "timestamp") VALUES ($1, $2, $3), ($4, $5, $6)`,
+		insertSQL("clicks", cols, 2))
+}
+
+func TestSourceSchemaCoversAllTables(t *testing.T) {
+	cfg := config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}
+	require.NoError(t, storage.InitSQLite(cfg))
+	defer storage.StatsDB.Close()
+	ctx := context.Background()
+
+	tables, err := sourceTables(ctx, storage.StatsDB)
+	require.NoError(t, err)
+	assert.Contains(t, tables, "clicks")
+	assert.Contains(t, tables, "links")
+	assert.Contains(t, tables, "link_events")
+	assert.NotContains(t, tables, "sqlite_sequence")
+
+	cols, err := sourceColumns(ctx, storage.StatsDB, "clicks")
+	require.NoError(t, err)
+	assert.Equal(t, Column{Name: "id", Type: "INTEGER", PrimaryKey: true}, cols[0])
+	assert.Equal(t, Column{Name: "short_code", Type: "TEXT", NotNull: true}, cols[1])
+
+	_, err = sourceColumns(ctx, storage.StatsDB, "missing")
+	assert.Error(t, err)
+}
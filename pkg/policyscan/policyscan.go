@@ -0,0 +1,102 @@
+// Package policyscan rechecks existing links against the destination policy and host
+// blocklists, so rules added and hosts flagged after a link was created still apply
+// to it. Violating links are disabled with a takedown, which admins can lift like any
+// other.
+package policyscan
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+	"riid.me/pkg/storage"
+	"riid.me/pkg/validation"
+)
+
+// TakedownCategory is the category of takedowns made by a policy scan.
+const TakedownCategory = "policy"
+
+// Notify tells the owner of a link that a scan disabled it.
+type Notify func(ctx context.Context, takedown models.LinkTakedown) error
+
+// Scan validates the destination of every live link and disables the violating ones,
+// passing each new takedown to notify unless it is nil. Links already disabled and
+// links to content hosted on our own domain are skipped. A failed notice is logged and
+// does not stop the scan.
+func Scan(ctx context.Context, now time.Time, notify Notify) (models.PolicyScanResult, error) {
+	result := models.PolicyScanResult{Violations: []models.PolicyViolation{}}
+	links, err := storage.LiveLinks(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, link := range links {
+		if destination, err := url.Parse(link.LongURL); err == nil && destination.Host == config.GlobalAppConfig.Domain {
+			continue
+		}
+		result.Scanned++
+		policyErr := validation.ValidateDestination(link.LongURL)
+		if policyErr == nil {
+			continue
+		}
+
+		existing, err := storage.GetLinkTakedown(ctx, link.ShortCode)
+		if err != nil {
+			return result, err
+		}
+		if existing != nil {
+			continue
+		}
+		takedown := models.LinkTakedown{
+			ShortCode: link.ShortCode,
+			Reason:    "Destination violates the deployment policy: " + policyErr.Error(),
+			Category:  TakedownCategory,
+			CreatedAt: now,
+		}
+		if err := storage.SaveLinkTakedown(ctx, takedown); err != nil {
+			return result, err
+		}
+		if notify != nil {
+			if err := notify(ctx, takedown); err != nil {
+				customlogger.Error().Err(err).Str("short_code", link.ShortCode).Msg("Failed to deliver takedown notice of policy scan")
+			}
+		}
+		result.Violations = append(result.Violations, models.PolicyViolation{
+			ShortCode: link.ShortCode,
+			LongURL:   link.LongURL,
+			Reason:    policyErr.Error(),
+		})
+	}
+	return result, nil
+}
+
+// Rescan scans all links now and logs what it disabled.
+func Rescan(ctx context.Context, notify Notify) {
+	result, err := Scan(ctx, time.Now().UTC(), notify)
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Destination policy scan failed")
+		return
+	}
+	for _, violation := range result.Violations {
+		customlogger.Warn().Str("short_code", violation.ShortCode).Str("reason", violation.Reason).Msg("Disabled link violating the destination policy")
+	}
+	customlogger.Info().Int("scanned", result.Scanned).Int("disabled", len(result.Violations)).Msg("Destination policy scan completed")
+}
+
+// Run scans all links at once, since policy changes take effect on restart, and then
+// every interval until ctx is cancelled.
+func Run(ctx context.Context, interval time.Duration, notify Notify) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		Rescan(ctx, notify)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
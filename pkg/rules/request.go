@@ -0,0 +1,71 @@
+package rules
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DetectDevice classifies a User-Agent string as ios, android or desktop.
+func DetectDevice(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return DeviceIOS
+	case strings.Contains(ua, "android"):
+		return DeviceAndroid
+	default:
+		return DeviceDesktop
+	}
+}
+
+// ParseAcceptLanguage returns the primary language subtags of an Accept-Language
+// header, ordered by preference (e.g. "de-CH, en;q=0.8" yields ["de", "en"]).
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var entries []weighted
+	seen := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if primary == "" || primary == "*" || seen[primary] {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		seen[primary] = true
+		entries = append(entries, weighted{primary, q})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].q > entries[j].q })
+
+	langs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		langs = append(langs, e.lang)
+	}
+	return langs
+}
+
+// RequestFromHTTP builds the rule evaluation attributes for an incoming request.
+// The visitor country is read from countryHeader, which is expected to be set by a
+// trusted proxy or CDN (e.g. Cloudflare's CF-IPCountry).
+func RequestFromHTTP(r *http.Request, countryHeader string) Request {
+	req := Request{
+		Device:    DetectDevice(r.UserAgent()),
+		Languages: ParseAcceptLanguage(r.Header.Get("Accept-Language")),
+		Time:      time.Now().UTC(),
+		Query:     r.URL.Query(),
+	}
+	if countryHeader != "" {
+		req.Country = strings.ToUpper(strings.TrimSpace(r.Header.Get(countryHeader)))
+	}
+	return req
+}
@@ -0,0 +1,238 @@
+// Package rules implements the per-link rules engine evaluated at redirect time.
+//
+// A RuleSet is an ordered list of rules, each pairing a condition on the incoming
+// request (country, device, language, time, query parameters) with a destination.
+// The first rule whose condition matches decides the destination; when no rule
+// matches, the link's default destination is used.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Device classes recognized by conditions and DetectDevice.
+const (
+	DeviceIOS     = "ios"
+	DeviceAndroid = "android"
+	DeviceDesktop = "desktop"
+)
+
+// weekdays maps the short weekday names accepted in conditions to time.Weekday values.
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Condition describes when a rule applies. All non-empty fields must match;
+// list fields match when the request value equals any of the listed values.
+type Condition struct {
+	Country  []string          `json:"country,omitempty" yaml:"country,omitempty"`   // ISO 3166-1 alpha-2 codes
+	Device   []string          `json:"device,omitempty" yaml:"device,omitempty"`     // ios, android or desktop
+	Language []string          `json:"language,omitempty" yaml:"language,omitempty"` // primary language subtags, e.g. "en"
+	After    *time.Time        `json:"after,omitempty" yaml:"after,omitempty"`       // matches at or after this instant
+	Before   *time.Time        `json:"before,omitempty" yaml:"before,omitempty"`     // matches before this instant
+	Weekdays []string          `json:"weekdays,omitempty" yaml:"weekdays,omitempty"` // mon..sun, evaluated in UTC
+	Hours    string            `json:"hours,omitempty" yaml:"hours,omitempty"`       // "HH:MM-HH:MM" in UTC, may wrap midnight
+	Query    map[string]string `json:"query,omitempty" yaml:"query,omitempty"`       // param -> value, "*" matches any value
+}
+
+// Rule sends requests matching its condition to Destination.
+type Rule struct {
+	Name        string    `json:"name,omitempty" yaml:"name,omitempty"`
+	When        Condition `json:"when" yaml:"when"`
+	Destination string    `json:"destination" yaml:"destination"`
+}
+
+// RuleSet is the ordered list of rules attached to a link.
+type RuleSet struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// Request holds the request attributes that rules are evaluated against.
+type Request struct {
+	Country   string     `json:"country,omitempty"`
+	Device    string     `json:"device,omitempty"`
+	Languages []string   `json:"languages,omitempty"`
+	Time      time.Time  `json:"time"`
+	Query     url.Values `json:"query,omitempty"`
+}
+
+// MaxRules is the maximum number of rules allowed on a single link.
+const MaxRules = 50
+
+// Evaluate returns the index of the first rule matching req, or -1 if none match.
+func (rs RuleSet) Evaluate(req Request) int {
+	for i, rule := range rs.Rules {
+		if rule.When.Matches(req) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Matches reports whether the condition holds for req.
+func (c Condition) Matches(req Request) bool {
+	if len(c.Country) > 0 && !containsFold(c.Country, req.Country) {
+		return false
+	}
+	if len(c.Device) > 0 && !containsFold(c.Device, req.Device) {
+		return false
+	}
+	if len(c.Language) > 0 {
+		matched := false
+		for _, lang := range req.Languages {
+			if containsFold(c.Language, lang) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	now := req.Time.UTC()
+	if c.After != nil && now.Before(*c.After) {
+		return false
+	}
+	if c.Before != nil && !now.Before(*c.Before) {
+		return false
+	}
+	if len(c.Weekdays) > 0 {
+		matched := false
+		for _, day := range c.Weekdays {
+			if weekdays[strings.ToLower(day)] == now.Weekday() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if c.Hours != "" {
+		start, end, err := parseHours(c.Hours)
+		if err != nil {
+			return false
+		}
+		minute := now.Hour()*60 + now.Minute()
+		if start <= end && (minute < start || minute >= end) {
+			return false
+		}
+		if start > end && minute < start && minute >= end {
+			return false
+		}
+	}
+
+	for param, want := range c.Query {
+		values, ok := req.Query[param]
+		if !ok {
+			return false
+		}
+		if want != "*" && !contains(values, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the rule set is well formed. Destinations are checked to be
+// absolute http(s) URLs; callers apply deployment destination policies separately.
+func (rs RuleSet) Validate() error {
+	if len(rs.Rules) > MaxRules {
+		return fmt.Errorf("a link may have at most %d rules", MaxRules)
+	}
+	for i, rule := range rs.Rules {
+		if err := rule.validate(); err != nil {
+			if rule.Name != "" {
+				return fmt.Errorf("rule %d (%s): %w", i+1, rule.Name, err)
+			}
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func (rule Rule) validate() error {
+	u, err := url.Parse(rule.Destination)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("destination must be an absolute http or https URL")
+	}
+
+	c := rule.When
+	for _, country := range c.Country {
+		if len(country) != 2 {
+			return fmt.Errorf("invalid country code %q", country)
+		}
+	}
+	for _, device := range c.Device {
+		switch strings.ToLower(device) {
+		case DeviceIOS, DeviceAndroid, DeviceDesktop:
+		default:
+			return fmt.Errorf("invalid device %q, expected ios, android or desktop", device)
+		}
+	}
+	for _, lang := range c.Language {
+		if lang == "" || strings.ContainsAny(lang, " ,;") {
+			return fmt.Errorf("invalid language %q", lang)
+		}
+	}
+	if c.After != nil && c.Before != nil && !c.After.Before(*c.Before) {
+		return errors.New("after must be earlier than before")
+	}
+	for _, day := range c.Weekdays {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid weekday %q, expected mon..sun", day)
+		}
+	}
+	if c.Hours != "" {
+		if _, _, err := parseHours(c.Hours); err != nil {
+			return err
+		}
+	}
+	for param := range c.Query {
+		if param == "" {
+			return errors.New("query conditions need a parameter name")
+		}
+	}
+	return nil
+}
+
+// parseHours parses an "HH:MM-HH:MM" window into start and end minutes after midnight.
+func parseHours(window string) (int, int, error) {
+	startStr, endStr, ok := strings.Cut(window, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", window)
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(startStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", window)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(endStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid hours %q, expected HH:MM-HH:MM", window)
+	}
+	return start.Hour()*60 + start.Minute(), end.Hour()*60 + end.Minute(), nil
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
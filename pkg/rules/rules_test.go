@@ -0,0 +1,66 @@
+package rules
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateFirstMatchWins(t *testing.T) {
+	rs := RuleSet{Rules: []Rule{
+		{Name: "german iphones", When: Condition{Country: []string{"DE"}, Device: []string{"ios"}}, Destination: "https://example.de/ios"},
+		{Name: "germany", When: Condition{Country: []string{"de", "at"}}, Destination: "https://example.de"},
+		{Name: "campaign", When: Condition{Query: map[string]string{"utm_source": "*"}}, Destination: "https://example.com/c"},
+	}}
+	now := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 0, rs.Evaluate(Request{Country: "DE", Device: "ios", Time: now}))
+	assert.Equal(t, 1, rs.Evaluate(Request{Country: "AT", Device: "android", Time: now}))
+	assert.Equal(t, 2, rs.Evaluate(Request{Country: "US", Time: now, Query: url.Values{"utm_source": {"x"}}}))
+	assert.Equal(t, -1, rs.Evaluate(Request{Country: "US", Time: now}))
+}
+
+func TestConditionTimeWindows(t *testing.T) {
+	after := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	before := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	c := Condition{After: &after, Before: &before, Weekdays: []string{"mon"}, Hours: "09:00-17:00"}
+
+	assert.True(t, c.Matches(Request{Time: time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)}))  // Monday
+	assert.False(t, c.Matches(Request{Time: time.Date(2026, 1, 6, 10, 0, 0, 0, time.UTC)})) // Tuesday
+	assert.False(t, c.Matches(Request{Time: time.Date(2026, 1, 5, 18, 0, 0, 0, time.UTC)}))
+	assert.False(t, c.Matches(Request{Time: time.Date(2026, 2, 2, 10, 0, 0, 0, time.UTC)}))
+
+	overnight := Condition{Hours: "22:00-06:00"}
+	assert.True(t, overnight.Matches(Request{Time: time.Date(2026, 1, 5, 23, 0, 0, 0, time.UTC)}))
+	assert.True(t, overnight.Matches(Request{Time: time.Date(2026, 1, 5, 5, 59, 0, 0, time.UTC)}))
+	assert.False(t, overnight.Matches(Request{Time: time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)}))
+}
+
+func TestValidate(t *testing.T) {
+	valid := RuleSet{Rules: []Rule{{When: Condition{Device: []string{"android"}, Hours: "08:00-09:30"}, Destination: "https://example.com"}}}
+	assert.NoError(t, valid.Validate())
+
+	invalid := []Rule{
+		{Destination: "ftp://example.com"},
+		{When: Condition{Country: []string{"GER"}}, Destination: "https://example.com"},
+		{When: Condition{Device: []string{"tablet"}}, Destination: "https://example.com"},
+		{When: Condition{Weekdays: []string{"funday"}}, Destination: "https://example.com"},
+		{When: Condition{Hours: "9-5"}, Destination: "https://example.com"},
+	}
+	for _, rule := range invalid {
+		assert.Error(t, RuleSet{Rules: []Rule{rule}}.Validate())
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"de", "en", "fr"}, ParseAcceptLanguage("fr;q=0.5, de-CH, en;q=0.8, de;q=0.9"))
+	assert.Empty(t, ParseAcceptLanguage(""))
+}
+
+func TestDetectDevice(t *testing.T) {
+	assert.Equal(t, DeviceIOS, DetectDevice("Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X)"))
+	assert.Equal(t, DeviceAndroid, DetectDevice("Mozilla/5.0 (Linux; Android 14; Pixel 8)"))
+	assert.Equal(t, DeviceDesktop, DetectDevice("Mozilla/5.0 (Windows NT 10.0; Win64; x64)"))
+}
@@ -0,0 +1,145 @@
+// Package safebrowsing checks link destinations with the Google Safe Browsing Lookup
+// API (v4), so links to pages Google has flagged as malware or phishing cannot be
+// created.
+package safebrowsing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"riid.me/pkg/buildinfo"
+	customlogger "riid.me/pkg/logger"
+)
+
+const (
+	// DefaultEndpoint is the Lookup API's threatMatches:find method.
+	DefaultEndpoint = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+	// clientID identifies us to the API, as it asks clients to.
+	clientID = "riidme"
+	// lookupTimeout bounds a single lookup, which new links wait for.
+	lookupTimeout = 5 * time.Second
+)
+
+// threatTypes are the kinds of threats destinations are checked for.
+var threatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+
+// threatDescriptions describe threat types to the people whose links they reject.
+var threatDescriptions = map[string]string{
+	"MALWARE":                         "malware",
+	"SOCIAL_ENGINEERING":              "phishing",
+	"UNWANTED_SOFTWARE":               "unwanted software",
+	"POTENTIALLY_HARMFUL_APPLICATION": "a potentially harmful application",
+}
+
+// Client looks URLs up in Safe Browsing. It is a validation.DestinationValidator, so
+// links to flagged URLs cannot be created.
+type Client struct {
+	APIKey   string
+	Endpoint string
+	Client   *http.Client
+}
+
+// NewClient returns a Client using apiKey against the public API.
+func NewClient(apiKey string) *Client {
+	return &Client{
+		APIKey:   apiKey,
+		Endpoint: DefaultEndpoint,
+		Client:   &http.Client{Timeout: lookupTimeout},
+	}
+}
+
+type threatEntry struct {
+	URL string `json:"url"`
+}
+
+type findRequest struct {
+	Client struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+	ThreatInfo struct {
+		ThreatTypes      []string      `json:"threatTypes"`
+		PlatformTypes    []string      `json:"platformTypes"`
+		ThreatEntryTypes []string      `json:"threatEntryTypes"`
+		ThreatEntries    []threatEntry `json:"threatEntries"`
+	} `json:"threatInfo"`
+}
+
+type findResponse struct {
+	Matches []struct {
+		ThreatType string      `json:"threatType"`
+		Threat     threatEntry `json:"threat"`
+	} `json:"matches"`
+}
+
+// Lookup returns the threat type, e.g. "MALWARE", Safe Browsing lists rawURL under,
+// or "" if it is not listed.
+func (c *Client) Lookup(ctx context.Context, rawURL string) (string, error) {
+	var body findRequest
+	body.Client.ClientID = clientID
+	body.Client.ClientVersion = buildinfo.Version
+	body.ThreatInfo.ThreatTypes = threatTypes
+	body.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	body.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	body.ThreatInfo.ThreatEntries = []threatEntry{{URL: rawURL}}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint+"?key="+url.QueryEscape(c.APIKey), bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		// The error would carry the URL, and with it the API key.
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("lookup responded with status %d", resp.StatusCode)
+	}
+
+	var found findResponse
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return "", err
+	}
+	for _, match := range found.Matches {
+		if match.ThreatType != "" {
+			return match.ThreatType, nil
+		}
+	}
+	return "", nil
+}
+
+// ValidateDestination implements validation.DestinationValidator. Lookups that fail
+// are logged and let the destination through, so an API outage does not stop links
+// from being created.
+func (c *Client) ValidateDestination(destination *url.URL) error {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	threat, err := c.Lookup(ctx, destination.String())
+	if err != nil {
+		customlogger.Error().Err(err).Str("host", destination.Hostname()).Msg("Safe Browsing lookup failed, allowing destination")
+		return nil
+	}
+	if threat == "" {
+		return nil
+	}
+	description, ok := threatDescriptions[threat]
+	if !ok {
+		description = strings.ToLower(strings.ReplaceAll(threat, "_", " "))
+	}
+	return fmt.Errorf("Destination is flagged by Google Safe Browsing as %s.", description)
+}
@@ -0,0 +1,46 @@
+package safebrowsing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDestination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "test-key", r.URL.Query().Get("key"))
+		var body findRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Len(t, body.ThreatInfo.ThreatEntries, 1)
+		switch body.ThreatInfo.ThreatEntries[0].URL {
+		case "https://phish.example/login":
+			w.Write([]byte(`{"matches":[{"threatType":"SOCIAL_ENGINEERING","platformType":"ANY_PLATFORM","threat":{"url":"https://phish.example/login"},"cacheDuration":"300s"}]}`))
+		case "https://broken.example/":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.Endpoint = server.URL
+
+	check := func(raw string) error {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		return client.ValidateDestination(u)
+	}
+	assert.EqualError(t, check("https://phish.example/login"), "Destination is flagged by Google Safe Browsing as phishing.")
+	assert.NoError(t, check("https://example.com/"))
+	assert.NoError(t, check("https://broken.example/"), "failed lookups let destinations through")
+
+	_, err := client.Lookup(context.Background(), "https://broken.example/")
+	assert.ErrorContains(t, err, "status 503")
+}
@@ -0,0 +1,110 @@
+// Package snippets renders text, code and Markdown notes shared through a short link
+// ("snippets") and removes them once their link has expired.
+package snippets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/extension"
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/storage"
+)
+
+const (
+	// LanguageText is shown as preformatted plain text.
+	LanguageText = "text"
+	// LanguageMarkdown is rendered to HTML, with fenced code blocks highlighted.
+	LanguageMarkdown = "markdown"
+	// highlightStyle is the chroma style used for code.
+	highlightStyle = "github"
+	// cleanupInterval is how often expired snippets are removed.
+	cleanupInterval = time.Hour
+)
+
+// markdown renders Markdown with GitHub extensions. Raw HTML in the source is omitted
+// and unsafe link schemes are dropped, as goldmark does unless told otherwise.
+var markdown = goldmark.New(goldmark.WithExtensions(
+	extension.GFM,
+	highlighting.NewHighlighting(
+		highlighting.WithStyle(highlightStyle),
+		highlighting.WithFormatOptions(chromahtml.WithClasses(false)),
+	),
+))
+
+// NormalizeLanguage returns the canonical name of a snippet language: LanguageText for
+// "", LanguageMarkdown for its aliases, or the lowercased name of a language the
+// highlighter knows. Other names are an error.
+func NormalizeLanguage(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case "", "text", "txt", "plain", "plaintext":
+		return LanguageText, nil
+	case "markdown", "md":
+		return LanguageMarkdown, nil
+	}
+	if lexers.Get(name) == nil {
+		return "", fmt.Errorf("unknown snippet language %q", name)
+	}
+	return name, nil
+}
+
+// Render returns content as HTML for display: Markdown rendered, code highlighted
+// and plain text escaped. language must be normalized.
+func Render(content, language string) (template.HTML, error) {
+	var out bytes.Buffer
+	switch language {
+	case LanguageText:
+		out.WriteString("<pre>")
+		out.WriteString(html.EscapeString(content))
+		out.WriteString("</pre>")
+	case LanguageMarkdown:
+		if err := markdown.Convert([]byte(content), &out); err != nil {
+			return "", err
+		}
+	default:
+		lexer := lexers.Get(language)
+		if lexer == nil {
+			return "", fmt.Errorf("unknown snippet language %q", language)
+		}
+		tokens, err := chroma.Coalesce(lexer).Tokenise(nil, content)
+		if err != nil {
+			return "", err
+		}
+		formatter := chromahtml.New(chromahtml.WithClasses(false))
+		if err := formatter.Format(&out, styles.Get(highlightStyle), tokens); err != nil {
+			return "", err
+		}
+	}
+	// Output is escaped by the renderers above.
+	return template.HTML(out.String()), nil
+}
+
+// Run removes expired snippets every cleanupInterval until ctx is cancelled.
+func Run(ctx context.Context) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := storage.DeleteExpiredSnippets(ctx); err != nil {
+			customlogger.Error().Err(err).Msg("Failed to clean up expired snippets")
+		} else if n > 0 {
+			customlogger.Info().Int64("count", n).Msg("Removed expired snippets")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package snippets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	for name, want := range map[string]string{"": LanguageText, "Plain": LanguageText, "MD": LanguageMarkdown, " Go ": "go", "python": "python"} {
+		got, err := NormalizeLanguage(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, got, name)
+	}
+	_, err := NormalizeLanguage("no-such-language")
+	assert.Error(t, err)
+}
+
+func TestRenderEscapesContent(t *testing.T) {
+	out, err := Render("<script>alert(1)</script>", LanguageText)
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "<script>")
+	assert.Contains(t, string(out), "&lt;script&gt;")
+
+	out, err = Render("# Title\n\n<script>alert(1)</script>\n\n[click](javascript:alert(1))", LanguageMarkdown)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "<h1>Title</h1>")
+	assert.NotContains(t, string(out), "<script>")
+	assert.NotContains(t, string(out), "javascript:")
+}
+
+func TestRenderHighlightsCode(t *testing.T) {
+	out, err := Render("package main\n\nfunc main() {}\n", "go")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "<span style=")
+	assert.Contains(t, string(out), "func")
+
+	out, err = Render("```go\nfunc main() {}\n```\n", LanguageMarkdown)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "<span style=", "fenced code blocks are highlighted")
+}
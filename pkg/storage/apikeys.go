@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// CreateAPIKey stores a new API key under keyHash, the hash of its secret.
+func CreateAPIKey(ctx context.Context, key models.APIKey, keyHash string) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT INTO api_keys (id, key_hash, name, role, created_at, shorten_quota, stats_quota) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.ID, keyHash, key.Name, key.Role, key.CreatedAt.UTC(), nullableInt(key.ShortenQuota), nullableInt(key.StatsQuota))
+	return err
+}
+
+// APIKeyByHash returns the unrevoked API key whose secret hashes to keyHash, or
+// ErrAPIKeyNotFound.
+func APIKeyByHash(ctx context.Context, keyHash string) (models.APIKey, error) {
+	var key models.APIKey
+	var shortenQuota, statsQuota sql.NullInt64
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT id, name, role, created_at, shorten_quota, stats_quota FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL`, keyHash,
+	).Scan(&key.ID, &key.Name, &key.Role, &key.CreatedAt, &shortenQuota, &statsQuota)
+	if err == sql.ErrNoRows {
+		return models.APIKey{}, ErrAPIKeyNotFound
+	} else if err != nil {
+		return models.APIKey{}, err
+	}
+	key.ShortenQuota = intPointer(shortenQuota)
+	key.StatsQuota = intPointer(statsQuota)
+	return key, nil
+}
+
+// ListAPIKeys returns all API keys, revoked ones included, newest first, with the
+// number of links created with each.
+func ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT k.id, k.name, k.role, k.created_at, k.revoked_at, k.shorten_quota, k.stats_quota,
+			(SELECT COUNT(*) FROM links WHERE owner = k.id)
+		FROM api_keys k ORDER BY k.created_at DESC, k.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		var revokedAt sql.NullTime
+		var shortenQuota, statsQuota sql.NullInt64
+		if err := rows.Scan(&key.ID, &key.Name, &key.Role, &key.CreatedAt, &revokedAt, &shortenQuota, &statsQuota, &key.Links); err != nil {
+			return nil, err
+		}
+		if revokedAt.Valid {
+			t := revokedAt.Time
+			key.RevokedAt = &t
+		}
+		key.ShortenQuota = intPointer(shortenQuota)
+		key.StatsQuota = intPointer(statsQuota)
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// SetAPIKeyQuotas replaces the quotas of an unrevoked API key, or returns
+// ErrAPIKeyNotFound.
+func SetAPIKeyQuotas(ctx context.Context, id string, quotas models.APIKeyQuotas) error {
+	res, err := StatsDB.ExecContext(ctx,
+		`UPDATE api_keys SET shorten_quota = ?, stats_quota = ? WHERE id = ? AND revoked_at IS NULL`,
+		nullableInt(quotas.ShortenQuota), nullableInt(quotas.StatsQuota), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// RevokeAPIKey stops an API key from being accepted, or returns ErrAPIKeyNotFound if
+// there is no such key or it was already revoked. Its record is kept so links created
+// with it stay attributed.
+func RevokeAPIKey(ctx context.Context, id string, at time.Time) error {
+	res, err := StatsDB.ExecContext(ctx,
+		`UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, at.UTC(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// nullableInt converts an optional int for storage, where nil is NULL.
+func nullableInt(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+// intPointer converts a nullable integer column back to an optional int.
+func intPointer(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
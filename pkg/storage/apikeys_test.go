@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestAPIKeys(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	require.NoError(t, CreateAPIKey(ctx, models.APIKey{ID: "k1", Name: "CI", Role: "member", CreatedAt: now}, "hash1"))
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "abc", LongURL: "https://example.com", Owner: "k1", CreatedAt: now}))
+
+	key, err := APIKeyByHash(ctx, "hash1")
+	require.NoError(t, err)
+	assert.Equal(t, "k1", key.ID)
+	assert.Equal(t, "member", key.Role)
+
+	assert.Nil(t, key.ShortenQuota, "the configured default applies")
+
+	quota := 50
+	require.NoError(t, SetAPIKeyQuotas(ctx, "k1", models.APIKeyQuotas{ShortenQuota: &quota}))
+	key, err = APIKeyByHash(ctx, "hash1")
+	require.NoError(t, err)
+	if assert.NotNil(t, key.ShortenQuota) {
+		assert.Equal(t, 50, *key.ShortenQuota)
+	}
+	assert.Nil(t, key.StatsQuota)
+	assert.ErrorIs(t, SetAPIKeyQuotas(ctx, "unknown", models.APIKeyQuotas{}), ErrAPIKeyNotFound)
+
+	_, err = APIKeyByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	require.NoError(t, RevokeAPIKey(ctx, "k1", now))
+	assert.ErrorIs(t, RevokeAPIKey(ctx, "k1", now), ErrAPIKeyNotFound, "already revoked")
+	_, err = APIKeyByHash(ctx, "hash1")
+	assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+	keys, err := ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Equal(t, 1, keys[0].Links)
+	require.NotNil(t, keys[0].RevokedAt)
+	assert.True(t, keys[0].RevokedAt.Equal(now))
+}
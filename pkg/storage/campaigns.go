@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// campaignLinksQuery selects the short codes of the links tagged with a campaign.
+const campaignLinksQuery = `SELECT l.short_code FROM links l, json_each(l.tags) t WHERE t.value = ?`
+
+// CampaignClicks returns how often the links of a campaign, those tagged with it, were
+// clicked, bare click counts of links keeping no click data included.
+func CampaignClicks(ctx context.Context, campaign string) (int, error) {
+	var n int
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT (SELECT COUNT(*) FROM clicks WHERE short_code IN (`+campaignLinksQuery+`))
+			+ (SELECT COALESCE(SUM(clicks), 0) FROM click_counts WHERE short_code IN (`+campaignLinksQuery+`))`,
+		campaign, campaign,
+	).Scan(&n)
+	return n, err
+}
+
+// CreateCampaignAlert stores a new campaign alert, starting from its Clicks, and
+// returns its ID.
+func CreateCampaignAlert(ctx context.Context, alert models.CampaignAlert) (int64, error) {
+	res, err := StatsDB.ExecContext(ctx,
+		`INSERT INTO campaign_alerts (campaign, threshold, clicks, webhook_url, email, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		alert.Campaign, alert.Threshold, alert.Clicks, alert.WebhookURL, alert.Email, alert.CreatedAt.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListCampaignAlerts returns all campaign alerts, triggered ones included, by
+// campaign and threshold.
+func ListCampaignAlerts(ctx context.Context) ([]models.CampaignAlert, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT id, campaign, threshold, clicks, webhook_url, email, created_at, triggered_at
+		FROM campaign_alerts ORDER BY campaign, threshold, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []models.CampaignAlert{}
+	for rows.Next() {
+		alert, err := scanCampaignAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// DeleteCampaignAlert removes a campaign alert, or returns ErrCampaignAlertNotFound.
+func DeleteCampaignAlert(ctx context.Context, id int64) error {
+	res, err := StatsDB.ExecContext(ctx, `DELETE FROM campaign_alerts WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCampaignAlertNotFound
+	}
+	return nil
+}
+
+// CountCampaignClick adds a click to the pending alerts of the campaigns a clicked link
+// is tagged with and returns the alerts the click made cross their threshold, marked
+// triggered at at. Each alert is returned by exactly one click, however many
+// instances count clicks concurrently.
+func CountCampaignClick(ctx context.Context, campaigns []string, at time.Time) ([]models.CampaignAlert, error) {
+	if len(campaigns) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(campaigns)+1)
+	args = append(args, at.UTC())
+	for _, campaign := range campaigns {
+		args = append(args, campaign)
+	}
+	in := `campaign IN (?` + strings.Repeat(", ?", len(campaigns)-1) + `)`
+
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE campaign_alerts SET clicks = clicks + 1 WHERE triggered_at IS NULL AND `+in, args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx,
+		`UPDATE campaign_alerts SET triggered_at = ? WHERE triggered_at IS NULL AND clicks >= threshold AND `+in+`
+		RETURNING id, campaign, threshold, clicks, webhook_url, email, created_at, triggered_at`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var triggered []models.CampaignAlert
+	for rows.Next() {
+		alert, err := scanCampaignAlert(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		triggered = append(triggered, alert)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return triggered, tx.Commit()
+}
+
+// scanCampaignAlert reads a campaign alert selected as id, campaign, threshold, clicks,
+// webhook_url, email, created_at, triggered_at.
+func scanCampaignAlert(rows *sql.Rows) (models.CampaignAlert, error) {
+	var alert models.CampaignAlert
+	var triggeredAt sql.NullTime
+	if err := rows.Scan(&alert.ID, &alert.Campaign, &alert.Threshold, &alert.Clicks, &alert.WebhookURL, &alert.Email, &alert.CreatedAt, &triggeredAt); err != nil {
+		return alert, err
+	}
+	if triggeredAt.Valid {
+		t := triggeredAt.Time
+		alert.TriggeredAt = &t
+	}
+	return alert, nil
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestCampaignAlerts(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "ad1", LongURL: "https://example.com/1", CreatedAt: now, Tags: []string{"paid", "spring"}}))
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "ad2", LongURL: "https://example.com/2", CreatedAt: now, Tags: []string{"paid"}, NoAnalytics: true}))
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "other", LongURL: "https://example.com/3", CreatedAt: now}))
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "ad1", At: now}))
+	require.NoError(t, CountClick(ctx, "ad2"))
+	require.NoError(t, CountClick(ctx, "ad2"))
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "other", At: now}))
+
+	clicks, err := CampaignClicks(ctx, "paid")
+	require.NoError(t, err)
+	assert.Equal(t, 3, clicks)
+
+	id, err := CreateCampaignAlert(ctx, models.CampaignAlert{Campaign: "paid", Threshold: 5, Clicks: clicks, Email: "ads@example.com", CreatedAt: now})
+	require.NoError(t, err)
+	_, err = CreateCampaignAlert(ctx, models.CampaignAlert{Campaign: "spring", Threshold: 100, CreatedAt: now})
+	require.NoError(t, err)
+
+	triggered, err := CountCampaignClick(ctx, []string{"paid", "spring"}, now)
+	require.NoError(t, err)
+	assert.Empty(t, triggered)
+	triggered, err = CountCampaignClick(ctx, []string{"paid"}, now)
+	require.NoError(t, err)
+	require.Len(t, triggered, 1)
+	assert.Equal(t, id, triggered[0].ID)
+	assert.Equal(t, 5, triggered[0].Clicks)
+	assert.Equal(t, "ads@example.com", triggered[0].Email)
+	require.NotNil(t, triggered[0].TriggeredAt)
+
+	// An alert triggers once; its count stops with it.
+	triggered, err = CountCampaignClick(ctx, []string{"paid"}, now)
+	require.NoError(t, err)
+	assert.Empty(t, triggered)
+
+	alerts, err := ListCampaignAlerts(ctx)
+	require.NoError(t, err)
+	require.Len(t, alerts, 2)
+	assert.Equal(t, 5, alerts[0].Clicks)
+	assert.NotNil(t, alerts[0].TriggeredAt)
+	assert.Equal(t, 1, alerts[1].Clicks)
+	assert.Nil(t, alerts[1].TriggeredAt)
+
+	require.NoError(t, DeleteCampaignAlert(ctx, id))
+	assert.ErrorIs(t, DeleteCampaignAlert(ctx, id), ErrCampaignAlertNotFound)
+}
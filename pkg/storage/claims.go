@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// SaveClaimToken stores the hash of the claim token of an anonymous link.
+func SaveClaimToken(ctx context.Context, shortCode, tokenHash string) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO link_claim_tokens (short_code, token_hash, created_at) VALUES (?, ?, ?)`,
+		shortCode, tokenHash, time.Now().UTC())
+	return err
+}
+
+// ClaimLink makes owner the owner of an anonymous link whose claim token hashes to
+// tokenHash and uses the token up, atomically. It returns ErrClaimNotFound if the
+// token is wrong or was used, or the link has an owner.
+func ClaimLink(ctx context.Context, shortCode, tokenHash, owner string) error {
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM link_claim_tokens WHERE short_code = ? AND token_hash = ?`, shortCode, tokenHash)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrClaimNotFound
+	}
+	res, err = tx.ExecContext(ctx, `UPDATE links SET owner = ? WHERE short_code = ? AND owner = ''`, owner, shortCode)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrClaimNotFound
+	}
+	return tx.Commit()
+}
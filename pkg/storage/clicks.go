@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	customlogger "riid.me/pkg/logger"
+	"riid.me/pkg/models"
+)
+
+// clickTimeLayout is how click timestamps are stored: RFC3339 in UTC with second
+// precision, so they sort chronologically as text.
+const clickTimeLayout = "2006-01-02T15:04:05Z"
+
+// storedTimeLayouts are the timestamp formats found in the clicks table: the current
+// one, SQLite's CURRENT_TIMESTAMP format of rows recorded before migration 13, and
+// Go's time.Time String format some drivers write.
+var storedTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+}
+
+// parseStoredTime parses a timestamp read from SQLite as text and returns it in UTC.
+func parseStoredTime(s string) (time.Time, error) {
+	for _, layout := range storedTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
+
+// RecordClick stores a click on a link.
+func RecordClick(ctx context.Context, click models.ClickRecord) error {
+	var clickID sql.NullString
+	if click.ClickID != "" {
+		clickID = sql.NullString{String: click.ClickID, Valid: true}
+	}
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT INTO clicks (short_code, timestamp, user_agent, referrer, destination, visitor, source, variant, country, click_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		click.ShortCode, click.At.UTC().Format(clickTimeLayout), click.UserAgent, click.Referrer, encryptDestination(click.Destination),
+		click.Visitor, click.Source, click.Variant, click.Country, clickID)
+	return err
+}
+
+// CountClick adds a click to the bare click count of a link that keeps no click data.
+func CountClick(ctx context.Context, shortCode string) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT INTO click_counts (short_code, clicks) VALUES (?, 1)
+		ON CONFLICT(short_code) DO UPDATE SET clicks = clicks + 1`, shortCode)
+	return err
+}
+
+// DeleteClicks removes the click history, conversions and click count of a link and
+// returns how many clicks were removed.
+func DeleteClicks(ctx context.Context, shortCode string) (int64, error) {
+	var counted int64
+	err := StatsDB.QueryRowContext(ctx, `DELETE FROM click_counts WHERE short_code = ? RETURNING clicks`, shortCode).Scan(&counted)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	if _, err := StatsDB.ExecContext(ctx, `DELETE FROM conversions WHERE short_code = ?`, shortCode); err != nil {
+		return 0, err
+	}
+	res, err := StatsDB.ExecContext(ctx, `DELETE FROM clicks WHERE short_code = ?`, shortCode)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return n + counted, err
+}
+
+const (
+	// listClicksQuery reads a link's newest clicks; idx_clicks_short_code_timestamp
+	// serves both the filter and the order, so no sort step is needed.
+	listClicksQuery = `SELECT CAST(timestamp AS TEXT), user_agent, referrer FROM clicks
+		WHERE short_code = ? ORDER BY timestamp DESC, id DESC LIMIT ?`
+	// countClicksQuery counts a link's clicks from idx_clicks_short_code_timestamp alone.
+	countClicksQuery = `SELECT COUNT(*) FROM clicks WHERE short_code = ?`
+)
+
+// ListClicks returns up to limit recorded clicks of a link, newest first. Timestamps
+// are read as text and parsed here so they do not depend on how the driver formats
+// DATETIME.
+func ListClicks(ctx context.Context, shortCode string, limit int) ([]models.ClickDetail, error) {
+	rows, err := StatsDB.QueryContext(ctx, listClicksQuery, shortCode, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clicks []models.ClickDetail
+	for rows.Next() {
+		var cd models.ClickDetail
+		var timestamp string
+		if err := rows.Scan(&timestamp, &cd.UserAgent, &cd.Referrer); err != nil {
+			return nil, err
+		}
+		if cd.Timestamp, err = parseStoredTime(timestamp); err != nil {
+			customlogger.Error().Err(err).Str("short_code", shortCode).Msg("Skipping click with unparseable timestamp")
+			continue
+		}
+		clicks = append(clicks, cd)
+	}
+	return clicks, rows.Err()
+}
+
+// CountClicks returns the number of recorded clicks of a link.
+func CountClicks(ctx context.Context, shortCode string) (int, error) {
+	var n int
+	err := StatsDB.QueryRowContext(ctx, countClicksQuery, shortCode).Scan(&n)
+	return n, err
+}
+
+// ClickTotalsByCode returns the total and unique click counts of each of codes in a
+// single query. Codes without clicks are reported with zero counts. Clicks recorded
+// before visitors were tracked, and bare counts of links keeping no click data, count
+// towards the total only.
+func ClickTotalsByCode(ctx context.Context, codes []string) (map[string]models.ClickTotals, error) {
+	totals := make(map[string]models.ClickTotals, len(codes))
+	if len(codes) == 0 {
+		return totals, nil
+	}
+	args := make([]interface{}, len(codes))
+	for i, code := range codes {
+		args[i] = code
+		totals[code] = models.ClickTotals{}
+	}
+
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT short_code, COUNT(*), COUNT(DISTINCT visitor) FROM clicks
+		WHERE short_code IN (?`+strings.Repeat(", ?", len(codes)-1)+`) GROUP BY short_code`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code string
+		var t models.ClickTotals
+		if err := rows.Scan(&code, &t.TotalClicks, &t.UniqueClicks); err != nil {
+			return nil, err
+		}
+		totals[code] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	counts, err := StatsDB.QueryContext(ctx,
+		`SELECT short_code, clicks FROM click_counts
+		WHERE short_code IN (?`+strings.Repeat(", ?", len(codes)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer counts.Close()
+	for counts.Next() {
+		var code string
+		var clicks int
+		if err := counts.Scan(&code, &clicks); err != nil {
+			return nil, err
+		}
+		t := totals[code]
+		t.TotalClicks += clicks
+		totals[code] = t
+	}
+	return totals, counts.Err()
+}
+
+// ClicksBySource returns the total and unique click counts of a link per source, most
+// clicked first. Untagged clicks are left out.
+func ClicksBySource(ctx context.Context, shortCode string) ([]models.SourceClicks, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT source, COUNT(*), COUNT(DISTINCT visitor) FROM clicks
+		WHERE short_code = ? AND source != '' GROUP BY source ORDER BY COUNT(*) DESC, source`, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := []models.SourceClicks{}
+	for rows.Next() {
+		var sc models.SourceClicks
+		if err := rows.Scan(&sc.Source, &sc.TotalClicks, &sc.UniqueClicks); err != nil {
+			return nil, err
+		}
+		sources = append(sources, sc)
+	}
+	return sources, rows.Err()
+}
+
+// ClicksByVariant returns the total and unique clicks of a link per A/B split variant,
+// most clicked first. Clicks served no variant are left out.
+func ClicksByVariant(ctx context.Context, shortCode string) ([]models.VariantClicks, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT variant, COUNT(*), COUNT(DISTINCT visitor) FROM clicks
+		WHERE short_code = ? AND variant != '' GROUP BY variant ORDER BY COUNT(*) DESC, variant`, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	variants := []models.VariantClicks{}
+	for rows.Next() {
+		var vc models.VariantClicks
+		if err := rows.Scan(&vc.Variant, &vc.TotalClicks, &vc.UniqueClicks); err != nil {
+			return nil, err
+		}
+		variants = append(variants, vc)
+	}
+	return variants, rows.Err()
+}
+
+// ClickSeries counts the clicks between from and to in buckets of step, aligned to the
+// Unix epoch, per link of codes or, without codes, of all links together. Every bucket
+// is reported, with zero clicks if it has none, ordered by link and then time. Bare
+// click counts of links keeping no click data have no time and are left out.
+func ClickSeries(ctx context.Context, codes []string, from, to time.Time, step time.Duration) ([]models.ClickSeriesPoint, error) {
+	seconds := int64(step / time.Second)
+	query := `SELECT '', CAST(strftime('%s', timestamp) AS INTEGER) / ? * ?, COUNT(*) FROM clicks
+		WHERE timestamp >= ? AND timestamp < ? GROUP BY 1, 2`
+	args := []interface{}{seconds, seconds, from.UTC().Format(clickTimeLayout), to.UTC().Format(clickTimeLayout)}
+	series := []string{""}
+	if len(codes) > 0 {
+		query = `SELECT short_code, CAST(strftime('%s', timestamp) AS INTEGER) / ? * ?, COUNT(*) FROM clicks
+		WHERE timestamp >= ? AND timestamp < ? AND short_code IN (?` + strings.Repeat(", ?", len(codes)-1) + `) GROUP BY 1, 2`
+		for _, code := range codes {
+			args = append(args, code)
+		}
+		series = codes
+	}
+
+	rows, err := StatsDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return bucketClickSeries(rows, series, from, to, seconds)
+}
+
+// bucketClickSeries reads (series, bucket, clicks) rows into a point per bucket of
+// seconds between from and to for each of series, with zero clicks for buckets without
+// a row.
+func bucketClickSeries(rows *sql.Rows, series []string, from, to time.Time, seconds int64) ([]models.ClickSeriesPoint, error) {
+	counts := map[string]map[int64]int{}
+	for rows.Next() {
+		var code string
+		var bucket int64
+		var n int
+		if err := rows.Scan(&code, &bucket, &n); err != nil {
+			return nil, err
+		}
+		if counts[code] == nil {
+			counts[code] = map[int64]int{}
+		}
+		counts[code][bucket] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	first := from.Unix() / seconds * seconds
+	points := []models.ClickSeriesPoint{}
+	for _, code := range series {
+		for bucket := first; bucket < to.Unix(); bucket += seconds {
+			points = append(points, models.ClickSeriesPoint{Time: time.Unix(bucket, 0).UTC(), ShortCode: code, Clicks: counts[code][bucket]})
+		}
+	}
+	return points, nil
+}
+
+// taggedLinksQuery selects, as the CTE tagged, the short codes of the links tagged
+// with its first argument and, unless its second is empty, owned by that owner.
+const taggedLinksQuery = `WITH tagged AS (SELECT DISTINCT l.short_code FROM links l, json_each(l.tags) t
+	WHERE t.value = ? AND (? = '' OR l.owner = ?)) `
+
+// TagClickTotals returns how many links are tagged with tag and their total and unique
+// clicks together, of all links or, when owner is set, only of owner's. A visitor of
+// several of the links counts once.
+func TagClickTotals(ctx context.Context, tag, owner string) (models.TagStatsResponse, error) {
+	stats := models.TagStatsResponse{Tag: tag}
+	err := StatsDB.QueryRowContext(ctx, taggedLinksQuery+`SELECT
+		(SELECT COUNT(*) FROM tagged),
+		(SELECT COUNT(*) FROM clicks WHERE short_code IN (SELECT short_code FROM tagged))
+			+ (SELECT COALESCE(SUM(clicks), 0) FROM click_counts WHERE short_code IN (SELECT short_code FROM tagged)),
+		(SELECT COUNT(DISTINCT visitor) FROM clicks WHERE short_code IN (SELECT short_code FROM tagged))`,
+		tag, owner, owner,
+	).Scan(&stats.Links, &stats.TotalClicks, &stats.UniqueClicks)
+	return stats, err
+}
+
+// TagClickSeries counts the clicks of the links tagged with tag, of all links or, when
+// owner is set, only of owner's, together between from and to in buckets of step, like
+// ClickSeries does for all links.
+func TagClickSeries(ctx context.Context, tag, owner string, from, to time.Time, step time.Duration) ([]models.ClickSeriesPoint, error) {
+	seconds := int64(step / time.Second)
+	rows, err := StatsDB.QueryContext(ctx, taggedLinksQuery+`SELECT '', CAST(strftime('%s', timestamp) AS INTEGER) / ? * ?, COUNT(*) FROM clicks
+		WHERE timestamp >= ? AND timestamp < ? AND short_code IN (SELECT short_code FROM tagged) GROUP BY 1, 2`,
+		tag, owner, owner, seconds, seconds, from.UTC().Format(clickTimeLayout), to.UTC().Format(clickTimeLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return bucketClickSeries(rows, []string{""}, from, to, seconds)
+}
+
+// TopLinksByClicks returns the limit links clicked most between from and to, with
+// their total and unique clicks in that range, most clicked first.
+func TopLinksByClicks(ctx context.Context, from, to time.Time, limit int) ([]models.LinkClicks, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT short_code, COUNT(*), COUNT(DISTINCT visitor) FROM clicks
+		WHERE timestamp >= ? AND timestamp < ?
+		GROUP BY short_code ORDER BY COUNT(*) DESC, short_code LIMIT ?`,
+		from.UTC().Format(clickTimeLayout), to.UTC().Format(clickTimeLayout), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.LinkClicks{}
+	for rows.Next() {
+		var lc models.LinkClicks
+		if err := rows.Scan(&lc.ShortCode, &lc.TotalClicks, &lc.UniqueClicks); err != nil {
+			return nil, err
+		}
+		links = append(links, lc)
+	}
+	return links, rows.Err()
+}
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestListClicksNormalizesTimestamps(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	// Rows as written before and by other drivers.
+	_, err := db.Exec(`INSERT INTO clicks (short_code, timestamp) VALUES ('abc', '2024-03-01 10:00:00')`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO clicks (short_code, timestamp) VALUES ('abc', '2024-03-01 11:00:00.5 +0000 UTC')`)
+	require.NoError(t, err)
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{
+		ShortCode: "abc", UserAgent: "ua", Referrer: "ref", Destination: "https://example.com", Visitor: "v1",
+		At: time.Date(2024, 3, 1, 13, 0, 0, 0, time.FixedZone("CET", 3600)),
+	}))
+
+	clicks, err := ListClicks(ctx, "abc", 10)
+	require.NoError(t, err)
+	require.Len(t, clicks, 3)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC), clicks[0].Timestamp)
+	assert.Equal(t, time.Date(2024, 3, 1, 11, 0, 0, 5e8, time.UTC), clicks[1].Timestamp)
+	assert.Equal(t, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), clicks[2].Timestamp)
+
+	var raw string
+	require.NoError(t, db.QueryRow(`SELECT timestamp FROM clicks WHERE user_agent = 'ua'`).Scan(&raw))
+	assert.Equal(t, "2024-03-01T12:00:00Z", raw)
+}
+
+func TestTimestampMigrationRewritesLegacyRows(t *testing.T) {
+	db := openTestDB(t)
+	_, err := db.Exec(`INSERT INTO clicks (short_code, timestamp) VALUES ('abc', '2024-03-01 10:00:00')`)
+	require.NoError(t, err)
+
+	_, err = db.Exec(migrations[12])
+	require.NoError(t, err)
+	var raw string
+	require.NoError(t, db.QueryRow(`SELECT CAST(timestamp AS TEXT) FROM clicks`).Scan(&raw))
+	assert.Equal(t, "2024-03-01T10:00:00Z", raw)
+}
+
+// queryPlan returns the EXPLAIN QUERY PLAN details of query, joined with newlines.
+func queryPlan(t *testing.T, query string, args ...interface{}) string {
+	t.Helper()
+	rows, err := StatsDB.Query(`EXPLAIN QUERY PLAN `+query, args...)
+	require.NoError(t, err)
+	defer rows.Close()
+	var plan []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		require.NoError(t, rows.Scan(&id, &parent, &unused, &detail))
+		plan = append(plan, detail)
+	}
+	return strings.Join(plan, "\n")
+}
+
+func TestClickQueriesUseIndexes(t *testing.T) {
+	openTestDB(t)
+
+	plan := queryPlan(t, listClicksQuery, "abc", 10)
+	assert.Contains(t, plan, "idx_clicks_short_code_timestamp")
+	assert.NotContains(t, plan, "TEMP B-TREE", "ordering must come from the index")
+
+	assert.Contains(t, queryPlan(t, countClicksQuery, "abc"), "idx_clicks_short_code_timestamp")
+	assert.Contains(t, queryPlan(t, `SELECT COUNT(*) FROM clicks WHERE timestamp >= ?`, "2024-01-01T00:00:00Z"), "idx_clicks_timestamp")
+}
+
+func TestRunMaintenance(t *testing.T) {
+	openTestDB(t)
+	require.NoError(t, RecordClick(context.Background(), models.ClickRecord{ShortCode: "abc", Destination: "https://example.com", Visitor: "v1", At: time.Now()}))
+
+	result, err := RunMaintenance(context.Background(), true)
+	require.NoError(t, err)
+	assert.True(t, result.Reindexed)
+	assert.EqualValues(t, 1, result.ClickRows)
+	assert.Equal(t, []string{"idx_clicks_click_id", "idx_clicks_short_code_timestamp", "idx_clicks_timestamp"}, result.IndexesOnClicks)
+}
+
+func TestClickTotalsByCode(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, click := range []struct{ code, visitor string }{
+		{"a", "v1"}, {"a", "v1"}, {"a", "v2"}, {"b", "v1"},
+	} {
+		require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: click.code, Destination: "https://example.com", Visitor: click.visitor, At: now}))
+	}
+
+	totals, err := ClickTotalsByCode(ctx, []string{"a", "b", "unused"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.ClickTotals{
+		"a":      {TotalClicks: 3, UniqueClicks: 2},
+		"b":      {TotalClicks: 1, UniqueClicks: 1},
+		"unused": {},
+	}, totals)
+}
+
+func TestDeleteClicks(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, code := range []string{"gone", "gone", "kept"} {
+		require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: code, UserAgent: "ua", Destination: "https://example.com", Visitor: "v1", At: now}))
+	}
+
+	purged, err := DeleteClicks(ctx, "gone")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, purged)
+	totals, err := ClickTotalsByCode(ctx, []string{"gone", "kept"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, totals["gone"].TotalClicks)
+	assert.Equal(t, 1, totals["kept"].TotalClicks)
+}
+
+func TestClicksBySource(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, click := range []struct{ visitor, source string }{
+		{"v1", "lobby"}, {"v1", "lobby"}, {"v2", "lobby"}, {"v1", "flyer-b"}, {"v3", ""},
+	} {
+		require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "abc", Destination: "https://example.com", Visitor: click.visitor, Source: click.source, At: now}))
+	}
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "other", Destination: "https://example.com", Visitor: "v1", Source: "lobby", At: now}))
+
+	sources, err := ClicksBySource(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, []models.SourceClicks{
+		{Source: "lobby", TotalClicks: 3, UniqueClicks: 2},
+		{Source: "flyer-b", TotalClicks: 1, UniqueClicks: 1},
+	}, sources)
+}
+
+func TestClicksByVariant(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+	for _, click := range []struct{ visitor, variant string }{
+		{"v1", "b"}, {"v2", "b"}, {"v2", "b"}, {"v1", "a"}, {"v3", ""},
+	} {
+		require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "abc", Destination: "https://example.com", Visitor: click.visitor, Variant: click.variant, At: now}))
+	}
+
+	variants, err := ClicksByVariant(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, []models.VariantClicks{
+		{Variant: "b", TotalClicks: 3, UniqueClicks: 2},
+		{Variant: "a", TotalClicks: 1, UniqueClicks: 1},
+	}, variants)
+}
+
+func TestCountClick(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	require.NoError(t, CountClick(ctx, "private"))
+	require.NoError(t, CountClick(ctx, "private"))
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "tracked", UserAgent: "Mozilla/5.0", Destination: "https://example.com", Visitor: "v1", At: time.Now()}))
+
+	totals, err := ClickTotalsByCode(ctx, []string{"private", "tracked"})
+	require.NoError(t, err)
+	assert.Equal(t, models.ClickTotals{TotalClicks: 2}, totals["private"], "counted clicks have no unique visitors")
+	assert.Equal(t, models.ClickTotals{TotalClicks: 1, UniqueClicks: 1}, totals["tracked"])
+	clicks, err := ListClicks(ctx, "private", 10)
+	require.NoError(t, err)
+	assert.Empty(t, clicks)
+
+	purged, err := DeleteClicks(ctx, "private")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+	totals, err = ClickTotalsByCode(ctx, []string{"private"})
+	require.NoError(t, err)
+	assert.Zero(t, totals["private"].TotalClicks)
+}
+
+func TestClickSeries(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, click := range []struct {
+		code string
+		at   time.Duration
+	}{{"a", 5 * time.Minute}, {"a", 10 * time.Minute}, {"b", 70 * time.Minute}, {"a", 3 * time.Hour}} {
+		require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: click.code, UserAgent: "ua", Destination: "https://example.com", Visitor: click.code + "-visitor", At: base.Add(click.at)}))
+	}
+
+	points, err := ClickSeries(ctx, nil, base, base.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ClickSeriesPoint{
+		{Time: base, Clicks: 2},
+		{Time: base.Add(time.Hour), Clicks: 1},
+	}, points)
+
+	points, err = ClickSeries(ctx, []string{"a", "b"}, base, base.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ClickSeriesPoint{
+		{Time: base, ShortCode: "a", Clicks: 2},
+		{Time: base.Add(time.Hour), ShortCode: "a"},
+		{Time: base, ShortCode: "b"},
+		{Time: base.Add(time.Hour), ShortCode: "b", Clicks: 1},
+	}, points)
+
+	links, err := TopLinksByClicks(ctx, base, base.Add(4*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []models.LinkClicks{
+		{ShortCode: "a", TotalClicks: 3, UniqueClicks: 1},
+		{ShortCode: "b", TotalClicks: 1, UniqueClicks: 1},
+	}, links)
+}
+
+func TestTagClickStats(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	base := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "a", LongURL: "https://example.com/a", CreatedAt: base, Tags: []string{"launch"}, Owner: "alice"}))
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "b", LongURL: "https://example.com/b", CreatedAt: base, Tags: []string{"launch", "paid"}, Owner: "bob"}))
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "c", LongURL: "https://example.com/c", CreatedAt: base, Tags: []string{"other"}, Owner: "alice"}))
+	for _, click := range []struct {
+		code, visitor string
+		at            time.Duration
+	}{{"a", "v1", 5 * time.Minute}, {"b", "v1", 10 * time.Minute}, {"b", "v2", 70 * time.Minute}, {"c", "v3", 5 * time.Minute}} {
+		require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: click.code, UserAgent: "ua", Destination: "https://example.com", Visitor: click.visitor, At: base.Add(click.at)}))
+	}
+	require.NoError(t, CountClick(ctx, "a"))
+
+	stats, err := TagClickTotals(ctx, "launch", "")
+	require.NoError(t, err)
+	assert.Equal(t, models.TagStatsResponse{Tag: "launch", Links: 2, TotalClicks: 4, UniqueClicks: 2}, stats)
+
+	stats, err = TagClickTotals(ctx, "launch", "alice")
+	require.NoError(t, err)
+	assert.Equal(t, models.TagStatsResponse{Tag: "launch", Links: 1, TotalClicks: 2, UniqueClicks: 1}, stats)
+
+	points, err := TagClickSeries(ctx, "launch", "", base, base.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ClickSeriesPoint{
+		{Time: base, Clicks: 2},
+		{Time: base.Add(time.Hour), Clicks: 1},
+	}, points)
+
+	points, err = TagClickSeries(ctx, "launch", "bob", base, base.Add(2*time.Hour), time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []models.ClickSeriesPoint{
+		{Time: base, Clicks: 1},
+		{Time: base.Add(time.Hour), Clicks: 1},
+	}, points)
+}
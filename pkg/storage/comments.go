@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// ErrCommentNotFound is returned when a comment does not exist for the given link.
+var ErrCommentNotFound = fmt.Errorf("comment %w", ErrNotFound)
+
+// AddComment stores a new comment on a link and returns it with its ID and creation time set.
+func AddComment(ctx context.Context, shortCode, author, body string) (models.LinkComment, error) {
+	comment := models.LinkComment{
+		ShortCode: shortCode,
+		Author:    author,
+		Body:      body,
+		CreatedAt: time.Now().UTC(),
+	}
+	res, err := StatsDB.ExecContext(ctx,
+		`INSERT INTO link_comments (short_code, author, body, created_at) VALUES (?, ?, ?, ?)`,
+		comment.ShortCode, comment.Author, comment.Body, comment.CreatedAt)
+	if err != nil {
+		return models.LinkComment{}, err
+	}
+	comment.ID, err = res.LastInsertId()
+	return comment, err
+}
+
+// ListComments returns all comments on a link, oldest first.
+func ListComments(ctx context.Context, shortCode string) ([]models.LinkComment, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT id, short_code, author, body, created_at FROM link_comments WHERE short_code = ? ORDER BY created_at ASC, id ASC`,
+		shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.LinkComment{}
+	for rows.Next() {
+		var c models.LinkComment
+		if err := rows.Scan(&c.ID, &c.ShortCode, &c.Author, &c.Body, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// DeleteComment removes a comment from a link. It returns ErrCommentNotFound
+// if no comment with that ID exists on the link.
+func DeleteComment(ctx context.Context, shortCode string, id int64) error {
+	res, err := StatsDB.ExecContext(ctx, `DELETE FROM link_comments WHERE id = ? AND short_code = ?`, id, shortCode)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrCommentNotFound
+	}
+	return nil
+}
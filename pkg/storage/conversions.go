@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// GetClickByID returns the link and time of the click with the given click ID. It
+// returns ErrNotFound if no click has that ID.
+func GetClickByID(ctx context.Context, clickID string) (string, time.Time, error) {
+	var shortCode, timestamp string
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT short_code, CAST(timestamp AS TEXT) FROM clicks WHERE click_id = ?`, clickID,
+	).Scan(&shortCode, &timestamp)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, ErrNotFound
+	} else if err != nil {
+		return "", time.Time{}, err
+	}
+	clickedAt, err := parseStoredTime(timestamp)
+	return shortCode, clickedAt, err
+}
+
+// RecordConversion stores a conversion and reports whether it is new: a click's
+// conversion of the same event is only kept once, so retried postbacks are harmless.
+func RecordConversion(ctx context.Context, conversion models.Conversion) (bool, error) {
+	var value sql.NullFloat64
+	if conversion.Value != nil {
+		value = sql.NullFloat64{Float64: *conversion.Value, Valid: true}
+	}
+	res, err := StatsDB.ExecContext(ctx,
+		`INSERT OR IGNORE INTO conversions (click_id, short_code, event, value, currency, clicked_at, converted_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		conversion.ClickID, conversion.ShortCode, conversion.Event, value, conversion.Currency,
+		conversion.ClickedAt.UTC().Format(clickTimeLayout), conversion.ConvertedAt.UTC().Format(clickTimeLayout))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ConversionsByEvent returns how many conversions of each event the clicks of a link
+// led to and the sum of their values, most frequent first.
+func ConversionsByEvent(ctx context.Context, shortCode string) ([]models.ConversionCount, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT event, COUNT(*), COALESCE(SUM(value), 0) FROM conversions
+		WHERE short_code = ? GROUP BY event ORDER BY COUNT(*) DESC, event`, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.ConversionCount{}
+	for rows.Next() {
+		var cc models.ConversionCount
+		if err := rows.Scan(&cc.Event, &cc.Conversions, &cc.Value); err != nil {
+			return nil, err
+		}
+		counts = append(counts, cc)
+	}
+	return counts, rows.Err()
+}
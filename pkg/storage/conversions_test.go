@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestConversions(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	clickedAt := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "abc", Destination: "https://example.com", ClickID: "c1", At: clickedAt}))
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "abc", Destination: "https://example.com", At: clickedAt}))
+
+	code, at, err := GetClickByID(ctx, "c1")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", code)
+	assert.Equal(t, clickedAt, at)
+	_, _, err = GetClickByID(ctx, "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	value := 12.5
+	conversion := models.Conversion{ClickID: "c1", ShortCode: "abc", Event: "purchase", Value: &value, Currency: "EUR", ClickedAt: clickedAt, ConvertedAt: clickedAt.Add(time.Hour)}
+	recorded, err := RecordConversion(ctx, conversion)
+	require.NoError(t, err)
+	assert.True(t, recorded)
+	recorded, err = RecordConversion(ctx, conversion)
+	require.NoError(t, err)
+	assert.False(t, recorded, "a repeated postback is recorded once")
+
+	conversion.Event, conversion.Value = "signup", nil
+	_, err = RecordConversion(ctx, conversion)
+	require.NoError(t, err)
+
+	counts, err := ConversionsByEvent(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, []models.ConversionCount{
+		{Event: "purchase", Conversions: 1, Value: 12.5},
+		{Event: "signup", Conversions: 1},
+	}, counts)
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// dedupeHash returns the hex SHA-256 of a destination, under which the reverse index
+// keeps it, so it holds no destinations even when they are stored encrypted.
+func dedupeHash(longURL string) string {
+	sum := sha256.Sum256([]byte(longURL))
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveDedupedLink records shortCode as owner's link to longURL in the reverse index,
+// replacing an earlier one.
+func SaveDedupedLink(ctx context.Context, owner, longURL, shortCode string) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO link_dedupe (owner, url_hash, short_code) VALUES (?, ?, ?)`,
+		owner, dedupeHash(longURL), shortCode)
+	return err
+}
+
+// DedupedLink returns the short code of owner's link to longURL from the reverse index,
+// or ErrNotFound. Links that no longer qualify, because they were deleted, expired,
+// disabled, handed to someone else or pointed elsewhere since, are not returned.
+func DedupedLink(ctx context.Context, owner, longURL string) (string, error) {
+	forms := storedDestinations(longURL)
+	args := []any{owner, dedupeHash(longURL), time.Now().UTC()}
+	for _, form := range forms {
+		args = append(args, form)
+	}
+	var shortCode string
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT d.short_code FROM link_dedupe d JOIN links l ON l.short_code = d.short_code
+		WHERE d.owner = ? AND d.url_hash = ? AND l.owner = d.owner AND l.draft = 0
+		AND (l.expires_at IS NULL OR l.expires_at > ?)
+		AND l.long_url IN (?`+strings.Repeat(", ?", len(forms)-1)+`)
+		AND NOT EXISTS (SELECT 1 FROM link_takedowns t WHERE t.short_code = d.short_code)`, args...,
+	).Scan(&shortCode)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	return shortCode, err
+}
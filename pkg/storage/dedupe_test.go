@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestDedupedLink(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	past := now.Add(-time.Hour)
+
+	link := models.Link{ShortCode: "first", LongURL: "https://example.com/a", CreatedAt: now, Owner: "alice"}
+	require.NoError(t, SaveLink(ctx, link))
+	require.NoError(t, SaveDedupedLink(ctx, "alice", link.LongURL, "first"))
+
+	code, err := DedupedLink(ctx, "alice", "https://example.com/a")
+	require.NoError(t, err)
+	assert.Equal(t, "first", code)
+	_, err = DedupedLink(ctx, "bob", "https://example.com/a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = DedupedLink(ctx, "alice", "https://example.com/b")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Links that were repointed, expired or taken down are not returned.
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "moved", LongURL: "https://example.com/elsewhere", CreatedAt: now, Owner: "alice"}))
+	require.NoError(t, SaveDedupedLink(ctx, "alice", "https://example.com/moved", "moved"))
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "old", LongURL: "https://example.com/old", CreatedAt: now, ExpiresAt: &past, Owner: "alice"}))
+	require.NoError(t, SaveDedupedLink(ctx, "alice", "https://example.com/old", "old"))
+	require.NoError(t, SaveLink(ctx, models.Link{ShortCode: "bad", LongURL: "https://example.com/bad", CreatedAt: now, Owner: "alice"}))
+	require.NoError(t, SaveDedupedLink(ctx, "alice", "https://example.com/bad", "bad"))
+	require.NoError(t, SaveLinkTakedown(ctx, models.LinkTakedown{ShortCode: "bad", Reason: "spam", CreatedAt: now}))
+	for _, longURL := range []string{"https://example.com/moved", "https://example.com/old", "https://example.com/bad"} {
+		_, err = DedupedLink(ctx, "alice", longURL)
+		assert.ErrorIs(t, err, ErrNotFound, longURL)
+	}
+
+	require.NoError(t, DeleteLink(ctx, link))
+	_, err = DedupedLink(ctx, "alice", "https://example.com/a")
+	assert.ErrorIs(t, err, ErrNotFound)
+	var count int
+	require.NoError(t, StatsDB.QueryRow(`SELECT COUNT(*) FROM link_dedupe WHERE short_code = 'first'`).Scan(&count))
+	assert.Zero(t, count)
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// GetDestination returns the long URL a short code redirects to. It returns
+// ErrLinkExpired if the link's metadata shows it has expired and ErrLinkNotFound
+// if the code is otherwise unknown.
+func GetDestination(ctx context.Context, shortCode string) (string, error) {
+	longURL, err := Rdb.Get(ctx, shortCode).Result()
+	if err == redis.Nil {
+		return "", missingLinkError(ctx, shortCode)
+	} else if err != nil {
+		return "", err
+	}
+	return decryptDestination(longURL)
+}
+
+// CreateDestination stores the long URL for a new short code, expiring after ttl
+// (0 means never). It returns ErrLinkExists if the code is already in use.
+func CreateDestination(ctx context.Context, shortCode, longURL string, ttl time.Duration) error {
+	created, err := Rdb.SetNX(ctx, shortCode, encryptDestination(longURL), ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !created {
+		return ErrLinkExists
+	}
+	return nil
+}
+
+// SetDestination stores or replaces the long URL for a short code, expiring after
+// ttl (0 means never).
+func SetDestination(ctx context.Context, shortCode, longURL string, ttl time.Duration) error {
+	return Rdb.Set(ctx, shortCode, encryptDestination(longURL), ttl).Err()
+}
+
+// DeleteDestination removes a short code's destination, along with its redirect
+// count. Deleting a missing code is not an error.
+func DeleteDestination(ctx context.Context, shortCode string) error {
+	return Rdb.Del(ctx, shortCode, redirectCountKey(shortCode)).Err()
+}
+
+// shortCodeSequenceKey is the Redis key numbering sequential short codes.
+const shortCodeSequenceKey = "sequence:shortcodes"
+
+// NextShortCodeSequence returns the next number of the sequential short codes,
+// starting from 1. Each number is handed out once, across instances.
+func NextShortCodeSequence(ctx context.Context) (int64, error) {
+	return Rdb.Incr(ctx, shortCodeSequenceKey).Result()
+}
+
+// redirectCountKey is the Redis key counting the redirects of a link with max_clicks.
+func redirectCountKey(shortCode string) string {
+	return "redirects:" + shortCode
+}
+
+// countRedirectScript increments the redirect count under KEYS[1] and has it expire
+// together with the destination under KEYS[2], so a code reused after its link expired
+// starts from zero. It returns the new count.
+var countRedirectScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+local ttl = redis.call('PTTL', KEYS[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+else
+	redis.call('PERSIST', KEYS[1])
+end
+return count
+`)
+
+// CountRedirect atomically counts a redirect of a link with max_clicks and returns how
+// many redirects it has had, including this one.
+func CountRedirect(ctx context.Context, shortCode string) (int64, error) {
+	return countRedirectScript.Run(ctx, Rdb, []string{redirectCountKey(shortCode), shortCode}).Int64()
+}
+
+// LinkExists reports whether a short code currently has a destination.
+func LinkExists(ctx context.Context, shortCode string) (bool, error) {
+	n, err := Rdb.Exists(ctx, shortCode).Result()
+	return n > 0, err
+}
+
+// missingLinkError tells an expired link apart from an unknown one using the metadata
+// kept after the destination itself is gone.
+func missingLinkError(ctx context.Context, shortCode string) error {
+	link, err := GetLink(ctx, shortCode)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return ErrLinkNotFound
+	}
+	if link.ExpiresAt != nil && !link.ExpiresAt.After(time.Now().UTC()) {
+		return ErrLinkExpired
+	}
+	return ErrLinkNotFound
+}
+
+// GetCached returns a value previously stored with SetCached, or ErrNotFound if it
+// is missing or has expired.
+func GetCached(ctx context.Context, key string) (string, error) {
+	value, err := Rdb.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+// SetCached stores a value under key for ttl.
+func SetCached(ctx context.Context, key, value string, ttl time.Duration) error {
+	return Rdb.Set(ctx, key, value, ttl).Err()
+}
+
+// IncrementCounter increments the counter under key and returns its new value. A new
+// counter expires after ttl, which makes it a fixed-window rate limit counter.
+func IncrementCounter(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	count, err := Rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := Rdb.Expire(ctx, key, ttl).Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
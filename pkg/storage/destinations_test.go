@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/models"
+)
+
+func TestMissingLinkError(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	past := time.Now().UTC().Add(-time.Hour)
+	future := time.Now().UTC().Add(time.Hour)
+
+	assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: "gone", LongURL: "https://example.com", CreatedAt: past, ExpiresAt: &past}))
+	assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: "live", LongURL: "https://example.com", CreatedAt: past, ExpiresAt: &future}))
+
+	err := missingLinkError(ctx, "gone")
+	assert.True(t, errors.Is(err, ErrExpired))
+	assert.Equal(t, ErrLinkExpired, err)
+
+	assert.Equal(t, ErrLinkNotFound, missingLinkError(ctx, "live"))
+	assert.Equal(t, ErrLinkNotFound, missingLinkError(ctx, "unknown"))
+
+	_, err = GetLink(ctx, "unknown")
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// ErrDomainNotFound is returned when a domain has no routing entry.
+var ErrDomainNotFound = fmt.Errorf("domain %w", ErrNotFound)
+
+// GetDomainRoute returns the routing entry for a host, or ErrDomainNotFound.
+func GetDomainRoute(ctx context.Context, host string) (models.DomainRoute, error) {
+	var d models.DomainRoute
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT host, root_destination, not_found_html, updated_at FROM domains WHERE host = ?`, host).
+		Scan(&d.Host, &d.RootDestination, &d.NotFoundHTML, &d.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return models.DomainRoute{}, ErrDomainNotFound
+	}
+	return d, err
+}
+
+// ListDomainRoutes returns all domain routing entries.
+func ListDomainRoutes(ctx context.Context) ([]models.DomainRoute, error) {
+	rows, err := StatsDB.QueryContext(ctx, `SELECT host, root_destination, not_found_html, updated_at FROM domains ORDER BY host`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []models.DomainRoute{}
+	for rows.Next() {
+		var d models.DomainRoute
+		if err := rows.Scan(&d.Host, &d.RootDestination, &d.NotFoundHTML, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// SaveDomainRoute creates or replaces the routing entry for a host.
+func SaveDomainRoute(ctx context.Context, d models.DomainRoute) (models.DomainRoute, error) {
+	d.UpdatedAt = time.Now().UTC()
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO domains (host, root_destination, not_found_html, updated_at) VALUES (?, ?, ?, ?)`,
+		d.Host, d.RootDestination, d.NotFoundHTML, d.UpdatedAt)
+	return d, err
+}
+
+// DeleteDomainRoute removes the routing entry for a host.
+func DeleteDomainRoute(ctx context.Context, host string) error {
+	res, err := StatsDB.ExecContext(ctx, `DELETE FROM domains WHERE host = ?`, host)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDomainNotFound
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// SavePreviewToken stores the preview token of a draft link, replacing any previous
+// token so only the newest one grants access.
+func SavePreviewToken(ctx context.Context, shortCode, token string, expiresAt time.Time) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO link_preview_tokens (short_code, token, expires_at) VALUES (?, ?, ?)`,
+		shortCode, token, expiresAt.UTC())
+	return err
+}
+
+// ValidPreviewToken reports whether token is the current, unexpired preview token of a link.
+func ValidPreviewToken(ctx context.Context, shortCode, token string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+	var n int
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM link_preview_tokens WHERE short_code = ? AND token = ? AND expires_at > ?`,
+		shortCode, token, time.Now().UTC()).Scan(&n)
+	return n > 0, err
+}
+
+// PublishLink takes a draft link live: it clears the draft flag, revokes the preview
+// token and records an "updated" event, atomically. It returns ErrDraftNotFound if the
+// link is not a draft.
+func PublishLink(ctx context.Context, shortCode string) (models.Link, error) {
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Link{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+linkColumns+` FROM links WHERE short_code = ? AND draft = 1`, shortCode)
+	if err != nil {
+		return models.Link{}, err
+	}
+	if !rows.Next() {
+		err := rows.Err()
+		rows.Close()
+		if err != nil {
+			return models.Link{}, err
+		}
+		return models.Link{}, ErrDraftNotFound
+	}
+	link, err := scanLink(rows)
+	rows.Close()
+	if err != nil {
+		return models.Link{}, err
+	}
+
+	link.Draft = false
+	if err := saveLink(ctx, tx, link); err != nil {
+		return models.Link{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_preview_tokens WHERE short_code = ?`, shortCode); err != nil {
+		return models.Link{}, err
+	}
+	if err := recordLinkEvent(ctx, tx, models.LinkEventUpdated, link); err != nil {
+		return models.Link{}, err
+	}
+	return link, tx.Commit()
+}
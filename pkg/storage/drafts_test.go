@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestDraftPreviewAndPublish(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	require.NoError(t, CreateLink(ctx, models.Link{ShortCode: "launch", LongURL: "https://example.com", CreatedAt: time.Now().UTC(), Draft: true}))
+
+	codes, err := ActiveCodes(ctx)
+	require.NoError(t, err)
+	assert.NotContains(t, codes, "launch", "drafts are not suggested to visitors")
+
+	require.NoError(t, SavePreviewToken(ctx, "launch", "old", time.Now().Add(time.Hour)))
+	require.NoError(t, SavePreviewToken(ctx, "launch", "new", time.Now().Add(time.Hour)))
+	ok, err := ValidPreviewToken(ctx, "launch", "old")
+	require.NoError(t, err)
+	assert.False(t, ok, "a new token revokes the previous one")
+	ok, err = ValidPreviewToken(ctx, "launch", "new")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, SavePreviewToken(ctx, "launch", "stale", time.Now().Add(-time.Minute)))
+	ok, err = ValidPreviewToken(ctx, "launch", "stale")
+	require.NoError(t, err)
+	assert.False(t, ok, "expired tokens are rejected")
+
+	link, err := PublishLink(ctx, "launch")
+	require.NoError(t, err)
+	assert.False(t, link.Draft)
+	stored, err := GetLink(ctx, "launch")
+	require.NoError(t, err)
+	assert.False(t, stored.Draft)
+
+	_, err = PublishLink(ctx, "launch")
+	assert.ErrorIs(t, err, ErrDraftNotFound)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
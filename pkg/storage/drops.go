@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// SaveDrop stores the record of a dropped file, replacing any previous record for the
+// short code (e.g. a custom handle re-registered after expiring).
+func SaveDrop(ctx context.Context, drop models.Drop) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO drops (short_code, file_key, filename, content_type, size, downloads, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		drop.ShortCode, drop.FileKey, drop.Filename, drop.ContentType, drop.Size, drop.Downloads, drop.CreatedAt.UTC())
+	return err
+}
+
+// GetDrop returns the record of the file dropped under a short code, or ErrDropNotFound.
+func GetDrop(ctx context.Context, shortCode string) (models.Drop, error) {
+	var drop models.Drop
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT short_code, file_key, filename, content_type, size, downloads, created_at FROM drops WHERE short_code = ?`,
+		shortCode,
+	).Scan(&drop.ShortCode, &drop.FileKey, &drop.Filename, &drop.ContentType, &drop.Size, &drop.Downloads, &drop.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.Drop{}, ErrDropNotFound
+	}
+	return drop, err
+}
+
+// IncrementDropDownloads counts a download of the file dropped under a short code.
+func IncrementDropDownloads(ctx context.Context, shortCode string) error {
+	_, err := StatsDB.ExecContext(ctx, `UPDATE drops SET downloads = downloads + 1 WHERE short_code = ?`, shortCode)
+	return err
+}
+
+// DeleteDrop removes the record of a dropped file.
+func DeleteDrop(ctx context.Context, shortCode string) error {
+	_, err := StatsDB.ExecContext(ctx, `DELETE FROM drops WHERE short_code = ?`, shortCode)
+	return err
+}
+
+// ExpiredDrops returns the drops whose link has expired or no longer has a record.
+func ExpiredDrops(ctx context.Context) ([]models.Drop, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT d.short_code, d.file_key, d.filename, d.content_type, d.size, d.downloads, d.created_at
+		FROM drops d LEFT JOIN links l ON l.short_code = d.short_code
+		WHERE l.short_code IS NULL OR (l.expires_at IS NOT NULL AND l.expires_at <= ?)`, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var drops []models.Drop
+	for rows.Next() {
+		var drop models.Drop
+		if err := rows.Scan(&drop.ShortCode, &drop.FileKey, &drop.Filename, &drop.ContentType, &drop.Size, &drop.Downloads, &drop.CreatedAt); err != nil {
+			return nil, err
+		}
+		drops = append(drops, drop)
+	}
+	return drops, rows.Err()
+}
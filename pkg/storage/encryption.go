@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+)
+
+// encryptedDestinationPrefix marks a stored destination as encrypted, followed by the
+// base64url-encoded nonce and AES-256-GCM ciphertext. Values without it are plain URLs,
+// written before encryption was enabled.
+const encryptedDestinationPrefix = "enc:v1:"
+
+// destinationKey encrypts destinations with one configured key.
+type destinationKey struct {
+	aead     cipher.AEAD
+	nonceKey []byte // derives the nonce of a destination from its plaintext
+}
+
+var (
+	// currentDestinationKey encrypts destinations at rest, nil when encryption is disabled.
+	currentDestinationKey *destinationKey
+	// previousDestinationKey still decrypts destinations while a key is being rotated,
+	// nil outside rotations.
+	previousDestinationKey *destinationKey
+)
+
+// errDestinationKeyMissing is returned when reading an encrypted destination none of
+// the configured keys can decrypt.
+var errDestinationKeyMissing = errors.New("destination is encrypted but no configured DESTINATION_ENCRYPTION_KEY decrypts it")
+
+// InitDestinationEncryption enables encryption of destination URLs at rest if the
+// configuration holds a key, directly or in a file such as one mounted by a KMS or
+// secret manager. Keys are 32 bytes, base64-encoded. A previous key, set while rotating
+// keys, is only used to decrypt.
+func InitDestinationEncryption(cfg config.AppConfig) error {
+	current, err := loadDestinationKey(cfg.DestinationEncryptionKey, cfg.DestinationEncryptionKeyFile)
+	if err != nil {
+		return err
+	}
+	previous, err := loadDestinationKey(cfg.DestinationEncryptionPreviousKey, cfg.DestinationEncryptionPreviousKeyFile)
+	if err != nil {
+		return fmt.Errorf("previous key: %w", err)
+	}
+	currentDestinationKey, previousDestinationKey = current, previous
+	if current != nil {
+		customlogger.Info().Bool("previous_key", previous != nil).Msg("Destination encryption at rest enabled")
+	}
+	return nil
+}
+
+// loadDestinationKey reads a key given directly or, taking precedence, in file. It
+// returns nil if neither is set.
+func loadDestinationKey(encoded, file string) (*destinationKey, error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read destination encryption key: %w", err)
+		}
+		encoded = strings.TrimSpace(string(data))
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("destination encryption key must be 32 bytes, base64-encoded")
+	}
+
+	// Separate keys for encryption and nonces, derived so one secret is enough.
+	block, err := aes.NewCipher(deriveDestinationKey(key, "encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &destinationKey{aead: aead, nonceKey: deriveDestinationKey(key, "nonce")}, nil
+}
+
+// deriveDestinationKey derives the key used for purpose from the configured key.
+func deriveDestinationKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("riid.me destination " + purpose))
+	return mac.Sum(nil)
+}
+
+// encrypt returns destination encrypted with k. The nonce is derived from the
+// destination, so equal destinations encrypt equally and can still be looked up, e.g.
+// by LinksByDestination. This reveals which links share a destination, but not what it is.
+func (k *destinationKey) encrypt(destination string) string {
+	mac := hmac.New(sha256.New, k.nonceKey)
+	mac.Write([]byte(destination))
+	nonce := mac.Sum(nil)[:k.aead.NonceSize()]
+	sealed := k.aead.Seal(nonce, nonce, []byte(destination), nil)
+	return encryptedDestinationPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// decrypt returns the destination sealed with k, or an error if k did not seal it.
+func (k *destinationKey) decrypt(sealed []byte) (string, error) {
+	if len(sealed) < k.aead.NonceSize() {
+		return "", errors.New("malformed encrypted destination")
+	}
+	nonce, ciphertext := sealed[:k.aead.NonceSize()], sealed[k.aead.NonceSize():]
+	plain, err := k.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt destination: %w", err)
+	}
+	return string(plain), nil
+}
+
+// encryptDestination returns the form destination is stored in: encrypted when a key is
+// configured, as is otherwise.
+func encryptDestination(destination string) string {
+	if currentDestinationKey == nil || destination == "" {
+		return destination
+	}
+	return currentDestinationKey.encrypt(destination)
+}
+
+// storedDestinations returns every form destination may be stored in while keys are
+// rotated: encrypted with the current key, with the previous key, and plain.
+func storedDestinations(destination string) []string {
+	forms := []string{encryptDestination(destination)}
+	if previousDestinationKey != nil && destination != "" {
+		forms = append(forms, previousDestinationKey.encrypt(destination))
+	}
+	if forms[0] != destination {
+		forms = append(forms, destination)
+	}
+	return forms
+}
+
+// decryptDestination returns the destination stored as value, trying the current key
+// and then the previous one.
+func decryptDestination(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedDestinationPrefix)
+	if !ok {
+		return value, nil
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New("malformed encrypted destination")
+	}
+	err = errDestinationKeyMissing
+	for _, key := range []*destinationKey{currentDestinationKey, previousDestinationKey} {
+		if key == nil {
+			continue
+		}
+		var destination string
+		if destination, err = key.decrypt(sealed); err == nil {
+			return destination, nil
+		}
+	}
+	return "", err
+}
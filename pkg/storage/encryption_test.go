@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+)
+
+var (
+	testDestinationKey    = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	testNewDestinationKey = base64.StdEncoding.EncodeToString([]byte("fedcba9876543210fedcba9876543210"))
+)
+
+func enableDestinationEncryption(t *testing.T) {
+	t.Helper()
+	require.NoError(t, InitDestinationEncryption(config.AppConfig{DestinationEncryptionKey: testDestinationKey}))
+	t.Cleanup(func() { currentDestinationKey, previousDestinationKey = nil, nil })
+}
+
+func TestInitDestinationEncryption(t *testing.T) {
+	require.NoError(t, InitDestinationEncryption(config.AppConfig{}))
+	assert.Nil(t, currentDestinationKey)
+
+	assert.Error(t, InitDestinationEncryption(config.AppConfig{DestinationEncryptionKey: "c2hvcnQ="}))
+	assert.Error(t, InitDestinationEncryption(config.AppConfig{DestinationEncryptionKeyFile: filepath.Join(t.TempDir(), "missing")}))
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(testDestinationKey+"\n"), 0o600))
+	require.NoError(t, InitDestinationEncryption(config.AppConfig{DestinationEncryptionKeyFile: keyFile}))
+	t.Cleanup(func() { currentDestinationKey, previousDestinationKey = nil, nil })
+	assert.NotNil(t, currentDestinationKey)
+	assert.Nil(t, previousDestinationKey)
+}
+
+func TestDestinationEncryption(t *testing.T) {
+	plain := "https://intranet.example.com/report?sig=secret"
+	assert.Equal(t, plain, encryptDestination(plain))
+
+	enableDestinationEncryption(t)
+	stored := encryptDestination(plain)
+	assert.True(t, strings.HasPrefix(stored, encryptedDestinationPrefix))
+	assert.NotContains(t, stored, "secret")
+	assert.Equal(t, stored, encryptDestination(plain))
+	assert.NotEqual(t, stored, encryptDestination(plain+"2"))
+
+	decrypted, err := decryptDestination(stored)
+	require.NoError(t, err)
+	assert.Equal(t, plain, decrypted)
+
+	// Destinations written before encryption was enabled stay readable.
+	decrypted, err = decryptDestination(plain)
+	require.NoError(t, err)
+	assert.Equal(t, plain, decrypted)
+
+	_, err = decryptDestination(stored[:len(stored)-2] + "AA")
+	assert.Error(t, err)
+
+	currentDestinationKey = nil
+	_, err = decryptDestination(stored)
+	assert.ErrorIs(t, err, errDestinationKeyMissing)
+}
+
+func TestEncryptedLinks(t *testing.T) {
+	db := openTestDB(t)
+	enableDestinationEncryption(t)
+	ctx := context.Background()
+	plain := "https://intranet.example.com/report?sig=secret"
+
+	require.NoError(t, CreateLink(ctx, models.Link{ShortCode: "report", LongURL: plain, Public: true, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "report", UserAgent: "ua", Destination: plain, Visitor: "v", At: time.Now()}))
+
+	var raw, rawClick string
+	require.NoError(t, db.QueryRow(`SELECT long_url FROM links WHERE short_code = 'report'`).Scan(&raw))
+	require.NoError(t, db.QueryRow(`SELECT destination FROM clicks WHERE short_code = 'report'`).Scan(&rawClick))
+	assert.NotContains(t, raw, "secret")
+	assert.NotContains(t, rawClick, "secret")
+
+	link, err := GetLink(ctx, "report")
+	require.NoError(t, err)
+	assert.Equal(t, plain, link.LongURL)
+
+	links, err := LinksByDestination(ctx, plain, "", 10)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, plain, links[0].LongURL)
+
+	counts, err := ClickCountsByDestination(ctx, "report")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{plain: 1}, counts)
+
+	events, err := ListLinkEvents(ctx, 0, 10)
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	assert.Equal(t, plain, events[0].Link.LongURL)
+}
+
+func TestEncryptedRolloutsAndRules(t *testing.T) {
+	db := openTestDB(t)
+	enableDestinationEncryption(t)
+	ctx := context.Background()
+	plain := "https://intranet.example.com/beta?sig=secret"
+
+	require.NoError(t, SaveLinkRollout(ctx, "report", models.LinkRollout{Destination: plain, Percent: 10}))
+	rs := rules.RuleSet{Rules: []rules.Rule{{Name: "mobile", When: rules.Condition{Device: []string{"ios"}}, Destination: plain}}}
+	require.NoError(t, SaveLinkRules(ctx, "report", rs))
+	assert.Equal(t, plain, rs.Rules[0].Destination, "the caller's rule set is left unchanged")
+
+	var rawRollout, rawRules string
+	require.NoError(t, db.QueryRow(`SELECT destination FROM link_rollouts WHERE short_code = 'report'`).Scan(&rawRollout))
+	require.NoError(t, db.QueryRow(`SELECT rules FROM link_rules WHERE short_code = 'report'`).Scan(&rawRules))
+	assert.NotContains(t, rawRollout, "secret")
+	assert.NotContains(t, rawRules, "secret")
+	assert.Contains(t, rawRules, "mobile", "only destinations are encrypted")
+
+	rollout, err := GetLinkRollout(ctx, "report")
+	require.NoError(t, err)
+	require.NotNil(t, rollout)
+	assert.Equal(t, plain, rollout.Destination)
+	stored, err := GetLinkRules(ctx, "report")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, rs, *stored)
+
+	// Rollouts and rules saved before encryption was enabled stay readable.
+	_, err = db.Exec(`UPDATE link_rollouts SET destination = ? WHERE short_code = 'report'`, plain)
+	require.NoError(t, err)
+	rollout, err = GetLinkRollout(ctx, "report")
+	require.NoError(t, err)
+	assert.Equal(t, plain, rollout.Destination)
+}
+
+func TestRotateDestinationEncryption(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	old, plain := "https://intranet.example.com/old?sig=secret", "https://intranet.example.com/plain"
+
+	require.NoError(t, CreateLink(ctx, models.Link{ShortCode: "plain", LongURL: plain, Public: true, CreatedAt: time.Now().UTC()}))
+	enableDestinationEncryption(t)
+	require.NoError(t, CreateLink(ctx, models.Link{ShortCode: "old", LongURL: old, Public: true, CreatedAt: time.Now().UTC()}))
+	require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: "old", UserAgent: "ua", Destination: old, Visitor: "v", At: time.Now()}))
+
+	require.NoError(t, InitDestinationEncryption(config.AppConfig{DestinationEncryptionKey: testNewDestinationKey, DestinationEncryptionPreviousKey: testDestinationKey}))
+	// Until rotated, links are readable and found by destination with either key.
+	link, err := GetLink(ctx, "old")
+	require.NoError(t, err)
+	assert.Equal(t, old, link.LongURL)
+	links, err := LinksByDestination(ctx, old, "", 10)
+	require.NoError(t, err)
+	assert.Len(t, links, 1)
+
+	var result models.KeyRotationResult
+	_, err = rotateLinkDestinations(ctx, &result)
+	require.NoError(t, err)
+	require.NoError(t, rotateClickDestinations(ctx, &result))
+	require.NoError(t, rotateEventDestinations(ctx, &result))
+	assert.Equal(t, models.KeyRotationResult{Links: 2, Clicks: 1, Events: 2}, result)
+
+	// Everything is readable with the new key alone now.
+	require.NoError(t, InitDestinationEncryption(config.AppConfig{DestinationEncryptionKey: testNewDestinationKey}))
+	var raw string
+	require.NoError(t, db.QueryRow(`SELECT long_url FROM links WHERE short_code = 'plain'`).Scan(&raw))
+	assert.True(t, strings.HasPrefix(raw, encryptedDestinationPrefix))
+	for code, want := range map[string]string{"old": old, "plain": plain} {
+		link, err := GetLink(ctx, code)
+		require.NoError(t, err)
+		assert.Equal(t, want, link.LongURL)
+	}
+	counts, err := ClickCountsByDestination(ctx, "old")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{old: 1}, counts)
+	events, err := ListLinkEvents(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	// A second run finds nothing left to rotate.
+	result = models.KeyRotationResult{}
+	_, err = rotateLinkDestinations(ctx, &result)
+	require.NoError(t, err)
+	assert.Zero(t, result.Links)
+}
+
+func TestRotateRolloutAndRuleDestinations(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	plain, beta := "https://intranet.example.com/plain", "https://intranet.example.com/beta?sig=secret"
+	rs := rules.RuleSet{Rules: []rules.Rule{{When: rules.Condition{Country: []string{"DE"}}, Destination: beta}}}
+
+	require.NoError(t, SaveLinkRollout(ctx, "plain", models.LinkRollout{Destination: plain, Percent: 50}))
+	enableDestinationEncryption(t)
+	require.NoError(t, SaveLinkRollout(ctx, "beta", models.LinkRollout{Destination: beta, Percent: 10}))
+	require.NoError(t, SaveLinkRules(ctx, "beta", rs))
+
+	require.NoError(t, InitDestinationEncryption(config.AppConfig{DestinationEncryptionKey: testNewDestinationKey, DestinationEncryptionPreviousKey: testDestinationKey}))
+	var result models.KeyRotationResult
+	require.NoError(t, rotateRolloutDestinations(ctx, &result))
+	require.NoError(t, rotateRuleDestinations(ctx, &result))
+	assert.Equal(t, models.KeyRotationResult{Rollouts: 2, Rules: 1}, result)
+
+	// The previous key can be dropped once rotated.
+	require.NoError(t, InitDestinationEncryption(config.AppConfig{DestinationEncryptionKey: testNewDestinationKey}))
+	for code, want := range map[string]string{"plain": plain, "beta": beta} {
+		rollout, err := GetLinkRollout(ctx, code)
+		require.NoError(t, err)
+		require.NotNil(t, rollout)
+		assert.Equal(t, want, rollout.Destination)
+	}
+	stored, err := GetLinkRules(ctx, "beta")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, rs, *stored)
+
+	result = models.KeyRotationResult{}
+	require.NoError(t, rotateRolloutDestinations(ctx, &result))
+	require.NoError(t, rotateRuleDestinations(ctx, &result))
+	assert.Zero(t, result, "a second run finds nothing left to rotate")
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors returned by the storage layer. Handlers test for them with errors.Is,
+// so every backend must report missing, conflicting and expired records the same way
+// instead of leaking driver errors such as redis.Nil or sql.ErrNoRows.
+var (
+	// ErrNotFound is returned when the requested record does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a record cannot be created because it already exists.
+	ErrConflict = errors.New("already exists")
+	// ErrExpired is returned when the requested record existed but has expired.
+	ErrExpired = errors.New("expired")
+)
+
+var (
+	// ErrLinkNotFound is returned when a short code has no destination.
+	ErrLinkNotFound = fmt.Errorf("short URL %w", ErrNotFound)
+	// ErrLinkExpired is returned when a short code's destination has expired.
+	ErrLinkExpired = fmt.Errorf("short URL has %w", ErrExpired)
+	// ErrLinkExists is returned when creating a short code that is already in use.
+	ErrLinkExists = fmt.Errorf("short code %w", ErrConflict)
+	// ErrDraftNotFound is returned when a preview or publish targets a link that is not a draft.
+	ErrDraftNotFound = fmt.Errorf("draft %w", ErrNotFound)
+	// ErrDropNotFound is returned when a short code has no dropped file.
+	ErrDropNotFound = fmt.Errorf("file %w", ErrNotFound)
+	// ErrSnippetNotFound is returned when a short code has no snippet, or it was burnt.
+	ErrSnippetNotFound = fmt.Errorf("snippet %w", ErrNotFound)
+	// ErrTakedownNotFound is returned when reinstating a link that is not disabled.
+	ErrTakedownNotFound = fmt.Errorf("takedown %w", ErrNotFound)
+	// ErrAPIKeyNotFound is returned when an API key does not exist or was revoked.
+	ErrAPIKeyNotFound = fmt.Errorf("API key %w", ErrNotFound)
+	// ErrUserNotFound is returned when there is no user account with an email or ID.
+	ErrUserNotFound = fmt.Errorf("user %w", ErrNotFound)
+	// ErrUserExists is returned when registering an email that already has an account.
+	ErrUserExists = fmt.Errorf("user %w", ErrConflict)
+	// ErrCampaignAlertNotFound is returned when a campaign alert does not exist.
+	ErrCampaignAlertNotFound = fmt.Errorf("campaign alert %w", ErrNotFound)
+	// ErrClaimNotFound is returned when claiming a link with a wrong or used claim token.
+	ErrClaimNotFound = fmt.Errorf("claim token %w", ErrNotFound)
+)
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// CheckSQLite verifies the stats database is usable: it pings the connection, reads
+// from the clicks table and round-trips a value through a temporary table.
+func CheckSQLite(ctx context.Context) error {
+	if StatsDB == nil {
+		return errors.New("SQLite database not initialized")
+	}
+	if err := StatsDB.PingContext(ctx); err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+
+	var n int
+	if err := StatsDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM (SELECT 1 FROM clicks LIMIT 1)`).Scan(&n); err != nil {
+		return fmt.Errorf("read clicks: %w", err)
+	}
+
+	// Temporary tables are per-connection, so the write and read share one transaction.
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `CREATE TEMP TABLE IF NOT EXISTS health_check (v INTEGER)`); err != nil {
+		return fmt.Errorf("create temp table: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO health_check (v) VALUES (42)`); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	var v int
+	if err := tx.QueryRowContext(ctx, `SELECT v FROM health_check LIMIT 1`).Scan(&v); err != nil {
+		return fmt.Errorf("read back: %w", err)
+	}
+	if v != 42 {
+		return fmt.Errorf("read back unexpected value %d", v)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM health_check`); err != nil {
+		return fmt.Errorf("cleanup: %w", err)
+	}
+	return tx.Commit()
+}
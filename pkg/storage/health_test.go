@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckSQLite(t *testing.T) {
+	ctx := context.Background()
+
+	StatsDB = nil
+	assert.Error(t, CheckSQLite(ctx), "an uninitialized database is unhealthy")
+
+	db := openTestDB(t)
+	// Checks run on every health request, so they must keep passing on a pooled connection.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, CheckSQLite(ctx))
+	}
+
+	_, err := db.Exec(`DROP TABLE clicks`)
+	require.NoError(t, err)
+	assert.ErrorContains(t, CheckSQLite(ctx), "read clicks")
+
+	require.NoError(t, db.Close())
+	assert.ErrorContains(t, CheckSQLite(ctx), "ping")
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// instancesKey is the Redis hash of registered instances, by ID.
+const instancesKey = "instances"
+
+// SaveInstance registers an instance or renews its registration.
+func SaveInstance(ctx context.Context, instance models.Instance) error {
+	raw, err := json.Marshal(instance)
+	if err != nil {
+		return err
+	}
+	return Rdb.HSet(ctx, instancesKey, instance.ID, raw).Err()
+}
+
+// ListInstances returns the registered instances seen within maxAge, by ID, and drops
+// the registrations of the others.
+func ListInstances(ctx context.Context, maxAge time.Duration) ([]models.Instance, error) {
+	registered, err := Rdb.HGetAll(ctx, instancesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	instances := []models.Instance{}
+	var stale []string
+	for id, raw := range registered {
+		var instance models.Instance
+		if err := json.Unmarshal([]byte(raw), &instance); err != nil || instance.LastSeen.Before(cutoff) {
+			stale = append(stale, id)
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	if len(stale) > 0 {
+		if err := Rdb.HDel(ctx, instancesKey, stale...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances, nil
+}
@@ -0,0 +1,549 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// SaveLink stores the metadata record for a link, replacing any previous record
+// with the same short code (e.g. a custom handle re-registered after expiring).
+func SaveLink(ctx context.Context, link models.Link) error {
+	return saveLink(ctx, StatsDB, link)
+}
+
+// CreateLink stores the metadata record for a new link and records its "created"
+// event in the outbox, atomically.
+func CreateLink(ctx context.Context, link models.Link) error {
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveLink(ctx, tx, link); err != nil {
+		return err
+	}
+	if err := recordLinkEvent(ctx, tx, models.LinkEventCreated, link); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateLink replaces the metadata record of an existing link and records its
+// "updated" event in the outbox, atomically.
+func UpdateLink(ctx context.Context, link models.Link) error {
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := saveLink(ctx, tx, link); err != nil {
+		return err
+	}
+	if err := recordLinkEvent(ctx, tx, models.LinkEventUpdated, link); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteLink removes the metadata record and redirect rules of a link, the records of
+// a file dropped or snippet shared under it, and records its "deleted" event in the
+// outbox, atomically. Click history is kept, and so is a dropped file itself, which
+// the caller removes from the drop store.
+func DeleteLink(ctx context.Context, link models.Link) error {
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_rules WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_rollouts WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_preview_tokens WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_claim_tokens WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_dedupe WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM link_takedowns WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM drops WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM snippets WHERE short_code = ?`, link.ShortCode); err != nil {
+		return err
+	}
+	if err := recordLinkEvent(ctx, tx, models.LinkEventDeleted, link); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveLink(ctx context.Context, db execer, link models.Link) error {
+	var expiresAt sql.NullTime
+	if link.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: link.ExpiresAt.UTC(), Valid: true}
+	}
+
+	var cacheMaxAge sql.NullInt64
+	if link.CacheMaxAge != nil {
+		cacheMaxAge = sql.NullInt64{Int64: int64(*link.CacheMaxAge), Valid: true}
+	}
+	var maxClicks sql.NullInt64
+	if link.MaxClicks != nil {
+		maxClicks = sql.NullInt64{Int64: int64(*link.MaxClicks), Valid: true}
+	}
+
+	tags, err := json.Marshal(link.Tags)
+	if err != nil {
+		return err
+	}
+	if link.Tags == nil {
+		tags = []byte("[]")
+	}
+	headers, err := json.Marshal(link.ResponseHeaders)
+	if err != nil {
+		return err
+	}
+	if link.ResponseHeaders == nil {
+		headers = []byte("{}")
+	}
+	var deviceTargets sql.NullString
+	if link.DeviceTargets != nil {
+		targets := models.DeviceTargets{
+			IOS:     encryptDestination(link.DeviceTargets.IOS),
+			Android: encryptDestination(link.DeviceTargets.Android),
+			Desktop: encryptDestination(link.DeviceTargets.Desktop),
+		}
+		raw, err := json.Marshal(targets)
+		if err != nil {
+			return err
+		}
+		deviceTargets = sql.NullString{String: string(raw), Valid: true}
+	}
+	var variants sql.NullString
+	if len(link.Variants) > 0 {
+		encrypted := make([]models.LinkVariant, len(link.Variants))
+		for i, variant := range link.Variants {
+			variant.Destination = encryptDestination(variant.Destination)
+			encrypted[i] = variant
+		}
+		raw, err := json.Marshal(encrypted)
+		if err != nil {
+			return err
+		}
+		variants = sql.NullString{String: string(raw), Valid: true}
+	}
+
+	pageMeta, err := pageMetaColumn(link.PageMeta)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO links (`+linkColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		link.ShortCode, encryptDestination(link.LongURL), link.Title, link.Public, link.CreatedAt.UTC(), expiresAt, cacheMaxAge,
+		string(tags), link.Managed, link.NotifyEachClick, link.Draft, link.Owner, link.Frozen, string(headers), link.HideReferrer, link.NoAnalytics, maxClicks, deviceTargets, variants, link.ForwardQuery, pageMeta, link.NoIndex)
+	return err
+}
+
+// GetLink returns the metadata record for a link. It returns ErrLinkNotFound if the
+// link has no record, e.g. because it was created before metadata was kept.
+func GetLink(ctx context.Context, shortCode string) (models.Link, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT `+linkColumns+` FROM links WHERE short_code = ?`, shortCode)
+	if err != nil {
+		return models.Link{}, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return models.Link{}, err
+		}
+		return models.Link{}, ErrLinkNotFound
+	}
+	return scanLink(rows)
+}
+
+// LinkExpiry derives a link's absolute expiration time from the TTL currently set on
+// its Redis key. It returns nil for links that never expire and ErrLinkNotFound if
+// the key does not exist.
+func LinkExpiry(ctx context.Context, shortCode string) (*time.Time, error) {
+	ttl, err := Rdb.PTTL(ctx, shortCode).Result()
+	if err != nil {
+		return nil, err
+	}
+	// go-redis reports the special replies -2 (no key) and -1 (no expiry) as raw durations.
+	switch {
+	case ttl == -2:
+		return nil, ErrLinkNotFound
+	case ttl < 0:
+		return nil, nil
+	}
+	expiresAt := time.Now().UTC().Add(ttl).Truncate(time.Second)
+	return &expiresAt, nil
+}
+
+// RecentPublicLinks returns up to limit public, unexpired links, newest first.
+func RecentPublicLinks(ctx context.Context, limit int) ([]models.Link, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT `+linkColumns+` FROM links
+		WHERE public = 1 AND draft = 0 AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY created_at DESC LIMIT ?`, time.Now().UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// ListLinks returns one page of all unexpired links, drafts included, newest first,
+// and how many there are in total.
+func ListLinks(ctx context.Context, offset, limit int) ([]models.Link, int, error) {
+	return listLinks(ctx, `expires_at IS NULL OR expires_at > ?`, nil, offset, limit)
+}
+
+// ListOwnedLinks is ListLinks restricted to the links created by owner. Links without
+// an owner are never listed.
+func ListOwnedLinks(ctx context.Context, owner string, offset, limit int) ([]models.Link, int, error) {
+	return listLinks(ctx, `(expires_at IS NULL OR expires_at > ?) AND owner != '' AND owner = ?`, []interface{}{owner}, offset, limit)
+}
+
+// listLinks returns one page of the links matching where, which takes the current
+// time and then args, and how many match in total.
+func listLinks(ctx context.Context, where string, args []interface{}, offset, limit int) ([]models.Link, int, error) {
+	filter := append([]interface{}{time.Now().UTC()}, args...)
+	var total int
+	if err := StatsDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM links WHERE `+where, filter...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT `+linkColumns+` FROM links WHERE `+where+`
+		ORDER BY created_at DESC, short_code LIMIT ? OFFSET ?`, append(filter, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	links := []models.Link{}
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		links = append(links, link)
+	}
+	return links, total, rows.Err()
+}
+
+// linkColumns is the column list, in scanLink order, used to read and write links rows.
+const linkColumns = `short_code, long_url, title, public, created_at, expires_at, cache_max_age, tags, managed, notify_each_click, draft, owner, frozen, response_headers, hide_referrer, no_analytics, max_clicks, device_targets, variants, forward_query, page_meta, noindex`
+
+// scanLink reads a links row selected with linkColumns.
+func scanLink(rows *sql.Rows) (models.Link, error) {
+	var link models.Link
+	var expiresAt sql.NullTime
+	var cacheMaxAge, maxClicks sql.NullInt64
+	var tags, headers string
+	var deviceTargets, variants, pageMeta sql.NullString
+	if err := rows.Scan(&link.ShortCode, &link.LongURL, &link.Title, &link.Public, &link.CreatedAt, &expiresAt, &cacheMaxAge, &tags, &link.Managed, &link.NotifyEachClick, &link.Draft, &link.Owner, &link.Frozen, &headers, &link.HideReferrer, &link.NoAnalytics, &maxClicks, &deviceTargets, &variants, &link.ForwardQuery, &pageMeta, &link.NoIndex); err != nil {
+		return models.Link{}, err
+	}
+	longURL, err := decryptDestination(link.LongURL)
+	if err != nil {
+		return models.Link{}, err
+	}
+	link.LongURL = longURL
+	if err := json.Unmarshal([]byte(tags), &link.Tags); err != nil {
+		return models.Link{}, err
+	}
+	if err := json.Unmarshal([]byte(headers), &link.ResponseHeaders); err != nil {
+		return models.Link{}, err
+	}
+	if len(link.ResponseHeaders) == 0 {
+		link.ResponseHeaders = nil
+	}
+	if expiresAt.Valid {
+		t := expiresAt.Time
+		link.ExpiresAt = &t
+	}
+	if cacheMaxAge.Valid {
+		v := int(cacheMaxAge.Int64)
+		link.CacheMaxAge = &v
+	}
+	if maxClicks.Valid {
+		v := int(maxClicks.Int64)
+		link.MaxClicks = &v
+	}
+	if deviceTargets.Valid {
+		var targets models.DeviceTargets
+		if err := json.Unmarshal([]byte(deviceTargets.String), &targets); err != nil {
+			return models.Link{}, err
+		}
+		for _, target := range []*string{&targets.IOS, &targets.Android, &targets.Desktop} {
+			if *target, err = decryptDestination(*target); err != nil {
+				return models.Link{}, err
+			}
+		}
+		link.DeviceTargets = &targets
+	}
+	if variants.Valid {
+		if err := json.Unmarshal([]byte(variants.String), &link.Variants); err != nil {
+			return models.Link{}, err
+		}
+		for i := range link.Variants {
+			if link.Variants[i].Destination, err = decryptDestination(link.Variants[i].Destination); err != nil {
+				return models.Link{}, err
+			}
+		}
+	}
+	if pageMeta.Valid {
+		link.PageMeta = &models.PageMeta{}
+		if err := json.Unmarshal([]byte(pageMeta.String), link.PageMeta); err != nil {
+			return models.Link{}, err
+		}
+	}
+	return link, nil
+}
+
+// pageMetaColumn encodes a link's page metadata for the page_meta column.
+func pageMetaColumn(meta *models.PageMeta) (sql.NullString, error) {
+	if meta == nil {
+		return sql.NullString{}, nil
+	}
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(raw), Valid: true}, nil
+}
+
+// SetLinkPageMeta stores the page metadata of a link's destination, leaving the rest
+// of its record alone. It returns ErrLinkNotFound if the link has no record.
+func SetLinkPageMeta(ctx context.Context, shortCode string, meta models.PageMeta) error {
+	column, err := pageMetaColumn(&meta)
+	if err != nil {
+		return err
+	}
+	res, err := StatsDB.ExecContext(ctx, `UPDATE links SET page_meta = ? WHERE short_code = ?`, column, shortCode)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+// CodesWithPrefix returns up to limit short codes of unexpired public links starting
+// with prefix, in alphabetical order.
+func CodesWithPrefix(ctx context.Context, prefix string, limit int) ([]string, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix)
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT short_code FROM links
+		WHERE short_code LIKE ? ESCAPE '\' AND public = 1 AND draft = 0 AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY short_code LIMIT ?`, escaped+"%", time.Now().UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, rows.Err()
+}
+
+// ShortCodesIgnoringCase returns the short codes of the links stored under code in any
+// letter case, the lowercase one first.
+func ShortCodesIgnoringCase(ctx context.Context, code string) ([]string, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT short_code FROM links WHERE short_code = ? COLLATE NOCASE
+		ORDER BY short_code = lower(short_code) DESC, short_code`, code)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var stored string
+		if err := rows.Scan(&stored); err != nil {
+			return nil, err
+		}
+		codes = append(codes, stored)
+	}
+	return codes, rows.Err()
+}
+
+// OwnedCodes returns those of codes whose links were created by owner (see
+// models.Link.Owner), in no particular order.
+func OwnedCodes(ctx context.Context, owner string, codes []string) ([]string, error) {
+	if owner == "" || len(codes) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(codes)+1)
+	args = append(args, owner)
+	for _, code := range codes {
+		args = append(args, code)
+	}
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT short_code FROM links
+		WHERE owner = ? AND short_code IN (?`+strings.Repeat(", ?", len(codes)-1)+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var owned []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		owned = append(owned, code)
+	}
+	return owned, rows.Err()
+}
+
+// ManagedLinks returns all links owned by declarative sync, ordered by short code.
+func ManagedLinks(ctx context.Context) ([]models.Link, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT `+linkColumns+` FROM links WHERE managed = 1 ORDER BY short_code`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// ActiveCodes returns the short codes of all unexpired public links.
+func ActiveCodes(ctx context.Context) ([]string, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT short_code FROM links WHERE public = 1 AND draft = 0 AND (expires_at IS NULL OR expires_at > ?)`, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, rows.Err()
+}
+
+// LiveLinks returns all unexpired links, drafts included, ordered by short code.
+func LiveLinks(ctx context.Context) ([]models.Link, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT `+linkColumns+` FROM links WHERE expires_at IS NULL OR expires_at > ? ORDER BY short_code`, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// LinksByDestination returns the live, published links pointing at longURL that are
+// public or, when owner is set, created by owner; newest first and at most limit.
+func LinksByDestination(ctx context.Context, longURL, owner string, limit int) ([]models.Link, error) {
+	return linksByDestination(ctx, longURL, `draft = 0 AND (public = 1 OR (owner != '' AND owner = ?))`, owner, limit)
+}
+
+// OwnedLinksByDestination returns the live links owner created pointing at longURL,
+// drafts included; newest first and at most limit.
+func OwnedLinksByDestination(ctx context.Context, longURL, owner string, limit int) ([]models.Link, error) {
+	return linksByDestination(ctx, longURL, `owner != '' AND owner = ?`, owner, limit)
+}
+
+// linksByDestination returns the unexpired links pointing at longURL that match the
+// condition where, with its single owner placeholder; newest first and at most limit.
+func linksByDestination(ctx context.Context, longURL, where, owner string, limit int) ([]models.Link, error) {
+	forms := storedDestinations(longURL)
+	args := make([]any, 0, len(forms)+3)
+	for _, form := range forms {
+		args = append(args, form)
+	}
+	args = append(args, time.Now().UTC(), owner, limit)
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT `+linkColumns+` FROM links
+		WHERE long_url IN (?`+strings.Repeat(", ?", len(forms)-1)+`) AND (expires_at IS NULL OR expires_at > ?)
+		AND `+where+`
+		ORDER BY created_at DESC LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.Link{}
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/models"
+)
+
+func TestCodesWithPrefix(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour)
+
+	for _, link := range []models.Link{
+		{ShortCode: "promo2", LongURL: "https://example.com", CreatedAt: now, Public: true},
+		{ShortCode: "promo1", LongURL: "https://example.com", CreatedAt: now, Public: true},
+		{ShortCode: "promo_old", LongURL: "https://example.com", CreatedAt: past, ExpiresAt: &past, Public: true},
+		{ShortCode: "promo_private", LongURL: "https://example.com", CreatedAt: now},
+		{ShortCode: "prxmo", LongURL: "https://example.com", CreatedAt: now, Public: true},
+	} {
+		assert.NoError(t, SaveLink(ctx, link))
+	}
+
+	codes, err := CodesWithPrefix(ctx, "promo", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"promo1", "promo2"}, codes)
+
+	codes, err = CodesWithPrefix(ctx, "pr%", 5)
+	assert.NoError(t, err)
+	assert.Empty(t, codes)
+}
+
+func TestLinksByDestination(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour)
+	const dest = "https://example.com/page"
+
+	for _, link := range []models.Link{
+		{ShortCode: "public", LongURL: dest, Public: true, CreatedAt: now.Add(-time.Minute)},
+		{ShortCode: "mine", LongURL: dest, Owner: "alice", CreatedAt: now},
+		{ShortCode: "theirs", LongURL: dest, Owner: "bob", CreatedAt: now},
+		{ShortCode: "anon", LongURL: dest, CreatedAt: now},
+		{ShortCode: "draft", LongURL: dest, Owner: "alice", Draft: true, CreatedAt: now},
+		{ShortCode: "expired", LongURL: dest, Public: true, CreatedAt: past, ExpiresAt: &past},
+		{ShortCode: "other", LongURL: "https://example.com/other", Public: true, CreatedAt: now},
+	} {
+		assert.NoError(t, SaveLink(ctx, link))
+	}
+
+	codes := func(links []models.Link) []string {
+		var out []string
+		for _, link := range links {
+			out = append(out, link.ShortCode)
+		}
+		return out
+	}
+
+	links, err := LinksByDestination(ctx, dest, "alice", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mine", "public"}, codes(links))
+
+	links, err = LinksByDestination(ctx, dest, "", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"public"}, codes(links), "anonymous callers only see public links")
+
+	links, err = OwnedLinksByDestination(ctx, dest, "alice", 10)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"mine", "draft"}, codes(links))
+
+	links, err = OwnedLinksByDestination(ctx, dest, "", 10)
+	assert.NoError(t, err)
+	assert.Empty(t, links, "anonymous links have no owner")
+}
+
+func TestListLinks(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	for _, link := range []models.Link{
+		{ShortCode: "oldest", LongURL: "https://example.com/1", CreatedAt: now.Add(-2 * time.Minute)},
+		{ShortCode: "middle", LongURL: "https://example.com/2", CreatedAt: now.Add(-time.Minute), ExpiresAt: &future},
+		{ShortCode: "newest", LongURL: "https://example.com/3", Draft: true, CreatedAt: now},
+		{ShortCode: "expired", LongURL: "https://example.com/4", CreatedAt: past, ExpiresAt: &past},
+	} {
+		assert.NoError(t, SaveLink(ctx, link))
+	}
+
+	links, total, err := ListLinks(ctx, 0, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	if assert.Len(t, links, 2) {
+		assert.Equal(t, "newest", links[0].ShortCode)
+		assert.Equal(t, "middle", links[1].ShortCode)
+	}
+
+	links, total, err = ListLinks(ctx, 2, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	if assert.Len(t, links, 1) {
+		assert.Equal(t, "oldest", links[0].ShortCode)
+	}
+}
+
+func TestLinkResponseHeaders(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: "plain", LongURL: "https://example.com", CreatedAt: now}))
+	link, err := GetLink(ctx, "plain")
+	assert.NoError(t, err)
+	assert.Nil(t, link.ResponseHeaders)
+
+	headers := map[string]string{"Referrer-Policy": "no-referrer", "X-Campaign": "spring"}
+	assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: "private", LongURL: "https://example.com", CreatedAt: now, ResponseHeaders: headers, HideReferrer: true}))
+	link, err = GetLink(ctx, "private")
+	assert.NoError(t, err)
+	assert.Equal(t, headers, link.ResponseHeaders)
+	assert.True(t, link.HideReferrer)
+}
+
+func TestLinkMaxClicks(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: "plain", LongURL: "https://example.com", CreatedAt: now}))
+	link, err := GetLink(ctx, "plain")
+	assert.NoError(t, err)
+	assert.Nil(t, link.MaxClicks)
+
+	limit := 3
+	assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: "once", LongURL: "https://example.com", CreatedAt: now, MaxClicks: &limit}))
+	link, err = GetLink(ctx, "once")
+	assert.NoError(t, err)
+	if assert.NotNil(t, link.MaxClicks) {
+		assert.Equal(t, 3, *link.MaxClicks)
+	}
+}
+
+func TestSetLinkPageMeta(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: "abc", LongURL: "https://example.com", Title: "Mine", CreatedAt: now}))
+	link, err := GetLink(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Nil(t, link.PageMeta)
+
+	meta := models.PageMeta{Title: "Example", Description: "An example page", FaviconURL: "https://example.com/favicon.ico", FetchedAt: now}
+	assert.NoError(t, SetLinkPageMeta(ctx, "abc", meta))
+	link, err = GetLink(ctx, "abc")
+	assert.NoError(t, err)
+	assert.Equal(t, "Mine", link.Title, "the rest of the record is left alone")
+	if assert.NotNil(t, link.PageMeta) {
+		assert.Equal(t, meta, *link.PageMeta)
+	}
+
+	// Saving the link again keeps its page metadata.
+	assert.NoError(t, SaveLink(ctx, link))
+	link, err = GetLink(ctx, "abc")
+	assert.NoError(t, err)
+	assert.NotNil(t, link.PageMeta)
+
+	assert.ErrorIs(t, SetLinkPageMeta(ctx, "missing", meta), ErrLinkNotFound)
+}
+
+func TestShortCodesIgnoringCase(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for _, code := range []string{"ABC", "abc", "AbC", "abd"} {
+		assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: code, LongURL: "https://example.com/" + code, CreatedAt: now}))
+	}
+
+	codes, err := ShortCodesIgnoringCase(ctx, "aBc")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc", "ABC", "AbC"}, codes, "the lowercase code comes first")
+
+	codes, err = ShortCodesIgnoringCase(ctx, "xyz")
+	assert.NoError(t, err)
+	assert.Empty(t, codes)
+}
+
+func TestOwnedCodes(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for _, link := range []models.Link{
+		{ShortCode: "mine1", LongURL: "https://example.com", CreatedAt: now, Owner: "me"},
+		{ShortCode: "mine2", LongURL: "https://example.com", CreatedAt: now, Owner: "me"},
+		{ShortCode: "theirs", LongURL: "https://example.com", CreatedAt: now, Owner: "them"},
+		{ShortCode: "anon", LongURL: "https://example.com", CreatedAt: now},
+	} {
+		assert.NoError(t, SaveLink(ctx, link))
+	}
+
+	codes, err := OwnedCodes(ctx, "me", []string{"mine1", "theirs", "anon", "missing", "mine2"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"mine1", "mine2"}, codes)
+
+	codes, err = OwnedCodes(ctx, "", []string{"anon"})
+	assert.NoError(t, err)
+	assert.Empty(t, codes, "links without an owner are nobody's")
+}
+
+func TestDeleteLinkRemovesHostedContent(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	for _, code := range []string{"drop1", "note1"} {
+		assert.NoError(t, SaveLink(ctx, models.Link{ShortCode: code, LongURL: "https://riid.me/d/" + code, CreatedAt: now}))
+	}
+	assert.NoError(t, SaveDrop(ctx, models.Drop{ShortCode: "drop1", FileKey: "0123456789abcdef", Filename: "a.txt", CreatedAt: now}))
+	assert.NoError(t, SaveSnippet(ctx, models.Snippet{ShortCode: "note1", Content: "hello", CreatedAt: now}))
+
+	assert.NoError(t, DeleteLink(ctx, models.Link{ShortCode: "drop1"}))
+	assert.NoError(t, DeleteLink(ctx, models.Link{ShortCode: "note1"}))
+
+	_, err := GetDrop(ctx, "drop1")
+	assert.ErrorIs(t, err, ErrDropNotFound, "a re-registered code must not serve the previous drop")
+	_, err = GetSnippet(ctx, "note1")
+	assert.ErrorIs(t, err, ErrSnippetNotFound)
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// RunMaintenance refreshes the statistics the SQLite query planner uses to pick
+// indexes (ANALYZE) and, when reindex is set, rebuilds all indexes first (REINDEX).
+// Both lock the database while they run, so they are meant for quiet periods.
+func RunMaintenance(ctx context.Context, reindex bool) (models.MaintenanceResult, error) {
+	var result models.MaintenanceResult
+	if reindex {
+		start := time.Now()
+		if _, err := StatsDB.ExecContext(ctx, `REINDEX`); err != nil {
+			return result, err
+		}
+		result.Reindexed = true
+		result.ReindexSeconds = time.Since(start).Seconds()
+	}
+
+	start := time.Now()
+	if _, err := StatsDB.ExecContext(ctx, `ANALYZE`); err != nil {
+		return result, err
+	}
+	result.AnalyzeSeconds = time.Since(start).Seconds()
+
+	if err := StatsDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM clicks`).Scan(&result.ClickRows); err != nil {
+		return result, err
+	}
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'clicks' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+	result.IndexesOnClicks = []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return result, err
+		}
+		result.IndexesOnClicks = append(result.IndexesOnClicks, name)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	customlogger "riid.me/pkg/logger"
+)
+
+// migrations lists the schema changes applied, in order, after the base tables are
+// created by InitSQLite. Each entry runs exactly once per database; applied versions
+// are tracked in the schema_migrations table. Only ever append to this list.
+var migrations = []string{
+	// 1: per-link redirect cache lifetime
+	`ALTER TABLE links ADD COLUMN cache_max_age INTEGER`,
+	// 2: admin-defined wildcard redirect patterns
+	`CREATE TABLE IF NOT EXISTS redirect_patterns (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pattern TEXT NOT NULL UNIQUE,
+		destination TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// 3: per-domain root destinations and 404 pages
+	`CREATE TABLE IF NOT EXISTS domains (
+		host TEXT PRIMARY KEY,
+		root_destination TEXT NOT NULL DEFAULT '',
+		not_found_html TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// 4: outbox of link lifecycle events
+	`CREATE TABLE IF NOT EXISTS link_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_type TEXT NOT NULL,
+		short_code TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	// 5: per-consumer positions in the link_events outbox
+	`CREATE TABLE IF NOT EXISTS outbox_cursors (
+		consumer TEXT PRIMARY KEY,
+		last_event_id INTEGER NOT NULL,
+		updated_at DATETIME NOT NULL
+	)`,
+	// 6: free-form link tags, stored as a JSON array
+	`ALTER TABLE links ADD COLUMN tags TEXT NOT NULL DEFAULT '[]'`,
+	// 7: links owned by declarative sync, which may update and delete them
+	`ALTER TABLE links ADD COLUMN managed INTEGER NOT NULL DEFAULT 0`,
+	// 8: percentage rollouts sending part of a link's traffic to a new destination
+	`CREATE TABLE IF NOT EXISTS link_rollouts (
+		short_code TEXT PRIMARY KEY,
+		destination TEXT NOT NULL,
+		percent INTEGER NOT NULL,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// 9: the destination each click was redirected to
+	`ALTER TABLE clicks ADD COLUMN destination TEXT`,
+	// 10: links whose every click is pushed to the click webhook
+	`ALTER TABLE links ADD COLUMN notify_each_click INTEGER NOT NULL DEFAULT 0`,
+	// 11: draft links, reachable only with a preview token until published
+	`ALTER TABLE links ADD COLUMN draft INTEGER NOT NULL DEFAULT 0`,
+	// 12: the current preview token of each draft link
+	`CREATE TABLE IF NOT EXISTS link_preview_tokens (
+		short_code TEXT PRIMARY KEY,
+		token TEXT NOT NULL,
+		expires_at DATETIME NOT NULL
+	)`,
+	// 13: click timestamps as RFC3339 UTC text instead of SQLite's "YYYY-MM-DD HH:MM:SS"
+	`UPDATE clicks SET timestamp = COALESCE(strftime('%Y-%m-%dT%H:%M:%SZ', timestamp), timestamp) WHERE timestamp IS NOT NULL`,
+	// 14: per-link click history and counts without scanning the whole table
+	`CREATE INDEX IF NOT EXISTS idx_clicks_short_code_timestamp ON clicks (short_code, timestamp)`,
+	// 15: time-range queries across all links
+	`CREATE INDEX IF NOT EXISTS idx_clicks_timestamp ON clicks (timestamp)`,
+	// 16: anonymized visitor of each click, for unique click counts
+	`ALTER TABLE clicks ADD COLUMN visitor TEXT`,
+	// 17: files shared through a short link
+	`CREATE TABLE IF NOT EXISTS drops (
+		short_code TEXT PRIMARY KEY,
+		file_key TEXT NOT NULL,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		downloads INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	)`,
+	// 18: text and Markdown snippets shared through a short link
+	`CREATE TABLE IF NOT EXISTS snippets (
+		short_code TEXT PRIMARY KEY,
+		content TEXT NOT NULL,
+		language TEXT NOT NULL,
+		burn_after_read INTEGER NOT NULL DEFAULT 0,
+		views INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL
+	)`,
+	// 19: links disabled by an admin for abuse
+	`CREATE TABLE IF NOT EXISTS link_takedowns (
+		short_code TEXT PRIMARY KEY,
+		reason TEXT NOT NULL,
+		category TEXT NOT NULL DEFAULT '',
+		notify_email TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	)`,
+	// 20: who created a link, as a hash of their auth code
+	`ALTER TABLE links ADD COLUMN owner TEXT NOT NULL DEFAULT ''`,
+	// 21: reverse index from destinations to links
+	`CREATE INDEX IF NOT EXISTS idx_links_long_url ON links (long_url)`,
+	// 22: links protected from edits until an admin unfreezes them
+	`ALTER TABLE links ADD COLUMN frozen INTEGER NOT NULL DEFAULT 0`,
+	// 23: where a click came from, e.g. the poster whose QR code was scanned
+	`ALTER TABLE clicks ADD COLUMN source TEXT NOT NULL DEFAULT ''`,
+	// 24: API keys issued by admins, stored as hashes
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id TEXT PRIMARY KEY,
+		key_hash TEXT NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		role TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	)`,
+	// 25: per-key daily quota of shortened links, NULL for the configured default
+	`ALTER TABLE api_keys ADD COLUMN shorten_quota INTEGER`,
+	// 26: per-key daily quota of stats requests, NULL for the configured default
+	`ALTER TABLE api_keys ADD COLUMN stats_quota INTEGER`,
+	// 27: user accounts, with bcrypt password hashes
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	// 28: extra headers sent with a link's redirects, as a JSON object
+	`ALTER TABLE links ADD COLUMN response_headers TEXT NOT NULL DEFAULT '{}'`,
+	// 29: links whose visitors are sent on without a Referer
+	`ALTER TABLE links ADD COLUMN hide_referrer INTEGER NOT NULL DEFAULT 0`,
+	// 30: links that keep no click data
+	`ALTER TABLE links ADD COLUMN no_analytics INTEGER NOT NULL DEFAULT 0`,
+	// 31: bare click counts of links that keep no click data
+	`CREATE TABLE IF NOT EXISTS click_counts (
+		short_code TEXT PRIMARY KEY,
+		clicks INTEGER NOT NULL
+	)`,
+	// 32: redirect limits of links that deactivate after a number of clicks
+	`ALTER TABLE links ADD COLUMN max_clicks INTEGER`,
+	// 33: per-device destinations of links, as JSON
+	`ALTER TABLE links ADD COLUMN device_targets TEXT`,
+	// 34: weighted A/B split destinations of links, as JSON
+	`ALTER TABLE links ADD COLUMN variants TEXT`,
+	// 35: the A/B split variant a click was sent to, '' for links without one
+	`ALTER TABLE clicks ADD COLUMN variant TEXT NOT NULL DEFAULT ''`,
+	// 36: the visitor's country of a click, '' if unknown
+	`ALTER TABLE clicks ADD COLUMN country TEXT NOT NULL DEFAULT ''`,
+	// 37: IDs conversions are attributed to clicks by, NULL when conversions were not tracked
+	`ALTER TABLE clicks ADD COLUMN click_id TEXT`,
+	// 38: unique lookup of clicks by click_id, for attributing conversions
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_clicks_click_id ON clicks (click_id) WHERE click_id IS NOT NULL`,
+	// 39: conversions posted for clicks, at most one per click and event
+	`CREATE TABLE IF NOT EXISTS conversions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		click_id TEXT NOT NULL,
+		short_code TEXT NOT NULL,
+		event TEXT NOT NULL,
+		value REAL,
+		currency TEXT NOT NULL DEFAULT '',
+		clicked_at TEXT NOT NULL,
+		converted_at TEXT NOT NULL,
+		UNIQUE (click_id, event)
+	)`,
+	// 40: conversions per link, for its stats
+	`CREATE INDEX IF NOT EXISTS idx_conversions_short_code ON conversions (short_code)`,
+	// 41: links passing the query parameters of their short URL on to the destination
+	`ALTER TABLE links ADD COLUMN forward_query INTEGER NOT NULL DEFAULT 0`,
+	// 42: public, append-only log of links disabled for abuse and reinstated
+	`CREATE TABLE IF NOT EXISTS takedown_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		short_code TEXT NOT NULL,
+		action TEXT NOT NULL,
+		category TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	)`,
+	// 43: the takedown log's entries can never be changed...
+	`CREATE TRIGGER IF NOT EXISTS takedown_log_no_update BEFORE UPDATE ON takedown_log
+	BEGIN SELECT RAISE(ABORT, 'takedown_log is append-only'); END`,
+	// 44: ...or removed
+	`CREATE TRIGGER IF NOT EXISTS takedown_log_no_delete BEFORE DELETE ON takedown_log
+	BEGIN SELECT RAISE(ABORT, 'takedown_log is append-only'); END`,
+	// 45: title, description and favicon of a link's destination page, as JSON
+	`ALTER TABLE links ADD COLUMN page_meta TEXT`,
+	// 46: click-count thresholds of campaigns, the links sharing a tag, and their
+	// running click counts
+	`CREATE TABLE IF NOT EXISTS campaign_alerts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		campaign TEXT NOT NULL,
+		threshold INTEGER NOT NULL,
+		clicks INTEGER NOT NULL DEFAULT 0,
+		webhook_url TEXT NOT NULL DEFAULT '',
+		email TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL,
+		triggered_at DATETIME
+	)`,
+	// 47: lookup of a campaign's pending alerts on every click
+	`CREATE INDEX IF NOT EXISTS idx_campaign_alerts_campaign ON campaign_alerts (campaign, triggered_at)`,
+	// 48: one-time tokens letting the creator of an anonymous link claim it, stored as hashes
+	`CREATE TABLE IF NOT EXISTS link_claim_tokens (
+		short_code TEXT PRIMARY KEY,
+		token_hash TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	)`,
+	// 49: links search engines are asked not to index
+	`ALTER TABLE links ADD COLUMN noindex INTEGER NOT NULL DEFAULT 0`,
+	// 50: reverse index from an owner's destinations, as SHA-256 hashes, to the plain
+	// links created for them, for DEDUPE_LINKS
+	`CREATE TABLE IF NOT EXISTS link_dedupe (
+		owner TEXT NOT NULL,
+		url_hash TEXT NOT NULL,
+		short_code TEXT NOT NULL,
+		PRIMARY KEY (owner, url_hash)
+	)`,
+	// 51: case-insensitive short code lookups, for CASE_INSENSITIVE_CODES
+	`CREATE INDEX IF NOT EXISTS idx_links_short_code_nocase ON links(short_code COLLATE NOCASE)`,
+}
+
+// runMigrations applies all pending migrations to db, each in its own transaction.
+func runMigrations(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations table: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for version := current + 1; version <= len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", version, err)
+		}
+		customlogger.Info().Int("version", version).Msg("Applied SQLite schema migration")
+	}
+	return nil
+}
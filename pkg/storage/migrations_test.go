@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/config"
+)
+
+// openTestDB initializes StatsDB against a fresh SQLite file in a temporary directory.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	cfg := config.AppConfig{SQLiteDBPath: filepath.Join(t.TempDir(), "stats.db")}
+	require.NoError(t, InitSQLite(cfg))
+	t.Cleanup(func() { StatsDB.Close() })
+	return StatsDB
+}
+
+func TestRunMigrationsIsIdempotent(t *testing.T) {
+	db := openTestDB(t)
+
+	var version int
+	require.NoError(t, db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version))
+	assert.Equal(t, len(migrations), version)
+
+	// Running again must not re-apply anything.
+	assert.NoError(t, runMigrations(db))
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&count))
+	assert.Equal(t, len(migrations), count)
+}
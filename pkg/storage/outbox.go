@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// RecordLinkEvent appends a lifecycle event for link to the outbox.
+func RecordLinkEvent(ctx context.Context, eventType string, link models.Link) error {
+	return recordLinkEvent(ctx, StatsDB, eventType, link)
+}
+
+func recordLinkEvent(ctx context.Context, db execer, eventType string, link models.Link) error {
+	link.LongURL = encryptDestination(link.LongURL)
+	payload, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO link_events (event_type, short_code, payload, created_at) VALUES (?, ?, ?, ?)`,
+		eventType, link.ShortCode, string(payload), time.Now().UTC())
+	return err
+}
+
+// ListLinkEvents returns up to limit outbox events with IDs greater than after, oldest first.
+func ListLinkEvents(ctx context.Context, after int64, limit int) ([]models.LinkEvent, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT id, event_type, short_code, payload, created_at FROM link_events
+		WHERE id > ? ORDER BY id LIMIT ?`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.LinkEvent{}
+	for rows.Next() {
+		var event models.LinkEvent
+		var payload string
+		if err := rows.Scan(&event.ID, &event.Type, &event.ShortCode, &payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &event.Link); err != nil {
+			return nil, err
+		}
+		longURL, err := decryptDestination(event.Link.LongURL)
+		if err != nil {
+			return nil, err
+		}
+		event.Link.LongURL = longURL
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// RecordExpiredLinkEvents records an "expired" event for every link whose expiry has
+// passed since its last "expired" event, and returns how many were recorded. A link
+// re-registered with a later expiry gets a new event when that expiry passes.
+func RecordExpiredLinkEvents(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT `+linkColumns+` FROM links l
+		WHERE l.expires_at IS NOT NULL AND l.expires_at <= ?
+		AND NOT EXISTS (
+			SELECT 1 FROM link_events e
+			WHERE e.short_code = l.short_code AND e.event_type = ? AND e.created_at >= l.expires_at
+		)`, now, models.LinkEventExpired)
+	if err != nil {
+		return 0, err
+	}
+	var expired []models.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expired = append(expired, link)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, link := range expired {
+		if err := RecordLinkEvent(ctx, models.LinkEventExpired, link); err != nil {
+			return i, err
+		}
+	}
+	return len(expired), nil
+}
+
+// OutboxCursor returns the ID of the last event a consumer processed, or 0 if it has
+// not processed any.
+func OutboxCursor(ctx context.Context, consumer string) (int64, error) {
+	var id int64
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT last_event_id FROM outbox_cursors WHERE consumer = ?`, consumer).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// SetOutboxCursor stores the ID of the last event a consumer processed.
+func SetOutboxCursor(ctx context.Context, consumer string, id int64) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT INTO outbox_cursors (consumer, last_event_id, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(consumer) DO UPDATE SET last_event_id = excluded.last_event_id, updated_at = excluded.updated_at`,
+		consumer, id, time.Now().UTC())
+	return err
+}
+
+// LatestLinkEventID returns the ID of the newest outbox event, or 0 if there are none.
+func LatestLinkEventID(ctx context.Context) (int64, error) {
+	var id int64
+	err := StatsDB.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM link_events`).Scan(&id)
+	return id, err
+}
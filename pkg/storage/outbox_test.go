@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/models"
+)
+
+func TestLinkEventsOutbox(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	past := now.Add(-time.Minute)
+
+	assert.NoError(t, CreateLink(ctx, models.Link{ShortCode: "live", LongURL: "https://example.com", CreatedAt: now}))
+	assert.NoError(t, CreateLink(ctx, models.Link{ShortCode: "gone", LongURL: "https://example.org", CreatedAt: past, ExpiresAt: &past}))
+
+	n, err := RecordExpiredLinkEvents(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	n, err = RecordExpiredLinkEvents(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n, "expired events are recorded once")
+
+	events, err := ListLinkEvents(ctx, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 3) {
+		assert.Equal(t, models.LinkEventCreated, events[0].Type)
+		assert.Equal(t, "live", events[0].ShortCode)
+		assert.Equal(t, "https://example.com", events[0].Link.LongURL)
+		assert.Equal(t, models.LinkEventExpired, events[2].Type)
+		assert.Equal(t, "gone", events[2].ShortCode)
+	}
+
+	events, err = ListLinkEvents(ctx, events[0].ID, 1)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "gone", events[0].ShortCode)
+}
+
+func TestOutboxCursor(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	id, err := OutboxCursor(ctx, "publisher")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), id)
+
+	assert.NoError(t, SetOutboxCursor(ctx, "publisher", 7))
+	assert.NoError(t, SetOutboxCursor(ctx, "publisher", 9))
+	id, err = OutboxCursor(ctx, "publisher")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9), id)
+}
@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// ErrPatternNotFound is returned when a redirect pattern does not exist.
+var ErrPatternNotFound = fmt.Errorf("redirect pattern %w", ErrNotFound)
+
+// ListRedirectPatterns returns all admin-defined redirect patterns.
+func ListRedirectPatterns(ctx context.Context) ([]models.RedirectPattern, error) {
+	rows, err := StatsDB.QueryContext(ctx, `SELECT id, pattern, destination, created_at FROM redirect_patterns ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	patterns := []models.RedirectPattern{}
+	for rows.Next() {
+		var p models.RedirectPattern
+		if err := rows.Scan(&p.ID, &p.Pattern, &p.Destination, &p.CreatedAt); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, rows.Err()
+}
+
+// SaveRedirectPattern stores a redirect pattern, replacing the destination of an
+// existing pattern with the same path.
+func SaveRedirectPattern(ctx context.Context, pattern, destination string) (models.RedirectPattern, error) {
+	p := models.RedirectPattern{Pattern: pattern, Destination: destination, CreatedAt: time.Now().UTC()}
+	err := StatsDB.QueryRowContext(ctx,
+		`INSERT INTO redirect_patterns (pattern, destination, created_at) VALUES (?, ?, ?)
+		ON CONFLICT(pattern) DO UPDATE SET destination = excluded.destination
+		RETURNING id, created_at`,
+		p.Pattern, p.Destination, p.CreatedAt).Scan(&p.ID, &p.CreatedAt)
+	return p, err
+}
+
+// DeleteRedirectPattern removes a redirect pattern by ID.
+func DeleteRedirectPattern(ctx context.Context, id int64) error {
+	res, err := StatsDB.ExecContext(ctx, `DELETE FROM redirect_patterns WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrPatternNotFound
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// BuildClickReport summarizes the clicks on all links between from and to, listing up
+// to limit links, referring hosts and countries, and clicks per day. Bare click counts
+// of links keeping no click data have no time and are left out.
+func BuildClickReport(ctx context.Context, from, to time.Time, limit int) (models.ClickReport, error) {
+	report := models.ClickReport{From: from.UTC(), To: to.UTC()}
+	start, end := from.UTC().Format(clickTimeLayout), to.UTC().Format(clickTimeLayout)
+
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT COUNT(*), COUNT(DISTINCT visitor) FROM clicks WHERE timestamp >= ? AND timestamp < ?`, start, end,
+	).Scan(&report.TotalClicks, &report.UniqueClicks)
+	if err != nil {
+		return report, err
+	}
+	if report.TopLinks, err = TopLinksByClicks(ctx, from, to, limit); err != nil {
+		return report, err
+	}
+
+	referrers, err := countClicksBy(ctx, "referrer", start, end)
+	if err != nil {
+		return report, err
+	}
+	hosts := map[string]int{}
+	for referrer, n := range referrers {
+		hosts[referrerHost(referrer)] += n
+	}
+	report.Referrers = topReportCounts(hosts, limit)
+
+	countries, err := countClicksBy(ctx, "country", start, end)
+	if err != nil {
+		return report, err
+	}
+	report.Countries = topReportCounts(countries, limit)
+
+	report.Timeline, err = ClickSeries(ctx, nil, from, to, 24*time.Hour)
+	return report, err
+}
+
+// countClicksBy counts the clicks between start and end per value of column, which
+// must be a trusted column name.
+func countClicksBy(ctx context.Context, column, start, end string) (map[string]int, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT COALESCE(`+column+`, ''), COUNT(*) FROM clicks WHERE timestamp >= ? AND timestamp < ? GROUP BY 1`, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var value string
+		var n int
+		if err := rows.Scan(&value, &n); err != nil {
+			return nil, err
+		}
+		counts[value] += n
+	}
+	return counts, rows.Err()
+}
+
+// referrerHost reduces a Referer to its host without "www.", so visits from different
+// pages of a site count together. It returns "" for direct visits.
+func referrerHost(referrer string) string {
+	if referrer == "" {
+		return ""
+	}
+	u, err := url.Parse(referrer)
+	if err != nil || u.Host == "" {
+		return referrer
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+// topReportCounts returns the limit values with the most clicks, most clicked first.
+func topReportCounts(counts map[string]int, limit int) []models.ReportCount {
+	top := make([]models.ReportCount, 0, len(counts))
+	for value, n := range counts {
+		top = append(top, models.ReportCount{Value: value, Clicks: n})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Clicks != top[j].Clicks {
+			return top[i].Clicks > top[j].Clicks
+		}
+		return top[i].Value < top[j].Value
+	})
+	if len(top) > limit {
+		top = top[:limit]
+	}
+	return top
+}
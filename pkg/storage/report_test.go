@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestBuildClickReport(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, click := range []struct {
+		code, referrer, visitor, country string
+		at                               time.Duration
+	}{
+		{"abc", "https://www.example.com/a", "v1", "DE", time.Hour},
+		{"abc", "https://example.com/b", "v2", "DE", 2 * time.Hour},
+		{"abc", "", "v1", "DE", 26 * time.Hour},
+		{"xyz", "https://news.ycombinator.com/", "v3", "", 27 * time.Hour},
+		{"xyz", "", "v3", "US", 49 * time.Hour}, // after the report
+	} {
+		require.NoError(t, RecordClick(ctx, models.ClickRecord{ShortCode: click.code, UserAgent: "ua", Referrer: click.referrer, Destination: "https://example.com", Visitor: click.visitor, Country: click.country, At: day.Add(click.at)}))
+	}
+
+	report, err := BuildClickReport(ctx, day, day.Add(48*time.Hour), 10)
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.TotalClicks)
+	assert.Equal(t, 3, report.UniqueClicks)
+	assert.Equal(t, []models.LinkClicks{
+		{ShortCode: "abc", TotalClicks: 3, UniqueClicks: 2},
+		{ShortCode: "xyz", TotalClicks: 1, UniqueClicks: 1},
+	}, report.TopLinks)
+	assert.Equal(t, []models.ReportCount{
+		{Value: "example.com", Clicks: 2}, {Value: "", Clicks: 1}, {Value: "news.ycombinator.com", Clicks: 1},
+	}, report.Referrers)
+	assert.Equal(t, []models.ReportCount{{Value: "DE", Clicks: 3}, {Value: "", Clicks: 1}}, report.Countries)
+	assert.Equal(t, []models.ClickSeriesPoint{{Time: day, Clicks: 2}, {Time: day.Add(24 * time.Hour), Clicks: 2}}, report.Timeline)
+
+	report, err = BuildClickReport(ctx, day, day.Add(48*time.Hour), 1)
+	require.NoError(t, err)
+	assert.Len(t, report.Referrers, 1)
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// GetLinkRollout returns the rollout configured for a link, or nil if it has none.
+func GetLinkRollout(ctx context.Context, shortCode string) (*models.LinkRollout, error) {
+	var rollout models.LinkRollout
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT destination, percent, updated_at FROM link_rollouts WHERE short_code = ?`, shortCode,
+	).Scan(&rollout.Destination, &rollout.Percent, &rollout.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if rollout.Destination, err = decryptDestination(rollout.Destination); err != nil {
+		return nil, err
+	}
+	return &rollout, nil
+}
+
+// SaveLinkRollout stores the rollout for a link, replacing any existing one. Its
+// destination is encrypted like those of links.
+func SaveLinkRollout(ctx context.Context, shortCode string, rollout models.LinkRollout) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO link_rollouts (short_code, destination, percent, updated_at) VALUES (?, ?, ?, ?)`,
+		shortCode, encryptDestination(rollout.Destination), rollout.Percent, time.Now().UTC())
+	return err
+}
+
+// DeleteLinkRollout ends the rollout of a link, sending all traffic to its regular destination.
+func DeleteLinkRollout(ctx context.Context, shortCode string) error {
+	_, err := StatsDB.ExecContext(ctx, `DELETE FROM link_rollouts WHERE short_code = ?`, shortCode)
+	return err
+}
+
+// ClickCountsByDestination returns the number of recorded clicks of a link per destination.
+// Clicks recorded before destinations were tracked are counted under "".
+func ClickCountsByDestination(ctx context.Context, shortCode string) (map[string]int, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT COALESCE(destination, ''), COUNT(*) FROM clicks WHERE short_code = ? GROUP BY 1`, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var destination string
+		var n int
+		if err := rows.Scan(&destination, &n); err != nil {
+			return nil, err
+		}
+		if destination, err = decryptDestination(destination); err != nil {
+			return nil, err
+		}
+		counts[destination] += n
+	}
+	return counts, rows.Err()
+}
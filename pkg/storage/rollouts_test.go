@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestLinkRolloutLifecycle(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+
+	rollout, err := GetLinkRollout(ctx, "abc")
+	require.NoError(t, err)
+	assert.Nil(t, rollout)
+
+	require.NoError(t, SaveLinkRollout(ctx, "abc", models.LinkRollout{Destination: "https://new.example.com", Percent: 10}))
+	require.NoError(t, SaveLinkRollout(ctx, "abc", models.LinkRollout{Destination: "https://new.example.com", Percent: 25}))
+	rollout, err = GetLinkRollout(ctx, "abc")
+	require.NoError(t, err)
+	require.NotNil(t, rollout)
+	assert.Equal(t, 25, rollout.Percent)
+	assert.Equal(t, "https://new.example.com", rollout.Destination)
+
+	require.NoError(t, DeleteLinkRollout(ctx, "abc"))
+	rollout, err = GetLinkRollout(ctx, "abc")
+	require.NoError(t, err)
+	assert.Nil(t, rollout)
+
+	_, err = db.Exec(`INSERT INTO clicks (short_code, user_agent, referrer) VALUES ('abc', '', '')`)
+	require.NoError(t, err)
+	for _, dest := range []string{"https://old.example.com", "https://old.example.com", "https://new.example.com"} {
+		_, err = db.Exec(`INSERT INTO clicks (short_code, user_agent, referrer, destination) VALUES ('abc', '', '', ?)`, dest)
+		require.NoError(t, err)
+	}
+	counts, err := ClickCountsByDestination(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"": 1, "https://old.example.com": 2, "https://new.example.com": 1}, counts)
+}
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"riid.me/pkg/models"
+	"riid.me/pkg/rules"
+)
+
+// replaceDestinationScript replaces the destination under KEYS[1] with ARGV[2] if it is
+// still ARGV[1], keeping its expiry, so a destination changed meanwhile is not reverted.
+var replaceDestinationScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) ~= ARGV[1] then
+	return 0
+end
+local ttl = redis.call('PTTL', KEYS[1])
+redis.call('SET', KEYS[1], ARGV[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// RotateDestinationEncryption rewrites every stored destination, in Redis, link
+// metadata including device targets and A/B split variants, rollouts, redirect rules,
+// recorded clicks and the event outbox, in the form encryptDestination now gives: encrypted with the current
+// key, or plain if encryption was turned off.
+// Destinations encrypted with the previous key or written in plain are re-encrypted.
+// It runs alongside the server: every write is conditional on the value it replaces.
+// Destinations in Redis are found through link metadata, so links created before
+// metadata was kept are left as they are.
+func RotateDestinationEncryption(ctx context.Context) (models.KeyRotationResult, error) {
+	var result models.KeyRotationResult
+	codes, err := rotateLinkDestinations(ctx, &result)
+	if err != nil {
+		return result, err
+	}
+	if err := rotateDeviceTargets(ctx, &result); err != nil {
+		return result, err
+	}
+	if err := rotateVariants(ctx, &result); err != nil {
+		return result, err
+	}
+	if err := rotateRolloutDestinations(ctx, &result); err != nil {
+		return result, err
+	}
+	if err := rotateRuleDestinations(ctx, &result); err != nil {
+		return result, err
+	}
+	if err := rotateRedisDestinations(ctx, codes, &result); err != nil {
+		return result, err
+	}
+	if err := rotateClickDestinations(ctx, &result); err != nil {
+		return result, err
+	}
+	if err := rotateEventDestinations(ctx, &result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// rotateLinkDestinations rotates the destinations in link metadata and returns the
+// short codes of all links.
+func rotateLinkDestinations(ctx context.Context, result *models.KeyRotationResult) ([]string, error) {
+	links := map[string]string{}
+	rows, err := StatsDB.QueryContext(ctx, `SELECT short_code, long_url FROM links`)
+	if err != nil {
+		return nil, err
+	}
+	if err := scanPairs(rows, links); err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, len(links))
+	for code, stored := range links {
+		codes = append(codes, code)
+		rotated, err := rotatedDestination(stored)
+		if err != nil {
+			return nil, fmt.Errorf("link %s: %w", code, err)
+		}
+		if rotated == stored {
+			continue
+		}
+		res, err := StatsDB.ExecContext(ctx,
+			`UPDATE links SET long_url = ? WHERE short_code = ? AND long_url = ?`, rotated, code, stored)
+		if err != nil {
+			return nil, err
+		}
+		n, _ := res.RowsAffected()
+		result.Links += n
+	}
+	return codes, nil
+}
+
+// rotateDeviceTargets rotates the per-device destinations in link metadata.
+func rotateDeviceTargets(ctx context.Context, result *models.KeyRotationResult) error {
+	stored := map[string]string{}
+	rows, err := StatsDB.QueryContext(ctx, `SELECT short_code, device_targets FROM links WHERE device_targets IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	if err := scanPairs(rows, stored); err != nil {
+		return err
+	}
+	for code, raw := range stored {
+		var targets models.DeviceTargets
+		if err := json.Unmarshal([]byte(raw), &targets); err != nil {
+			return fmt.Errorf("device targets of %s: %w", code, err)
+		}
+		for _, target := range []*string{&targets.IOS, &targets.Android, &targets.Desktop} {
+			if *target, err = rotatedDestination(*target); err != nil {
+				return fmt.Errorf("device targets of %s: %w", code, err)
+			}
+		}
+		rotated, err := json.Marshal(targets)
+		if err != nil {
+			return err
+		}
+		if string(rotated) == raw {
+			continue
+		}
+		res, err := StatsDB.ExecContext(ctx,
+			`UPDATE links SET device_targets = ? WHERE short_code = ? AND device_targets = ?`, string(rotated), code, raw)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Links += n
+	}
+	return nil
+}
+
+// rotateVariants rotates the A/B split destinations in link metadata.
+func rotateVariants(ctx context.Context, result *models.KeyRotationResult) error {
+	stored := map[string]string{}
+	rows, err := StatsDB.QueryContext(ctx, `SELECT short_code, variants FROM links WHERE variants IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	if err := scanPairs(rows, stored); err != nil {
+		return err
+	}
+	for code, raw := range stored {
+		var variants []models.LinkVariant
+		if err := json.Unmarshal([]byte(raw), &variants); err != nil {
+			return fmt.Errorf("variants of %s: %w", code, err)
+		}
+		for i := range variants {
+			if variants[i].Destination, err = rotatedDestination(variants[i].Destination); err != nil {
+				return fmt.Errorf("variants of %s: %w", code, err)
+			}
+		}
+		rotated, err := json.Marshal(variants)
+		if err != nil {
+			return err
+		}
+		if string(rotated) == raw {
+			continue
+		}
+		res, err := StatsDB.ExecContext(ctx,
+			`UPDATE links SET variants = ? WHERE short_code = ? AND variants = ?`, string(rotated), code, raw)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Links += n
+	}
+	return nil
+}
+
+// rotateRolloutDestinations rotates the destinations of rollouts.
+func rotateRolloutDestinations(ctx context.Context, result *models.KeyRotationResult) error {
+	rollouts := map[string]string{}
+	rows, err := StatsDB.QueryContext(ctx, `SELECT short_code, destination FROM link_rollouts`)
+	if err != nil {
+		return err
+	}
+	if err := scanPairs(rows, rollouts); err != nil {
+		return err
+	}
+	for code, stored := range rollouts {
+		rotated, err := rotatedDestination(stored)
+		if err != nil {
+			return fmt.Errorf("rollout of %s: %w", code, err)
+		}
+		if rotated == stored {
+			continue
+		}
+		res, err := StatsDB.ExecContext(ctx,
+			`UPDATE link_rollouts SET destination = ? WHERE short_code = ? AND destination = ?`, rotated, code, stored)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Rollouts += n
+	}
+	return nil
+}
+
+// rotateRuleDestinations rotates the destinations of redirect rules.
+func rotateRuleDestinations(ctx context.Context, result *models.KeyRotationResult) error {
+	stored := map[string]string{}
+	rows, err := StatsDB.QueryContext(ctx, `SELECT short_code, rules FROM link_rules`)
+	if err != nil {
+		return err
+	}
+	if err := scanPairs(rows, stored); err != nil {
+		return err
+	}
+	for code, raw := range stored {
+		var rs rules.RuleSet
+		if err := json.Unmarshal([]byte(raw), &rs); err != nil {
+			return fmt.Errorf("rules of %s: %w", code, err)
+		}
+		for i := range rs.Rules {
+			if rs.Rules[i].Destination, err = rotatedDestination(rs.Rules[i].Destination); err != nil {
+				return fmt.Errorf("rules of %s: %w", code, err)
+			}
+		}
+		rotated, err := json.Marshal(rs)
+		if err != nil {
+			return err
+		}
+		if string(rotated) == raw {
+			continue
+		}
+		res, err := StatsDB.ExecContext(ctx,
+			`UPDATE link_rules SET rules = ? WHERE short_code = ? AND rules = ?`, string(rotated), code, raw)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Rules += n
+	}
+	return nil
+}
+
+// rotateRedisDestinations rotates the destinations of codes in Redis.
+func rotateRedisDestinations(ctx context.Context, codes []string, result *models.KeyRotationResult) error {
+	for _, code := range codes {
+		stored, err := Rdb.Get(ctx, code).Result()
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return err
+		}
+		rotated, err := rotatedDestination(stored)
+		if err != nil {
+			return fmt.Errorf("destination of %s: %w", code, err)
+		}
+		if rotated == stored {
+			continue
+		}
+		replaced, err := replaceDestinationScript.Run(ctx, Rdb, []string{code}, stored, rotated).Int()
+		if err != nil {
+			return err
+		}
+		result.Destinations += int64(replaced)
+	}
+	return nil
+}
+
+// rotateClickDestinations rotates the destinations recorded with clicks.
+func rotateClickDestinations(ctx context.Context, result *models.KeyRotationResult) error {
+	destinations := map[string]string{}
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT DISTINCT destination, '' FROM clicks WHERE destination IS NOT NULL AND destination != ''`)
+	if err != nil {
+		return err
+	}
+	if err := scanPairs(rows, destinations); err != nil {
+		return err
+	}
+	for stored := range destinations {
+		rotated, err := rotatedDestination(stored)
+		if err != nil {
+			return fmt.Errorf("click destination: %w", err)
+		}
+		if rotated == stored {
+			continue
+		}
+		res, err := StatsDB.ExecContext(ctx, `UPDATE clicks SET destination = ? WHERE destination = ?`, rotated, stored)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Clicks += n
+	}
+	return nil
+}
+
+// rotateEventDestinations rotates the destinations in outbox event payloads.
+func rotateEventDestinations(ctx context.Context, result *models.KeyRotationResult) error {
+	payloads := map[string]string{}
+	rows, err := StatsDB.QueryContext(ctx, `SELECT CAST(id AS TEXT), payload FROM link_events`)
+	if err != nil {
+		return err
+	}
+	if err := scanPairs(rows, payloads); err != nil {
+		return err
+	}
+	for id, payload := range payloads {
+		// Only long_url is rewritten, so payloads of older events keep their shape.
+		var event map[string]any
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			return fmt.Errorf("event %s: %w", id, err)
+		}
+		stored, _ := event["long_url"].(string)
+		rotated, err := rotatedDestination(stored)
+		if err != nil {
+			return fmt.Errorf("event %s: %w", id, err)
+		}
+		if rotated == stored {
+			continue
+		}
+		event["long_url"] = rotated
+		updated, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		res, err := StatsDB.ExecContext(ctx,
+			`UPDATE link_events SET payload = ? WHERE id = ? AND payload = ?`, string(updated), id, payload)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		result.Events += n
+	}
+	return nil
+}
+
+// rotatedDestination returns the form the destination stored as value is to be
+// stored in from now on.
+func rotatedDestination(value string) (string, error) {
+	destination, err := decryptDestination(value)
+	if err != nil {
+		return "", err
+	}
+	return encryptDestination(destination), nil
+}
+
+// scanPairs reads two-column rows into pairs and closes them. Rows are read in full
+// before anything is updated, as SQLite cannot write while a query is open.
+func scanPairs(rows *sql.Rows, pairs map[string]string) error {
+	defer rows.Close()
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return err
+		}
+		pairs[key] = value
+	}
+	return rows.Err()
+}
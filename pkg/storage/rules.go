@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"riid.me/pkg/rules"
+)
+
+// GetLinkRules returns the rule set attached to a link, or nil if the link has none.
+func GetLinkRules(ctx context.Context, shortCode string) (*rules.RuleSet, error) {
+	var raw string
+	err := StatsDB.QueryRowContext(ctx, `SELECT rules FROM link_rules WHERE short_code = ?`, shortCode).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var rs rules.RuleSet
+	if err := json.Unmarshal([]byte(raw), &rs); err != nil {
+		return nil, err
+	}
+	for i := range rs.Rules {
+		if rs.Rules[i].Destination, err = decryptDestination(rs.Rules[i].Destination); err != nil {
+			return nil, err
+		}
+	}
+	return &rs, nil
+}
+
+// SaveLinkRules stores the rule set for a link, replacing any existing rules. The
+// destinations of the rules are encrypted like those of links.
+func SaveLinkRules(ctx context.Context, shortCode string, rs rules.RuleSet) error {
+	raw, err := marshalLinkRules(rs)
+	if err != nil {
+		return err
+	}
+	_, err = StatsDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO link_rules (short_code, rules, updated_at) VALUES (?, ?, ?)`,
+		shortCode, raw, time.Now().UTC())
+	return err
+}
+
+// marshalLinkRules returns rs in the form it is stored in, with encrypted destinations.
+// rs itself is left unchanged.
+func marshalLinkRules(rs rules.RuleSet) (string, error) {
+	stored := rs
+	stored.Rules = make([]rules.Rule, len(rs.Rules))
+	for i, rule := range rs.Rules {
+		rule.Destination = encryptDestination(rule.Destination)
+		stored.Rules[i] = rule
+	}
+	raw, err := json.Marshal(stored)
+	return string(raw), err
+}
+
+// DeleteLinkRules removes all rules from a link.
+func DeleteLinkRules(ctx context.Context, shortCode string) error {
+	_, err := StatsDB.ExecContext(ctx, `DELETE FROM link_rules WHERE short_code = ?`, shortCode)
+	return err
+}
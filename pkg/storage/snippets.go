@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// snippetColumns are the columns scanned by scanSnippet, in order.
+const snippetColumns = `short_code, content, language, burn_after_read, views, created_at`
+
+// scanSnippet reads a snippet selected or returned as snippetColumns.
+func scanSnippet(row *sql.Row) (models.Snippet, error) {
+	var snippet models.Snippet
+	err := row.Scan(&snippet.ShortCode, &snippet.Content, &snippet.Language, &snippet.BurnAfterRead, &snippet.Views, &snippet.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.Snippet{}, ErrSnippetNotFound
+	}
+	return snippet, err
+}
+
+// SaveSnippet stores a snippet, replacing any previous one for the short code (e.g. a
+// custom handle re-registered after expiring).
+func SaveSnippet(ctx context.Context, snippet models.Snippet) error {
+	_, err := StatsDB.ExecContext(ctx,
+		`INSERT OR REPLACE INTO snippets (`+snippetColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		snippet.ShortCode, snippet.Content, snippet.Language, snippet.BurnAfterRead, snippet.Views, snippet.CreatedAt.UTC())
+	return err
+}
+
+// GetSnippet returns the snippet stored under a short code without counting a view,
+// or ErrSnippetNotFound.
+func GetSnippet(ctx context.Context, shortCode string) (models.Snippet, error) {
+	return scanSnippet(StatsDB.QueryRowContext(ctx,
+		`SELECT `+snippetColumns+` FROM snippets WHERE short_code = ?`, shortCode))
+}
+
+// ViewSnippet returns the snippet stored under a short code and counts the view. A
+// burn-after-read snippet is deleted in the same statement, so only one viewer can
+// ever get it.
+func ViewSnippet(ctx context.Context, shortCode string) (models.Snippet, error) {
+	snippet, err := scanSnippet(StatsDB.QueryRowContext(ctx,
+		`DELETE FROM snippets WHERE short_code = ? AND burn_after_read = 1 RETURNING `+snippetColumns, shortCode))
+	if err == nil {
+		snippet.Views++
+		return snippet, nil
+	} else if err != ErrSnippetNotFound {
+		return models.Snippet{}, err
+	}
+	return scanSnippet(StatsDB.QueryRowContext(ctx,
+		`UPDATE snippets SET views = views + 1 WHERE short_code = ? RETURNING `+snippetColumns, shortCode))
+}
+
+// DeleteExpiredSnippets removes the snippets whose link has expired or no longer has
+// a record and returns how many were removed.
+func DeleteExpiredSnippets(ctx context.Context) (int64, error) {
+	res, err := StatsDB.ExecContext(ctx,
+		`DELETE FROM snippets WHERE short_code NOT IN (
+			SELECT short_code FROM links WHERE expires_at IS NULL OR expires_at > ?
+		)`, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestViewSnippet(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	require.NoError(t, SaveSnippet(ctx, models.Snippet{ShortCode: "note", Content: "hello", Language: "text", CreatedAt: now}))
+	require.NoError(t, SaveSnippet(ctx, models.Snippet{ShortCode: "secret", Content: "once", Language: "text", BurnAfterRead: true, CreatedAt: now}))
+
+	snippet, err := ViewSnippet(ctx, "note")
+	require.NoError(t, err)
+	assert.Equal(t, 1, snippet.Views)
+	snippet, err = ViewSnippet(ctx, "note")
+	require.NoError(t, err)
+	assert.Equal(t, 2, snippet.Views)
+
+	snippet, err = ViewSnippet(ctx, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "once", snippet.Content)
+	_, err = ViewSnippet(ctx, "secret")
+	assert.ErrorIs(t, err, ErrSnippetNotFound, "a burn-after-read snippet is gone after one view")
+}
+
+func TestDeleteExpiredSnippets(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	for code, expiresAt := range map[string]*time.Time{"gone": &past, "live": &future, "forever": nil} {
+		require.NoError(t, CreateLink(ctx, models.Link{ShortCode: code, LongURL: "https://example.com/s/" + code, CreatedAt: now, ExpiresAt: expiresAt}))
+		require.NoError(t, SaveSnippet(ctx, models.Snippet{ShortCode: code, Content: code, Language: "text", CreatedAt: now}))
+	}
+	require.NoError(t, SaveSnippet(ctx, models.Snippet{ShortCode: "orphan", Content: "x", Language: "text", CreatedAt: now}))
+
+	n, err := DeleteExpiredSnippets(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	for _, code := range []string{"live", "forever"} {
+		_, err := GetSnippet(ctx, code)
+		assert.NoError(t, err, code)
+	}
+}
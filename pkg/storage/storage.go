@@ -39,7 +39,9 @@ func InitRedis(cfg config.AppConfig) error {
 }
 
 // InitSQLite initializes the connection to the SQLite database using the path from AppConfig.
-// It also ensures that the necessary 'clicks' table exists for storing statistics.
+// It also ensures that the necessary 'clicks', 'links', 'link_comments' and 'link_rules' tables
+// exist for storing statistics, link metadata, team comments and redirect rules, and applies
+// any pending schema migrations.
 // The connection is stored in the global StatsDB variable.
 func InitSQLite(cfg config.AppConfig) error {
 	var err error
@@ -71,5 +73,60 @@ func InitSQLite(cfg config.AppConfig) error {
 		return err
 	}
 	customlogger.Info().Msg("Clicks table ensured in SQLite database")
+
+	// Create links table if it doesn't exist
+	createLinksTableSQL := `
+	CREATE TABLE IF NOT EXISTS links (
+		short_code TEXT PRIMARY KEY,
+		long_url TEXT NOT NULL,
+		title TEXT NOT NULL DEFAULT '',
+		public INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		expires_at DATETIME
+	);`
+
+	_, err = StatsDB.Exec(createLinksTableSQL)
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Failed to create links table in SQLite database")
+		return err
+	}
+	customlogger.Info().Msg("Links table ensured in SQLite database")
+
+	// Create link_comments table if it doesn't exist
+	createCommentsTableSQL := `
+	CREATE TABLE IF NOT EXISTS link_comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		short_code TEXT NOT NULL,
+		author TEXT NOT NULL,
+		body TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = StatsDB.Exec(createCommentsTableSQL)
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Failed to create link_comments table in SQLite database")
+		return err
+	}
+	customlogger.Info().Msg("Link comments table ensured in SQLite database")
+
+	// Create link_rules table if it doesn't exist
+	createRulesTableSQL := `
+	CREATE TABLE IF NOT EXISTS link_rules (
+		short_code TEXT PRIMARY KEY,
+		rules TEXT NOT NULL,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err = StatsDB.Exec(createRulesTableSQL)
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Failed to create link_rules table in SQLite database")
+		return err
+	}
+	customlogger.Info().Msg("Link rules table ensured in SQLite database")
+
+	if err = runMigrations(StatsDB); err != nil {
+		customlogger.Error().Err(err).Msg("Failed to migrate SQLite database schema")
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// GetLinkTakedown returns the takedown of a link, or nil if it is not disabled.
+func GetLinkTakedown(ctx context.Context, shortCode string) (*models.LinkTakedown, error) {
+	var takedown models.LinkTakedown
+	err := StatsDB.QueryRowContext(ctx,
+		`SELECT short_code, reason, category, notify_email, created_at FROM link_takedowns WHERE short_code = ?`, shortCode,
+	).Scan(&takedown.ShortCode, &takedown.Reason, &takedown.Category, &takedown.NotifyEmail, &takedown.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &takedown, nil
+}
+
+// SaveLinkTakedown disables a link, replacing the record of an earlier takedown, and
+// appends it to the takedown log, atomically.
+func SaveLinkTakedown(ctx context.Context, takedown models.LinkTakedown) error {
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT OR REPLACE INTO link_takedowns (short_code, reason, category, notify_email, created_at) VALUES (?, ?, ?, ?, ?)`,
+		takedown.ShortCode, takedown.Reason, takedown.Category, takedown.NotifyEmail, takedown.CreatedAt.UTC())
+	if err != nil {
+		return err
+	}
+	if err := appendTakedownLog(ctx, tx, takedown.ShortCode, models.TakedownActionDisabled, takedown.Category); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteLinkTakedown reinstates a disabled link and appends that to the takedown log,
+// or returns ErrTakedownNotFound.
+func DeleteLinkTakedown(ctx context.Context, shortCode string) error {
+	tx, err := StatsDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `DELETE FROM link_takedowns WHERE short_code = ?`, shortCode)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrTakedownNotFound
+	}
+	if err := appendTakedownLog(ctx, tx, shortCode, models.TakedownActionReinstated, ""); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// appendTakedownLog records a takedown action in the takedown log.
+func appendTakedownLog(ctx context.Context, db execer, shortCode, action, category string) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO takedown_log (short_code, action, category, created_at) VALUES (?, ?, ?, ?)`,
+		shortCode, action, category, time.Now().UTC())
+	return err
+}
+
+// ListTakedownLog returns up to limit takedown log entries with IDs greater than
+// after, oldest first.
+func ListTakedownLog(ctx context.Context, after int64, limit int) ([]models.TakedownLogEntry, error) {
+	rows, err := StatsDB.QueryContext(ctx,
+		`SELECT id, short_code, action, category, created_at FROM takedown_log
+		WHERE id > ? ORDER BY id LIMIT ?`, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.TakedownLogEntry{}
+	for rows.Next() {
+		var entry models.TakedownLogEntry
+		if err := rows.Scan(&entry.ID, &entry.ShortCode, &entry.Action, &entry.Category, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// ListLinkTakedowns returns the disabled links, most recent first: all of them, or
+// those disabled in category if it is not empty.
+func ListLinkTakedowns(ctx context.Context, category string) ([]models.LinkTakedown, error) {
+	query := `SELECT short_code, reason, category, notify_email, created_at FROM link_takedowns`
+	var args []any
+	if category != "" {
+		query += ` WHERE category = ?`
+		args = append(args, category)
+	}
+	rows, err := StatsDB.QueryContext(ctx, query+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	takedowns := []models.LinkTakedown{}
+	for rows.Next() {
+		var takedown models.LinkTakedown
+		if err := rows.Scan(&takedown.ShortCode, &takedown.Reason, &takedown.Category, &takedown.NotifyEmail, &takedown.CreatedAt); err != nil {
+			return nil, err
+		}
+		takedowns = append(takedowns, takedown)
+	}
+	return takedowns, rows.Err()
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"riid.me/pkg/models"
+)
+
+func TestTakedownLog(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	require.NoError(t, SaveLinkTakedown(ctx, models.LinkTakedown{ShortCode: "bad", Reason: "phishing kit at example.com", Category: "phishing", CreatedAt: now}))
+	require.NoError(t, DeleteLinkTakedown(ctx, "bad"))
+	assert.ErrorIs(t, DeleteLinkTakedown(ctx, "bad"), ErrTakedownNotFound)
+	require.NoError(t, SaveLinkTakedown(ctx, models.LinkTakedown{ShortCode: "spam", Reason: "spam", CreatedAt: now}))
+
+	entries, err := ListTakedownLog(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "bad", entries[0].ShortCode)
+	assert.Equal(t, models.TakedownActionDisabled, entries[0].Action)
+	assert.Equal(t, "phishing", entries[0].Category)
+	assert.Equal(t, models.TakedownActionReinstated, entries[1].Action)
+	assert.Equal(t, "spam", entries[2].ShortCode)
+
+	entries, err = ListTakedownLog(ctx, entries[0].ID, 1)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, models.TakedownActionReinstated, entries[0].Action)
+
+	// Entries outlive the link and can never be rewritten.
+	require.NoError(t, DeleteLink(ctx, models.Link{ShortCode: "spam"}))
+	_, err = StatsDB.ExecContext(ctx, `UPDATE takedown_log SET category = 'x'`)
+	assert.ErrorContains(t, err, "append-only")
+	_, err = StatsDB.ExecContext(ctx, `DELETE FROM takedown_log`)
+	assert.ErrorContains(t, err, "append-only")
+	entries, err = ListTakedownLog(ctx, 0, 10)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+}
+
+func TestListLinkTakedownsByCategory(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	require.NoError(t, SaveLinkTakedown(ctx, models.LinkTakedown{ShortCode: "scanned", Reason: "on a blocklist", Category: "policy", CreatedAt: now}))
+	require.NoError(t, SaveLinkTakedown(ctx, models.LinkTakedown{ShortCode: "reported", Reason: "phishing", Category: "phishing", CreatedAt: now.Add(time.Second)}))
+
+	takedowns, err := ListLinkTakedowns(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, takedowns, 2)
+	assert.Equal(t, "reported", takedowns[0].ShortCode)
+
+	takedowns, err = ListLinkTakedowns(ctx, "policy")
+	require.NoError(t, err)
+	require.Len(t, takedowns, 1)
+	assert.Equal(t, "scanned", takedowns[0].ShortCode)
+	assert.Equal(t, "on a blocklist", takedowns[0].Reason)
+}
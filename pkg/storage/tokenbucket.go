@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// tokenBucketScript refills the token bucket under KEYS[1], a hash of its token count
+// and the time it was last updated, and takes a token if one is available, atomically.
+// ARGV holds the capacity, the refill rate in tokens per millisecond and the current
+// time in milliseconds. It returns {1, 0} when a token was taken, or {0, wait} with the
+// milliseconds until the next token. Idle buckets expire once they would be full.
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or capacity
+local ts = tonumber(bucket[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local taken, wait = 0, 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	taken = 1
+else
+	wait = math.ceil((1 - tokens) / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+return {taken, wait}
+`)
+
+// TakeToken takes a token from the bucket under key, which holds up to capacity tokens
+// and refills completely over period. It reports whether a token was available and,
+// if not, how long until the next one is.
+func TakeToken(ctx context.Context, key string, capacity int, period time.Duration, now time.Time) (bool, time.Duration, error) {
+	rate := float64(capacity) / float64(period.Milliseconds())
+	result, err := tokenBucketScript.Run(ctx, Rdb, []string{key}, capacity, rate, now.UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	return result[0] == 1, time.Duration(result[1]) * time.Millisecond, nil
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"riid.me/pkg/models"
+)
+
+// CreateUser stores a new account with the bcrypt hash of its password and returns it
+// with its ID, or ErrUserExists if email already has an account.
+func CreateUser(ctx context.Context, email, passwordHash string, createdAt time.Time) (models.User, error) {
+	user := models.User{Email: email, PasswordHash: passwordHash, CreatedAt: createdAt.UTC()}
+	res, err := StatsDB.ExecContext(ctx,
+		`INSERT INTO users (email, password_hash, created_at) VALUES (?, ?, ?) ON CONFLICT(email) DO NOTHING`,
+		user.Email, user.PasswordHash, user.CreatedAt)
+	if err != nil {
+		return models.User{}, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return models.User{}, err
+	} else if n == 0 {
+		return models.User{}, ErrUserExists
+	}
+	if user.ID, err = res.LastInsertId(); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// UserByEmail returns the account registered with email, or ErrUserNotFound.
+func UserByEmail(ctx context.Context, email string) (models.User, error) {
+	return scanUser(StatsDB.QueryRowContext(ctx,
+		`SELECT id, email, password_hash, created_at FROM users WHERE email = ?`, email))
+}
+
+// UserByID returns the account with id, or ErrUserNotFound.
+func UserByID(ctx context.Context, id int64) (models.User, error) {
+	return scanUser(StatsDB.QueryRowContext(ctx,
+		`SELECT id, email, password_hash, created_at FROM users WHERE id = ?`, id))
+}
+
+// scanUser reads a users row selected by UserByEmail or UserByID.
+func scanUser(row *sql.Row) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.User{}, ErrUserNotFound
+	} else if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
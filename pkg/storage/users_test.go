@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsers(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	user, err := CreateUser(ctx, "ada@example.com", "hash", now)
+	require.NoError(t, err)
+	assert.NotZero(t, user.ID)
+
+	_, err = CreateUser(ctx, "ada@example.com", "other", now)
+	assert.ErrorIs(t, err, ErrUserExists)
+
+	byEmail, err := UserByEmail(ctx, "ada@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, byEmail.ID)
+	assert.Equal(t, "hash", byEmail.PasswordHash)
+	assert.True(t, now.Equal(byEmail.CreatedAt))
+
+	byID, err := UserByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "ada@example.com", byID.Email)
+
+	_, err = UserByEmail(ctx, "bob@example.com")
+	assert.ErrorIs(t, err, ErrUserNotFound)
+	_, err = UserByID(ctx, user.ID+1)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
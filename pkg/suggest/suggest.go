@@ -0,0 +1,119 @@
+// Package suggest finds short codes within one edit of a code that does not exist,
+// so visitors who mistype a printed link can be pointed at the one they meant.
+//
+// The Index uses the symmetric delete scheme: every code is stored under itself and
+// each string obtained by deleting one of its characters. Two strings are within one
+// insertion, deletion or substitution of each other only if they share such a key,
+// so a lookup touches a handful of map entries instead of every code.
+package suggest
+
+import (
+	"sort"
+	"sync"
+)
+
+// Index is a set of short codes searchable by edit distance. It is safe for
+// concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	codes    map[string]struct{}
+	variants map[string][]string
+}
+
+// NewIndex returns an index holding the given codes.
+func NewIndex(codes []string) *Index {
+	idx := &Index{
+		codes:    make(map[string]struct{}, len(codes)),
+		variants: make(map[string][]string, len(codes)*8),
+	}
+	for _, code := range codes {
+		idx.add(code)
+	}
+	return idx
+}
+
+// Add inserts a code into the index.
+func (idx *Index) Add(code string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.add(code)
+}
+
+func (idx *Index) add(code string) {
+	if _, ok := idx.codes[code]; ok || code == "" {
+		return
+	}
+	idx.codes[code] = struct{}{}
+	for _, key := range keys(code) {
+		idx.variants[key] = append(idx.variants[key], code)
+	}
+}
+
+// Len returns the number of codes in the index.
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.codes)
+}
+
+// Similar returns up to limit indexed codes exactly one edit away from code, in
+// alphabetical order. The code itself is never returned.
+func (idx *Index) Similar(code string, limit int) []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	seen := map[string]struct{}{code: {}}
+	var matches []string
+	for _, key := range keys(code) {
+		for _, candidate := range idx.variants[key] {
+			if _, ok := seen[candidate]; ok {
+				continue
+			}
+			seen[candidate] = struct{}{}
+			if withinOneEdit(code, candidate) {
+				matches = append(matches, candidate)
+			}
+		}
+	}
+	sort.Strings(matches)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// keys returns s and every string obtained by deleting one character from it.
+func keys(s string) []string {
+	r := []rune(s)
+	out := make([]string, 0, len(r)+1)
+	out = append(out, s)
+	for i := range r {
+		out = append(out, string(r[:i])+string(r[i+1:]))
+	}
+	return out
+}
+
+// withinOneEdit reports whether a and b differ by at most one insertion, deletion
+// or substitution.
+func withinOneEdit(a, b string) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < len(rb) {
+		ra, rb = rb, ra
+	}
+	if len(ra)-len(rb) > 1 {
+		return false
+	}
+	i := 0
+	for i < len(rb) && ra[i] == rb[i] {
+		i++
+	}
+	if i == len(ra) {
+		return true
+	}
+	if len(ra) == len(rb) {
+		// Skip the one substituted character.
+		return string(ra[i+1:]) == string(rb[i+1:])
+	}
+	// Skip the one character inserted into the longer string.
+	return string(ra[i+1:]) == string(rb[i:])
+}
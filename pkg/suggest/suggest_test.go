@@ -0,0 +1,40 @@
+package suggest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithinOneEdit(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"abc", "abc", true},
+		{"abc", "abd", true},
+		{"abc", "xbc", true},
+		{"abc", "ab", true},
+		{"abc", "bc", true},
+		{"abc", "abxc", true},
+		{"abc", "axd", false},
+		{"abc", "a", false},
+		{"abc", "acb", false},
+		{"", "a", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, withinOneEdit(tt.a, tt.b), "%q vs %q", tt.a, tt.b)
+		assert.Equal(t, tt.want, withinOneEdit(tt.b, tt.a), "%q vs %q", tt.b, tt.a)
+	}
+}
+
+func TestIndexSimilar(t *testing.T) {
+	idx := NewIndex([]string{"launch", "lunch", "launches", "Launch", "docs", "launcH2"})
+	idx.Add("laumch")
+
+	assert.Equal(t, []string{"Launch", "laumch", "lunch"}, idx.Similar("launch", 5))
+	assert.Equal(t, []string{"launch"}, idx.Similar("launc", 5))
+	assert.Equal(t, []string{"Launch", "laumch"}, idx.Similar("launch", 2))
+	assert.Empty(t, idx.Similar("nothing", 5))
+	assert.Equal(t, 7, idx.Len())
+}
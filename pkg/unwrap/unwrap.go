@@ -0,0 +1,181 @@
+// Package unwrap follows the redirect chain of a URL to its final destination, so a
+// link can be stored pointing where it really leads, and reports chains that pass
+// through other link shorteners.
+package unwrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// requestTimeout bounds each hop of a chain.
+const requestTimeout = 5 * time.Second
+
+var (
+	// ErrTooManyHops is returned for chains with more redirects than allowed.
+	ErrTooManyHops = errors.New("redirect chain is too long")
+	// errBlockedAddress is returned for hops resolving to non-public addresses, which
+	// must never be requested on a user's behalf.
+	errBlockedAddress = errors.New("destination resolves to a non-public address")
+)
+
+// DefaultShorteners are the hosts of well-known link shorteners.
+var DefaultShorteners = []string{
+	"adf.ly", "bit.ly", "bl.ink", "buff.ly", "cutt.ly", "goo.gl", "is.gd", "lnkd.in",
+	"ow.ly", "rb.gy", "rebrand.ly", "s.id", "shorte.st", "shorturl.at", "t.co", "t.ly",
+	"tiny.cc", "tinyurl.com", "v.gd",
+}
+
+// Result describes the redirect chain of a URL. Hops lists the URLs redirected to,
+// in order, so the last one is Final; it is empty when the URL does not redirect.
+// Shorteners lists the known shortener hosts the chain passed through.
+type Result struct {
+	Final      string
+	Hops       []string
+	Shorteners []string
+}
+
+// Resolver follows redirect chains of at most MaxHops redirects.
+type Resolver struct {
+	Client     *http.Client
+	MaxHops    int
+	Shorteners map[string]bool
+}
+
+// NewResolver returns a Resolver following up to maxHops redirects, treating the
+// hosts in shorteners (ports and "www." are ignored) as link shorteners. Its client refuses to connect to private,
+// loopback and other non-public addresses, and ignores HTTP_PROXY and HTTPS_PROXY, as
+// through a proxy that check would never see the resolved address.
+func NewResolver(maxHops int, shorteners []string) *Resolver {
+	dialer := &net.Dialer{Timeout: requestTimeout, Control: PublicAddressesOnly}
+	transport := &http.Transport{
+		// No proxy: the dialer could then only check the proxy's address, not the destination's.
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   requestTimeout,
+		ResponseHeaderTimeout: requestTimeout,
+	}
+	return newResolver(&http.Client{Transport: transport, Timeout: requestTimeout}, maxHops, shorteners)
+}
+
+// newResolver returns a Resolver using client, which is changed not to follow redirects.
+func newResolver(client *http.Client, maxHops int, shorteners []string) *Resolver {
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	known := map[string]bool{}
+	for _, host := range shorteners {
+		known[hostOf("//"+host)] = true
+	}
+	return &Resolver{Client: client, MaxHops: maxHops, Shorteners: known}
+}
+
+// PublicAddressesOnly is a dialer Control function rejecting connections to
+// addresses that are not public unicast addresses.
+func PublicAddressesOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// Resolve follows the redirects of rawURL. Only http and https hops are followed; a
+// redirect to any other scheme ends the chain there.
+func (r *Resolver) Resolve(ctx context.Context, rawURL string) (Result, error) {
+	result := Result{Final: rawURL}
+	seen := map[string]bool{}
+	current := rawURL
+	for {
+		next, err := r.location(ctx, current)
+		if err != nil {
+			return result, err
+		}
+		if next == "" {
+			return result, nil
+		}
+		if host := hostOf(current); r.Shorteners[host] && !seen[host] {
+			seen[host] = true
+			result.Shorteners = append(result.Shorteners, host)
+		}
+		if len(result.Hops) == r.MaxHops {
+			return result, fmt.Errorf("%w: more than %d redirects", ErrTooManyHops, r.MaxHops)
+		}
+		result.Hops = append(result.Hops, next)
+		result.Final = next
+		current = next
+	}
+}
+
+// location requests rawURL and returns the absolute URL it redirects to, or "" if it
+// does not redirect to an http(s) URL. Servers rejecting HEAD are asked with GET.
+func (r *Resolver) location(ctx context.Context, rawURL string) (string, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return "", nil
+	}
+
+	resp, err := r.do(ctx, http.MethodHead, rawURL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = r.do(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 300 || resp.StatusCode > 399 {
+		return "", nil
+	}
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", nil
+	}
+	next, err := base.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid redirect location %q: %w", location, err)
+	}
+	if next.Scheme != "http" && next.Scheme != "https" {
+		return "", nil
+	}
+	return next.String(), nil
+}
+
+// do makes a single request without reading the body.
+func (r *Resolver) do(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "riid.me-unwrap/1.0")
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// hostOf returns the lowercased host name of rawURL without port and "www." prefix.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
@@ -0,0 +1,83 @@
+package unwrap
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chainServer redirects /hop/N to /hop/N-1 and serves /hop/0 without redirecting.
+// HEAD requests are rejected on /nohead to exercise the GET fallback.
+func chainServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hop/", func(w http.ResponseWriter, r *http.Request) {
+		switch n := strings.TrimPrefix(r.URL.Path, "/hop/"); n {
+		case "0":
+			w.WriteHeader(http.StatusOK)
+		case "1":
+			http.Redirect(w, r, "/hop/0", http.StatusFound)
+		case "2":
+			http.Redirect(w, r, "/hop/1", http.StatusMovedPermanently)
+		default:
+			http.Redirect(w, r, "/hop/2", http.StatusTemporaryRedirect)
+		}
+	})
+	mux.HandleFunc("/nohead", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.Redirect(w, r, "/hop/0", http.StatusFound)
+	})
+	mux.HandleFunc("/mailto", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "mailto:someone@example.com", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolve(t *testing.T) {
+	server := chainServer(t)
+	ctx := context.Background()
+	resolver := newResolver(server.Client(), 3, []string{"127.0.0.1:1234"})
+
+	result, err := resolver.Resolve(ctx, server.URL+"/hop/0")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/hop/0", result.Final)
+	assert.Empty(t, result.Hops)
+
+	result, err = resolver.Resolve(ctx, server.URL+"/hop/2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{server.URL + "/hop/1", server.URL + "/hop/0"}, result.Hops)
+	assert.Equal(t, server.URL+"/hop/0", result.Final)
+	assert.Equal(t, []string{"127.0.0.1"}, result.Shorteners, "shortener hosts match regardless of port")
+
+	result, err = resolver.Resolve(ctx, server.URL+"/nohead")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/hop/0", result.Final, "servers rejecting HEAD are asked with GET")
+
+	result, err = resolver.Resolve(ctx, server.URL+"/mailto")
+	require.NoError(t, err)
+	assert.Equal(t, server.URL+"/mailto", result.Final, "only http(s) redirects are followed")
+
+	resolver.MaxHops = 2
+	_, err = resolver.Resolve(ctx, server.URL+"/hop/3")
+	assert.ErrorIs(t, err, ErrTooManyHops)
+}
+
+func TestNewResolverRefusesPrivateAddresses(t *testing.T) {
+	server := chainServer(t)
+	_, err := NewResolver(3, nil).Resolve(context.Background(), server.URL+"/hop/0")
+	assert.ErrorIs(t, err, errBlockedAddress)
+}
+
+func TestNewResolverBypassesProxies(t *testing.T) {
+	transport := NewResolver(3, nil).Client.Transport.(*http.Transport)
+	assert.Nil(t, transport.Proxy, "a proxy would hide the resolved address from the dial check")
+}
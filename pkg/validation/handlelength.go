@@ -0,0 +1,27 @@
+package validation
+
+import (
+	"fmt"
+
+	"riid.me/pkg/config"
+)
+
+// HandleLengthLimits returns the shortest and longest custom handles allowed,
+// HANDLE_MIN_LEN and HANDLE_MAX_LEN, or the defaults when they are not loaded.
+func HandleLengthLimits() (minLength, maxLength int) {
+	minLength, maxLength = config.GlobalAppConfig.HandleMinLength, config.GlobalAppConfig.HandleMaxLength
+	if minLength < 1 || maxLength < minLength {
+		return config.DefaultHandleMinLength, config.DefaultHandleMaxLength
+	}
+	return minLength, maxLength
+}
+
+// validateHandleLength rejects handles outside HandleLengthLimits. Unlike the other
+// rules it always applies, so ValidateHandle checks it before any validator.
+func validateHandleLength(handle string) error {
+	minLength, maxLength := HandleLengthLimits()
+	if len(handle) < minLength || len(handle) > maxLength {
+		return fmt.Errorf("Custom handle must be between %d and %d characters.", minLength, maxLength)
+	}
+	return nil
+}
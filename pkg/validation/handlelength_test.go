@@ -0,0 +1,22 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"riid.me/pkg/config"
+)
+
+func TestValidateHandleLength(t *testing.T) {
+	original := config.GlobalAppConfig
+	defer func() { config.GlobalAppConfig = original }()
+
+	config.GlobalAppConfig.HandleMinLength, config.GlobalAppConfig.HandleMaxLength = 0, 0
+	assert.EqualError(t, ValidateHandle("ab"), "Custom handle must be between 3 and 30 characters.", "defaults when not loaded")
+	assert.NoError(t, ValidateHandle("abc"))
+
+	config.GlobalAppConfig.HandleMinLength, config.GlobalAppConfig.HandleMaxLength = 5, 8
+	assert.EqualError(t, ValidateHandle("abcd"), "Custom handle must be between 5 and 8 characters.")
+	assert.EqualError(t, ValidateHandle("abcdefghi"), "Custom handle must be between 5 and 8 characters.")
+	assert.NoError(t, ValidateHandle("abcde"))
+}
@@ -0,0 +1,38 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultHandlePattern is the HANDLE_PATTERN custom handles must match unless
+// configured otherwise: letters, digits, '_' and '-', nothing that needs escaping in
+// a URL path.
+const DefaultHandlePattern = `[a-zA-Z0-9_-]+`
+
+// HandlePattern is a HandleValidator requiring custom handles to fully match a
+// regular expression.
+type HandlePattern struct {
+	Pattern string
+	re      *regexp.Regexp
+}
+
+// NewHandlePattern compiles pattern, which a whole handle must match.
+func NewHandlePattern(pattern string) (*HandlePattern, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid handle pattern %q: %w", pattern, err)
+	}
+	return &HandlePattern{Pattern: pattern, re: re}, nil
+}
+
+// ValidateHandle implements HandleValidator.
+func (p *HandlePattern) ValidateHandle(handle string) error {
+	if p.re.MatchString(handle) {
+		return nil
+	}
+	if p.Pattern == DefaultHandlePattern {
+		return fmt.Errorf("Custom handle '%s' may only contain letters, digits, '_' and '-'.", handle)
+	}
+	return fmt.Errorf("Custom handle '%s' must match the pattern %s.", handle, p.Pattern)
+}
@@ -0,0 +1,26 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePattern(t *testing.T) {
+	pattern, err := NewHandlePattern(DefaultHandlePattern)
+	require.NoError(t, err)
+	assert.NoError(t, pattern.ValidateHandle("My_link-2"))
+	for _, handle := range []string{"a/b", "a b", "a?b", "a#b", "a%20b", "naïve", "a.b"} {
+		assert.Error(t, pattern.ValidateHandle(handle), handle)
+	}
+	assert.EqualError(t, pattern.ValidateHandle("a b"), "Custom handle 'a b' may only contain letters, digits, '_' and '-'.")
+
+	pattern, err = NewHandlePattern(`[a-z]+|[0-9]+`)
+	require.NoError(t, err)
+	assert.NoError(t, pattern.ValidateHandle("abc"))
+	assert.EqualError(t, pattern.ValidateHandle("abc123"), "Custom handle 'abc123' must match the pattern [a-z]+|[0-9]+.")
+
+	_, err = NewHandlePattern(`[a-z`)
+	assert.Error(t, err)
+}
@@ -0,0 +1,50 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultReservedHandles are handles that are never available, besides the paths of
+// registered routes: names of pages and files clients expect at the root of a site,
+// and of routes the service might add.
+var DefaultReservedHandles = []string{
+	"api", "admin", "shorten", "login", "logout", "register", "signup", "account",
+	"static", "assets", "health", "status", "metrics", "help", "about", "docs",
+	"favicon.ico", "robots.txt", "sitemap.xml", "humans.txt", "security.txt", "www",
+}
+
+// ReservedHandles is a HandleValidator rejecting handles that shadow routes of the
+// service or are otherwise kept back. Handles are compared case-insensitively.
+type ReservedHandles map[string]bool
+
+// NewReservedHandles returns the handles validator for the given reserved words.
+func NewReservedHandles(words ...[]string) ReservedHandles {
+	reserved := ReservedHandles{}
+	for _, list := range words {
+		for _, word := range list {
+			if word = strings.ToLower(strings.Trim(strings.TrimSpace(word), "/")); word != "" {
+				reserved[word] = true
+			}
+		}
+	}
+	return reserved
+}
+
+// RoutePrefix returns the first path segment of a route's path template, e.g. "api"
+// for "/api/links/{shortcode}", or "" if it is empty or a variable.
+func RoutePrefix(template string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(template, "/"), "/")
+	if segment == "" || strings.ContainsAny(segment, "{}") {
+		return ""
+	}
+	return segment
+}
+
+// ValidateHandle implements HandleValidator.
+func (r ReservedHandles) ValidateHandle(handle string) error {
+	if r[strings.ToLower(handle)] {
+		return fmt.Errorf("Custom handle '%s' is reserved.", handle)
+	}
+	return nil
+}
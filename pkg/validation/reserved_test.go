@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedHandles(t *testing.T) {
+	reserved := NewReservedHandles(DefaultReservedHandles, []string{" Promo ", "/feed.xml", ""})
+	assert.EqualError(t, reserved.ValidateHandle("health"), "Custom handle 'health' is reserved.")
+	assert.Error(t, reserved.ValidateHandle("API"))
+	assert.Error(t, reserved.ValidateHandle("promo"))
+	assert.Error(t, reserved.ValidateHandle("feed.xml"))
+	assert.NoError(t, reserved.ValidateHandle("my-link"))
+	assert.NoError(t, reserved.ValidateHandle("apis"))
+}
+
+func TestIsReserved(t *testing.T) {
+	mu.Lock()
+	original := handleValidators
+	handleValidators = nil
+	mu.Unlock()
+	defer func() {
+		mu.Lock()
+		handleValidators = original
+		mu.Unlock()
+	}()
+
+	assert.False(t, IsReserved("api"), "nothing is reserved before registration")
+	RegisterHandleValidator(HandleValidatorFunc(func(string) error { return errors.New("any rule") }))
+	RegisterHandleValidator(NewReservedHandles(DefaultReservedHandles))
+	assert.True(t, IsReserved("Health"))
+	assert.False(t, IsReserved("x7Kq"), "other handle rules do not apply")
+}
+
+func TestRoutePrefix(t *testing.T) {
+	assert.Equal(t, "api", RoutePrefix("/api/links/{shortcode}"))
+	assert.Equal(t, "feed.xml", RoutePrefix("/feed.xml"))
+	assert.Equal(t, "test-route", RoutePrefix("/test-route"))
+	assert.Empty(t, RoutePrefix("/"))
+	assert.Empty(t, RoutePrefix("/{shortcode}"))
+	assert.Empty(t, RoutePrefix("/{shortcode}+"))
+}
@@ -0,0 +1,254 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The policy DSL is a semicolon-separated list of rules, each a directive followed
+// by an optional argument, e.g.
+//
+//	HANDLE_POLICY="min_len 4; pattern [a-z0-9-]+; deny_prefix admin"
+//	DESTINATION_POLICY="require_https; allow_host *.example.com; deny_host evil.example.com; deny_tld zip"
+//
+// Handle directives:
+//
+//	min_len N       handle must be at least N characters
+//	max_len N       handle must be at most N characters
+//	pattern RE      handle must fully match the regular expression RE
+//	deny RE         handle must not contain a match of RE
+//	deny_prefix P   handle must not start with P
+//
+// Destination directives:
+//
+//	require_https     destination must use the https scheme
+//	allow_host H      destination host must match one of the allow_host entries
+//	deny_host H       destination host must not match H
+//	deny RE           full destination URL must not contain a match of RE
+//	deny_tld T        destination host must not end in the top-level domain T (e.g. zip, mov)
+//	deny_credentials  destination must not embed a user name or password (user:pass@host)
+//
+// Host patterns match the host exactly, or any subdomain when prefixed with "*.".
+
+var errInvalidDestination = errors.New("Destination must be a valid absolute URL.")
+
+// HandleRules is a HandleValidator built from the policy DSL.
+type HandleRules struct {
+	MinLen       int
+	MaxLen       int
+	Pattern      *regexp.Regexp
+	Deny         []*regexp.Regexp
+	DenyPrefixes []string
+}
+
+// DestinationRules is a DestinationValidator built from the policy DSL.
+type DestinationRules struct {
+	RequireHTTPS    bool
+	AllowHosts      []string
+	DenyHosts       []string
+	Deny            []*regexp.Regexp
+	DenyTLDs        []string
+	DenyCredentials bool
+}
+
+// splitRules breaks a policy string into (directive, argument) pairs, skipping empty rules.
+func splitRules(policy string) [][2]string {
+	var rules [][2]string
+	for _, rule := range strings.Split(policy, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		directive, arg, _ := strings.Cut(rule, " ")
+		rules = append(rules, [2]string{strings.ToLower(directive), strings.TrimSpace(arg)})
+	}
+	return rules
+}
+
+// ParseHandleRules parses a handle policy. It returns nil rules for an empty policy.
+func ParseHandleRules(policy string) (*HandleRules, error) {
+	rules := splitRules(policy)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	hr := &HandleRules{}
+	for _, rule := range rules {
+		directive, arg := rule[0], rule[1]
+		switch directive {
+		case "min_len", "max_len":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("handle policy: %s expects a non-negative integer, got %q", directive, arg)
+			}
+			if directive == "min_len" {
+				hr.MinLen = n
+			} else {
+				hr.MaxLen = n
+			}
+		case "pattern":
+			re, err := regexp.Compile("^(?:" + arg + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("handle policy: invalid pattern %q: %w", arg, err)
+			}
+			hr.Pattern = re
+		case "deny":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("handle policy: invalid deny expression %q: %w", arg, err)
+			}
+			hr.Deny = append(hr.Deny, re)
+		case "deny_prefix":
+			if arg == "" {
+				return nil, errors.New("handle policy: deny_prefix expects a prefix")
+			}
+			hr.DenyPrefixes = append(hr.DenyPrefixes, arg)
+		default:
+			return nil, fmt.Errorf("handle policy: unknown directive %q", directive)
+		}
+	}
+	return hr, nil
+}
+
+// ValidateHandle implements HandleValidator.
+func (hr *HandleRules) ValidateHandle(handle string) error {
+	length := len([]rune(handle))
+	if hr.MinLen > 0 && length < hr.MinLen {
+		return fmt.Errorf("Custom handle must be at least %d characters.", hr.MinLen)
+	}
+	if hr.MaxLen > 0 && length > hr.MaxLen {
+		return fmt.Errorf("Custom handle must be at most %d characters.", hr.MaxLen)
+	}
+	if hr.Pattern != nil && !hr.Pattern.MatchString(handle) {
+		return errors.New("Custom handle does not match the allowed naming pattern.")
+	}
+	for _, re := range hr.Deny {
+		if re.MatchString(handle) {
+			return errors.New("Custom handle is not allowed by the naming policy.")
+		}
+	}
+	for _, prefix := range hr.DenyPrefixes {
+		if strings.HasPrefix(handle, prefix) {
+			return fmt.Errorf("Custom handles may not start with '%s'.", prefix)
+		}
+	}
+	return nil
+}
+
+// ParseDestinationRules parses a destination policy. It returns nil rules for an empty policy.
+func ParseDestinationRules(policy string) (*DestinationRules, error) {
+	rules := splitRules(policy)
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	dr := &DestinationRules{}
+	for _, rule := range rules {
+		directive, arg := rule[0], rule[1]
+		switch directive {
+		case "require_https":
+			dr.RequireHTTPS = true
+		case "allow_host", "deny_host":
+			if arg == "" {
+				return nil, fmt.Errorf("destination policy: %s expects a host", directive)
+			}
+			if directive == "allow_host" {
+				dr.AllowHosts = append(dr.AllowHosts, strings.ToLower(arg))
+			} else {
+				dr.DenyHosts = append(dr.DenyHosts, strings.ToLower(arg))
+			}
+		case "deny":
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("destination policy: invalid deny expression %q: %w", arg, err)
+			}
+			dr.Deny = append(dr.Deny, re)
+		case "deny_tld":
+			tld := strings.ToLower(strings.TrimPrefix(arg, "."))
+			if tld == "" || strings.Contains(tld, ".") {
+				return nil, fmt.Errorf("destination policy: deny_tld expects a top-level domain, got %q", arg)
+			}
+			dr.DenyTLDs = append(dr.DenyTLDs, tld)
+		case "deny_credentials":
+			dr.DenyCredentials = true
+		default:
+			return nil, fmt.Errorf("destination policy: unknown directive %q", directive)
+		}
+	}
+	return dr, nil
+}
+
+// ValidateDestination implements DestinationValidator.
+func (dr *DestinationRules) ValidateDestination(destination *url.URL) error {
+	if dr.RequireHTTPS && destination.Scheme != "https" {
+		return errors.New("Destination must use HTTPS.")
+	}
+
+	host := strings.ToLower(destination.Hostname())
+	if len(dr.AllowHosts) > 0 && !hostMatchesAny(host, dr.AllowHosts) {
+		return fmt.Errorf("Destination host '%s' is not allowed.", host)
+	}
+	if hostMatchesAny(host, dr.DenyHosts) {
+		return fmt.Errorf("Destination host '%s' is not allowed.", host)
+	}
+	if dr.DenyCredentials && destination.User != nil {
+		return errors.New("Destination URLs may not contain credentials.")
+	}
+	tld := strings.TrimSuffix(host, ".")
+	tld = tld[strings.LastIndex(tld, ".")+1:]
+	for _, denied := range dr.DenyTLDs {
+		if tld == denied {
+			return fmt.Errorf("Destinations in the .%s top-level domain are not allowed.", tld)
+		}
+	}
+
+	full := destination.String()
+	for _, re := range dr.Deny {
+		if re.MatchString(full) {
+			return errors.New("Destination URL is not allowed by the deployment policy.")
+		}
+	}
+	return nil
+}
+
+// DomainRules returns the destination rules of the DOMAIN_ALLOWLIST and DOMAIN_DENYLIST
+// settings, or nil if both are empty. A domain listed there covers its subdomains too,
+// as if written "*.example.com" in the policy DSL.
+func DomainRules(allow, deny []string) *DestinationRules {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+	dr := &DestinationRules{}
+	for _, domain := range allow {
+		dr.AllowHosts = append(dr.AllowHosts, domainPattern(domain))
+	}
+	for _, domain := range deny {
+		dr.DenyHosts = append(dr.DenyHosts, domainPattern(domain))
+	}
+	return dr
+}
+
+// domainPattern returns the host pattern matching domain and all of its subdomains.
+func domainPattern(domain string) string {
+	domain = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(domain), "*"), ".")
+	return "*." + strings.TrimSuffix(domain, ".")
+}
+
+// hostMatchesAny reports whether host matches any of the host patterns.
+// A pattern of the form "*.example.com" matches example.com and all of its subdomains.
+func hostMatchesAny(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+// Package validation provides the plugin point for per-deployment link policies.
+//
+// Deployments can enforce org-specific naming and destination rules either by
+// registering their own HandleValidator / DestinationValidator implementations
+// (compiled in, typically from an init function) or by describing simple rules
+// in configuration using the policy DSL understood by ParseHandleRules and
+// ParseDestinationRules.
+package validation
+
+import (
+	"net/url"
+	"sync"
+
+	"riid.me/pkg/config"
+	customlogger "riid.me/pkg/logger"
+)
+
+// HandleValidator validates a custom handle requested for a new short link.
+// A non-nil error rejects the handle; its message is returned to the client.
+type HandleValidator interface {
+	ValidateHandle(handle string) error
+}
+
+// DestinationValidator validates the destination URL of a new short link.
+// A non-nil error rejects the destination; its message is returned to the client.
+type DestinationValidator interface {
+	ValidateDestination(destination *url.URL) error
+}
+
+// HandleValidatorFunc adapts an ordinary function to the HandleValidator interface.
+type HandleValidatorFunc func(handle string) error
+
+// ValidateHandle calls f(handle).
+func (f HandleValidatorFunc) ValidateHandle(handle string) error { return f(handle) }
+
+// DestinationValidatorFunc adapts an ordinary function to the DestinationValidator interface.
+type DestinationValidatorFunc func(destination *url.URL) error
+
+// ValidateDestination calls f(destination).
+func (f DestinationValidatorFunc) ValidateDestination(destination *url.URL) error {
+	return f(destination)
+}
+
+var (
+	mu                    sync.RWMutex
+	handleValidators      []HandleValidator
+	destinationValidators []DestinationValidator
+)
+
+// RegisterHandleValidator adds a validator that every custom handle must pass.
+// Validators run in registration order and the first error wins.
+func RegisterHandleValidator(v HandleValidator) {
+	mu.Lock()
+	defer mu.Unlock()
+	handleValidators = append(handleValidators, v)
+}
+
+// RegisterDestinationValidator adds a validator that every destination URL must pass.
+// Validators run in registration order and the first error wins.
+func RegisterDestinationValidator(v DestinationValidator) {
+	mu.Lock()
+	defer mu.Unlock()
+	destinationValidators = append(destinationValidators, v)
+}
+
+// Init parses the handle and destination policies from the application configuration
+// and registers them as validators. It should be called once at application startup.
+func Init(cfg config.AppConfig) error {
+	if cfg.HandlePattern != "" {
+		pattern, err := NewHandlePattern(cfg.HandlePattern)
+		if err != nil {
+			customlogger.Error().Err(err).Msg("Invalid HANDLE_PATTERN configuration")
+			return err
+		}
+		RegisterHandleValidator(pattern)
+	}
+
+	handleRules, err := ParseHandleRules(cfg.HandlePolicy)
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Invalid HANDLE_POLICY configuration")
+		return err
+	}
+	if handleRules != nil {
+		RegisterHandleValidator(handleRules)
+	}
+
+	destinationRules, err := ParseDestinationRules(cfg.DestinationPolicy)
+	if err != nil {
+		customlogger.Error().Err(err).Msg("Invalid DESTINATION_POLICY configuration")
+		return err
+	}
+	if destinationRules != nil {
+		RegisterDestinationValidator(destinationRules)
+	}
+	if domainRules := DomainRules(cfg.DomainAllowlist, cfg.DomainDenylist); domainRules != nil {
+		RegisterDestinationValidator(domainRules)
+	}
+
+	customlogger.Info().Msg("Link validation policies initialized")
+	return nil
+}
+
+// ValidateHandle checks a custom handle's length against HANDLE_MIN_LEN and
+// HANDLE_MAX_LEN, then runs it through all registered handle validators.
+func ValidateHandle(handle string) error {
+	if err := validateHandleLength(handle); err != nil {
+		return err
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, v := range handleValidators {
+		if err := v.ValidateHandle(handle); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsReserved reports whether handle is one of the reserved words of the registered
+// ReservedHandles, so generated short codes, which skip the other handle rules, can
+// avoid them too.
+func IsReserved(handle string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, v := range handleValidators {
+		if reserved, ok := v.(ReservedHandles); ok && reserved.ValidateHandle(handle) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateDestination parses a destination URL and runs it through all registered
+// destination validators.
+func ValidateDestination(rawURL string) error {
+	destination, err := url.Parse(rawURL)
+	if err != nil || destination.Host == "" {
+		return errInvalidDestination
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, v := range destinationValidators {
+		if err := v.ValidateDestination(destination); err != nil {
+			return err
+		}
+	}
+	return nil
+}